@@ -2,13 +2,16 @@ package fs
 
 import (
 	"io"
+	"syscall"
 	"time"
 
 	"github.com/tiglabs/baudstorage/fuse"
 	"github.com/tiglabs/baudstorage/fuse/fs"
 	"golang.org/x/net/context"
 
+	"github.com/tiglabs/baudstorage/proto"
 	"github.com/tiglabs/baudstorage/sdk/data/stream"
+	"github.com/tiglabs/baudstorage/util"
 	"github.com/tiglabs/baudstorage/util/log"
 )
 
@@ -20,16 +23,17 @@ type File struct {
 
 //functions that File needs to implement
 var (
-	_ fs.Node           = (*File)(nil)
-	_ fs.Handle         = (*File)(nil)
-	_ fs.NodeForgetter  = (*File)(nil)
-	_ fs.NodeOpener     = (*File)(nil)
-	_ fs.HandleReleaser = (*File)(nil)
-	_ fs.HandleReader   = (*File)(nil)
-	_ fs.HandleWriter   = (*File)(nil)
-	_ fs.HandleFlusher  = (*File)(nil)
-	_ fs.NodeFsyncer    = (*File)(nil)
-	_ fs.NodeSetattrer  = (*File)(nil)
+	_ fs.Node             = (*File)(nil)
+	_ fs.Handle           = (*File)(nil)
+	_ fs.NodeForgetter    = (*File)(nil)
+	_ fs.NodeOpener       = (*File)(nil)
+	_ fs.HandleReleaser   = (*File)(nil)
+	_ fs.HandleReader     = (*File)(nil)
+	_ fs.HandleWriter     = (*File)(nil)
+	_ fs.HandleFlusher    = (*File)(nil)
+	_ fs.HandleFallocater = (*File)(nil)
+	_ fs.NodeFsyncer      = (*File)(nil)
+	_ fs.NodeSetattrer    = (*File)(nil)
 
 	//TODO:HandleReadAller
 )
@@ -136,6 +140,17 @@ func (f *File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadR
 		log.LogErrorf("Read error: ino(%v) request size(%v) read size(%v)", f.inode.ino, req.Size, size)
 		return fuse.ERANGE
 	}
+	if size < req.Size {
+		// the range beyond the last key up to the size of the inode is a hole, it reads as zeros
+		if fileSize := f.cachedSize(); uint64(req.Offset)+uint64(size) < fileSize {
+			fill := util.Min(req.Size, int(fileSize-uint64(req.Offset)))
+			hole := resp.Data[fuse.OutHeaderSize+size : fuse.OutHeaderSize+fill]
+			for i := range hole {
+				hole[i] = 0
+			}
+			size = fill
+		}
+	}
 	if size > 0 {
 		resp.Data = resp.Data[:size+fuse.OutHeaderSize]
 	}
@@ -145,14 +160,16 @@ func (f *File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadR
 	return nil
 }
 
+// cachedSize returns the size of the inode in the inode cache, or the size it is opened with.
+func (f *File) cachedSize() uint64 {
+	if inode := f.super.ic.Get(f.inode.ino); inode != nil {
+		return inode.size
+	}
+	return f.inode.size
+}
+
 func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) (err error) {
 	reqlen := len(req.Data)
-	if uint64(req.Offset) > f.inode.size && reqlen == 1 {
-		// Workaround: the fuse package is probably doing truncate size up
-		// if we reach here, which is not supported yet. So Just return.
-		log.LogDebugf("Write: ino(%v) offset(%v) len(%v) flags(%v) fileflags(%v)", f.inode.ino, req.Offset, reqlen, req.Flags, req.FileFlags)
-		return nil
-	}
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -205,6 +222,42 @@ func (f *File) Flush(ctx context.Context, req *fuse.FlushRequest) (err error) {
 	return nil
 }
 
+func (f *File) Fallocate(ctx context.Context, req *fuse.FallocateRequest) (err error) {
+	log.LogDebugf("Fallocate: ino(%v) HandleID(%v) offset(%v) length(%v) mode(%v)", f.inode.ino, req.Handle, req.Offset, req.Length, req.Mode)
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.LogWarn("Recovered from Fallocate: ", r)
+			err = fuse.EIO
+			return
+		}
+		// Invalidate inode cache
+		f.super.ic.Delete(f.inode.ino)
+	}()
+
+	if !f.super.mw.SupportFeature(proto.FeatureFallocate) {
+		return fuse.Errno(syscall.EOPNOTSUPP)
+	}
+	// a hole is punched in the size of the file only, as the fallocate system call requires.
+	// no extent space is reserved for a preallocation, so it is refused and the callers fall
+	// back to writing the range
+	mode := req.Mode
+	if mode != proto.FallocPunchHole|proto.FallocKeepSize {
+		return fuse.Errno(syscall.EOPNOTSUPP)
+	}
+
+	start := time.Now()
+	err = f.super.ec.Fallocate(f.inode.ino, mode, int(req.Offset), int(req.Length))
+	if err != nil {
+		log.LogErrorf("Fallocate: ino(%v) offset(%v) length(%v) mode(%v) err(%v)", f.inode.ino, req.Offset, req.Length, mode, err)
+		return fuse.EIO
+	}
+
+	elapsed := time.Since(start)
+	log.LogDebugf("PERF: Fallocate ino(%v) (%v)ns", f.inode.ino, elapsed.Nanoseconds())
+	return nil
+}
+
 func (f *File) Fsync(ctx context.Context, req *fuse.FsyncRequest) (err error) {
 	log.LogDebugf("Fsync: ino(%v) HandleID(%v)", f.inode.ino, req.Handle)
 
@@ -26,7 +26,7 @@ var (
 	_ fs.FSStatfser = (*Super)(nil)
 )
 
-func NewSuper(volname, master string) (s *Super, err error) {
+func NewSuper(volname, master string, staleRead bool) (s *Super, err error) {
 	s = new(Super)
 	s.mw, err = meta.NewMetaWrapper(volname, master)
 	if err != nil {
@@ -34,11 +34,14 @@ func NewSuper(volname, master string) (s *Super, err error) {
 		return nil, err
 	}
 
-	s.ec, err = stream.NewExtentClient(volname, master, s.mw.AppendExtentKey, s.mw.GetExtents)
+	s.ec, err = stream.NewExtentClient(volname, master, s.mw.AppendExtentKey, s.mw.GetExtents, s.mw.Fallocate)
 	if err != nil {
 		log.LogErrorf("NewExtentClient failed! %v", err.Error())
 		return nil, err
 	}
+	s.mw.SetStaleRead(staleRead)
+	s.ec.SetStaleRead(staleRead)
+	s.ec.SetSupportFeature(s.mw.SupportFeature)
 
 	s.volname = volname
 	s.cluster = s.mw.Cluster()
@@ -23,6 +23,7 @@ import (
 	bdfs "github.com/tiglabs/baudstorage/client/fs"
 	"github.com/tiglabs/baudstorage/util/config"
 	"github.com/tiglabs/baudstorage/util/log"
+	"github.com/tiglabs/baudstorage/util/metrics"
 	"github.com/tiglabs/baudstorage/util/ump"
 )
 
@@ -44,9 +45,13 @@ var (
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
-	ump.InitUmp(UmpModuleName)
 	flag.Parse()
 	cfg := config.LoadConfigFile(*configFile)
+	if err := ump.ConfigSink(cfg); err != nil {
+		fmt.Println("Config ump sink failed: ", err)
+		return
+	}
+	ump.InitUmp(UmpModuleName)
 	if err := Mount(cfg); err != nil {
 		fmt.Println("Mount failed: ", err)
 	}
@@ -62,6 +67,7 @@ func Mount(cfg *config.Config) error {
 	logpath := cfg.GetString("logpath")
 	loglvl := cfg.GetString("loglvl")
 	profport := cfg.GetString("profport")
+	staleRead := cfg.GetBool("staleread")
 	c, err := fuse.Mount(
 		mnt,
 		fuse.AllowOther(),
@@ -82,11 +88,12 @@ func Mount(cfg *config.Config) error {
 		return err
 	}
 
-	super, err := bdfs.NewSuper(volname, master)
+	super, err := bdfs.NewSuper(volname, master, staleRead)
 	if err != nil {
 		return err
 	}
 
+	metrics.RegisterHandler()
 	go func() {
 		fmt.Println(http.ListenAndServe(":"+profport, nil))
 	}()
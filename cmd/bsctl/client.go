@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	MaxRedirectTimes = 3
+	RequestTimeout   = 60 * time.Second
+)
+
+// MasterClient sends requests to the masters and follows the leader redirects.
+type MasterClient struct {
+	masters []string
+	client  *http.Client
+}
+
+func NewMasterClient(masters []string) *MasterClient {
+	return &MasterClient{masters: masters, client: &http.Client{Timeout: RequestTimeout}}
+}
+
+// Request tries every master until one answers, a follower answering 403 is
+// followed to the leader in its body.
+func (mc *MasterClient) Request(path string, params url.Values) (body []byte, err error) {
+	for _, addr := range mc.masters {
+		if body, err = mc.requestLeader(addr, path, params); err == nil {
+			return
+		}
+		if _, ok := err.(*StatusError); ok {
+			return
+		}
+	}
+	return
+}
+
+func (mc *MasterClient) requestLeader(addr, path string, params url.Values) (body []byte, err error) {
+	var resp *http.Response
+	for i := 0; i <= MaxRedirectTimes; i++ {
+		reqURL := fmt.Sprintf("http://%v%v", addr, path)
+		if len(params) > 0 {
+			reqURL = reqURL + "?" + params.Encode()
+		}
+		if resp, err = mc.client.Get(reqURL); err != nil {
+			return
+		}
+		body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return
+		case http.StatusForbidden:
+			leader := strings.TrimSpace(string(body))
+			if leader == "" || leader == addr {
+				return nil, fmt.Errorf("master[%v] has no leader", addr)
+			}
+			addr = leader
+		default:
+			return nil, &StatusError{Code: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+		}
+	}
+	return nil, fmt.Errorf("too many redirects,last master[%v]", addr)
+}
+
+// StatusError is returned when the leader rejects the request, other masters are not tried.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status[%v] %v", e.Code, e.Body)
+}
@@ -0,0 +1,153 @@
+package main
+
+// Param is a query parameter of a master endpoint.
+type Param struct {
+	Name     string
+	Usage    string
+	Required bool
+}
+
+// Command maps "bsctl <resource> <action>" to a master endpoint.
+type Command struct {
+	Resource string
+	Action   string
+	Path     string
+	Usage    string
+	Params   []Param
+	// ToFile means the response is binary and written to the file given by -o
+	ToFile bool
+}
+
+var (
+	paraName      = Param{Name: "name", Usage: "volume name", Required: true}
+	paraAddr      = Param{Name: "addr", Usage: "node address, ip:port", Required: true}
+	paraID        = Param{Name: "id", Usage: "partition id", Required: true}
+	paraType      = Param{Name: "type", Usage: "data partition type, extent or tiny", Required: true}
+	paraStale     = Param{Name: "stale", Usage: "true to accept a stale view from a follower"}
+	paraDisk      = Param{Name: "disk", Usage: "disk path on the data node", Required: true}
+	paraRaftID    = Param{Name: "id", Usage: "raft node id", Required: true}
+	paraTaskID    = Param{Name: "id", Usage: "admin task id", Required: true}
+	paraTaskAddr  = Param{Name: "addr", Usage: "only list the tasks of the node"}
+	paraReplicas  = Param{Name: "replicas", Usage: "replica number", Required: true}
+	paraPolicy    = Param{Name: "policy", Usage: "placement policy, rack, zone or crossZone"}
+	paraCount     = Param{Name: "count", Usage: "count of data partitions to create", Required: true}
+	paraStart     = Param{Name: "start", Usage: "start inode of the new meta partition", Required: true}
+	paraLocal     = Param{Name: "local", Usage: "true to migrate the partitions to other disks of the node"}
+	paraEnable    = Param{Name: "enable", Usage: "true or false", Required: true}
+	paraThreshold = Param{Name: "threshold", Usage: "memory usage threshold of meta nodes, 0~1", Required: true}
+	paraMaintain  = Param{Name: "enable", Usage: "false to end the maintenance window, default true"}
+	paraDuration  = Param{Name: "duration", Usage: "maintenance window in seconds, default 3600"}
+	paraReason    = Param{Name: "reason", Usage: "reason of the maintenance"}
+	paraRate      = Param{Name: "rate", Usage: "read bytes per second of each data node, default from master config"}
+	paraFreezeID  = Param{Name: "id", Usage: "freeze the data partition"}
+	paraFreezeOn  = Param{Name: "addr", Usage: "freeze all data partitions on the data node"}
+	paraFreezeVol = Param{Name: "name", Usage: "freeze all data partitions of the volume"}
+	paraStatus    = Param{Name: "status", Usage: "readOnly or unavailable, default readOnly"}
+	paraFreeze    = Param{Name: "enable", Usage: "false to release the freeze, default true"}
+	paraReadIOPS  = Param{Name: "readIops", Usage: "read ops per second on each data node, 0 for no limit"}
+	paraWriteIOPS = Param{Name: "writeIops", Usage: "write ops per second on each data node, 0 for no limit"}
+	paraReadBW    = Param{Name: "readBandwidth", Usage: "read bytes per second on each data node, 0 for no limit"}
+	paraWriteBW   = Param{Name: "writeBandwidth", Usage: "write bytes per second on each data node, 0 for no limit"}
+	paraMetaOPS   = Param{Name: "metaOps", Usage: "metadata ops per second on each meta node, 0 for no limit"}
+	paraDpType    = Param{Name: "type", Usage: "data partition type, extent, tiny or ec", Required: true}
+	paraECData    = Param{Name: "ecData", Usage: "data shards of an ec partition, default 4"}
+	paraECParity  = Param{Name: "ecParity", Usage: "parity shards of an ec partition, default 2"}
+	paraColdDays  = Param{Name: "coldAfterDays", Usage: "days without modification after which a file is cold, 0 to remove the policy", Required: true}
+	paraCodec     = Param{Name: "codec", Usage: "lz4, flate or none to stop compressing", Required: true}
+)
+
+var commands = []*Command{
+	{Resource: "cluster", Action: "info", Path: "/admin/getCluster", Usage: "show cluster view"},
+	{Resource: "cluster", Action: "ip", Path: "/admin/getIp", Usage: "show cluster name and ip of the caller"},
+	{Resource: "cluster", Action: "backup", Path: "/admin/backupMetadata", Usage: "backup master metadata to a file", ToFile: true},
+
+	{Resource: "vol", Action: "create", Path: "/admin/createVol", Usage: "create a volume",
+		Params: []Param{paraName, paraReplicas, paraType, paraPolicy}},
+	{Resource: "vol", Action: "get", Path: "/client/vol", Usage: "show a volume view",
+		Params: []Param{paraName, paraStale}},
+	{Resource: "vol", Action: "stat", Path: "/client/volStat", Usage: "show volume space",
+		Params: []Param{paraName}},
+	{Resource: "vol", Action: "dataPartitions", Path: "/client/dataPartitions", Usage: "list data partitions of a volume",
+		Params: []Param{paraName, paraStale}},
+	{Resource: "vol", Action: "scrub", Path: "/vol/scrub", Usage: "scrub all data partitions of a volume",
+		Params: []Param{paraName, paraRate}},
+	{Resource: "vol", Action: "scrubReport", Path: "/vol/scrubReport", Usage: "show the last scrub report of a volume",
+		Params: []Param{paraName}},
+	{Resource: "vol", Action: "setQos", Path: "/vol/setQos", Usage: "set the qos limits of a volume, omitted limits are kept",
+		Params: []Param{paraName, paraReadIOPS, paraWriteIOPS, paraReadBW, paraWriteBW, paraMetaOPS}},
+	{Resource: "vol", Action: "qos", Path: "/vol/qos", Usage: "show the qos limits of a volume", Params: []Param{paraName}},
+	{Resource: "vol", Action: "setLifecycle", Path: "/vol/setLifecycle",
+		Usage:  "move the files of a volume to its ec data partitions when they are cold",
+		Params: []Param{paraName, paraColdDays}},
+	{Resource: "vol", Action: "lifecycle", Path: "/vol/lifecycle", Usage: "show the lifecycle policy of a volume",
+		Params: []Param{paraName}},
+	{Resource: "vol", Action: "setCompression", Path: "/vol/setCompression",
+		Usage:  "compress the data blocks of a volume written from now on",
+		Params: []Param{paraName, paraCodec}},
+	{Resource: "vol", Action: "compression", Path: "/vol/compression",
+		Usage: "show the codec of a volume and the ratio of its compressed blocks", Params: []Param{paraName}},
+	{Resource: "vol", Action: "rotateKey", Path: "/vol/rotateKey",
+		Usage:  "add a data key to a volume, the first one starts to encrypt the volume", Params: []Param{paraName}},
+	{Resource: "vol", Action: "encryption", Path: "/vol/encryption",
+		Usage: "show the data key versions of a volume", Params: []Param{paraName}},
+
+	{Resource: "dataNode", Action: "add", Path: "/dataNode/add", Usage: "add a data node", Params: []Param{paraAddr}},
+	{Resource: "dataNode", Action: "get", Path: "/dataNode/get", Usage: "show a data node", Params: []Param{paraAddr}},
+	{Resource: "dataNode", Action: "offline", Path: "/dataNode/offline", Usage: "decommission a data node", Params: []Param{paraAddr}},
+	{Resource: "dataNode", Action: "progress", Path: "/dataNode/decommissionProgress", Usage: "show decommission progress of a data node",
+		Params: []Param{paraAddr}},
+	{Resource: "dataNode", Action: "maintenance", Path: "/dataNode/maintenance", Usage: "put a data node into or out of maintenance",
+		Params: []Param{paraAddr, paraMaintain, paraDuration, paraReason}},
+
+	{Resource: "metaNode", Action: "add", Path: "/metaNode/add", Usage: "add a meta node", Params: []Param{paraAddr}},
+	{Resource: "metaNode", Action: "get", Path: "/metaNode/get", Usage: "show a meta node", Params: []Param{paraAddr}},
+	{Resource: "metaNode", Action: "offline", Path: "/metaNode/offline", Usage: "decommission a meta node", Params: []Param{paraAddr}},
+	{Resource: "metaNode", Action: "progress", Path: "/metaNode/decommissionProgress", Usage: "show decommission progress of a meta node",
+		Params: []Param{paraAddr}},
+	{Resource: "metaNode", Action: "maintenance", Path: "/metaNode/maintenance", Usage: "put a meta node into or out of maintenance",
+		Params: []Param{paraAddr, paraMaintain, paraDuration, paraReason}},
+
+	{Resource: "disk", Action: "decommission", Path: "/disk/decommission", Usage: "decommission a disk of a data node",
+		Params: []Param{paraAddr, paraDisk, paraLocal}},
+	{Resource: "disk", Action: "progress", Path: "/disk/decommissionProgress", Usage: "show decommission progress of a disk",
+		Params: []Param{paraAddr, paraDisk}},
+
+	{Resource: "dataPartition", Action: "create", Path: "/dataPartition/create", Usage: "create data partitions",
+		Params: []Param{paraCount, paraName, paraDpType, paraECData, paraECParity}},
+	{Resource: "dataPartition", Action: "get", Path: "/dataPartition/get", Usage: "show a data partition", Params: []Param{paraID}},
+	{Resource: "dataPartition", Action: "load", Path: "/dataPartition/load", Usage: "load a data partition to check crc",
+		Params: []Param{paraID, paraName}},
+	{Resource: "dataPartition", Action: "offline", Path: "/dataPartition/offline", Usage: "move a replica of a data partition off the node",
+		Params: []Param{paraID, paraAddr, paraName}},
+	{Resource: "dataPartition", Action: "freeze", Path: "/dataPartition/freeze", Usage: "set data partitions read only or unavailable, one of id, addr and name",
+		Params: []Param{paraFreezeID, paraFreezeOn, paraFreezeVol, paraStatus, paraFreeze}},
+
+	{Resource: "metaPartition", Action: "create", Path: "/metaPartition/create", Usage: "split the last meta partition of a volume",
+		Params: []Param{paraName, paraStart}},
+	{Resource: "metaPartition", Action: "get", Path: "/client/metaPartition", Usage: "show a meta partition",
+		Params: []Param{paraName, paraID}},
+	{Resource: "metaPartition", Action: "offline", Path: "/metaPartition/offline", Usage: "move a replica of a meta partition off the node",
+		Params: []Param{paraName, paraAddr, paraID}},
+
+	{Resource: "raftNode", Action: "add", Path: "/raftNode/add", Usage: "add a master to the raft group",
+		Params: []Param{paraRaftID, paraAddr}},
+	{Resource: "raftNode", Action: "remove", Path: "/raftNode/remove", Usage: "remove a master from the raft group",
+		Params: []Param{paraRaftID, paraAddr}},
+
+	{Resource: "compact", Action: "get", Path: "/compactStatus/get", Usage: "show whether compaction is enabled"},
+	{Resource: "compact", Action: "set", Path: "/compactStatus/set", Usage: "enable or disable compaction", Params: []Param{paraEnable}},
+	{Resource: "threshold", Action: "set", Path: "/threshold/set", Usage: "set memory threshold of meta nodes", Params: []Param{paraThreshold}},
+
+	{Resource: "task", Action: "list", Path: "/adminTask/list", Usage: "list admin tasks", Params: []Param{paraTaskAddr}},
+	{Resource: "task", Action: "get", Path: "/adminTask/get", Usage: "show an admin task", Params: []Param{paraTaskID}},
+	{Resource: "task", Action: "cancel", Path: "/adminTask/cancel", Usage: "cancel an admin task", Params: []Param{paraTaskID}},
+}
+
+func findCommand(resource, action string) *Command {
+	for _, cmd := range commands {
+		if cmd.Resource == resource && cmd.Action == action {
+			return cmd
+		}
+	}
+	return nil
+}
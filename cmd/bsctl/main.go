@@ -0,0 +1,138 @@
+// bsctl wraps the http endpoints of the master for cluster administration.
+//
+//	bsctl [-master ip:port,ip:port] [-json] <resource> <action> [-param value ...]
+//
+// The exit code is 0 on success, 1 if the request fails and 2 on usage errors.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	ExitOK           = 0
+	ExitRequestError = 1
+	ExitUsageError   = 2
+
+	EnvMasterAddr = "BSCTL_MASTER"
+)
+
+var (
+	masterAddrs = flag.String("master", os.Getenv(EnvMasterAddr), "master addresses separated by comma, default $"+EnvMasterAddr)
+	jsonOutput  = flag.Bool("json", false, "print the response as json")
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	os.Exit(run(flag.Args()))
+}
+
+func run(args []string) int {
+	if len(args) < 2 {
+		usage()
+		return ExitUsageError
+	}
+	cmd := findCommand(args[0], args[1])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %v %v\n", args[0], args[1])
+		usage()
+		return ExitUsageError
+	}
+	params, output, err := parseParams(cmd, args[2:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitUsageError
+	}
+	masters := splitAddrs(*masterAddrs)
+	if len(masters) == 0 {
+		fmt.Fprintln(os.Stderr, "no master address, use -master or $"+EnvMasterAddr)
+		return ExitUsageError
+	}
+	body, err := NewMasterClient(masters).Request(cmd.Path, params)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v %v failed: %v\n", cmd.Resource, cmd.Action, err)
+		return ExitRequestError
+	}
+	if cmd.ToFile {
+		if err = ioutil.WriteFile(output, body, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "write %v failed: %v\n", output, err)
+			return ExitRequestError
+		}
+		fmt.Printf("%v bytes written to %v\n", len(body), output)
+		return ExitOK
+	}
+	if *jsonOutput {
+		printJson(os.Stdout, body)
+	} else {
+		printTable(os.Stdout, body)
+	}
+	return ExitOK
+}
+
+func parseParams(cmd *Command, args []string) (params url.Values, output string, err error) {
+	fs := flag.NewFlagSet(cmd.Resource+" "+cmd.Action, flag.ContinueOnError)
+	values := make(map[string]*string)
+	for _, p := range cmd.Params {
+		values[p.Name] = fs.String(p.Name, "", p.Usage)
+	}
+	var outputFile *string
+	if cmd.ToFile {
+		outputFile = fs.String("o", "", "output file")
+	}
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	params = make(url.Values)
+	for _, p := range cmd.Params {
+		value := *values[p.Name]
+		if value == "" {
+			if p.Required {
+				return nil, "", fmt.Errorf("%v %v: -%v is required", cmd.Resource, cmd.Action, p.Name)
+			}
+			continue
+		}
+		params.Set(p.Name, value)
+	}
+	if cmd.ToFile {
+		if output = *outputFile; output == "" {
+			return nil, "", fmt.Errorf("%v %v: -o is required", cmd.Resource, cmd.Action)
+		}
+	}
+	return
+}
+
+func splitAddrs(s string) (addrs []string) {
+	addrs = make([]string, 0)
+	for _, addr := range strings.Split(s, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: bsctl [-master ip:port,ip:port] [-json] <resource> <action> [-param value ...]")
+	flag.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, cmd := range commands {
+		params := make([]string, 0, len(cmd.Params))
+		for _, p := range cmd.Params {
+			if p.Required {
+				params = append(params, fmt.Sprintf("-%v", p.Name))
+			} else {
+				params = append(params, fmt.Sprintf("[-%v]", p.Name))
+			}
+		}
+		if cmd.ToFile {
+			params = append(params, "-o")
+		}
+		fmt.Fprintf(os.Stderr, "  %-14v %-15v %-30v %v\n", cmd.Resource, cmd.Action, strings.Join(params, " "), cmd.Usage)
+	}
+}
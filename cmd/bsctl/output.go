@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// printJson prints the body indented, or as it is if it is not json.
+func printJson(w io.Writer, body []byte) {
+	var out bytes.Buffer
+	if err := json.Indent(&out, body, "", "  "); err != nil {
+		fmt.Fprintln(w, strings.TrimSpace(string(body)))
+		return
+	}
+	fmt.Fprintln(w, out.String())
+}
+
+// printTable prints a json array of objects as a table with a column per key,
+// a json object as a table of key and value, and anything else as it is.
+func printTable(w io.Writer, body []byte) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		fmt.Fprintln(w, strings.TrimSpace(string(body)))
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+	switch v := value.(type) {
+	case []interface{}:
+		printRows(tw, v)
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			if rows, ok := v[key].([]interface{}); ok && len(rows) > 0 && isObject(rows[0]) {
+				fmt.Fprintf(tw, "%v:\n", key)
+				printRows(tw, rows)
+				continue
+			}
+			fmt.Fprintf(tw, "%v\t%v\n", key, formatCell(v[key]))
+		}
+	default:
+		fmt.Fprintln(tw, formatCell(v))
+	}
+}
+
+func printRows(tw io.Writer, rows []interface{}) {
+	columns := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		if obj, ok := row.(map[string]interface{}); ok {
+			for _, key := range sortedKeys(obj) {
+				if !seen[key] {
+					seen[key] = true
+					columns = append(columns, key)
+				}
+			}
+		}
+	}
+	if len(columns) == 0 {
+		for _, row := range rows {
+			fmt.Fprintln(tw, formatCell(row))
+		}
+		return
+	}
+	fmt.Fprintln(tw, strings.Join(columns, "\t"))
+	for _, row := range rows {
+		obj, _ := row.(map[string]interface{})
+		cells := make([]string, 0, len(columns))
+		for _, column := range columns {
+			cells = append(cells, formatCell(obj[column]))
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+}
+
+func isObject(v interface{}) bool {
+	_, ok := v.(map[string]interface{})
+	return ok
+}
+
+func sortedKeys(obj map[string]interface{}) (keys []string) {
+	keys = make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+func formatCell(v interface{}) string {
+	switch cell := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		return cell
+	case json.Number:
+		return cell.String()
+	case bool:
+		return fmt.Sprint(cell)
+	default:
+		data, err := json.Marshal(cell)
+		if err != nil {
+			return fmt.Sprint(cell)
+		}
+		return string(data)
+	}
+}
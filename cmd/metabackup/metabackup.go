@@ -0,0 +1,82 @@
+// metabackup downloads a consistent snapshot of the master store to a file.
+// To restore, set "restoreFile" in the config of a master with empty walDir and storeDir,
+// the master boots a single-member raft group from the file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	BackupMetadataPath = "/admin/backupMetadata"
+	MaxRedirectTimes   = 3
+)
+
+var (
+	masterAddr = flag.String("master", "", "master address, ip:port")
+	output     = flag.String("o", "", "backup file path")
+)
+
+func main() {
+	flag.Parse()
+	if *masterAddr == "" || *output == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := backup(*masterAddr, *output); err != nil {
+		fmt.Println("backup failed:", err)
+		os.Exit(1)
+	}
+	fmt.Println("backup success:", *output)
+}
+
+func backup(addr, path string) (err error) {
+	var resp *http.Response
+	for i := 0; ; i++ {
+		if resp, err = http.Get(fmt.Sprintf("http://%v%v", addr, BackupMetadataPath)); err != nil {
+			return
+		}
+		if resp.StatusCode != http.StatusForbidden {
+			break
+		}
+		// a follower answers with the address of the leader
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		leader := strings.TrimSpace(string(body))
+		if leader == "" {
+			return fmt.Errorf("no leader")
+		}
+		if i+1 >= MaxRedirectTimes {
+			return fmt.Errorf("master[%v] redirected to leader[%v] after %v redirects", addr, leader, MaxRedirectTimes)
+		}
+		addr = leader
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("status[%v] body[%v]", resp.StatusCode, string(body))
+	}
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return
+	}
+	f.Close()
+	return os.Rename(tmpPath, path)
+}
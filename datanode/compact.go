@@ -0,0 +1,312 @@
+package datanode
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tiglabs/baudstorage/storage"
+	"github.com/tiglabs/baudstorage/util/log"
+	"github.com/tiglabs/baudstorage/util/qos"
+)
+
+const (
+	CompactTriggerGarbage = "garbage"
+	CompactTriggerNotify  = "notify"
+)
+
+// CompactRecord is a finished compaction of a tiny chunk.
+type CompactRecord struct {
+	PartitionId  uint32
+	ChunkId      int
+	Trigger      string
+	GarbageRatio uint64
+	GarbageBytes uint64
+	Released     uint64
+	StartTime    int64
+	CostMs       int64
+	Err          string
+}
+
+// compactHistory keeps the last DefaultCompactHistorySize compactions of a disk.
+type compactHistory struct {
+	records []*CompactRecord
+	sync.Mutex
+}
+
+func (h *compactHistory) add(record *CompactRecord) {
+	h.Lock()
+	defer h.Unlock()
+	h.records = append(h.records, record)
+	if len(h.records) > DefaultCompactHistorySize {
+		h.records = h.records[len(h.records)-DefaultCompactHistorySize:]
+	}
+}
+
+// list returns the records, the latest first.
+func (h *compactHistory) list() (records []*CompactRecord) {
+	h.Lock()
+	defer h.Unlock()
+	records = make([]*CompactRecord, 0, len(h.records))
+	for i := len(h.records) - 1; i >= 0; i-- {
+		records = append(records, h.records[i])
+	}
+	return
+}
+
+// setCompactEnabled follows the compaction switch of the cluster pushed by the master.
+func (s *DataNode) setCompactEnabled(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	if atomic.SwapInt32(&s.compactEnabled, value) != value {
+		log.LogInfof("action[setCompactEnabled] compaction enabled[%v]", enabled)
+	}
+}
+
+func (s *DataNode) isCompactEnabled() bool {
+	return atomic.LoadInt32(&s.compactEnabled) == 1
+}
+
+// startDiskCompactors starts a compaction scheduler on every disk.
+func (s *DataNode) startDiskCompactors() {
+	for _, d := range s.space.GetDisks() {
+		d.compactSlots = make(chan bool, s.diskCompactConcurrency)
+		if s.diskCompactBytesPerSec > 0 {
+			d.compactLimit = qos.NewTokenBucket(uint64(s.diskCompactBytesPerSec))
+		}
+		go s.diskCompactScheduler(d)
+	}
+}
+
+// diskCompactScheduler runs the compactions notified by OpNotifyCompact, and looks for the chunks
+// full of garbage every DefaultCompactCheckIntervalSec while the compaction of the cluster is enabled.
+func (s *DataNode) diskCompactScheduler(d *Disk) {
+	ticker := time.NewTicker(DefaultCompactCheckIntervalSec * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case t := <-d.compactCh:
+			if err := s.launchCompact(d, t); err != nil {
+				log.LogWarnf("action[diskCompactScheduler] task[%v] err[%v]", t.toString(), err)
+			}
+		case <-ticker.C:
+			if !s.isCompactEnabled() {
+				continue
+			}
+			for _, t := range s.pickCompactTasks(d) {
+				if err := s.launchCompact(d, t); err == ErrDiskCompactBusy {
+					break
+				}
+			}
+		case <-s.stopC:
+			return
+		}
+	}
+}
+
+// pickCompactTasks returns the chunks on the disk whose garbage reaches compactGarbageRatio,
+// the chunk of the highest garbage ratio first. The garbage of a chunk is punched first,
+// the chunk is copied only if the garbage left between the live objects is still too much.
+func (s *DataNode) pickCompactTasks(d *Disk) (tasks []*CompactTask) {
+	tasks = make([]*CompactTask, 0)
+	for _, partitionId := range d.DataPartitionList() {
+		dp := s.space.GetPartition(partitionId)
+		if dp == nil {
+			continue
+		}
+		store := dp.GetTinyStore()
+		for _, stat := range store.ChunkStats() {
+			if d.isCompacting(partitionId, stat.ChunkId) || !store.IsReadyToCompact(stat.ChunkId, s.compactGarbageRatio) {
+				continue
+			}
+			if !stat.Full {
+				if !s.punchChunk(partitionId, store, stat.ChunkId) ||
+					!store.IsReadyToCompact(stat.ChunkId, s.compactGarbageRatio) {
+					continue
+				}
+				if stat, _ = store.ChunkStat(stat.ChunkId); stat == nil {
+					continue
+				}
+			}
+			tasks = append(tasks, &CompactTask{
+				partitionId: partitionId,
+				chunkId:     stat.ChunkId,
+				isLeader:    dp.IsLeader(),
+				trigger:     CompactTriggerGarbage,
+				stat:        stat,
+			})
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].stat.GarbageRatio != tasks[j].stat.GarbageRatio {
+			return tasks[i].stat.GarbageRatio > tasks[j].stat.GarbageRatio
+		}
+		return tasks[i].stat.GarbageBytes > tasks[j].stat.GarbageBytes
+	})
+	return
+}
+
+// punchChunk punches the garbage of the chunk, it returns false if the chunk is busy.
+func (s *DataNode) punchChunk(partitionId uint32, store *storage.TinyStore, chunkId int) bool {
+	before, err := store.ChunkStat(chunkId)
+	if err != nil {
+		return false
+	}
+	if _, err = store.PunchGarbage(chunkId); err != nil {
+		log.LogWarnf("action[punchChunk] partition[%v] chunk[%v] err[%v]", partitionId, chunkId, err)
+		return false
+	}
+	if after, err := store.ChunkStat(chunkId); err == nil && after.ReclaimedBytes > before.ReclaimedBytes {
+		log.LogInfof("action[punchChunk] partition[%v] chunk[%v] reclaimed[%v] garbage ratio[%v]",
+			partitionId, chunkId, after.ReclaimedBytes-before.ReclaimedBytes, after.GarbageRatio)
+	}
+	return true
+}
+
+func compactKey(partitionId uint32, chunkId int) string {
+	return fmt.Sprintf("%v_%v", partitionId, chunkId)
+}
+
+func (d *Disk) isCompacting(partitionId uint32, chunkId int) bool {
+	_, ok := d.compacting.Load(compactKey(partitionId, chunkId))
+	return ok
+}
+
+// launchCompact runs the compaction in the background if the disk has a free slot.
+func (s *DataNode) launchCompact(d *Disk, t *CompactTask) (err error) {
+	dp := s.space.GetPartition(t.partitionId)
+	if dp == nil {
+		return ErrPartitionNotExist
+	}
+	select {
+	case d.compactSlots <- true:
+	default:
+		return ErrDiskCompactBusy
+	}
+	key := compactKey(t.partitionId, t.chunkId)
+	if _, running := d.compacting.LoadOrStore(key, t); running {
+		<-d.compactSlots
+		return ErrChunkCompacting
+	}
+	go func() {
+		defer func() {
+			d.compacting.Delete(key)
+			<-d.compactSlots
+		}()
+		d.compactChunk(dp, t)
+	}()
+	return
+}
+
+func (d *Disk) compactChunk(dp DataPartition, t *CompactTask) {
+	record := &CompactRecord{
+		PartitionId: t.partitionId,
+		ChunkId:     t.chunkId,
+		Trigger:     t.trigger,
+		StartTime:   time.Now().Unix(),
+	}
+	if t.stat != nil {
+		record.GarbageRatio = t.stat.GarbageRatio
+		record.GarbageBytes = t.stat.GarbageBytes
+	}
+	start := time.Now()
+	err, released := dp.GetTinyStore().DoCompactWork(t.chunkId, d.compactThrottle())
+	record.CostMs = int64(time.Since(start) / time.Millisecond)
+	record.Released = released
+	if err != nil {
+		record.Err = err.Error()
+		log.LogErrorf("action[compactChunk] task[%v] compact error[%v]", t.toString(), err.Error())
+	} else {
+		log.LogInfof("action[compactChunk] task[%v] compact success Release [%v] cost[%vms]",
+			t.toString(), released, record.CostMs)
+	}
+	d.compactHistory.add(record)
+}
+
+// compactThrottle shares the bandwidth limit of the disk between its compactions.
+func (d *Disk) compactThrottle() storage.CompactThrottle {
+	if d.compactLimit == nil {
+		return nil
+	}
+	return func(size int) {
+		for !d.compactLimit.Allow(uint64(size)) {
+			time.Sleep(DefaultCompactThrottleSleepMs * time.Millisecond)
+		}
+	}
+}
+
+func (s *DataNode) apiGetChunks(w http.ResponseWriter, r *http.Request) {
+	const (
+		paramPartitionId = "id"
+	)
+	var (
+		partitionId uint64
+		err         error
+	)
+	if err = r.ParseForm(); err != nil {
+		err = fmt.Errorf("parse form fail: %v", err)
+		s.buildApiFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if partitionId, err = strconv.ParseUint(r.FormValue(paramPartitionId), 10, 64); err != nil {
+		err = fmt.Errorf("parse param %v fail: %v", paramPartitionId, err)
+		s.buildApiFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	partition := s.space.GetPartition(uint32(partitionId))
+	if partition == nil {
+		s.buildApiFailureResp(w, http.StatusNotFound, "partition not exist")
+		return
+	}
+	result := &struct {
+		ID     uint32
+		Chunks []*storage.ChunkStat
+	}{
+		ID:     partition.ID(),
+		Chunks: partition.GetTinyStore().ChunkStats(),
+	}
+	s.buildApiSuccessResp(w, result)
+}
+
+func (s *DataNode) apiGetCompactions(w http.ResponseWriter, r *http.Request) {
+	type diskCompactions struct {
+		Path    string
+		Running []string
+		History []*CompactRecord
+	}
+	disks := make([]*diskCompactions, 0)
+	for _, d := range s.space.GetDisks() {
+		running := make([]string, 0)
+		d.compacting.Range(func(key, value interface{}) bool {
+			running = append(running, value.(*CompactTask).toString())
+			return true
+		})
+		sort.Strings(running)
+		disks = append(disks, &diskCompactions{
+			Path:    d.Path,
+			Running: running,
+			History: d.compactHistory.list(),
+		})
+	}
+	sort.Slice(disks, func(i, j int) bool { return disks[i].Path < disks[j].Path })
+	result := &struct {
+		Enabled      bool
+		Concurrency  int
+		BytesPerSec  int64
+		GarbageRatio uint64
+		Disks        []*diskCompactions
+	}{
+		Enabled:      s.isCompactEnabled(),
+		Concurrency:  s.diskCompactConcurrency,
+		BytesPerSec:  s.diskCompactBytesPerSec,
+		GarbageRatio: s.compactGarbageRatio,
+		Disks:        disks,
+	}
+	s.buildApiSuccessResp(w, result)
+}
@@ -12,6 +12,21 @@ const (
 	RequestChanSize = 10240
 )
 
+const (
+	DefaultScrubConcurrency     = 1
+	DefaultDiskScrubMBPerSec    = 4
+	DefaultDiskScrubDelaySec    = 10 * 60
+	DefaultDiskScrubIntervalSec = 7 * 24 * 3600
+)
+
+const (
+	DefaultDiskCompactConcurrency  = 1
+	DefaultDiskCompactMBPerSec     = 20
+	DefaultCompactCheckIntervalSec = 60
+	DefaultCompactHistorySize      = 100
+	DefaultCompactThrottleSleepMs  = 10
+)
+
 const (
 	ActionSendToNext                                 = "ActionSendToNext"
 	LocalProcessAddr                                 = "LocalProcess"
@@ -71,6 +86,7 @@ const (
 	LogDelPartition      = "DELV:"
 	LogDelFile           = "DELF:"
 	LogMarkDel           = "MDEL:"
+	LogPunchHole         = "PH:"
 	LogPartitionSnapshot = "Snapshot:"
 	LogGetWm             = "WM:"
 	LogGetAllWm          = "AllWM:"
@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"github.com/juju/errors"
 	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/storage"
 	"github.com/tiglabs/baudstorage/util"
 	"github.com/tiglabs/baudstorage/util/log"
+	"github.com/tiglabs/baudstorage/util/qos"
 	"io/ioutil"
 	"math"
+	"os"
 	"path"
 	"regexp"
 	"strconv"
@@ -22,6 +25,8 @@ type CompactTask struct {
 	partitionId uint32
 	chunkId     int
 	isLeader    bool
+	trigger     string
+	stat        *storage.ChunkStat
 }
 
 func (t *CompactTask) toString() (m string) {
@@ -29,11 +34,15 @@ func (t *CompactTask) toString() (m string) {
 }
 
 const (
-	CompactThreadNum = 4
+	CompactChanSize = 4
+	// DiskDecommissionedFile marks a decommissioned disk, the mark is kept after restart
+	DiskDecommissionedFile = ".decommissioned"
 )
 
 var (
 	ErrDiskCompactChanFull = errors.New("disk compact chan is full")
+	ErrDiskCompactBusy     = errors.New("disk compactions reach the limit")
+	ErrChunkCompacting     = errors.New("chunk is being compacted")
 )
 
 var (
@@ -56,8 +65,16 @@ type Disk struct {
 	Status                          int
 	PartitionNames                  []string
 	RestSize                        uint64
+	Decommissioned                  bool
+	migrating                       bool
 	compactCh                       chan *CompactTask
 	space                           SpaceManager
+	// compactSlots limits the compactions running on the disk at the same time,
+	// compactLimit limits their bandwidth
+	compactSlots   chan bool
+	compactLimit   *qos.TokenBucket
+	compacting     sync.Map
+	compactHistory compactHistory
 }
 
 func NewDisk(path string, restSize uint64, maxErrs int) (d *Disk) {
@@ -68,9 +85,10 @@ func NewDisk(path string, restSize uint64, maxErrs int) (d *Disk) {
 	d.PartitionNames = make([]string, 0)
 	d.RestSize = util.GB * 1
 	d.MaxErrs = 2000
-	d.compactCh = make(chan *CompactTask, CompactThreadNum)
-	for i := 0; i < CompactThreadNum; i++ {
-		go d.compact()
+	d.compactCh = make(chan *CompactTask, CompactChanSize)
+	d.compactSlots = make(chan bool, DefaultDiskCompactConcurrency)
+	if _, err := os.Stat(d.decommissionedMark()); err == nil {
+		d.Decommissioned = true
 	}
 	d.computeUsage()
 	d.computePartitionCnt()
@@ -106,24 +124,6 @@ func (d *Disk) addReadErr() {
 	atomic.AddUint64(&d.ReadErrs, 1)
 }
 
-func (d *Disk) compact() {
-	for {
-		select {
-		case t := <-d.compactCh:
-			dp := d.space.GetPartition(t.partitionId)
-			if dp == nil {
-				continue
-			}
-			err, release := dp.GetTinyStore().DoCompactWork(t.chunkId)
-			if err != nil {
-				log.LogErrorf("action[compact] task[%v] compact error[%v]", t.toString(), err.Error())
-			} else {
-				log.LogInfof("action[compact] task[%v] compact success Release [%v]", t.toString(), release)
-			}
-		}
-	}
-}
-
 func (d *Disk) addWriteErr() {
 	atomic.AddUint64(&d.WriteErrs, 1)
 }
@@ -180,7 +180,7 @@ func (d *Disk) updateSpaceInfo() (err error) {
 	currErrs := d.ReadErrs + d.WriteErrs
 	if currErrs >= uint64(d.MaxErrs) {
 		d.Status = proto.Unavaliable
-	} else if d.Available <= 0 {
+	} else if d.Available <= 0 || d.isDecommissioned() {
 		d.Status = proto.ReadOnly
 	} else {
 		d.Status = proto.ReadWrite
@@ -193,6 +193,67 @@ func (d *Disk) updateSpaceInfo() (err error) {
 	return
 }
 
+// decommission marks the disk as retired, no new partition is placed on it
+// and the partitions on it turn to read only. The mark is persisted on the disk.
+func (d *Disk) decommission() (err error) {
+	if !d.isDecommissioned() {
+		if err = d.persistDecommissioned(); err != nil {
+			return
+		}
+	}
+	d.Lock()
+	d.Decommissioned = true
+	d.Unlock()
+	d.updateSpaceInfo()
+	return
+}
+
+func (d *Disk) persistDecommissioned() (err error) {
+	var f *os.File
+	if f, err = os.OpenFile(d.decommissionedMark(), os.O_CREATE|os.O_WRONLY, 0666); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	var dir *os.File
+	if dir, err = os.Open(d.Path); err != nil {
+		return
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+func (d *Disk) decommissionedMark() string {
+	return path.Join(d.Path, DiskDecommissionedFile)
+}
+
+func (d *Disk) isDecommissioned() bool {
+	d.Lock()
+	defer d.Unlock()
+	return d.Decommissioned
+}
+
+func (d *Disk) startMigrate() (ok bool) {
+	d.Lock()
+	defer d.Unlock()
+	if d.migrating {
+		return false
+	}
+	d.migrating = true
+	return true
+}
+
+func (d *Disk) finishMigrate() {
+	d.Lock()
+	d.migrating = false
+	d.Unlock()
+}
+
 func (d *Disk) AddDataPartition(dp *dataPartition) {
 	name := dp.String()
 	d.Lock()
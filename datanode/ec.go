@@ -0,0 +1,436 @@
+package datanode
+
+import (
+	"fmt"
+	"hash/crc32"
+	"net"
+	"sync"
+
+	"github.com/juju/errors"
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/storage"
+	"github.com/tiglabs/baudstorage/util"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+const (
+	// ECStripeUnitSize is the size of one shard in a stripe, a stripe holds
+	// ECStripeUnitSize*k bytes of the extent.
+	ECStripeUnitSize = util.ReadBlockSize
+	// ECMaxReadSize limits the data of an OpECRead, which is replied in a single packet.
+	ECMaxReadSize = util.BlockSize
+)
+
+var (
+	ErrNotECPartition = errors.New("not an ec data partition")
+	ErrECLayout       = errors.New("ec partition hosts do not match its stripe layout")
+	ErrECStripeOffset = errors.New("ec write is not aligned to a stripe")
+	ErrECShardGap     = errors.New("ec shard write leaves a gap, the shard must be repaired first")
+)
+
+/*
+An ec partition stores every extent as stripes of k data shards and m parity shards,
+shard i of all the stripes is kept in the extent of the same id on replicaHosts[i].
+Stripe s holds the bytes [s*k*unit, (s+1)*k*unit) of the extent, its data shard j holds
+the bytes [j*unit, (j+1)*unit) of the stripe and lies at s*unit in the shard extent.
+The last stripe may be short, all its shards then hold min(len, unit) bytes.
+A stripe is written when k+1 of its shards are, a shard which missed it refuses the later
+writes, which would leave a gap, until ecRepair has rebuilt it.
+*/
+
+// Handle OpECWrite packet, the stripe is encoded here and its shards are written to all hosts.
+func (s *DataNode) handleECWrite(pkg *Packet) {
+	var err error
+	defer func() {
+		if err != nil {
+			err = errors.Annotatef(err, "Request[%v] ECWrite Error", pkg.GetUniqueLogId())
+			pkg.PackErrorBody(LogWrite, err.Error())
+		} else {
+			pkg.PackOkReply()
+		}
+	}()
+	dp := pkg.DataPartition.(*dataPartition)
+	if crc32.ChecksumIEEE(pkg.Data[:pkg.Size]) != pkg.Crc {
+		err = storage.ErrPkgCrcMismatch
+		return
+	}
+	err = dp.ecWrite(pkg.FileID, pkg.Offset, pkg.Data[:pkg.Size])
+	s.addDiskErrs(pkg.PartitionID, err, WriteFlag)
+}
+
+// Handle OpECRead packet, the missing data shards are reconstructed from the others.
+func (s *DataNode) handleECRead(pkg *Packet) {
+	dp := pkg.DataPartition.(*dataPartition)
+	data, err := dp.ecRead(pkg.FileID, pkg.Offset, int(pkg.Size))
+	if err != nil {
+		err = errors.Annotatef(err, "Request[%v] ECRead Error", pkg.GetUniqueLogId())
+		pkg.PackErrorBody(LogRead, err.Error())
+		return
+	}
+	pkg.Data = data
+	pkg.Size = uint32(len(data))
+	pkg.Crc = crc32.ChecksumIEEE(data)
+	pkg.PackOkReadReply()
+}
+
+// Handle OpECWriteShard packet sent by the host which encoded the stripe.
+func (s *DataNode) handleECWriteShard(pkg *Packet) {
+	var err error
+	defer func() {
+		if err != nil {
+			err = errors.Annotatef(err, "Request[%v] ECWriteShard Error", pkg.GetUniqueLogId())
+			pkg.PackErrorBody(LogWrite, err.Error())
+		} else {
+			pkg.PackOkReply()
+		}
+	}()
+	dp := pkg.DataPartition.(*dataPartition)
+	if dp.partitionType != proto.ECPartition {
+		err = ErrNotECPartition
+		return
+	}
+	if crc32.ChecksumIEEE(pkg.Data[:pkg.Size]) != pkg.Crc {
+		err = storage.ErrPkgCrcMismatch
+		return
+	}
+	err = dp.writeLocalShard(pkg.FileID, pkg.Offset, pkg.Data[:pkg.Size], pkg.Crc)
+	s.addDiskErrs(pkg.PartitionID, err, WriteFlag)
+}
+
+// Handle OpECReadShard packet, the reply is shorter than requested at the end of the shard.
+func (s *DataNode) handleECReadShard(pkg *Packet) {
+	dp := pkg.DataPartition.(*dataPartition)
+	data, err := dp.readLocalShard(pkg.FileID, pkg.Offset, int(pkg.Size))
+	s.addDiskErrs(pkg.PartitionID, err, ReadFlag)
+	if err != nil {
+		pkg.PackErrorBody(LogRead, err.Error())
+		return
+	}
+	pkg.Data = data
+	pkg.Size = uint32(len(data))
+	pkg.Crc = crc32.ChecksumIEEE(data)
+	pkg.PackOkReadReply()
+}
+
+// ecHosts returns the hosts of the shards, they are fetched from the master when
+// the partition has not learnt them since it was loaded.
+func (dp *dataPartition) ecHosts() (hosts []string, err error) {
+	if dp.partitionType != proto.ECPartition {
+		return nil, ErrNotECPartition
+	}
+	hosts = dp.replicaHosts
+	if len(hosts) != dp.ecEncoder.TotalShards() {
+		if err = dp.updateReplicaHosts(); err != nil {
+			return
+		}
+		hosts = dp.replicaHosts
+	}
+	if len(hosts) != dp.ecEncoder.TotalShards() {
+		return nil, errors.Annotatef(ErrECLayout, "partition[%v] hosts[%v] shards[%v]",
+			dp.partitionId, hosts, dp.ecEncoder.TotalShards())
+	}
+	return
+}
+
+// ecShardIndex returns the index of the shard kept by this node, the hosts are
+// matched by ip and port as several datanodes may share an ip.
+func ecShardIndex(hosts []string) int {
+	for index, host := range hosts {
+		if host == LocalServeAddr {
+			return index
+		}
+	}
+	return -1
+}
+
+func (dp *dataPartition) ecWrite(extentId uint64, offset int64, stripe []byte) (err error) {
+	var hosts []string
+	if hosts, err = dp.ecHosts(); err != nil {
+		return
+	}
+	k := dp.ecEncoder.DataShards()
+	if offset%int64(k*ECStripeUnitSize) != 0 {
+		return errors.Annotatef(ErrECStripeOffset, "offset[%v] stripeSize[%v]", offset, k*ECStripeUnitSize)
+	}
+	shards, err := dp.ecEncoder.SplitStripe(stripe, ECStripeUnitSize)
+	if err != nil {
+		return
+	}
+	if err = dp.ecEncoder.Encode(shards); err != nil {
+		return
+	}
+	shardOffset := offset / int64(k)
+	errs := make([]error, len(hosts))
+	var wg sync.WaitGroup
+	for index := range hosts {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			errs[index] = dp.writeShard(hosts, index, extentId, shardOffset, shards[index])
+		}(index)
+	}
+	wg.Wait()
+	written := 0
+	for index, err := range errs {
+		if err == nil {
+			written++
+			continue
+		}
+		log.LogWarnf("action[ecWrite] partition[%v] extent[%v] offset[%v] write shard[%v] to host[%v] err[%v].",
+			dp.partitionId, extentId, offset, index, hosts[index], err)
+	}
+	if written <= k {
+		return fmt.Errorf("extent[%v] offset[%v] only %v of %v shards written, %v needed",
+			extentId, offset, written, len(hosts), k+1)
+	}
+	return
+}
+
+// writeLocalShard writes a shard of this node, the write must start within the shard
+// so that a shard which missed some stripes is not left with a hole.
+func (dp *dataPartition) writeLocalShard(extentId uint64, offset int64, data []byte, crc uint32) (err error) {
+	var info *storage.FileInfo
+	if info, err = dp.extentStore.GetWatermark(extentId); err != nil {
+		return
+	}
+	if uint64(offset) > info.Size {
+		return errors.Annotatef(ErrECShardGap, "extent[%v] offset[%v] shard size[%v]", extentId, offset, info.Size)
+	}
+	return dp.extentStore.Write(extentId, offset, int64(len(data)), data, crc)
+}
+
+func (dp *dataPartition) writeShard(hosts []string, index int, extentId uint64, offset int64, data []byte) (err error) {
+	crc := crc32.ChecksumIEEE(data)
+	if index == ecShardIndex(hosts) {
+		return dp.writeLocalShard(extentId, offset, data, crc)
+	}
+	var conn *net.TCPConn
+	if conn, err = gConnPool.Get(hosts[index]); err != nil {
+		return
+	}
+	request := NewECWriteShardPacket(dp.partitionId, extentId, offset, data, crc)
+	if err = request.WriteToConn(conn); err != nil {
+		gConnPool.Put(conn, true)
+		return
+	}
+	if err = request.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+		gConnPool.Put(conn, true)
+		return
+	}
+	gConnPool.Put(conn, false)
+	if request.ResultCode != proto.OpOk {
+		err = fmt.Errorf("reply[%v] %v", request.GetResultMesg(), string(request.Data[:request.Size]))
+	}
+	return
+}
+
+func (dp *dataPartition) readLocalShard(extentId uint64, offset int64, size int) (data []byte, err error) {
+	if dp.partitionType != proto.ECPartition {
+		return nil, ErrNotECPartition
+	}
+	var info *storage.FileInfo
+	if info, err = dp.extentStore.GetWatermark(extentId); err != nil {
+		return
+	}
+	if uint64(offset) >= info.Size {
+		return nil, fmt.Errorf("extent[%v] offset[%v] beyond shard size[%v]", extentId, offset, info.Size)
+	}
+	if remain := int(info.Size - uint64(offset)); remain < size {
+		size = remain
+	}
+	data = make([]byte, size)
+	_, err = dp.extentStore.Read(extentId, offset, int64(size), data)
+	return
+}
+
+func (dp *dataPartition) readShard(hosts []string, index int, extentId uint64, offset int64, size int) (data []byte, err error) {
+	if index == ecShardIndex(hosts) {
+		return dp.readLocalShard(extentId, offset, size)
+	}
+	var conn *net.TCPConn
+	if conn, err = gConnPool.Get(hosts[index]); err != nil {
+		return
+	}
+	request := NewECReadShardPacket(dp.partitionId, extentId, offset, size)
+	if err = request.WriteToConn(conn); err != nil {
+		gConnPool.Put(conn, true)
+		return
+	}
+	if err = request.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+		gConnPool.Put(conn, true)
+		return
+	}
+	gConnPool.Put(conn, false)
+	if request.ResultCode != proto.OpOk {
+		return nil, fmt.Errorf("reply[%v] %v", request.GetResultMesg(), string(request.Data[:request.Size]))
+	}
+	if request.Crc != crc32.ChecksumIEEE(request.Data[:request.Size]) {
+		return nil, storage.ErrPkgCrcMismatch
+	}
+	return request.Data[:request.Size], nil
+}
+
+// readStripeShards reads the given shards of a stripe in parallel, a shard which
+// cannot be read is left nil.
+func (dp *dataPartition) readStripeShards(hosts []string, indexes []int, extentId uint64, stripeNo int64,
+	shards [][]byte) {
+	var wg sync.WaitGroup
+	for _, index := range indexes {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			data, err := dp.readShard(hosts, index, extentId, stripeNo*ECStripeUnitSize, ECStripeUnitSize)
+			if err != nil {
+				log.LogWarnf("action[readStripeShards] partition[%v] extent[%v] stripe[%v] shard[%v] host[%v] err[%v].",
+					dp.partitionId, extentId, stripeNo, index, hosts[index], err)
+				return
+			}
+			shards[index] = data
+		}(index)
+	}
+	wg.Wait()
+}
+
+// reconstructStripe reads the shards of a stripe which are still nil, except the
+// skipped one, and rebuilds the missing shards from them.
+func (dp *dataPartition) reconstructStripe(hosts []string, extentId uint64, stripeNo int64, shards [][]byte,
+	skip int) (err error) {
+	others := make([]int, 0, len(shards))
+	for index, shard := range shards {
+		if shard == nil && index != skip {
+			others = append(others, index)
+		}
+	}
+	dp.readStripeShards(hosts, others, extentId, stripeNo, shards)
+	return dp.ecEncoder.Reconstruct(shards)
+}
+
+func (dp *dataPartition) ecRead(extentId uint64, offset int64, size int) (data []byte, err error) {
+	var hosts []string
+	if hosts, err = dp.ecHosts(); err != nil {
+		return
+	}
+	if size <= 0 || size > ECMaxReadSize {
+		return nil, fmt.Errorf("illegal ec read size[%v]", size)
+	}
+	k := dp.ecEncoder.DataShards()
+	stripeSize := int64(k * ECStripeUnitSize)
+	data = make([]byte, size)
+	for done := 0; done < size; {
+		pos := offset + int64(done)
+		stripeNo := pos / stripeSize
+		start := int(pos % stripeSize)
+		end := start + size - done
+		if end > int(stripeSize) {
+			end = int(stripeSize)
+		}
+		needed := make([]int, 0, k)
+		for index := start / ECStripeUnitSize; index <= (end-1)/ECStripeUnitSize; index++ {
+			needed = append(needed, index)
+		}
+		shards := make([][]byte, len(hosts))
+		dp.readStripeShards(hosts, needed, extentId, stripeNo, shards)
+		for _, index := range needed {
+			if shards[index] == nil {
+				log.LogWarnf("action[ecRead] partition[%v] extent[%v] stripe[%v] degraded read.",
+					dp.partitionId, extentId, stripeNo)
+				if err = dp.reconstructStripe(hosts, extentId, stripeNo, shards, -1); err != nil {
+					return nil, errors.Annotatef(err, "extent[%v] stripe[%v] reconstruct", extentId, stripeNo)
+				}
+				break
+			}
+		}
+		for _, index := range needed {
+			shardStart := util.Max(start, index*ECStripeUnitSize) - index*ECStripeUnitSize
+			shardEnd := util.Min(end, (index+1)*ECStripeUnitSize) - index*ECStripeUnitSize
+			if shardEnd > len(shards[index]) {
+				return nil, fmt.Errorf("extent[%v] offset[%v] size[%v] beyond the data", extentId, offset, size)
+			}
+			done += copy(data[done:], shards[index][shardStart:shardEnd])
+		}
+	}
+	return
+}
+
+/*
+ecRepair rebuilds the shards this node lacks, it is run by every host of an ec partition.
+The stable extents of the other hosts tell the size the shards should have, the missing
+part of a local shard is reconstructed stripe by stripe from k shards of the other hosts.
+*/
+func (dp *dataPartition) ecRepair() {
+	hosts, err := dp.ecHosts()
+	if err != nil {
+		log.LogErrorf("action[ecRepair] partition[%v] err[%v].", dp.partitionId, err)
+		return
+	}
+	local := ecShardIndex(hosts)
+	if local < 0 {
+		return
+	}
+	targets := make(map[int]*storage.FileInfo)
+	for index, host := range hosts {
+		if index == local {
+			continue
+		}
+		fileMetas, err := dp.getRemoteFileMetas(host)
+		if err != nil {
+			log.LogWarnf("action[ecRepair] partition[%v] err[%v].", dp.partitionId, err)
+			continue
+		}
+		for fileId, file := range fileMetas.files {
+			if fileId <= storage.TinyChunkCount {
+				continue
+			}
+			if target, ok := targets[fileId]; !ok || target.Size < file.Size {
+				targets[fileId] = file
+			}
+		}
+	}
+	deleted := make(map[uint64]bool)
+	for _, extentId := range dp.extentStore.GetDelObjects() {
+		deleted[extentId] = true
+	}
+	for fileId, target := range targets {
+		extentId := uint64(fileId)
+		if deleted[extentId] {
+			continue
+		}
+		start := startRepair(RepairTypeEC)
+		err := dp.rebuildShard(hosts, local, extentId, target)
+		observeRepair(RepairTypeEC, start, err)
+		if err != nil {
+			log.LogErrorf("action[ecRepair] partition[%v] extent[%v] err[%v].", dp.partitionId, extentId, err)
+		}
+	}
+}
+
+func (dp *dataPartition) rebuildShard(hosts []string, local int, extentId uint64, target *storage.FileInfo) (err error) {
+	store := dp.extentStore
+	if !store.IsExistExtent(extentId) {
+		if err = store.Create(extentId, target.Inode, false); err != nil {
+			return
+		}
+	}
+	var info *storage.FileInfo
+	if info, err = store.GetWatermark(extentId); err != nil {
+		return
+	}
+	if info.Deleted || info.Size >= target.Size {
+		return
+	}
+	log.LogInfof("action[rebuildShard] partition[%v] extent[%v] shard[%v] rebuild from size[%v] to [%v].",
+		dp.partitionId, extentId, local, info.Size, target.Size)
+	for stripeNo := int64(info.Size / ECStripeUnitSize); stripeNo*ECStripeUnitSize < int64(target.Size); stripeNo++ {
+		// the local shard is short or stale, it is not read
+		shards := make([][]byte, len(hosts))
+		if err = dp.reconstructStripe(hosts, extentId, stripeNo, shards, local); err != nil {
+			return errors.Annotatef(err, "stripe[%v]", stripeNo)
+		}
+		shard := shards[local]
+		if err = store.Write(extentId, stripeNo*ECStripeUnitSize, int64(len(shard)), shard,
+			crc32.ChecksumIEEE(shard)); err != nil {
+			return
+		}
+	}
+	return
+}
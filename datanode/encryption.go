@@ -0,0 +1,140 @@
+package datanode
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/encrypt"
+	"hash/crc32"
+)
+
+/*
+The data keys of an encrypted vol are pushed by the master wrapped by the master key, a data
+node unwraps them by the master key loaded from its key file and keeps them wrapped in the meta
+file of the partition. The extents, the chunks and the repair stream of a partition are encrypted
+by subkeys of their own context, so the repair stream between the replicas ships ciphertext.
+
+Arg of a sealed repair reply:
+
+	+---------+-------+
+	| version | nonce |
+	+---------+-------+
+	|    4    |   8   |
+	+---------+-------+
+*/
+const repairSealSize = 12
+
+// masterKey is the master key of the cluster, nil if the node is not configured with one.
+var masterKey []byte
+
+// localFeatures hides the encryption feature if the node can not unwrap the data keys.
+func localFeatures() uint64 {
+	if masterKey == nil {
+		return proto.LocalFeatures &^ proto.FeatureEncryption
+	}
+	return proto.LocalFeatures
+}
+
+func unwrapKeys(keys []*proto.VolKey) (plain map[uint32][]byte, err error) {
+	if len(keys) == 0 {
+		return
+	}
+	if masterKey == nil {
+		return nil, fmt.Errorf("no master key to unwrap the data keys")
+	}
+	plain = make(map[uint32][]byte, len(keys))
+	for _, k := range keys {
+		if plain[k.Version], err = encrypt.UnwrapKey(masterKey, k.Key); err != nil {
+			return nil, fmt.Errorf("unwrap data key version[%v]: %v", k.Version, err)
+		}
+	}
+	return
+}
+
+// applyKeys hands the data keys of the vol to the stores of the partition.
+func (dp *dataPartition) applyKeys(keys []*proto.VolKey) (err error) {
+	var (
+		plain                            map[uint32][]byte
+		extentKeys, tinyKeys, repairKeys *encrypt.Keys
+	)
+	if plain, err = unwrapKeys(keys); err != nil {
+		return
+	}
+	if len(plain) != 0 {
+		if extentKeys, err = encrypt.NewKeys(plain, fmt.Sprintf("extent/%v", dp.partitionId)); err != nil {
+			return
+		}
+		if tinyKeys, err = encrypt.NewKeys(plain, fmt.Sprintf("tiny/%v", dp.partitionId)); err != nil {
+			return
+		}
+		if repairKeys, err = encrypt.NewKeys(plain, fmt.Sprintf("repair/%v", dp.partitionId)); err != nil {
+			return
+		}
+	}
+	dp.extentStore.SetKeys(extentKeys)
+	dp.tinyStore.SetKeys(tinyKeys)
+	dp.repairKeys = repairKeys
+	dp.keys = keys
+	return
+}
+
+// SetKeys keeps the data keys pushed by the master in the meta file and starts to encrypt
+// the new data by the last version. The keys of a vol are only added, so a partition
+// holding as many keys is up to date.
+func (dp *dataPartition) SetKeys(keys []*proto.VolKey) (err error) {
+	dp.keyLock.Lock()
+	defer dp.keyLock.Unlock()
+	if len(keys) <= len(dp.keys) {
+		return
+	}
+	if _, err = unwrapKeys(keys); err != nil {
+		return
+	}
+	if err = dp.updateMeta(func(meta *dataPartitionMeta) { meta.Keys = keys }); err != nil {
+		return
+	}
+	return dp.applyKeys(keys)
+}
+
+// SealRepairData encrypts the data of a repair reply in place, the crc is the one of the ciphertext.
+func (dp *dataPartition) SealRepairData(p *Packet) (err error) {
+	dp.keyLock.RLock()
+	defer dp.keyLock.RUnlock()
+	version := dp.repairKeys.Current()
+	if version == 0 {
+		return
+	}
+	var c *encrypt.Cipher
+	if c, err = dp.repairKeys.Cipher(version); err != nil {
+		return
+	}
+	arg := make([]byte, repairSealSize)
+	binary.BigEndian.PutUint32(arg[0:4], version)
+	if _, err = rand.Read(arg[4:repairSealSize]); err != nil {
+		return
+	}
+	data := p.Data[:p.Size]
+	c.XORKeyStreamAt(data, data, binary.BigEndian.Uint64(arg[4:repairSealSize]), 0, 0)
+	p.Crc = crc32.ChecksumIEEE(data)
+	p.Arg = arg
+	p.Arglen = repairSealSize
+	return
+}
+
+// OpenRepairData decrypts the data of a sealed repair reply in place, the crc becomes the one of the plain data.
+func (dp *dataPartition) OpenRepairData(p *Packet) (err error) {
+	if p.Arglen < repairSealSize {
+		return
+	}
+	dp.keyLock.RLock()
+	defer dp.keyLock.RUnlock()
+	var c *encrypt.Cipher
+	if c, err = dp.repairKeys.Cipher(binary.BigEndian.Uint32(p.Arg[0:4])); err != nil {
+		return
+	}
+	data := p.Data[:p.Size]
+	c.XORKeyStreamAt(data, data, binary.BigEndian.Uint64(p.Arg[4:repairSealSize]), 0, 0)
+	p.Crc = crc32.ChecksumIEEE(data)
+	return
+}
@@ -0,0 +1,114 @@
+package datanode
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/metrics"
+)
+
+const (
+	MetricsSubsystem = "datanode"
+
+	RepairTypeExtent = "extent"
+	RepairTypeTiny   = "tiny"
+	RepairTypeEC     = "ec"
+
+	OpClassRead  = "read"
+	OpClassWrite = "write"
+)
+
+var (
+	opMetrics = metrics.NewOpMetrics(MetricsSubsystem)
+
+	repairRunning = metrics.NewGaugeVec(MetricsSubsystem, "repair_running",
+		"Count of the files being repaired from the leader.", "type")
+	repairTotal = metrics.NewCounterVec(MetricsSubsystem, "repair_total",
+		"Count of the finished file repairs by result.", "type", "result")
+	repairDuration = metrics.NewHistogramVec(MetricsSubsystem, "repair_duration_seconds",
+		"Latency of the file repairs.", metrics.DefLatencyBuckets, "type")
+)
+
+// observeRepair is deferred by the repair of a file, with the start time of the repair.
+func observeRepair(repairType string, start time.Time, err error) {
+	repairRunning.Add(-1, repairType)
+	result := "success"
+	if err != nil {
+		result = "fail"
+	}
+	repairTotal.Inc(repairType, result)
+	repairDuration.Observe(metrics.Since(start), repairType)
+}
+
+func startRepair(repairType string) time.Time {
+	repairRunning.Add(1, repairType)
+	return time.Now()
+}
+
+// opClass is the class the latency of the op is summed in for the heartbeat, the stream reads,
+// the repairs and the ops between the nodes are left out since they take long by nature.
+func opClass(opcode uint8) string {
+	switch opcode {
+	case proto.OpRead, proto.OpECRead:
+		return OpClassRead
+	case proto.OpWrite, proto.OpRandomWrite, proto.OpECWrite, proto.OpCreateFile, proto.OpMarkDelete, proto.OpPunchHole:
+		return OpClassWrite
+	}
+	return ""
+}
+
+// heartbeatStats summarizes the operations by class since the last heartbeat, the master scores the health of the node with it.
+func heartbeatStats() map[string]proto.NodeStats {
+	stats := make(map[string]proto.NodeStats)
+	for class, s := range opMetrics.TakeStats() {
+		stats[class] = proto.NodeStats{
+			OpCount:      s.Count,
+			ErrCount:     s.Errors,
+			AvgLatencyUs: uint64(s.AvgLatency / time.Microsecond),
+			MaxLatencyUs: uint64(s.MaxLatency / time.Microsecond),
+		}
+	}
+	return stats
+}
+
+func (s *DataNode) registerMetrics() {
+	diskLabels := []string{"disk"}
+	metrics.NewGaugeFunc(MetricsSubsystem, "disk_total_bytes", "Total space of the disk.", diskLabels,
+		func(report func(v float64, labelValues ...string)) {
+			for _, d := range s.space.GetDisks() {
+				report(float64(d.All), d.Path)
+			}
+		})
+	metrics.NewGaugeFunc(MetricsSubsystem, "disk_used_bytes", "Used space of the disk.", diskLabels,
+		func(report func(v float64, labelValues ...string)) {
+			for _, d := range s.space.GetDisks() {
+				report(float64(d.Used), d.Path)
+			}
+		})
+	metrics.NewGaugeFunc(MetricsSubsystem, "disk_available_bytes", "Available space of the disk.", diskLabels,
+		func(report func(v float64, labelValues ...string)) {
+			for _, d := range s.space.GetDisks() {
+				report(float64(d.Available), d.Path)
+			}
+		})
+	metrics.NewGaugeFunc(MetricsSubsystem, "disk_status", "Status of the disk, 1 readonly, 2 readwrite, -1 unavailable.", diskLabels,
+		func(report func(v float64, labelValues ...string)) {
+			for _, d := range s.space.GetDisks() {
+				report(float64(d.Status), d.Path)
+			}
+		})
+	metrics.NewGaugeFunc(MetricsSubsystem, "partitions", "Count of the data partitions by disk, status and role.",
+		[]string{"disk", "status", "leader"},
+		func(report func(v float64, labelValues ...string)) {
+			counts := make(map[[3]string]int)
+			s.space.RangePartitions(func(dp DataPartition) bool {
+				key := [3]string{dp.Disk().Path, strconv.Itoa(dp.Status()), strconv.FormatBool(dp.IsLeader())}
+				counts[key]++
+				return true
+			})
+			for key, count := range counts {
+				report(float64(count), key[0], key[1], key[2])
+			}
+		})
+}
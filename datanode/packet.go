@@ -75,7 +75,8 @@ func NewPacket() (p *Packet) {
 }
 
 func (p *Packet) IsMasterCommand() bool {
-	if p.Opcode == proto.OpDataNodeHeartbeat || p.Opcode == proto.OpLoadDataPartition || p.Opcode == proto.OpCreateDataPartition {
+	if p.Opcode == proto.OpDataNodeHeartbeat || p.Opcode == proto.OpLoadDataPartition || p.Opcode == proto.OpCreateDataPartition ||
+		p.Opcode == proto.OpScrubDataPartition || p.Opcode == proto.OpRepairFile || p.Opcode == proto.OpSetPartitionStatus {
 		return true
 	}
 	return false
@@ -136,6 +137,44 @@ func NewStreamReadPacket(partitionId uint32, extentId, offset, size int) (p *Pac
 	return
 }
 
+// NewExtentRepairReadPacket asks the leader for extent data to repair a replica,
+// the data of an encrypted vol is sealed by the repair key of the partition.
+func NewExtentRepairReadPacket(partitionId uint32, extentId, offset, size int) (p *Packet) {
+	p = NewStreamReadPacket(partitionId, extentId, offset, size)
+	p.Opcode = proto.OpERepairRead
+	return
+}
+
+func NewECWriteShardPacket(partitionId uint32, extentId uint64, offset int64, data []byte, crc uint32) (p *Packet) {
+	p = new(Packet)
+	p.FileID = extentId
+	p.PartitionID = partitionId
+	p.Magic = proto.ProtoMagic
+	p.Offset = offset
+	p.Size = uint32(len(data))
+	p.Data = data
+	p.Crc = crc
+	p.Opcode = proto.OpECWriteShard
+	p.StoreMode = proto.ExtentStoreMode
+	p.ReqID = proto.GetReqID()
+
+	return
+}
+
+func NewECReadShardPacket(partitionId uint32, extentId uint64, offset int64, size int) (p *Packet) {
+	p = new(Packet)
+	p.FileID = extentId
+	p.PartitionID = partitionId
+	p.Magic = proto.ProtoMagic
+	p.Offset = offset
+	p.Size = uint32(size)
+	p.Opcode = proto.OpECReadShard
+	p.StoreMode = proto.ExtentStoreMode
+	p.ReqID = proto.GetReqID()
+
+	return
+}
+
 func NewStreamChunkRepairReadPacket(partitionId uint32, chunkId int) (p *Packet) {
 	p = new(Packet)
 	p.FileID = uint64(chunkId)
@@ -168,7 +207,7 @@ func (p *Packet) IsTailNode() (ok bool) {
 }
 
 func (p *Packet) IsWriteOperation() bool {
-	return p.Opcode == proto.OpWrite
+	return p.Opcode == proto.OpWrite || p.Opcode == proto.OpRandomWrite
 }
 
 func (p *Packet) IsCreateFileOperation() bool {
@@ -184,7 +223,7 @@ func (p *Packet) IsExtentWritePacket() bool {
 }
 
 func (p *Packet) IsReadOperation() bool {
-	return p.Opcode == proto.OpStreamRead || p.Opcode == proto.OpRead
+	return p.Opcode == proto.OpStreamRead || p.Opcode == proto.OpRead || p.Opcode == proto.OpERepairRead
 }
 
 func (p *Packet) IsMarkDeleteReq() bool {
@@ -288,7 +327,7 @@ func (p *Packet) ReadFromConnFromCli(c net.Conn, deadlineTime time.Duration) (er
 		return
 	}
 	size := p.Size
-	if (p.Opcode == proto.OpRead || p.Opcode == proto.OpStreamRead) && p.ResultCode == proto.OpInitResultCode {
+	if p.IsReadRequest() && p.ResultCode == proto.OpInitResultCode {
 		size = 0
 	}
 	return p.ReadFull(c, int(size))
@@ -8,6 +8,8 @@ import (
 	"github.com/tiglabs/baudstorage/master"
 	"github.com/tiglabs/baudstorage/proto"
 	"github.com/tiglabs/baudstorage/storage"
+	"github.com/tiglabs/baudstorage/util/ec"
+	"github.com/tiglabs/baudstorage/util/encrypt"
 	"github.com/tiglabs/baudstorage/util/log"
 	"io/ioutil"
 	"math"
@@ -16,6 +18,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -35,6 +38,7 @@ var (
 
 type DataPartition interface {
 	ID() uint32
+	VolumeID() string
 	Path() string
 	IsLeader() bool
 	ReplicaHosts() []string
@@ -60,6 +64,9 @@ type DataPartition interface {
 
 	FlushDelete() error
 
+	SealRepairData(p *Packet) error
+	OpenRepairData(p *Packet) error
+
 	Stop()
 }
 
@@ -69,6 +76,11 @@ type dataPartitionMeta struct {
 	PartitionId   uint32
 	PartitionSize int
 	CreateTime    string
+	ManualStatus  int
+	ECDataNum     int
+	ECParityNum   int
+	// Keys are the data keys of the vol wrapped by the master key
+	Keys []*proto.VolKey
 }
 
 func (meta *dataPartitionMeta) Validate() (err error) {
@@ -79,6 +91,10 @@ func (meta *dataPartitionMeta) Validate() (err error) {
 		err = errors.New("illegal data partition meta")
 		return
 	}
+	if meta.PartitionType == proto.ECPartition && (meta.ECDataNum <= 0 || meta.ECParityNum <= 0) {
+		err = errors.New("illegal ec layout of data partition meta")
+		return
+	}
 	return
 }
 
@@ -92,16 +108,27 @@ type dataPartition struct {
 	isLeader        bool
 	path            string
 	used            int
+	manualStatus    int32
+	partitionType   string
+	ecEncoder       *ec.Encoder
 	extentStore     *storage.ExtentStore
 	tinyStore       *storage.TinyStore
 	stopC           chan bool
+	keys            []*proto.VolKey
+	repairKeys      *encrypt.Keys
+	keyLock         sync.RWMutex
+	metaLock        sync.Mutex
 }
 
-func CreateDataPartition(volId string, partitionId uint32, disk *Disk, size int, partitionType string) (dp DataPartition, err error) {
+func CreateDataPartition(volId string, partitionId uint32, disk *Disk, size int, partitionType string,
+	ecDataNum, ecParityNum int) (dp DataPartition, err error) {
 
 	if dp, err = newDataPartition(volId, partitionId, disk, size); err != nil {
 		return
 	}
+	if err = dp.(*dataPartition).setPartitionType(partitionType, ecDataNum, ecParityNum); err != nil {
+		return
+	}
 	// Store meta information into meta file.
 	var (
 		metaFile *os.File
@@ -118,6 +145,8 @@ func CreateDataPartition(volId string, partitionId uint32, disk *Disk, size int,
 		PartitionType: partitionType,
 		PartitionSize: size,
 		CreateTime:    time.Now().Format(TimeLayout),
+		ECDataNum:     ecDataNum,
+		ECParityNum:   ecParityNum,
 	}
 	if metaData, err = json.Marshal(meta); err != nil {
 		return
@@ -145,10 +174,71 @@ func LoadDataPartition(partitionDir string, disk *Disk) (dp DataPartition, err e
 	if err = meta.Validate(); err != nil {
 		return
 	}
-	dp, err = newDataPartition(meta.VolumeId, meta.PartitionId, disk, meta.PartitionSize)
+	if dp, err = newDataPartition(meta.VolumeId, meta.PartitionId, disk, meta.PartitionSize); err != nil {
+		return
+	}
+	partition := dp.(*dataPartition)
+	if err = partition.setPartitionType(meta.PartitionType, meta.ECDataNum, meta.ECParityNum); err != nil {
+		return
+	}
+	if err = partition.applyKeys(meta.Keys); err != nil {
+		return
+	}
+	atomic.StoreInt32(&partition.manualStatus, int32(meta.ManualStatus))
 	return
 }
 
+// setPartitionType keeps the type of the partition, an ec partition holds one shard
+// of every stripe and needs the encoder to rebuild the shards of the others.
+func (dp *dataPartition) setPartitionType(partitionType string, ecDataNum, ecParityNum int) (err error) {
+	dp.partitionType = partitionType
+	if partitionType != proto.ECPartition {
+		return
+	}
+	dp.ecEncoder, err = ec.NewEncoder(ecDataNum, ecParityNum)
+	return
+}
+
+// SetManualStatus freezes the partition to ReadOnly or Unavaliable on request of the master,
+// a zero status releases it. The status is kept in the meta file to survive a restart,
+// and applies at once as Status folds it in.
+func (dp *dataPartition) SetManualStatus(status int) (err error) {
+	switch status {
+	case 0, proto.ReadOnly, proto.Unavaliable:
+	default:
+		return fmt.Errorf("illegal manual status[%v]", status)
+	}
+	if err = dp.updateMeta(func(meta *dataPartitionMeta) { meta.ManualStatus = status }); err != nil {
+		return
+	}
+	atomic.StoreInt32(&dp.manualStatus, int32(status))
+	return
+}
+
+// updateMeta rewrites the meta file of the partition with the change applied.
+func (dp *dataPartition) updateMeta(change func(meta *dataPartitionMeta)) (err error) {
+	var metaFileData []byte
+	dp.metaLock.Lock()
+	defer dp.metaLock.Unlock()
+	metaFilePath := path.Join(dp.Path(), DataPartitionMetaFileName)
+	if metaFileData, err = ioutil.ReadFile(metaFilePath); err != nil {
+		return
+	}
+	meta := &dataPartitionMeta{}
+	if err = json.Unmarshal(metaFileData, meta); err != nil {
+		return
+	}
+	change(meta)
+	if metaFileData, err = json.Marshal(meta); err != nil {
+		return
+	}
+	tmpFilePath := metaFilePath + ".tmp"
+	if err = ioutil.WriteFile(tmpFilePath, metaFileData, 0666); err != nil {
+		return
+	}
+	return os.Rename(tmpFilePath, metaFilePath)
+}
+
 func newDataPartition(volumeId string, partitionId uint32, disk *Disk, size int) (dp DataPartition, err error) {
 	partition := &dataPartition{
 		volumeId:        volumeId,
@@ -178,6 +268,10 @@ func (dp *dataPartition) ID() uint32 {
 	return dp.partitionId
 }
 
+func (dp *dataPartition) VolumeID() string {
+	return dp.volumeId
+}
+
 func (dp *dataPartition) Path() string {
 	return dp.path
 }
@@ -210,7 +304,11 @@ func (dp *dataPartition) Disk() *Disk {
 }
 
 func (dp *dataPartition) Status() int {
-	return dp.partitionStatus
+	status := dp.partitionStatus
+	if manualStatus := int(atomic.LoadInt32(&dp.manualStatus)); manualStatus != 0 && manualStatus < status {
+		status = manualStatus
+	}
+	return status
 }
 
 func (dp *dataPartition) Size() int {
@@ -255,7 +353,8 @@ func (dp *dataPartition) statusUpdate() {
 	if dp.isLeader {
 		dp.tinyStore.MoveChunkToUnavailChan()
 	}
-	dp.partitionStatus = int(math.Min(float64(status), float64(dp.disk.Status)))
+	status = int(math.Min(float64(status), float64(dp.disk.Status)))
+	dp.partitionStatus = status
 }
 
 func (dp *dataPartition) computeUsage() {
@@ -293,6 +392,10 @@ func (dp *dataPartition) LaunchRepair() {
 		log.LogErrorf("action[LaunchRepair] err[%v].", err)
 		return
 	}
+	if dp.partitionType == proto.ECPartition {
+		dp.ecRepair()
+		return
+	}
 	if !dp.isLeader {
 		return
 	}
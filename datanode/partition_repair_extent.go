@@ -15,7 +15,9 @@ import (
 // It receive from leader notifyRepair command extent file repair.
 func (dp *dataPartition) doStreamExtentFixRepair(wg *sync.WaitGroup, remoteExtentInfo *storage.FileInfo) {
 	defer wg.Done()
+	start := startRepair(RepairTypeExtent)
 	err := dp.streamRepairExtent(remoteExtentInfo)
+	observeRepair(RepairTypeExtent, start, err)
 	if err != nil {
 		localExtentInfo, err1 := dp.GetExtentStore().GetWatermark(uint64(remoteExtentInfo.FileId))
 		if err != nil {
@@ -43,8 +45,8 @@ func (dp *dataPartition) streamRepairExtent(remoteExtentInfo *storage.FileInfo)
 	// Get need fix size for this extent file
 	needFixSize := remoteExtentInfo.Size - localExtentInfo.Size
 
-	// Create streamRead packet, it offset is local extentInfoSize, size is needFixSize
-	request := NewStreamReadPacket(dp.ID(), remoteExtentInfo.FileId, int(localExtentInfo.Size), int(needFixSize))
+	// Create repairRead packet, it offset is local extentInfoSize, size is needFixSize
+	request := NewExtentRepairReadPacket(dp.ID(), remoteExtentInfo.FileId, int(localExtentInfo.Size), int(needFixSize))
 	var conn *net.TCPConn
 
 	// Get a connection to leader host
@@ -54,7 +56,7 @@ func (dp *dataPartition) streamRepairExtent(remoteExtentInfo *storage.FileInfo)
 	}
 	defer gConnPool.Put(conn, true)
 
-	// Write OpStreamRead command to leader
+	// Write OpERepairRead command to leader
 	if err = request.WriteToConn(conn); err != nil {
 		err = errors.Annotatef(err, "streamRepairExtent send streamRead to host[%v] error", remoteExtentInfo.Source)
 		log.LogErrorf("action[streamRepairExtent] err[%v].", err)
@@ -92,6 +94,11 @@ func (dp *dataPartition) streamRepairExtent(remoteExtentInfo *storage.FileInfo)
 			log.LogErrorf("action[streamRepairExtent] err[%v].", err)
 			return errors.Annotatef(err, "streamRepairExtent receive data error")
 		}
+		if err = dp.OpenRepairData(request); err != nil {
+			err = errors.Annotatef(err, "streamRepairExtent open repair data error")
+			log.LogErrorf("action[streamRepairExtent] err[%v].", err)
+			return
+		}
 		// Write it to local extent file
 		if err = store.Write(uint64(localExtentInfo.FileId), int64(localExtentInfo.Size), int64(request.Size), request.Data, request.Crc); err != nil {
 			err = errors.Annotatef(err, "streamRepairExtent repair data error")
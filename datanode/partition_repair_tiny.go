@@ -22,7 +22,9 @@ type RepairChunkTask struct {
 //do stream repair chunkfile,it do on follower host
 func (dp *dataPartition) doStreamTinyFixRepair(wg *sync.WaitGroup, remoteTinyFileInfo *storage.FileInfo) {
 	defer wg.Done()
+	start := startRepair(RepairTypeTiny)
 	err := dp.streamRepairTinyObjects(remoteTinyFileInfo)
+	observeRepair(RepairTypeTiny, start, err)
 	if err != nil {
 		localTinyInfo, err1 := dp.GetTinyStore().GetWatermark(uint64(remoteTinyFileInfo.FileId))
 		if err != nil {
@@ -85,6 +87,10 @@ func (dp *dataPartition) streamRepairTinyObjects(remoteChunkInfo *storage.FileIn
 				" %v, expect max objid is %v", newLastOid, remoteChunkInfo.FileId)
 			return err
 		}
+		if err = dp.OpenRepairData(request); err != nil {
+			gConnPool.Put(conn, true)
+			return errors.Annotatef(err, "streamRepairTinyObjects open repair data failed")
+		}
 		// write this tinyObject to local
 		err = dp.applyRepairTinyObjects(remoteChunkInfo.FileId, request.Data, newLastOid)
 		if err != nil {
@@ -153,6 +159,9 @@ func postRepairData(pkg *Packet, lastOid uint64, data []byte, size int, conn *ne
 	pkg.Size = uint32(size)
 	pkg.Data = data
 	pkg.Crc = crc32.ChecksumIEEE(pkg.Data)
+	if err = pkg.DataPartition.SealRepairData(pkg); err != nil {
+		return
+	}
 	err = pkg.WriteToNoDeadLineConn(conn)
 	log.LogWrite(pkg.ActionMsg(ActionLeaderToFollowerOpRepairReadSendPackBuffer, conn.RemoteAddr().String(), pkg.StartT, err))
 
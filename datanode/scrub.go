@@ -0,0 +1,406 @@
+package datanode
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/tiglabs/baudstorage/master"
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/storage"
+	"github.com/tiglabs/baudstorage/util"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+var (
+	// scrubC limits the number of partitions scrubbed at the same time on this node.
+	scrubC = make(chan bool, DefaultScrubConcurrency)
+	// scrubbing holds the partitions being scrubbed, the master resends a task
+	// which is not answered in time and the duplicate is dropped.
+	scrubbing sync.Map
+)
+
+// newScrubThrottle paces the reads of a scrub to bytesPerSec, zero means no limit.
+func newScrubThrottle(bytesPerSec int64) storage.ScrubThrottle {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	var readBytes int64
+	start := time.Now()
+	return func(size int) {
+		readBytes += int64(size)
+		expect := time.Duration(float64(readBytes) / float64(bytesPerSec) * float64(time.Second))
+		if elapsed := time.Since(start); elapsed < expect {
+			time.Sleep(expect - elapsed)
+		}
+	}
+}
+
+// Scrub re-reads every stable extent and every tiny chunk of the partition and checks them
+// against the stored crc. The result of each file is comparable between replicas.
+func (dp *dataPartition) Scrub(bytesPerSec int64) (files []*proto.ScrubFile, err error) {
+	return dp.scrub(newScrubThrottle(bytesPerSec))
+}
+
+// scrub quarantines the bad blocks and objects it finds, their reads fail until they are repaired.
+func (dp *dataPartition) scrub(throttle storage.ScrubThrottle) (files []*proto.ScrubFile, err error) {
+	var (
+		extents []*storage.FileInfo
+		chunks  []*storage.FileInfo
+		result  *storage.ScrubResult
+	)
+	files = make([]*proto.ScrubFile, 0)
+	if extents, err = dp.extentStore.GetAllWatermark(storage.GetStableExtentFilter()); err != nil {
+		return
+	}
+	for _, extent := range extents {
+		if result, err = dp.extentStore.ScrubExtent(uint64(extent.FileId), throttle); err != nil {
+			if err == storage.ErrorHasDelete {
+				err = nil
+				continue
+			}
+			return nil, errors.Annotatef(err, "scrub extent[%v]", extent.FileId)
+		}
+		dp.extentStore.QuarantineBlocks(uint64(extent.FileId), result.Bad)
+		files = append(files, newScrubFile(extent.FileId, proto.ExtentStoreMode, result))
+	}
+	if chunks, err = dp.tinyStore.GetAllWatermark(); err != nil {
+		return
+	}
+	for _, chunk := range chunks {
+		if result, err = dp.tinyStore.ScrubChunk(uint32(chunk.FileId), throttle); err != nil {
+			return nil, errors.Annotatef(err, "scrub chunk[%v]", chunk.FileId)
+		}
+		dp.tinyStore.QuarantineObjects(uint32(chunk.FileId), result.Bad)
+		files = append(files, newScrubFile(chunk.FileId, proto.TinyStoreMode, result))
+	}
+	return
+}
+
+func newScrubFile(fileId int, storeMode uint8, result *storage.ScrubResult) *proto.ScrubFile {
+	return &proto.ScrubFile{
+		Name:      strconv.Itoa(fileId),
+		StoreMode: storeMode,
+		Size:      result.Size,
+		Count:     result.Count,
+		Crc:       result.Crc,
+		Bad:       result.Bad,
+	}
+}
+
+// startDiskScrubbers starts a background scrubber on every disk, a zero rate disables them.
+func (s *DataNode) startDiskScrubbers() {
+	if s.diskScrubBytesPerSec <= 0 {
+		log.LogInfof("action[startDiskScrubbers] background scrub is disabled")
+		return
+	}
+	for _, d := range s.space.GetDisks() {
+		go s.diskScrubScheduler(d)
+	}
+}
+
+// diskScrubScheduler scrubs all the partitions of the disk round after round,
+// a round starts DefaultDiskScrubIntervalSec after the end of the last one.
+func (s *DataNode) diskScrubScheduler(d *Disk) {
+	timer := time.NewTimer(DefaultDiskScrubDelaySec * time.Second)
+	for {
+		select {
+		case <-timer.C:
+			s.scrubDisk(d)
+			timer = time.NewTimer(DefaultDiskScrubIntervalSec * time.Second)
+		case <-s.stopC:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// scrubDisk re-reads the partitions of the disk at diskScrubBytesPerSec in total. The bad files
+// are reported to the master, which repairs them from a healthy replica. The ec partitions
+// are skipped, their shards can not be fetched from another replica.
+func (s *DataNode) scrubDisk(d *Disk) {
+	var checkedFiles, badFiles int
+	start := time.Now()
+	throttle := newScrubThrottle(s.diskScrubBytesPerSec)
+	for _, partitionId := range d.DataPartitionList() {
+		select {
+		case <-s.stopC:
+			return
+		default:
+		}
+		partition := s.space.GetPartition(partitionId)
+		if partition == nil {
+			continue
+		}
+		dp := partition.(*dataPartition)
+		if dp.partitionType == proto.ECPartition {
+			continue
+		}
+		if _, running := scrubbing.LoadOrStore(uint64(partitionId), true); running {
+			continue
+		}
+		files, err := dp.scrub(throttle)
+		scrubbing.Delete(uint64(partitionId))
+		if err != nil {
+			log.LogErrorf("action[scrubDisk] disk[%v] partition[%v] err[%v]", d.Path, partitionId, err)
+			continue
+		}
+		bad := make([]*proto.ScrubFile, 0)
+		for _, f := range files {
+			if len(f.Bad) > 0 {
+				bad = append(bad, f)
+			}
+		}
+		checkedFiles += len(files)
+		badFiles += len(bad)
+		if len(bad) > 0 {
+			s.reportCorruption(dp, bad)
+		}
+	}
+	log.LogInfof("action[scrubDisk] disk[%v] checkedFiles[%v] badFiles[%v] cost[%v]",
+		d.Path, checkedFiles, badFiles, time.Since(start))
+}
+
+// reportCorruption reports the bad files of the partition to the master, the master sends
+// back an OpRepairFile task for each of them.
+func (s *DataNode) reportCorruption(dp *dataPartition, files []*proto.ScrubFile) {
+	for _, f := range files {
+		log.LogWarnf("action[reportCorruption] partition[%v] file[%v] storeMode[%v] bad%v",
+			dp.ID(), f.Name, f.StoreMode, f.Bad)
+	}
+	report := &proto.CorruptionReport{
+		PartitionId: uint64(dp.ID()),
+		Addr:        s.localServeAddr,
+		Files:       files,
+	}
+	data, _ := json.Marshal(report)
+	if _, err := MasterHelper.Request("POST", master.DataNodeCorruption, nil, data); err != nil {
+		err = errors.Annotatef(err, "report corruption failed,partitionId[%v]", dp.ID())
+		log.LogError(errors.ErrorStack(err))
+	}
+}
+
+// RepairFile fetches the bad blocks of an extent, or the bad objects of a chunk,
+// from the healthy replica source and overwrites the local copy in place.
+func (dp *dataPartition) RepairFile(request *proto.RepairFileRequest) (err error) {
+	var fileId int
+	if fileId, err = strconv.Atoi(request.Name); err != nil {
+		return
+	}
+	switch request.StoreMode {
+	case proto.ExtentStoreMode:
+		for _, blockNo := range request.Bad {
+			if err = dp.repairExtentBlock(uint64(fileId), blockNo, request.Source); err != nil {
+				return
+			}
+		}
+	case proto.TinyStoreMode:
+		for _, oid := range request.Bad {
+			if err = dp.repairObject(uint32(fileId), oid, request.Source); err != nil {
+				return
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown store mode[%v]", request.StoreMode)
+	}
+	return
+}
+
+func (dp *dataPartition) repairExtentBlock(extentId, blockNo uint64, source string) (err error) {
+	var (
+		info *storage.FileInfo
+		conn *net.TCPConn
+	)
+	store := dp.GetExtentStore()
+	if info, err = store.GetWatermark(extentId); err != nil {
+		return
+	}
+	offset := int64(blockNo) * util.BlockSize
+	if offset >= int64(info.Size) {
+		return fmt.Errorf("extent[%v] block[%v] out of size[%v]", extentId, blockNo, info.Size)
+	}
+	size := util.Min(int(int64(info.Size)-offset), util.BlockSize)
+	request := NewExtentRepairReadPacket(dp.ID(), int(extentId), int(offset), size)
+	if conn, err = gConnPool.Get(source); err != nil {
+		return errors.Annotatef(err, "repairExtentBlock get conn from host[%v] error", source)
+	}
+	if err = request.WriteToConn(conn); err != nil {
+		gConnPool.Put(conn, true)
+		return errors.Annotatef(err, "repairExtentBlock send streamRead to host[%v] error", source)
+	}
+	defer func() {
+		gConnPool.Put(conn, err != nil)
+	}()
+	// the replies of a stream read all carry the offset of the request, the block is written
+	// as a whole so that it leaves the quarantine
+	block := make([]byte, size)
+	for readN := 0; readN < size; readN += int(request.Size) {
+		if err = request.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+			return errors.Annotatef(err, "repairExtentBlock receive data error")
+		}
+		if request.ResultCode != proto.OpOk {
+			return fmt.Errorf("repairExtentBlock host[%v] reply %v", source, request.GetResultMesg())
+		}
+		if request.Crc != crc32.ChecksumIEEE(request.Data[:request.Size]) {
+			return fmt.Errorf("repairExtentBlock crc mismatch extent[%v] offset[%v] from [%v]",
+				extentId, offset+int64(readN), source)
+		}
+		if err = dp.OpenRepairData(request); err != nil {
+			return
+		}
+		copy(block[readN:], request.Data[:request.Size])
+	}
+	crc := crc32.ChecksumIEEE(block)
+	if storedCrc, quarantined := store.QuarantinedBlockCrc(extentId, blockNo); quarantined && crc != storedCrc {
+		return fmt.Errorf("repairExtentBlock extent[%v] block[%v] from [%v] does not match the stored crc",
+			extentId, blockNo, source)
+	}
+	if err = store.Write(extentId, offset, int64(size), block, crc); err != nil {
+		return
+	}
+	log.LogInfof("action[repairExtentBlock] partition[%v] extent[%v] block[%v] repaired from [%v]",
+		dp.ID(), extentId, blockNo, source)
+	return
+}
+
+func (dp *dataPartition) repairObject(chunkId uint32, oid uint64, source string) (err error) {
+	var (
+		o    *storage.Object
+		conn *net.TCPConn
+	)
+	store := dp.GetTinyStore()
+	if o, err = store.GetObject(chunkId, oid); err != nil {
+		return
+	}
+	request := NewPacket()
+	request.Opcode = proto.OpRead
+	request.StoreMode = proto.TinyStoreMode
+	request.PartitionID = dp.ID()
+	request.FileID = uint64(chunkId)
+	request.Offset = int64(oid)
+	request.Size = o.Size
+	request.ReqID = proto.GetReqID()
+	if conn, err = gConnPool.Get(source); err != nil {
+		return errors.Annotatef(err, "repairObject get conn from host[%v] error", source)
+	}
+	defer func() {
+		gConnPool.Put(conn, err != nil)
+	}()
+	if err = request.WriteToConn(conn); err != nil {
+		return errors.Annotatef(err, "repairObject send read to host[%v] error", source)
+	}
+	if err = request.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+		return errors.Annotatef(err, "repairObject receive data error")
+	}
+	if request.ResultCode != proto.OpOk {
+		return fmt.Errorf("repairObject host[%v] reply %v", source, request.GetResultMesg())
+	}
+	if err = store.RepairObject(chunkId, oid, request.Data[:request.Size], request.Crc); err != nil {
+		return
+	}
+	log.LogInfof("action[repairObject] partition[%v] chunk[%v] object[%v] repaired from [%v]",
+		dp.ID(), chunkId, oid, source)
+	return
+}
+
+// Handle OpScrubDataPartition packet.
+func (s *DataNode) handleScrubDataPartition(pkg *Packet) {
+	task := &proto.AdminTask{}
+	json.Unmarshal(pkg.Data, task)
+	pkg.PackOkReply()
+	go s.scrubDataPartition(task)
+}
+
+func (s *DataNode) scrubDataPartition(task *proto.AdminTask) {
+	request := &proto.ScrubDataPartitionRequest{}
+	response := &proto.ScrubDataPartitionResponse{}
+	if task.OpCode == proto.OpScrubDataPartition {
+		bytes, _ := json.Marshal(task.Request)
+		json.Unmarshal(bytes, request)
+		response.PartitionType = request.PartitionType
+		response.PartitionId = request.PartitionId
+		dp := s.space.GetPartition(uint32(request.PartitionId))
+		if dp == nil {
+			response.Status = proto.TaskFail
+			response.Result = fmt.Sprintf("dataPartition[%v] not found", request.PartitionId)
+			log.LogErrorf("from master Task[%v] failed,error[%v]", task.ToString(), response.Result)
+		} else {
+			if _, running := scrubbing.LoadOrStore(request.PartitionId, true); running {
+				log.LogWarnf("action[scrubDataPartition] partition[%v] is being scrubbed", request.PartitionId)
+				return
+			}
+			scrubC <- true
+			files, err := dp.(*dataPartition).Scrub(request.ReadBytesPerSec)
+			<-scrubC
+			scrubbing.Delete(request.PartitionId)
+			if err != nil {
+				response.Status = proto.TaskFail
+				response.Result = err.Error()
+				log.LogErrorf("from master Task[%v] failed,error[%v]", task.ToString(), err.Error())
+			} else {
+				response.Files = files
+				response.Status = proto.TaskSuccess
+			}
+		}
+	} else {
+		response.PartitionId = request.PartitionId
+		response.Status = proto.TaskFail
+		response.Result = "illegal opcode "
+		log.LogErrorf("from master Task[%v] failed,error[%v]", task.ToString(), response.Result)
+	}
+	task.Response = response
+	data, _ := json.Marshal(task)
+	_, err := MasterHelper.Request("POST", master.DataNodeResponse, nil, data)
+	if err != nil {
+		err = errors.Annotatef(err, "scrub dataPartition failed,partitionId[%v]", request.PartitionId)
+		log.LogError(errors.ErrorStack(err))
+	}
+}
+
+// Handle OpRepairFile packet.
+func (s *DataNode) handleRepairFile(pkg *Packet) {
+	task := &proto.AdminTask{}
+	json.Unmarshal(pkg.Data, task)
+	pkg.PackOkReply()
+	go s.repairFile(task)
+}
+
+func (s *DataNode) repairFile(task *proto.AdminTask) {
+	request := &proto.RepairFileRequest{}
+	response := &proto.RepairFileResponse{}
+	if task.OpCode == proto.OpRepairFile {
+		bytes, _ := json.Marshal(task.Request)
+		json.Unmarshal(bytes, request)
+		response.PartitionId = request.PartitionId
+		response.Name = request.Name
+		dp := s.space.GetPartition(uint32(request.PartitionId))
+		if dp == nil {
+			response.Status = proto.TaskFail
+			response.Result = fmt.Sprintf("dataPartition[%v] not found", request.PartitionId)
+			log.LogErrorf("from master Task[%v] failed,error[%v]", task.ToString(), response.Result)
+		} else if err := dp.(*dataPartition).RepairFile(request); err != nil {
+			response.Status = proto.TaskFail
+			response.Result = err.Error()
+			log.LogErrorf("from master Task[%v] failed,error[%v]", task.ToString(), err.Error())
+		} else {
+			response.Status = proto.TaskSuccess
+		}
+	} else {
+		response.PartitionId = request.PartitionId
+		response.Status = proto.TaskFail
+		response.Result = "illegal opcode "
+		log.LogErrorf("from master Task[%v] failed,error[%v]", task.ToString(), response.Result)
+	}
+	task.Response = response
+	data, _ := json.Marshal(task)
+	_, err := MasterHelper.Request("POST", master.DataNodeResponse, nil, data)
+	if err != nil {
+		err = errors.Annotatef(err, "repair file failed,partitionId[%v] file[%v]", request.PartitionId, request.Name)
+		log.LogError(errors.ErrorStack(err))
+	}
+}
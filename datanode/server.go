@@ -9,8 +9,11 @@ import (
 	"github.com/tiglabs/baudstorage/storage"
 	"github.com/tiglabs/baudstorage/util"
 	"github.com/tiglabs/baudstorage/util/config"
+	"github.com/tiglabs/baudstorage/util/encrypt"
 	"github.com/tiglabs/baudstorage/util/log"
+	"github.com/tiglabs/baudstorage/util/metrics"
 	"github.com/tiglabs/baudstorage/util/pool"
+	"github.com/tiglabs/baudstorage/util/qos"
 	"github.com/tiglabs/baudstorage/util/ump"
 	"io"
 	"net"
@@ -28,18 +31,22 @@ import (
 var (
 	ErrStoreTypeMismatch        = errors.New("store type error")
 	ErrPartitionNotExist        = errors.New("dataPartition not exists")
+	ErrPartitionUnavailable     = errors.New("dataPartition unavailable")
 	ErrChunkOffsetMismatch      = errors.New("chunk offset not mismatch")
 	ErrNoDiskForCreatePartition = errors.New("no disk for create dataPartition")
+	ErrDiskMigrating            = errors.New("disk partitions are being migrated")
 	ErrBadConfFile              = errors.New("bad config file")
 
-	LocalIP      string
-	gConnPool    = pool.NewConnPool()
-	MasterHelper = util.NewMasterHelper()
+	LocalIP        string
+	LocalServeAddr string
+	gConnPool      = pool.NewConnPool()
+	MasterHelper   = util.NewMasterHelper()
 )
 
 const (
 	GetIpFromMaster = master.AdminGetIp
 	DefaultRackName = "huitian_rack1"
+	DefaultZoneName = "default"
 )
 
 const (
@@ -47,16 +54,26 @@ const (
 )
 
 const (
-	ConfigKeyPort       = "port"       // int
-	ConfigKeyClusterID  = "clusterID"  // string
-	ConfigKeyMasterAddr = "masterAddr" // array
-	ConfigKeyRack       = "rack"       // string
-	ConfigKeyDisks      = "disks"      // array
+	ConfigKeyPort          = "port"              // int
+	ConfigKeyClusterID     = "clusterID"         // string
+	ConfigKeyMasterAddr    = "masterAddr"        // array
+	ConfigKeyZone          = "zone"              // string
+	ConfigKeyRack          = "rack"              // string
+	ConfigKeyDisks         = "disks"             // array
+	ConfigKeyMasterKey     = "masterKeyFile"     // string
+	ConfigKeyDiskScrubRate = "diskScrubMBPerSec" // string, "0" disables the background scrub
+
+	ConfigKeyDiskCompactConcurrency = "diskCompactConcurrency" // string
+	ConfigKeyDiskCompactRate        = "diskCompactMBPerSec"    // string, "0" means no limit
+	ConfigKeyCompactGarbageRatio    = "compactGarbageRatio"    // string, percent of a chunk
+
+	ConfigKeyDisableZeroCopyRead = "disableZeroCopyRead" // bool
 )
 
 type DataNode struct {
 	space          SpaceManager
 	port           string
+	zoneName       string
 	rackName       string
 	clusterId      string
 	localIp        string
@@ -65,10 +82,18 @@ type DataNode struct {
 	stopC          chan bool
 	state          uint32
 	wg             sync.WaitGroup
+	volQos         *qos.Limiter
+
+	diskScrubBytesPerSec   int64
+	compactEnabled         int32
+	diskCompactConcurrency int
+	diskCompactBytesPerSec int64
+	compactGarbageRatio    uint64
+	zeroCopyRead           bool
 }
 
 func NewServer() *DataNode {
-	return &DataNode{}
+	return &DataNode{volQos: qos.NewLimiter()}
 }
 
 func (s *DataNode) Start(cfg *config.Config) (err error) {
@@ -108,15 +133,20 @@ func (s *DataNode) onStart(cfg *config.Config) (err error) {
 	if err = s.parseConfig(cfg); err != nil {
 		return
 	}
-
-	go s.registerProfHandler()
+	if err = ump.ConfigSink(cfg); err != nil {
+		return
+	}
 
 	if err = s.startSpaceManager(cfg); err != nil {
 		return
 	}
+	// the handlers and the metrics read the space manager
+	go s.registerProfHandler()
 	if err = s.startTcpService(); err != nil {
 		return
 	}
+	s.startDiskScrubbers()
+	s.startDiskCompactors()
 
 	go s.registerToMaster()
 	ump.InitUmp(UmpModuleName)
@@ -150,14 +180,66 @@ func (s *DataNode) parseConfig(cfg *config.Config) (err error) {
 	for _, ip := range cfg.GetArray(ConfigKeyMasterAddr) {
 		MasterHelper.AddNode(ip.(string))
 	}
+	s.zoneName = cfg.GetString(ConfigKeyZone)
+	if s.zoneName == "" {
+		s.zoneName = DefaultZoneName
+	}
 	s.rackName = cfg.GetString(ConfigKeyRack)
 	if s.rackName == "" {
 		s.rackName = DefaultRackName
 	}
+	if keyFile := cfg.GetString(ConfigKeyMasterKey); keyFile != "" {
+		if masterKey, err = encrypt.LoadMasterKey(keyFile); err != nil {
+			return
+		}
+	}
+	var (
+		scrubRate, compactConcurrency, compactRate, garbageRatio int64
+	)
+	if scrubRate, err = parseConfigInt(cfg, ConfigKeyDiskScrubRate, DefaultDiskScrubMBPerSec); err != nil {
+		return
+	}
+	s.diskScrubBytesPerSec = scrubRate * util.MB
+	if compactConcurrency, err = parseConfigInt(cfg, ConfigKeyDiskCompactConcurrency, DefaultDiskCompactConcurrency); err != nil {
+		return
+	}
+	if compactConcurrency <= 0 {
+		return ErrBadConfFile
+	}
+	s.diskCompactConcurrency = int(compactConcurrency)
+	if compactRate, err = parseConfigInt(cfg, ConfigKeyDiskCompactRate, DefaultDiskCompactMBPerSec); err != nil {
+		return
+	}
+	s.diskCompactBytesPerSec = compactRate * util.MB
+	if garbageRatio, err = parseConfigInt(cfg, ConfigKeyCompactGarbageRatio, storage.CompactThreshold); err != nil {
+		return
+	}
+	if garbageRatio <= 0 || garbageRatio > 100 {
+		return ErrBadConfFile
+	}
+	s.compactGarbageRatio = uint64(garbageRatio)
+	s.zeroCopyRead = !cfg.GetBool(ConfigKeyDisableZeroCopyRead)
 	log.LogDebugf("action[parseConfig] load masterAddrs[%v].", MasterHelper.Nodes())
 	log.LogDebugf("action[parseConfig] load port[%v].", s.port)
 	log.LogDebugf("action[parseConfig] load clusterId[%v].", s.clusterId)
+	log.LogDebugf("action[parseConfig] load zoneName[%v].", s.zoneName)
 	log.LogDebugf("action[parseConfig] load rackName[%v].", s.rackName)
+	log.LogDebugf("action[parseConfig] load diskScrubBytesPerSec[%v].", s.diskScrubBytesPerSec)
+	log.LogDebugf("action[parseConfig] load diskCompactConcurrency[%v] diskCompactBytesPerSec[%v] compactGarbageRatio[%v].",
+		s.diskCompactConcurrency, s.diskCompactBytesPerSec, s.compactGarbageRatio)
+	log.LogDebugf("action[parseConfig] load zeroCopyRead[%v].", s.zeroCopyRead)
+	return
+}
+
+// parseConfigInt parses the integer config of the key, the default value is used if it is absent.
+func parseConfigInt(cfg *config.Config, key string, defaultValue int64) (value int64, err error) {
+	raw := cfg.GetString(key)
+	if raw == "" {
+		return defaultValue, nil
+	}
+	if value, err = strconv.ParseInt(raw, 10, 64); err != nil {
+		return 0, ErrBadConfFile
+	}
 	return
 }
 
@@ -217,6 +299,7 @@ func (s *DataNode) registerToMaster() {
 			LocalIP = string(cInfo.Ip)
 			s.clusterId = cInfo.Cluster
 			s.localServeAddr = fmt.Sprintf("%s:%v", LocalIP, s.port)
+			LocalServeAddr = s.localServeAddr
 			if !util.IP(LocalIP) {
 				log.LogErrorf("action[registerToMaster] got an invalid local ip[%v] from master[%v].",
 					LocalIP, masterAddr)
@@ -226,6 +309,8 @@ func (s *DataNode) registerToMaster() {
 			// Register this data node to master.
 			params := make(map[string]string)
 			params["addr"] = fmt.Sprintf("%s:%v", LocalIP, s.port)
+			params["version"] = strconv.FormatUint(uint64(proto.Version), 10)
+			params["features"] = strconv.FormatUint(localFeatures(), 10)
 			data, err = MasterHelper.Request(http.MethodPost, master.AddDataNode, params, nil)
 			if err != nil {
 				log.LogErrorf("action[registerToMaster] cannot register this node to master[%] err[%v].",
@@ -248,6 +333,10 @@ func (s *DataNode) registerProfHandler() {
 	http.HandleFunc("/partition", s.apiGetPartition)
 	http.HandleFunc("/extent", s.handleExtentInfo)
 	http.HandleFunc("/stats", s.handleStat)
+	http.HandleFunc("/chunks", s.apiGetChunks)
+	http.HandleFunc("/compactions", s.apiGetCompactions)
+	s.registerMetrics()
+	metrics.RegisterHandler()
 }
 
 func (s *DataNode) startTcpService() (err error) {
@@ -434,6 +523,7 @@ func (s *DataNode) isDiskErr(errMsg string) bool {
 		strings.Contains(errMsg, storage.ErrorNoAvaliFile.Error()) || strings.Contains(errMsg, storage.ErrorObjNotFound.Error()) ||
 		strings.Contains(errMsg, io.EOF.Error()) || strings.Contains(errMsg, storage.ErrSyscallNoSpace.Error()) ||
 		strings.Contains(errMsg, storage.ErrorHasDelete.Error()) || strings.Contains(errMsg, ErrPartitionNotExist.Error()) ||
+		strings.Contains(errMsg, ErrPartitionUnavailable.Error()) ||
 		strings.Contains(errMsg, storage.ErrObjectSmaller.Error()) ||
 		strings.Contains(errMsg, storage.ErrPkgCrcMismatch.Error()) || strings.Contains(errMsg, ErrStoreTypeMismatch.Error()) ||
 		strings.Contains(errMsg, storage.ErrorNoUnAvaliFile.Error()) ||
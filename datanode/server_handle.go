@@ -12,9 +12,11 @@ import (
 func (s *DataNode) handleGetDisk(w http.ResponseWriter, r *http.Request) {
 	diskReport := &struct{
 		Disks []*Disk
+		Zone string
 		Rack string
 	}{
 		Disks: s.space.GetDisks(),
+		Zone:  s.zoneName,
 		Rack:  s.rackName,
 	}
 	s.buildApiSuccessResp(w, diskReport)
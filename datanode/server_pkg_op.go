@@ -36,9 +36,9 @@ func (s *DataNode) operatePacket(pkg *Packet, c *net.TCPConn) {
 			logContent := fmt.Sprintf("action[operatePacket] %v.",
 				pkg.ActionMsg(pkg.GetOpMsg(), c.RemoteAddr().String(), start, nil))
 			switch pkg.Opcode {
-			case proto.OpStreamRead, proto.OpRead:
+			case proto.OpStreamRead, proto.OpRead, proto.OpECRead, proto.OpERepairRead:
 				log.LogRead(logContent)
-			case proto.OpWrite:
+			case proto.OpWrite, proto.OpRandomWrite, proto.OpECWrite:
 				log.LogWrite(logContent)
 			default:
 				log.LogInfo(logContent)
@@ -46,20 +46,30 @@ func (s *DataNode) operatePacket(pkg *Packet, c *net.TCPConn) {
 		}
 		pkg.Size = resultSize
 		ump.AfterTP(tpObject, err)
+		// only the failures of the node itself count against its health
+		var nodeErr error
+		if pkg.IsNodeErr() {
+			nodeErr = err
+		}
+		opMetrics.ObserveClass(pkg.GetOpMsg(), opClass(pkg.Opcode), time.Unix(0, start), nodeErr)
 	}()
 	switch pkg.Opcode {
 	case proto.OpCreateFile:
 		s.handleCreateFile(pkg)
 	case proto.OpWrite:
 		s.handleWrite(pkg)
+	case proto.OpRandomWrite:
+		s.handleRandomWrite(pkg)
 	case proto.OpRead:
 		s.handleRead(pkg)
 	case proto.OpChunkRepairRead:
 		s.handleChunkRepairRead(pkg, c)
-	case proto.OpStreamRead:
+	case proto.OpStreamRead, proto.OpERepairRead:
 		s.handleStreamRead(pkg, c)
 	case proto.OpMarkDelete:
 		s.handleMarkDelete(pkg)
+	case proto.OpPunchHole:
+		s.handlePunchHole(pkg)
 	case proto.OpNotifyCompact:
 		s.handleNotifyCompact(pkg)
 	case proto.OpNotifyRepair:
@@ -76,6 +86,22 @@ func (s *DataNode) operatePacket(pkg *Packet, c *net.TCPConn) {
 		s.handleDeleteDataPartition(pkg)
 	case proto.OpDataNodeHeartbeat:
 		s.handleHeartbeats(pkg)
+	case proto.OpDecommissionDisk:
+		s.handleDecommissionDisk(pkg)
+	case proto.OpScrubDataPartition:
+		s.handleScrubDataPartition(pkg)
+	case proto.OpRepairFile:
+		s.handleRepairFile(pkg)
+	case proto.OpSetPartitionStatus:
+		s.handleSetPartitionStatus(pkg)
+	case proto.OpECWrite:
+		s.handleECWrite(pkg)
+	case proto.OpECRead:
+		s.handleECRead(pkg)
+	case proto.OpECWriteShard:
+		s.handleECWriteShard(pkg)
+	case proto.OpECReadShard:
+		s.handleECReadShard(pkg)
 	case proto.OpPing:
 	default:
 		pkg.PackErrorBody(ErrorUnknownOp.Error(), ErrorUnknownOp.Error()+strconv.Itoa(int(pkg.Opcode)))
@@ -126,8 +152,12 @@ func (s *DataNode) handleCreateDataPartition(pkg *Packet) {
 	if task.OpCode == proto.OpCreateDataPartition {
 		bytes, _ := json.Marshal(task.Request)
 		json.Unmarshal(bytes, request)
-		if _, err := s.space.CreatePartition(request.VolumeId, uint32(request.PartitionId),
-			request.PartitionSize, request.PartitionType); err != nil {
+		dp, err := s.space.CreatePartition(request.VolumeId, uint32(request.PartitionId),
+			request.PartitionSize, request.PartitionType, int(request.ECDataNum), int(request.ECParityNum))
+		if err == nil {
+			err = dp.(*dataPartition).SetKeys(request.Keys)
+		}
+		if err != nil {
 			response.PartitionId = uint64(request.PartitionId)
 			response.Status = proto.TaskFail
 			response.Result = err.Error()
@@ -162,12 +192,19 @@ func (s *DataNode) handleHeartbeats(pkg *Packet) {
 	response := &proto.DataNodeHeartBeatResponse{}
 
 	s.fillHeartBeatResponse(response)
+	response.Version = proto.Version
+	response.Features = localFeatures()
+	response.Stats = heartbeatStats()
 
 	if task.OpCode == proto.OpDataNodeHeartbeat {
 		bytes, _ := json.Marshal(task.Request)
 		json.Unmarshal(bytes, request)
 		response.Status = proto.TaskSuccess
 		MasterHelper.AddNode(request.MasterAddr)
+		s.volQos.Update(request.VolQos)
+		s.updateCompressions(request.Compressions)
+		s.updateVolKeys(request.VolKeys)
+		s.setCompactEnabled(request.Compact)
 	} else {
 		response.Status = proto.TaskFail
 		response.Result = "illegal opcode"
@@ -223,6 +260,92 @@ func (s *DataNode) handleDeleteDataPartition(pkg *Packet) {
 	}
 }
 
+// Handle OpDecommissionDisk packet.
+func (s *DataNode) handleDecommissionDisk(pkg *Packet) {
+	task := &proto.AdminTask{}
+	json.Unmarshal(pkg.Data, task)
+	pkg.PackOkReply()
+	// the response is sent once the partitions of the disk are migrated
+	go s.decommissionDisk(task)
+}
+
+func (s *DataNode) decommissionDisk(task *proto.AdminTask) {
+	request := &proto.DecommissionDiskRequest{}
+	response := &proto.DecommissionDiskResponse{}
+	if task.OpCode == proto.OpDecommissionDisk {
+		bytes, _ := json.Marshal(task.Request)
+		json.Unmarshal(bytes, request)
+		response.DiskPath = request.DiskPath
+		failures, err := s.space.DecommissionDisk(request.DiskPath, request.MigrateLocal)
+		if err == ErrDiskMigrating {
+			// the running migration sends the response
+			log.LogWarnf("from master Task[%v] skipped,disk[%v] is migrating", task.ToString(), request.DiskPath)
+			return
+		}
+		response.Failures = failures
+		if err == nil && len(failures) != 0 {
+			err = fmt.Errorf("%v partitions are not migrated", len(failures))
+		}
+		if err != nil {
+			response.Status = proto.TaskFail
+			response.Result = err.Error()
+			log.LogErrorf("from master Task[%v] failed,error[%v]", task.ToString(), err.Error())
+		} else {
+			response.Status = proto.TaskSuccess
+		}
+	} else {
+		response.DiskPath = request.DiskPath
+		response.Status = proto.TaskFail
+		response.Result = "illegal opcode "
+		log.LogErrorf("from master Task[%v] failed,error[%v]", task.ToString(), response.Result)
+	}
+	task.Response = response
+	data, _ := json.Marshal(task)
+	_, err := MasterHelper.Request("POST", master.DataNodeResponse, nil, data)
+	if err != nil {
+		err = errors.Annotatef(err, "decommission disk failed,diskPath[%v]", request.DiskPath)
+		log.LogError(errors.ErrorStack(err))
+	}
+}
+
+// Handle OpSetPartitionStatus packet.
+func (s *DataNode) handleSetPartitionStatus(pkg *Packet) {
+	task := &proto.AdminTask{}
+	json.Unmarshal(pkg.Data, task)
+	pkg.PackOkReply()
+	request := &proto.SetPartitionStatusRequest{}
+	response := &proto.SetPartitionStatusResponse{}
+	if task.OpCode == proto.OpSetPartitionStatus {
+		bytes, _ := json.Marshal(task.Request)
+		json.Unmarshal(bytes, request)
+		response.PartitionId = request.PartitionId
+		dp := s.space.GetPartition(uint32(request.PartitionId))
+		if dp == nil {
+			response.Status = proto.TaskFail
+			response.Result = fmt.Sprintf("dataPartition[%v] not found", request.PartitionId)
+			log.LogErrorf("from master Task[%v] failed,error[%v]", task.ToString(), response.Result)
+		} else if err := dp.(*dataPartition).SetManualStatus(int(request.ManualStatus)); err != nil {
+			response.Status = proto.TaskFail
+			response.Result = err.Error()
+			log.LogErrorf("from master Task[%v] failed,error[%v]", task.ToString(), err.Error())
+		} else {
+			response.Status = proto.TaskSuccess
+		}
+	} else {
+		response.PartitionId = request.PartitionId
+		response.Status = proto.TaskFail
+		response.Result = "illegal opcode "
+		log.LogErrorf("from master Task[%v] failed,error[%v]", task.ToString(), response.Result)
+	}
+	task.Response = response
+	data, _ := json.Marshal(task)
+	_, err := MasterHelper.Request("POST", master.DataNodeResponse, nil, data)
+	if err != nil {
+		err = errors.Annotatef(err, "set dataPartition status failed,partitionId[%v]", request.PartitionId)
+		log.LogError(errors.ErrorStack(err))
+	}
+}
+
 // Handle OpLoadDataPartition packet.
 func (s *DataNode) handleLoadDataPartition(pkg *Packet) {
 	task := &proto.AdminTask{}
@@ -310,6 +433,53 @@ func (s *DataNode) handleWrite(pkg *Packet) {
 	return
 }
 
+// Handle OpRandomWrite packet, which overwrites the data of an extent in place.
+// The extents of an ec partition are written by whole stripes and are never overwritten.
+func (s *DataNode) handleRandomWrite(pkg *Packet) {
+	var err error
+	defer func() {
+		if err != nil {
+			err = errors.Annotatef(err, "Request[%v] RandomWrite Error", pkg.GetUniqueLogId())
+			pkg.PackErrorBody(LogWrite, err.Error())
+		} else {
+			pkg.PackOkReply()
+		}
+	}()
+	if pkg.DataPartition.(*dataPartition).partitionType == proto.ECPartition {
+		err = ErrorUnknownOp
+		return
+	}
+	err = pkg.DataPartition.GetExtentStore().Overwrite(pkg.FileID, pkg.Offset, int64(pkg.Size), pkg.Data, pkg.Crc)
+	s.addDiskErrs(pkg.PartitionID, err, WriteFlag)
+	return
+}
+
+// Handle OpPunchHole packet, which deallocates a range of an extent punched from a file.
+// The length of the range is carried in the data.
+func (s *DataNode) handlePunchHole(pkg *Packet) {
+	var err error
+	defer func() {
+		if err != nil {
+			err = errors.Annotatef(err, "Request[%v] PunchHole Error", pkg.GetUniqueLogId())
+			pkg.PackErrorBody(LogPunchHole, err.Error())
+		} else {
+			pkg.PackOkReply()
+		}
+	}()
+	if pkg.DataPartition.(*dataPartition).partitionType == proto.ECPartition {
+		err = ErrorUnknownOp
+		return
+	}
+	if pkg.Size != 8 {
+		err = ErrArgLenMismatch
+		return
+	}
+	size := int64(binary.BigEndian.Uint64(pkg.Data[:8]))
+	err = pkg.DataPartition.GetExtentStore().PunchHole(pkg.FileID, pkg.Offset, size)
+	s.addDiskErrs(pkg.PartitionID, err, WriteFlag)
+	return
+}
+
 // Handle OpRead packet.
 func (s *DataNode) handleRead(pkg *Packet) {
 	pkg.Data = make([]byte, pkg.Size)
@@ -331,7 +501,7 @@ func (s *DataNode) handleRead(pkg *Packet) {
 	return
 }
 
-// Handle OpStreamRead packet.
+// Handle OpStreamRead and OpERepairRead packet.
 func (s *DataNode) handleStreamRead(request *Packet, connect net.Conn) {
 	var (
 		err error
@@ -339,12 +509,53 @@ func (s *DataNode) handleStreamRead(request *Packet, connect net.Conn) {
 	needReplySize := request.Size
 	offset := request.Offset
 	store := request.DataPartition.GetExtentStore()
+	// the whole blocks are sent from the extent files with their stored crc,
+	// the repair reads are sealed in the user space
+	tcpConn, zeroCopy := connect.(*net.TCPConn)
+	zeroCopy = zeroCopy && s.zeroCopyRead && request.Opcode == proto.OpStreamRead
 	for {
 		if needReplySize <= 0 {
 			break
 		}
 		err = nil
+		if zeroCopy && offset%util.BlockSize == 0 && needReplySize >= util.BlockSize {
+			var sent bool
+			err = store.SendBlock(request.FileID, offset, tcpConn, func(crc uint32) error {
+				request.Size = util.BlockSize
+				request.Crc = crc
+				request.ResultCode = proto.OpOk
+				request.Data = nil
+				sent = true
+				return request.WriteToConn(connect)
+			})
+			if err == nil {
+				needReplySize -= util.BlockSize
+				offset += util.BlockSize
+				continue
+			}
+			if sent {
+				err = fmt.Errorf(request.ActionMsg(ActionWriteToCli, connect.RemoteAddr().String(),
+					request.StartT, err))
+				log.LogErrorf(err.Error())
+				connect.Close()
+				return
+			}
+			if err != storage.ErrorNoZeroCopy {
+				request.PackErrorBody(ActionStreamRead, err.Error())
+				if err = request.WriteToConn(connect); err != nil {
+					err = fmt.Errorf(request.ActionMsg(ActionWriteToCli, connect.RemoteAddr().String(),
+						request.StartT, err))
+					log.LogErrorf(err.Error())
+				}
+				return
+			}
+			err = nil
+		}
 		currReadSize := uint32(util.Min(int(needReplySize), util.ReadBlockSize))
+		if zeroCopy {
+			// stop at the end of the block so the next one is sent from the file
+			currReadSize = uint32(util.Min(int(currReadSize), util.BlockSize-int(offset%util.BlockSize)))
+		}
 		if currReadSize == util.ReadBlockSize {
 			request.Data, _ = proto.Buffers.Get(util.ReadBlockSize)
 		} else {
@@ -363,6 +574,13 @@ func (s *DataNode) handleStreamRead(request *Packet, connect net.Conn) {
 		}
 		request.Size = currReadSize
 		request.ResultCode = proto.OpOk
+		if request.Opcode == proto.OpERepairRead {
+			if err = request.DataPartition.SealRepairData(request); err != nil {
+				request.PackErrorBody(ActionStreamRead, err.Error())
+				request.WriteToConn(connect)
+				return
+			}
+		}
 		if err = request.WriteToConn(connect); err != nil {
 			err = fmt.Errorf(request.ActionMsg(ActionWriteToCli, connect.RemoteAddr().String(),
 				request.StartT, err))
@@ -425,6 +643,7 @@ func (s *DataNode) handleNotifyCompact(pkg *Packet) {
 		partitionId: vId,
 		chunkId:     int(cId),
 		isLeader:    false,
+		trigger:     CompactTriggerNotify,
 	}
 	err := s.AddCompactTask(task)
 	if err != nil {
@@ -39,6 +39,11 @@ func (s *DataNode) readFromCliAndDeal(msgH *MessageHandler) (err error) {
 		msgH.replyCh <- pkg
 		return
 	}
+	if err = s.checkVolQos(pkg); err != nil {
+		pkg.PackErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		msgH.replyCh <- pkg
+		return
+	}
 	if err = s.checkAndAddInfo(pkg); err != nil {
 		pkg.PackErrorBody("checkAndAddInfo", err.Error())
 		msgH.replyCh <- pkg
@@ -82,7 +87,7 @@ func (s *DataNode) doRequestCh(req *Packet, msgH *MessageHandler) {
 	var err error
 	if !req.IsTransitPkg() {
 		s.operatePacket(req, msgH.inConn)
-		if !(req.Opcode == proto.OpStreamRead) {
+		if !(req.Opcode == proto.OpStreamRead || req.Opcode == proto.OpERepairRead) {
 			msgH.replyCh <- req
 		}
 
@@ -229,6 +234,9 @@ func (s *DataNode) sendToNext(pkg *Packet, msgH *MessageHandler) error {
 }
 
 func (s *DataNode) checkStoreMode(p *Packet) (err error) {
+	if (p.Opcode == proto.OpRandomWrite || p.Opcode == proto.OpPunchHole) && p.StoreMode != proto.ExtentStoreMode {
+		return ErrStoreTypeMismatch
+	}
 	if p.StoreMode == proto.TinyStoreMode || p.StoreMode == proto.ExtentStoreMode {
 		return nil
 	}
@@ -262,8 +270,16 @@ func (s *DataNode) checkAction(pkg *Packet) (err error) {
 		return
 	}
 	pkg.DataPartition = dp
-	if pkg.Opcode == proto.OpWrite || pkg.Opcode == proto.OpCreateFile {
-		if pkg.DataPartition.Status() == proto.ReadOnly {
+	// a partition frozen to unavailable serves no client read, the replicas still repair each other
+	if pkg.Opcode == proto.OpRead || pkg.Opcode == proto.OpStreamRead || pkg.Opcode == proto.OpECRead {
+		if dp.Status() == proto.Unavaliable {
+			err = ErrPartitionUnavailable
+			return
+		}
+	}
+	if pkg.Opcode == proto.OpWrite || pkg.Opcode == proto.OpRandomWrite || pkg.Opcode == proto.OpCreateFile ||
+		pkg.Opcode == proto.OpECWrite || pkg.Opcode == proto.OpECWriteShard {
+		if pkg.DataPartition.Status() != proto.ReadWrite {
 			err = storage.ErrorPartitionReadOnly
 			return
 		}
@@ -275,6 +291,23 @@ func (s *DataNode) checkAction(pkg *Packet) (err error) {
 	return
 }
 
+// checkVolQos charges the packet to the limits of its volume before operatePacket.
+// A replicated write is charged on the head node only, and before it is sent to the
+// next replica, so a refused write is refused by the whole replica chain.
+func (s *DataNode) checkVolQos(pkg *Packet) (err error) {
+	switch pkg.Opcode {
+	case proto.OpRead, proto.OpStreamRead, proto.OpECRead:
+		return s.volQos.AllowRead(pkg.DataPartition.VolumeID(), int(pkg.Size))
+	case proto.OpWrite, proto.OpRandomWrite:
+		if pkg.isHeadNode() {
+			return s.volQos.AllowWrite(pkg.DataPartition.VolumeID(), int(pkg.Size))
+		}
+	case proto.OpECWrite:
+		return s.volQos.AllowWrite(pkg.DataPartition.VolumeID(), int(pkg.Size))
+	}
+	return
+}
+
 func (s *DataNode) statsFlow(pkg *Packet, flag int) {
 	stat := s.space.Stats()
 	if pkg == nil {
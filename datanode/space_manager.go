@@ -3,8 +3,13 @@ package datanode
 import (
 	"fmt"
 	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/compress"
 	"github.com/tiglabs/baudstorage/util/log"
+	"io"
 	"math"
+	"os"
+	"path"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,8 +22,9 @@ type SpaceManager interface {
 	GetPartition(partitionId uint32) (dp DataPartition)
 	Stats() *Stats
 	GetDisks() []*Disk
-	CreatePartition(volId string, partitionId uint32, storeSize int, storeType string) (DataPartition, error)
+	CreatePartition(volId string, partitionId uint32, storeSize int, storeType string, ecDataNum, ecParityNum int) (DataPartition, error)
 	DeletePartition(partitionId uint32)
+	DecommissionDisk(path string, migrateLocal bool) (failures map[uint64]string, err error)
 	RangePartitions(f func(partition DataPartition) bool)
 	Stop()
 }
@@ -133,8 +139,11 @@ func (space *spaceManager) updateMetrics() {
 		used += d.Used
 		available += d.Available
 		createdPartitionWeights += d.CreatedPartitionWeights
-		remainWeightsForCreatePartition += d.RemainWeightsForCreatePartition
 		partitionCnt += d.PartitionCnt
+		if d.isDecommissioned() {
+			continue
+		}
+		remainWeightsForCreatePartition += d.RemainWeightsForCreatePartition
 		if maxWeightsForCreatePartition < d.RemainWeightsForCreatePartition {
 			maxWeightsForCreatePartition = d.RemainWeightsForCreatePartition
 		}
@@ -153,6 +162,9 @@ func (space *spaceManager) getMinPartitionCntDisk() (d *Disk) {
 	minPartitionCnt = math.MaxUint64
 	var path string
 	for index, disk := range space.disks {
+		if disk.isDecommissioned() {
+			continue
+		}
 		if atomic.LoadUint64(&disk.PartitionCnt) < minPartitionCnt {
 			minPartitionCnt = atomic.LoadUint64(&disk.PartitionCnt)
 			path = index
@@ -249,7 +261,8 @@ func (space *spaceManager) PutPartition(dp DataPartition) {
 	return
 }
 
-func (space *spaceManager) CreatePartition(volId string, partitionId uint32, storeSize int, storeType string) (dp DataPartition, err error) {
+func (space *spaceManager) CreatePartition(volId string, partitionId uint32, storeSize int, storeType string,
+	ecDataNum, ecParityNum int) (dp DataPartition, err error) {
 	if space.GetPartition(partitionId) != nil {
 		return
 	}
@@ -257,7 +270,7 @@ func (space *spaceManager) CreatePartition(volId string, partitionId uint32, sto
 	if disk == nil || disk.Available < uint64(storeSize) {
 		return nil, ErrNoDiskForCreatePartition
 	}
-	if dp, err = CreateDataPartition(volId, partitionId, disk, storeSize, storeType); err != nil {
+	if dp, err = CreateDataPartition(volId, partitionId, disk, storeSize, storeType, ecDataNum, ecParityNum); err != nil {
 		return
 	}
 	space.PutPartition(dp)
@@ -275,6 +288,148 @@ func (space *spaceManager) DeletePartition(dpId uint32) {
 	dp.Stop()
 }
 
+// DecommissionDisk retires the disk, and moves its partitions to the other disks of this node
+// when migrateLocal is set. It returns the partitions which are not moved.
+func (space *spaceManager) DecommissionDisk(path string, migrateLocal bool) (failures map[uint64]string, err error) {
+	var disk *Disk
+	if disk, err = space.GetDisk(path); err != nil {
+		return
+	}
+	if err = disk.decommission(); err != nil {
+		return
+	}
+	log.LogWarnf("action[DecommissionDisk] disk[%v] decommissioned migrateLocal[%v]", path, migrateLocal)
+	if !migrateLocal {
+		return
+	}
+	if !disk.startMigrate() {
+		return nil, ErrDiskMigrating
+	}
+	defer disk.finishMigrate()
+	return space.migrateDiskPartitions(disk), nil
+}
+
+// migrateDiskPartitions moves all the partitions of a decommissioned disk to the other disks of this node.
+func (space *spaceManager) migrateDiskPartitions(disk *Disk) (failures map[uint64]string) {
+	failures = make(map[uint64]string, 0)
+	partitions := make([]DataPartition, 0)
+	space.RangePartitions(func(dp DataPartition) bool {
+		if dp.Disk() == disk {
+			partitions = append(partitions, dp)
+		}
+		return true
+	})
+	for _, dp := range partitions {
+		target := space.getMinPartitionCntDisk()
+		if target == nil || target.Available < uint64(dp.Size()) {
+			log.LogErrorf("action[migrateDiskPartitions] disk[%v] partition[%v] err[%v]",
+				disk.Path, dp.ID(), ErrNoDiskForCreatePartition)
+			failures[uint64(dp.ID())] = ErrNoDiskForCreatePartition.Error()
+			continue
+		}
+		if err := space.movePartition(dp, target); err != nil {
+			log.LogErrorf("action[migrateDiskPartitions] move partition[%v] from disk[%v] to disk[%v] err[%v]",
+				dp.ID(), disk.Path, target.Path, err)
+			failures[uint64(dp.ID())] = err.Error()
+			continue
+		}
+		log.LogInfof("action[migrateDiskPartitions] move partition[%v] from disk[%v] to disk[%v] success",
+			dp.ID(), disk.Path, target.Path)
+	}
+	return
+}
+
+// movePartition copies the directory of the partition to the target disk while it still serves, then
+// stops it, copies the files changed meanwhile and loads it from there. The replica is unavailable
+// during the final catch-up only, the writes which fail meanwhile are repaired by the leader later.
+func (space *spaceManager) movePartition(dp DataPartition, target *Disk) (err error) {
+	var newDp DataPartition
+	srcDir := dp.Path()
+	dstDir := path.Join(target.Path, path.Base(srcDir))
+	if err = syncPartitionDir(srcDir, dstDir); err != nil {
+		os.RemoveAll(dstDir)
+		return
+	}
+	space.partitionMu.Lock()
+	delete(space.partitions, dp.ID())
+	space.partitionMu.Unlock()
+	dp.Stop()
+	if err = syncPartitionDir(srcDir, dstDir); err == nil {
+		newDp, err = LoadDataPartition(dstDir, target)
+	}
+	if err != nil {
+		os.RemoveAll(dstDir)
+		if oldDp, loadErr := LoadDataPartition(srcDir, dp.Disk()); loadErr == nil {
+			space.PutPartition(oldDp)
+		}
+		return
+	}
+	space.PutPartition(newDp)
+	os.RemoveAll(srcDir)
+	return
+}
+
+// syncPartitionDir makes dstDir a copy of srcDir, the files which keep their size and
+// modify time since the last copy are not copied again.
+func syncPartitionDir(srcDir, dstDir string) (err error) {
+	err = filepath.Walk(srcDir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, name)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dstDir, rel)
+		if info.IsDir() {
+			return os.MkdirAll(dst, info.Mode())
+		}
+		if dstInfo, err := os.Stat(dst); err == nil && dstInfo.Size() == info.Size() && dstInfo.ModTime().Equal(info.ModTime()) {
+			return nil
+		}
+		if err = copyPartitionFile(name, dst, info.Mode()); err != nil {
+			return err
+		}
+		return os.Chtimes(dst, info.ModTime(), info.ModTime())
+	})
+	if err != nil {
+		return
+	}
+	// the files deleted from the partition meanwhile
+	return filepath.Walk(dstDir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dstDir, name)
+		if err != nil {
+			return err
+		}
+		if _, err = os.Stat(filepath.Join(srcDir, rel)); os.IsNotExist(err) {
+			os.RemoveAll(name)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+}
+
+func copyPartitionFile(src, dst string, mode os.FileMode) (err error) {
+	var srcFile, dstFile *os.File
+	if srcFile, err = os.Open(src); err != nil {
+		return
+	}
+	defer srcFile.Close()
+	if dstFile, err = os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode); err != nil {
+		return
+	}
+	defer dstFile.Close()
+	if _, err = io.Copy(dstFile, srcFile); err != nil {
+		return
+	}
+	return dstFile.Sync()
+}
+
 func (s *DataNode) fillHeartBeatResponse(response *proto.DataNodeHeartBeatResponse) {
 	response.Status = proto.TaskSuccess
 	stat := s.space.Stats()
@@ -288,6 +443,7 @@ func (s *DataNode) fillHeartBeatResponse(response *proto.DataNodeHeartBeatRespon
 	response.RemainWeightsForCreatePartition = stat.RemainWeightsForCreatePartition
 	stat.Unlock()
 
+	response.ZoneName = s.zoneName
 	response.RackName = s.rackName
 	response.PartitionInfo = make([]*proto.PartitionReport, 0)
 	space := s.space
@@ -297,8 +453,39 @@ func (s *DataNode) fillHeartBeatResponse(response *proto.DataNodeHeartBeatRespon
 			PartitionStatus: partition.Status(),
 			Total:           uint64(partition.Size()),
 			Used:            uint64(partition.Used()),
+			DiskPath:        partition.Disk().Path,
 		}
+		stat := partition.GetExtentStore().GetCompressStat()
+		vr.CompressedBlocks = stat.Blocks
+		vr.CompressedRawSize = stat.RawBytes
+		vr.CompressedSize = stat.StoredBytes
 		response.PartitionInfo = append(response.PartitionInfo, vr)
 		return true
 	})
 }
+
+// updateCompressions sets the codec of every partition to the one of its vol pushed by the master.
+func (s *DataNode) updateCompressions(compressions map[string]string) {
+	s.space.RangePartitions(func(partition DataPartition) bool {
+		codec, err := compress.ParseCodec(compressions[partition.VolumeID()])
+		if err != nil {
+			log.LogWarnf("action[updateCompressions] partition[%v] err[%v].", partition.ID(), err)
+		}
+		partition.GetExtentStore().SetCompression(codec)
+		return true
+	})
+}
+
+// updateVolKeys hands the data keys of its vol pushed by the master to every partition.
+func (s *DataNode) updateVolKeys(volKeys map[string][]*proto.VolKey) {
+	s.space.RangePartitions(func(partition DataPartition) bool {
+		keys, ok := volKeys[partition.VolumeID()]
+		if !ok {
+			return true
+		}
+		if err := partition.(*dataPartition).SetKeys(keys); err != nil {
+			log.LogWarnf("action[updateVolKeys] partition[%v] err[%v].", partition.ID(), err)
+		}
+		return true
+	})
+}
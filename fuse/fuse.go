@@ -1004,6 +1004,19 @@ loop:
 			IntrID: RequestID(in.Unique),
 		}
 
+	case opFallocate:
+		in := (*fallocateIn)(m.data())
+		if m.len() < unsafe.Sizeof(*in) {
+			goto corrupt
+		}
+		req = &FallocateRequest{
+			Header: m.Header(),
+			Handle: HandleID(in.Fh),
+			Offset: in.Offset,
+			Length: in.Length,
+			Mode:   in.Mode,
+		}
+
 	case opBmap:
 		panic("opBmap")
 
@@ -2264,6 +2277,28 @@ func (r *FsyncRequest) Respond() {
 	r.respond(buf)
 }
 
+// A FallocateRequest asks to allocate or deallocate the space of a range of an open file.
+type FallocateRequest struct {
+	Header `json:"-"`
+	Handle HandleID
+	Offset uint64
+	Length uint64
+	// Mode is the mode of the fallocate system call, as FALLOC_FL_PUNCH_HOLE.
+	Mode uint32
+}
+
+var _ = Request(&FallocateRequest{})
+
+func (r *FallocateRequest) String() string {
+	return fmt.Sprintf("Fallocate [%s] Handle %v off %d len %d mode %#x", &r.Header, r.Handle, r.Offset, r.Length, r.Mode)
+}
+
+// Respond replies to the request, indicating that the range is allocated or deallocated.
+func (r *FallocateRequest) Respond() {
+	buf := newBuffer(0)
+	r.respond(buf)
+}
+
 // An InterruptRequest is a request to interrupt another pending request. The
 // response to that request should return an error status of EINTR.
 type InterruptRequest struct {
@@ -387,6 +387,7 @@ const (
 	opDestroy     = 38
 	opIoctl       = 39 // Linux?
 	opPoll        = 40 // Linux?
+	opFallocate   = 43 // Linux?
 
 	// OS X
 	opSetvolname = 61
@@ -645,6 +646,14 @@ type fsyncIn struct {
 	_          uint32
 }
 
+type fallocateIn struct {
+	Fh     uint64
+	Offset uint64
+	Length uint64
+	Mode   uint32
+	_      uint32
+}
+
 type setxattrInCommon struct {
 	Size  uint32
 	Flags uint32
@@ -0,0 +1,158 @@
+package master
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+/*the admin tasks except heartbeat are recorded in the raft state machine,
+the new leader sends the unfinished ones again after a master failover*/
+
+//newAdminTaskRecord copies the task without the response,the record is never modified after stored
+func newAdminTaskRecord(t *proto.AdminTask) (record *proto.AdminTask) {
+	record = new(proto.AdminTask)
+	*record = *t
+	record.Response = nil
+	return
+}
+
+func (c *Cluster) getTaskSender(addr string) (sender *AdminTaskSender, err error) {
+	if dataNode, err := c.getDataNode(addr); err == nil {
+		return dataNode.Sender, nil
+	}
+	if metaNode, err := c.getMetaNode(addr); err == nil {
+		return metaNode.Sender, nil
+	}
+	return nil, errors.Annotatef(DataNodeNotFound, "%v", addr)
+}
+
+func (c *Cluster) trackAdminTask(sender *AdminTaskSender, t *proto.AdminTask) {
+	if t.IsHeartbeatTask() || sender.IsExist(t) {
+		return
+	}
+	//a task put again keeps its record
+	if record, err := c.getAdminTask(t.ID); err == nil && record.Status == t.Status {
+		return
+	}
+	c.putAdminTaskRecord(newAdminTaskRecord(t))
+}
+
+func (c *Cluster) putAdminTaskRecord(record *proto.AdminTask) {
+	if err := c.syncPutAdminTask(record); err != nil {
+		log.LogErrorf("action[putAdminTaskRecord] task[%v] persist failed,err:%v", record.ID, err)
+		return
+	}
+	c.adminTasks.Store(record.ID, record)
+}
+
+func (c *Cluster) finishAdminTask(t *proto.AdminTask) {
+	if _, ok := c.adminTasks.Load(t.ID); !ok {
+		return
+	}
+	if err := c.syncDeleteAdminTask(t); err != nil {
+		log.LogErrorf("action[finishAdminTask] task[%v] delete record failed,err:%v", t.ID, err)
+		return
+	}
+	c.adminTasks.Delete(t.ID)
+}
+
+func (c *Cluster) getAdminTask(id string) (t *proto.AdminTask, err error) {
+	value, ok := c.adminTasks.Load(id)
+	if !ok {
+		return nil, errors.Annotatef(AdminTaskNotFound, "%v", id)
+	}
+	return value.(*proto.AdminTask), nil
+}
+
+//getAdminTasks returns the recorded tasks,limited to the tasks sent to addr if addr is not empty
+func (c *Cluster) getAdminTasks(addr string) (tasks []*proto.AdminTask) {
+	tasks = make([]*proto.AdminTask, 0)
+	c.adminTasks.Range(func(key, value interface{}) bool {
+		t := value.(*proto.AdminTask)
+		if addr == "" || t.OperatorAddr == addr {
+			tasks = append(tasks, t)
+		}
+		return true
+	})
+	return
+}
+
+func (c *Cluster) cancelAdminTask(id string) (err error) {
+	var t *proto.AdminTask
+	if t, err = c.getAdminTask(id); err != nil {
+		return
+	}
+	if sender, err := c.getTaskSender(t.OperatorAddr); err == nil {
+		sender.DelTask(t)
+	}
+	if err = c.syncDeleteAdminTask(t); err != nil {
+		return
+	}
+	c.adminTasks.Delete(t.ID)
+	Warn(c.Name, fmt.Sprintf("clusterID[%v] admin task[%v] is canceled", c.Name, t.ID))
+	return
+}
+
+func (c *Cluster) startCheckAdminTasks() {
+	go func() {
+		for {
+			if c.partition.IsLeader() {
+				c.checkAdminTasks()
+			}
+			time.Sleep(time.Second * DefaultCheckAdminTaskIntervalSeconds)
+		}
+	}()
+}
+
+func (c *Cluster) checkAdminTasks() {
+	c.dataNodes.Range(func(addr, value interface{}) bool {
+		c.syncAdminTasksOfSender(value.(*DataNode).Sender)
+		return true
+	})
+	c.metaNodes.Range(func(addr, value interface{}) bool {
+		c.syncAdminTasksOfSender(value.(*MetaNode).Sender)
+		return true
+	})
+}
+
+//syncAdminTasksOfSender persists the status transitions of the tasks in the sender with their attempts
+//and errors so far,the retries in between are not persisted. the dead letters are kept in the record only
+func (c *Cluster) syncAdminTasksOfSender(sender *AdminTaskSender) {
+	for _, t := range sender.getTasks() {
+		task := t
+		record, err := c.getAdminTask(task.ID)
+		if err != nil || record.Status != task.Status {
+			c.putAdminTaskRecord(newAdminTaskRecord(&task))
+		}
+		if task.Status == proto.TaskDeadLetter {
+			sender.DelTask(&task)
+		}
+	}
+}
+
+//restoreAdminTasks puts the recorded tasks back to the senders after the master becomes leader,
+//the new leader starts the retries over
+func (c *Cluster) restoreAdminTasks() {
+	c.adminTasks.Range(func(key, value interface{}) bool {
+		record := value.(*proto.AdminTask)
+		if record.Status == proto.TaskDeadLetter {
+			return true
+		}
+		sender, err := c.getTaskSender(record.OperatorAddr)
+		if err != nil {
+			log.LogWarnf("action[restoreAdminTasks] task[%v] target node is gone,drop it", record.ID)
+			c.finishAdminTask(record)
+			return true
+		}
+		t := newAdminTaskRecord(record)
+		t.SendTime = 0
+		t.SendCount = 0
+		t.Status = proto.TaskStart
+		sender.PutTask(t)
+		return true
+	})
+}
@@ -0,0 +1,40 @@
+package master
+
+import (
+	"testing"
+
+	"github.com/tiglabs/baudstorage/proto"
+)
+
+func TestSyncAdminTasksPersistsTransitions(t *testing.T) {
+	c, p := newTestCluster()
+	sender := &AdminTaskSender{TaskMap: make(map[string]*proto.AdminTask)}
+	task := newDecommissionDiskTask("a:1", "/data1", false)
+	c.trackAdminTask(sender, task)
+	sender.TaskMap[task.ID] = task
+	if len(p.cmds) != 1 {
+		t.Fatalf("new task submitted %v times", len(p.cmds))
+	}
+	//a task put again keeps its record
+	c.trackAdminTask(&AdminTaskSender{TaskMap: make(map[string]*proto.AdminTask)}, task)
+	c.syncAdminTasksOfSender(sender)
+	if len(p.cmds) != 1 {
+		t.Fatalf("unchanged task submitted %v times", len(p.cmds))
+	}
+	//a retry is not a transition
+	task.SendCount++
+	task.UpdateTime++
+	task.LastError = "connection refused"
+	c.syncAdminTasksOfSender(sender)
+	if len(p.cmds) != 1 {
+		t.Fatalf("retried task submitted %v times", len(p.cmds))
+	}
+	task.Status = proto.TaskRunning
+	c.syncAdminTasksOfSender(sender)
+	if len(p.cmds) != 2 {
+		t.Fatalf("running task submitted %v times", len(p.cmds))
+	}
+	if record, err := c.getAdminTask(task.ID); err != nil || record.Status != proto.TaskRunning || record.LastError != task.LastError {
+		t.Fatalf("transition not recorded: %v %v", record, err)
+	}
+}
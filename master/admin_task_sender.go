@@ -77,12 +77,25 @@ func (sender *AdminTaskSender) doDeleteTasks() {
 	return
 }
 
-// the task which is time out will be delete
+// the heartbeat task which is time out will be delete,
+// the other tasks are marked as dead letter and removed after the cluster has persisted them
 func (sender *AdminTaskSender) getNeedDeleteTasks() (delTasks []*proto.AdminTask) {
 	sender.Lock()
 	defer sender.Unlock()
 	delTasks = make([]*proto.AdminTask, 0)
 	for _, task := range sender.TaskMap {
+		if !task.IsHeartbeatTask() {
+			if task.CheckTaskDeadLetter() {
+				task.Status = proto.TaskDeadLetter
+				task.UpdateTime = time.Now().Unix()
+				if task.LastError == "" {
+					task.LastError = "no response util time out"
+				}
+				Warn(sender.clusterID, fmt.Sprintf("clusterID[%v] %v has no response after %v times,become dead letter",
+					sender.clusterID, task.ID, task.SendCount))
+			}
+			continue
+		}
 		if task.CheckTaskTimeOut() {
 			log.LogWarnf(fmt.Sprintf("clusterID[%v] %v has no response util time out",
 				sender.clusterID, task.ID))
@@ -113,12 +126,14 @@ func (sender *AdminTaskSender) sendTasks(tasks []*proto.AdminTask) {
 			WarnBySpecialUmpKey(fmt.Sprintf("%v_%v_sendTask", sender.clusterID, UmpModuleName), msg)
 			sender.connPool.Put(conn, ForceCloseConnect)
 			sender.updateTaskInfo(task, false)
+			sender.setTaskError(task, err)
 			break
 		}
 		if err = sender.sendAdminTask(task, conn); err != nil {
 			log.LogError(fmt.Sprintf("send task %v to %v,err,%v", task.ToString(), sender.targetAddr, errors.ErrorStack(err)))
 			sender.connPool.Put(conn, ForceCloseConnect)
 			sender.updateTaskInfo(task, true)
+			sender.setTaskError(task, err)
 			continue
 		}
 		sender.connPool.Put(conn, NoCloseConnect)
@@ -126,7 +141,10 @@ func (sender *AdminTaskSender) sendTasks(tasks []*proto.AdminTask) {
 }
 
 func (sender *AdminTaskSender) updateTaskInfo(task *proto.AdminTask, connSuccess bool) {
+	sender.Lock()
+	defer sender.Unlock()
 	task.SendCount++
+	task.UpdateTime = time.Now().Unix()
 	if connSuccess {
 		task.SendTime = time.Now().Unix()
 		task.Status = proto.TaskRunning
@@ -134,6 +152,13 @@ func (sender *AdminTaskSender) updateTaskInfo(task *proto.AdminTask, connSuccess
 
 }
 
+func (sender *AdminTaskSender) setTaskError(task *proto.AdminTask, err error) {
+	sender.Lock()
+	defer sender.Unlock()
+	task.LastError = err.Error()
+	task.UpdateTime = time.Now().Unix()
+}
+
 func (sender *AdminTaskSender) buildPacket(task *proto.AdminTask) (packet *proto.Packet, err error) {
 	packet = proto.NewPacket()
 	packet.Opcode = task.OpCode
@@ -186,6 +211,19 @@ func (sender *AdminTaskSender) PutTask(t *proto.AdminTask) {
 	}
 }
 
+//getTasks returns a copy of the tasks which are not heartbeat
+func (sender *AdminTaskSender) getTasks() (tasks []proto.AdminTask) {
+	sender.Lock()
+	defer sender.Unlock()
+	tasks = make([]proto.AdminTask, 0)
+	for _, t := range sender.TaskMap {
+		if !t.IsHeartbeatTask() {
+			tasks = append(tasks, *t)
+		}
+	}
+	return
+}
+
 func (sender *AdminTaskSender) IsExist(t *proto.AdminTask) bool {
 	sender.Lock()
 	defer sender.Unlock()
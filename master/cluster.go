@@ -23,9 +23,14 @@ type Cluster struct {
 	fsm           *MetadataFsm
 	partition     raftstore.Partition
 	retainLogs    uint64
+	masterKey     []byte
 	idAlloc       *IDAllocator
 	t             *Topology
 	compactStatus bool
+	decommissions sync.Map
+	adminTasks    sync.Map
+	maintenances  sync.Map
+	scrubReports  sync.Map
 }
 
 func newCluster(name string, leaderInfo *LeaderInfo, fsm *MetadataFsm, partition raftstore.Partition) (c *Cluster) {
@@ -43,6 +48,10 @@ func newCluster(name string, leaderInfo *LeaderInfo, fsm *MetadataFsm, partition
 	c.startCheckReleaseDataPartitions()
 	c.startCheckHeartbeat()
 	c.startCheckMetaPartitions()
+	c.startCheckDecommissions()
+	c.startCheckAdminTasks()
+	c.startCheckMaintenances()
+	c.startCheckScrubs()
 	return
 }
 
@@ -136,10 +145,13 @@ func (c *Cluster) checkLeaderAddr() {
 
 func (c *Cluster) checkDataNodeHeartbeat() {
 	tasks := make([]*proto.AdminTask, 0)
+	volQos := c.getVolQosLimits()
+	compressions := c.getVolCompressions()
+	volKeys := c.getVolKeys()
 	c.dataNodes.Range(func(addr, dataNode interface{}) bool {
 		node := dataNode.(*DataNode)
 		node.checkHeartBeat()
-		task := node.generateHeartbeatTask(c.getMasterAddr())
+		task := node.generateHeartbeatTask(c.getMasterAddr(), volQos, compressions, volKeys, c.compactStatus)
 		tasks = append(tasks, task)
 		return true
 	})
@@ -148,10 +160,13 @@ func (c *Cluster) checkDataNodeHeartbeat() {
 
 func (c *Cluster) checkMetaNodeHeartbeat() {
 	tasks := make([]*proto.AdminTask, 0)
+	volQos := c.getVolQosLimits()
+	lifecycles := c.getVolLifecycles()
+	_, features := c.getClusterVersion()
 	c.metaNodes.Range(func(addr, metaNode interface{}) bool {
 		node := metaNode.(*MetaNode)
 		node.checkHeartbeat()
-		task := node.generateHeartbeatTask(c.getMasterAddr())
+		task := node.generateHeartbeatTask(c.getMasterAddr(), volQos, lifecycles, features)
 		tasks = append(tasks, task)
 		return true
 	})
@@ -176,15 +191,17 @@ func (c *Cluster) checkMetaPartitions() {
 	}
 }
 
-func (c *Cluster) addMetaNode(nodeAddr string) (id uint64, err error) {
+func (c *Cluster) addMetaNode(nodeAddr string, version uint32, features uint64) (id uint64, err error) {
 	var (
 		metaNode *MetaNode
 	)
 	if value, ok := c.metaNodes.Load(nodeAddr); ok {
 		metaNode = value.(*MetaNode)
+		metaNode.setVersion(version, features)
 		return metaNode.ID, nil
 	}
 	metaNode = NewMetaNode(nodeAddr, c.Name)
+	metaNode.setVersion(version, features)
 
 	if id, err = c.idAlloc.allocateMetaNodeID(); err != nil {
 		goto errDeal
@@ -203,13 +220,15 @@ errDeal:
 	return
 }
 
-func (c *Cluster) addDataNode(nodeAddr string) (err error) {
+func (c *Cluster) addDataNode(nodeAddr string, version uint32, features uint64) (err error) {
 	var dataNode *DataNode
-	if _, ok := c.dataNodes.Load(nodeAddr); ok {
+	if value, ok := c.dataNodes.Load(nodeAddr); ok {
+		value.(*DataNode).setVersion(version, features)
 		return
 	}
 
 	dataNode = NewDataNode(nodeAddr, c.Name)
+	dataNode.setVersion(version, features)
 	if err = c.syncAddDataNode(dataNode); err != nil {
 		goto errDeal
 	}
@@ -269,10 +288,13 @@ func (c *Cluster) deleteVol(name string) {
 	return
 }
 
-func (c *Cluster) createDataPartition(volName, partitionType string) (dp *DataPartition, err error) {
+/*createDataPartition creates a partition of the replica number of the vol,
+an ec partition spreads its k+m shards on ecDataNum+ecParityNum hosts instead*/
+func (c *Cluster) createDataPartition(volName, partitionType string, ecDataNum, ecParityNum uint8) (dp *DataPartition, err error) {
 	var (
 		vol         *Vol
 		partitionID uint64
+		replicaNum  uint8
 		tasks       []*proto.AdminTask
 		targetHosts []string
 	)
@@ -281,18 +303,33 @@ func (c *Cluster) createDataPartition(volName, partitionType string) (dp *DataPa
 	if vol, err = c.getVol(volName); err != nil {
 		goto errDeal
 	}
-	if targetHosts, err = c.ChooseTargetDataHosts(int(vol.dpReplicaNum)); err != nil {
+	replicaNum = vol.dpReplicaNum
+	if partitionType == proto.ECPartition {
+		if err = c.checkFeature(proto.FeatureEC); err != nil {
+			goto errDeal
+		}
+		replicaNum = ecDataNum + ecParityNum
+	}
+	if partitionType == proto.ECPartition {
+		targetHosts, err = c.ChooseTargetECHosts(int(ecDataNum), int(ecParityNum), vol.placementPolicy)
+	} else {
+		targetHosts, err = c.ChooseTargetDataHosts(int(replicaNum), vol.placementPolicy)
+	}
+	if err != nil {
 		goto errDeal
 	}
 	if partitionID, err = c.idAlloc.allocateDataPartitionID(); err != nil {
 		goto errDeal
 	}
-	dp = newDataPartition(partitionID, vol.dpReplicaNum, partitionType, volName)
+	dp = newDataPartition(partitionID, replicaNum, partitionType, volName)
+	if partitionType == proto.ECPartition {
+		dp.ECDataNum = ecDataNum
+	}
 	dp.PersistenceHosts = targetHosts
 	if err = c.syncAddDataPartition(volName, dp); err != nil {
 		goto errDeal
 	}
-	tasks = dp.GenerateCreateTasks()
+	tasks = dp.GenerateCreateTasks(vol.getKeys())
 	c.putDataNodeTasks(tasks)
 	vol.dataPartitions.putDataPartition(dp)
 
@@ -304,51 +341,21 @@ errDeal:
 	return
 }
 
-func (c *Cluster) ChooseTargetDataHosts(replicaNum int) (hosts []string, err error) {
+func (c *Cluster) ChooseTargetDataHosts(replicaNum int, policy string) (hosts []string, err error) {
 	var (
-		masterAddr []string
-		addrs      []string
-		racks      []*Rack
-		rack       *Rack
+		addrs  []string
+		zones  []*Zone
+		counts []int
 	)
 	hosts = make([]string, 0)
-	if c.t.isSingleRack() {
-		var newHosts []string
-		if rack, err = c.t.getRack(c.t.racks[0]); err != nil {
-			return nil, errors.Trace(err)
-		}
-		if newHosts, err = rack.getAvailDataNodeHosts(hosts, replicaNum); err != nil {
-			return nil, errors.Trace(err)
-		}
-		hosts = newHosts
-		return
-	}
-
-	if racks, err = c.t.allocRacks(replicaNum, nil); err != nil {
+	if zones, counts, err = c.t.allocZones(policy, replicaNum, nil, (*Zone).canWriteForDataNode); err != nil {
 		return nil, errors.Trace(err)
 	}
-
-	if len(racks) == 2 {
-		masterRack := racks[0]
-		slaveRack := racks[1]
-		masterReplicaNum := replicaNum/2 + 1
-		slaveReplicaNum := replicaNum - masterReplicaNum
-		if masterAddr, err = masterRack.getAvailDataNodeHosts(hosts, masterReplicaNum); err != nil {
-			return nil, errors.Trace(err)
-		}
-		hosts = append(hosts, masterAddr...)
-		if addrs, err = slaveRack.getAvailDataNodeHosts(hosts, slaveReplicaNum); err != nil {
+	for index, zone := range zones {
+		if addrs, err = zone.getAvailDataNodeHosts(hosts, counts[index]); err != nil {
 			return nil, errors.Trace(err)
 		}
 		hosts = append(hosts, addrs...)
-	} else if len(racks) == replicaNum {
-		for index := 0; index < replicaNum; index++ {
-			rack := racks[index]
-			if addrs, err = rack.getAvailDataNodeHosts(hosts, 1); err != nil {
-				return nil, errors.Trace(err)
-			}
-			hosts = append(hosts, addrs...)
-		}
 	}
 	if len(hosts) != replicaNum {
 		return nil, NoAnyDataNodeForCreateDataPartition
@@ -356,6 +363,55 @@ func (c *Cluster) ChooseTargetDataHosts(replicaNum int) (hosts []string, err err
 	return
 }
 
+/*chooseDataHostForOffline choose the data node which takes over a replica from offlineNode.
+The new host is taken from the rack of offlineNode first, then from any rack of its zone,
+and only when the whole zone can't be written it falls back to a zone allowed by the
+placement policy of the vol.*/
+func (c *Cluster) chooseDataHostForOffline(vol *Vol, offlineNode *DataNode, excludeHosts []string) (newAddr string, err error) {
+	var (
+		zone         *Zone
+		rack         *Rack
+		zones        []*Zone
+		newHosts     []string
+		excludeZones []string
+	)
+	if zone, err = c.t.getZone(offlineNode.ZoneName); err == nil {
+		if rack, err = zone.getRack(offlineNode.RackName); err == nil {
+			if newHosts, err = rack.getAvailDataNodeHosts(excludeHosts, 1); err == nil {
+				return newHosts[0], nil
+			}
+		}
+		if newHosts, err = zone.getAvailDataNodeHosts(excludeHosts, 1); err == nil {
+			return newHosts[0], nil
+		}
+	}
+	excludeZones = []string{offlineNode.ZoneName}
+	if vol.placementPolicy == PlacementPolicyZone {
+		excludeZones = append(excludeZones, c.getDataNodeZones(excludeHosts)...)
+	}
+	if zones, _, err = c.t.allocZones(PlacementPolicyRack, 1, excludeZones, (*Zone).canWriteForDataNode); err != nil {
+		return "", errors.Trace(err)
+	}
+	if newHosts, err = zones[0].getAvailDataNodeHosts(excludeHosts, 1); err != nil {
+		return "", errors.Trace(err)
+	}
+	return newHosts[0], nil
+}
+
+func (c *Cluster) getDataNodeZones(addrs []string) (zones []string) {
+	zones = make([]string, 0)
+	for _, addr := range addrs {
+		dataNode, err := c.getDataNode(addr)
+		if err != nil {
+			continue
+		}
+		if !contains(zones, dataNode.ZoneName) {
+			zones = append(zones, dataNode.ZoneName)
+		}
+	}
+	return
+}
+
 func (c *Cluster) getDataNode(addr string) (dataNode *DataNode, err error) {
 	value, ok := c.dataNodes.Load(addr)
 	if !ok {
@@ -376,56 +432,36 @@ func (c *Cluster) getMetaNode(addr string) (metaNode *MetaNode, err error) {
 	return
 }
 
-func (c *Cluster) dataNodeOffLine(dataNode *DataNode) {
-	msg := fmt.Sprintf("action[dataNodeOffLine], Node[%v] OffLine", dataNode.Addr)
-	log.LogWarn(msg)
-
-	safeVols := c.copyVols()
-	for _, vol := range safeVols {
-		for _, dp := range vol.dataPartitions.dataPartitions {
-			c.dataPartitionOffline(dataNode.Addr, vol.Name, dp, DataNodeOfflineInfo)
-		}
-	}
-	if err := c.syncDeleteDataNode(dataNode); err != nil {
-		msg = fmt.Sprintf("action[dataNodeOffLine],clusterID[%v] Node[%v] OffLine failed,err[%v]",
-			c.Name, dataNode.Addr, err)
-		Warn(c.Name, msg)
-		return
-	}
-	c.delDataNodeFromCache(dataNode)
-	msg = fmt.Sprintf("action[dataNodeOffLine],clusterID[%v] Node[%v] OffLine success",
-		c.Name, dataNode.Addr)
-	Warn(c.Name, msg)
-}
-
 func (c *Cluster) delDataNodeFromCache(dataNode *DataNode) {
 	c.dataNodes.Delete(dataNode.Addr)
+	c.t.removeDataNode(dataNode)
 	go dataNode.clean()
 }
 
-func (c *Cluster) dataPartitionOffline(offlineAddr, volName string, dp *DataPartition, errMsg string) {
+func (c *Cluster) dataPartitionOffline(offlineAddr, volName string, dp *DataPartition, errMsg string) (err error) {
 	var (
-		newHosts []string
 		newAddr  string
 		msg      string
 		tasks    []*proto.AdminTask
 		task     *proto.AdminTask
-		err      error
 		dataNode *DataNode
-		rack     *Rack
 		vol      *Vol
 	)
+	//the tasks are put after the lock of dp is released,their records are submitted to raft
+	defer func() {
+		c.putDataNodeTasks(tasks)
+	}()
 	dp.Lock()
 	defer dp.Unlock()
 	if ok := dp.isInPersistenceHosts(offlineAddr); !ok {
-		return
+		return errors.Annotatef(OfflineSkipped, "partitionID:%v has no replica on %v", dp.PartitionID, offlineAddr)
 	}
 
 	if vol, err = c.getVol(volName); err != nil {
 		goto errDeal
 	}
 
-	if err = dp.hasMissOne(int(vol.dpReplicaNum)); err != nil {
+	if err = dp.hasMissOne(int(dp.ReplicaNum)); err != nil {
 		goto errDeal
 	}
 	if err = dp.canOffLine(offlineAddr); err != nil {
@@ -438,15 +474,12 @@ func (c *Cluster) dataPartitionOffline(offlineAddr, volName string, dp *DataPart
 	}
 
 	if dataNode.RackName == "" {
-		return
-	}
-	if rack, err = c.t.getRack(dataNode.RackName); err != nil {
+		err = errors.Annotatef(OfflineSkipped, "data node %v reports no rack", offlineAddr)
 		goto errDeal
 	}
-	if newHosts, err = rack.getAvailDataNodeHosts(dp.PersistenceHosts, 1); err != nil {
+	if newAddr, err = c.chooseDataHostForOffline(vol, dataNode, dp.PersistenceHosts); err != nil {
 		goto errDeal
 	}
-	newAddr = newHosts[0]
 	if err = dp.updateForOffline(offlineAddr, newAddr, volName, c); err != nil {
 		goto errDeal
 	}
@@ -456,7 +489,6 @@ func (c *Cluster) dataPartitionOffline(offlineAddr, volName string, dp *DataPart
 	task = dp.GenerateDeleteTask(offlineAddr)
 	tasks = make([]*proto.AdminTask, 0)
 	tasks = append(tasks, task)
-	c.putDataNodeTasks(tasks)
 	goto errDeal
 errDeal:
 	msg = fmt.Sprintf(errMsg+" clusterID[%v] partitionID:%v  on Node:%v  "+
@@ -469,35 +501,15 @@ errDeal:
 	return
 }
 
-func (c *Cluster) metaNodeOffLine(metaNode *MetaNode) {
-	msg := fmt.Sprintf("action[metaNodeOffLine],clusterID[%v] Node[%v] OffLine", c.Name, metaNode.Addr)
-	log.LogWarn(msg)
-
-	safeVols := c.copyVols()
-	for _, vol := range safeVols {
-		for _, mp := range vol.MetaPartitions {
-			c.metaPartitionOffline(vol.Name, metaNode.Addr, mp.PartitionID)
-		}
-	}
-	if err := c.syncDeleteMetaNode(metaNode); err != nil {
-		msg = fmt.Sprintf("action[metaNodeOffLine],clusterID[%v] Node[%v] OffLine failed,err[%v]",
-			c.Name, metaNode.Addr, err)
-		Warn(c.Name, msg)
-		return
-	}
-	c.delMetaNodeFromCache(metaNode)
-	msg = fmt.Sprintf("action[metaNodeOffLine],clusterID[%v] Node[%v] OffLine success", c.Name, metaNode.Addr)
-	Warn(c.Name, msg)
-}
-
 func (c *Cluster) delMetaNodeFromCache(metaNode *MetaNode) {
 	c.metaNodes.Delete(metaNode.Addr)
+	c.t.removeMetaNode(metaNode)
 	go metaNode.clean()
 }
 
-func (c *Cluster) createVol(name, volType string, replicaNum uint8) (err error) {
+func (c *Cluster) createVol(name, volType, placementPolicy string, replicaNum uint8) (err error) {
 	var vol *Vol
-	if vol, err = c.createVolInternal(name, volType, replicaNum); err != nil {
+	if vol, err = c.createVolInternal(name, volType, placementPolicy, replicaNum); err != nil {
 		goto errDeal
 	}
 
@@ -517,12 +529,12 @@ errDeal:
 	return
 }
 
-func (c *Cluster) createVolInternal(name, volType string, replicaNum uint8) (vol *Vol, err error) {
+func (c *Cluster) createVolInternal(name, volType, placementPolicy string, replicaNum uint8) (vol *Vol, err error) {
 	if _, err = c.getVol(name); err == nil {
 		err = hasExist(name)
 		goto errDeal
 	}
-	vol = NewVol(name, volType, replicaNum)
+	vol = NewVol(name, volType, placementPolicy, replicaNum)
 	if err = c.syncAddVol(vol); err != nil {
 		goto errDeal
 	}
@@ -575,7 +587,7 @@ func (c *Cluster) CreateMetaPartition(volName string, start, end uint64) (err er
 		return errors.Annotatef(err, "get vol [%v] err", volName)
 	}
 
-	if hosts, peers, err = c.ChooseTargetMetaHosts(int(vol.mpReplicaNum)); err != nil {
+	if hosts, peers, err = c.ChooseTargetMetaHosts(int(vol.mpReplicaNum), vol.placementPolicy); err != nil {
 		return errors.Trace(err)
 	}
 	log.LogInfof("target meta hosts:%v,peers:%v", hosts, peers)
@@ -593,44 +605,96 @@ func (c *Cluster) CreateMetaPartition(volName string, start, end uint64) (err er
 	return
 }
 
-func (c *Cluster) hasEnoughWritableMetaHosts(replicaNum int) bool {
-	maxTotal := c.GetMetaNodeMaxTotal()
-	excludeHosts := make([]string, 0)
-	nodeTabs, _ := c.GetAvailCarryMetaNodeTab(maxTotal, excludeHosts)
-	if nodeTabs != nil && len(nodeTabs) >= replicaNum {
-		return true
+func (c *Cluster) hasEnoughWritableMetaHosts(replicaNum int, policy string) bool {
+	if policy == PlacementPolicyRack {
+		return c.allMetaNodesZone().canWriteForMetaNode(replicaNum)
+	}
+	if _, _, err := c.t.allocZones(policy, replicaNum, nil, (*Zone).canWriteForMetaNode); err != nil {
+		return false
 	}
-	return false
+	return true
+}
+
+/*allMetaNodesZone returns a zone holding all the meta nodes. The meta nodes do not report
+their racks, so the replicas of the vols placed by racks are chosen from all the meta nodes
+regardless of their zones.*/
+func (c *Cluster) allMetaNodesZone() (zone *Zone) {
+	zone = NewZone("all")
+	c.metaNodes.Range(func(addr, metaNode interface{}) bool {
+		zone.metaNodes.Store(addr, metaNode)
+		return true
+	})
+	return
 }
 
-func (c *Cluster) ChooseTargetMetaHosts(replicaNum int) (hosts []string, peers []proto.Peer, err error) {
+func (c *Cluster) ChooseTargetMetaHosts(replicaNum int, policy string) (hosts []string, peers []proto.Peer, err error) {
 	var (
-		masterAddr []string
-		slaveAddrs []string
-		masterPeer []proto.Peer
-		slavePeers []proto.Peer
+		addrs     []string
+		zones     []*Zone
+		counts    []int
+		zonePeers []proto.Peer
 	)
 	hosts = make([]string, 0)
-	if masterAddr, masterPeer, err = c.getAvailMetaNodeHosts(hosts, 1); err != nil {
+	peers = make([]proto.Peer, 0)
+	if policy == PlacementPolicyRack {
+		zones, counts = []*Zone{c.allMetaNodesZone()}, []int{replicaNum}
+	} else if zones, counts, err = c.t.allocZones(policy, replicaNum, nil, (*Zone).canWriteForMetaNode); err != nil {
 		return nil, nil, errors.Trace(err)
 	}
-	peers = append(peers, masterPeer...)
-	hosts = append(hosts, masterAddr[0])
-	otherReplica := replicaNum - 1
-	if otherReplica == 0 {
-		return
-	}
-	if slaveAddrs, slavePeers, err = c.getAvailMetaNodeHosts(hosts, otherReplica); err != nil {
-		return nil, nil, errors.Trace(err)
+	for index, zone := range zones {
+		if addrs, zonePeers, err = zone.getAvailMetaNodeHosts(hosts, counts[index]); err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+		hosts = append(hosts, addrs...)
+		peers = append(peers, zonePeers...)
 	}
-	hosts = append(hosts, slaveAddrs...)
-	peers = append(peers, slavePeers...)
 	if len(hosts) != replicaNum {
 		return nil, nil, NoAnyMetaNodeForCreateMetaPartition
 	}
 	return
 }
 
+/*chooseMetaHostForOffline choose the meta node which takes over a replica from offlineAddr,
+preferring the zone of the offline node so the failure domains of the partition are kept.*/
+func (c *Cluster) chooseMetaHostForOffline(vol *Vol, offlineAddr string, excludeHosts []string) (newHosts []string, newPeers []proto.Peer, err error) {
+	var (
+		zone         *Zone
+		zones        []*Zone
+		metaNode     *MetaNode
+		excludeZones []string
+	)
+	excludeZones = make([]string, 0)
+	if metaNode, err = c.getMetaNode(offlineAddr); err == nil {
+		if zone, err = c.t.getZone(metaNode.ZoneName); err == nil {
+			if newHosts, newPeers, err = zone.getAvailMetaNodeHosts(excludeHosts, 1); err == nil {
+				return
+			}
+		}
+		excludeZones = append(excludeZones, metaNode.ZoneName)
+	}
+	if vol.placementPolicy == PlacementPolicyZone {
+		excludeZones = append(excludeZones, c.getMetaNodeZones(excludeHosts)...)
+	}
+	if zones, _, err = c.t.allocZones(PlacementPolicyRack, 1, excludeZones, (*Zone).canWriteForMetaNode); err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	return zones[0].getAvailMetaNodeHosts(excludeHosts, 1)
+}
+
+func (c *Cluster) getMetaNodeZones(addrs []string) (zones []string) {
+	zones = make([]string, 0)
+	for _, addr := range addrs {
+		metaNode, err := c.getMetaNode(addr)
+		if err != nil {
+			continue
+		}
+		if !contains(zones, metaNode.ZoneName) {
+			zones = append(zones, metaNode.ZoneName)
+		}
+	}
+	return
+}
+
 func (c *Cluster) DataNodeCount() (len int) {
 
 	c.dataNodes.Range(func(key, value interface{}) bool {
@@ -644,7 +708,8 @@ func (c *Cluster) getAllDataNodes() (dataNodes []DataNodeView) {
 	dataNodes = make([]DataNodeView, 0)
 	c.dataNodes.Range(func(addr, node interface{}) bool {
 		dataNode := node.(*DataNode)
-		dataNodes = append(dataNodes, DataNodeView{Addr: dataNode.Addr, Status: dataNode.isActive})
+		dataNodes = append(dataNodes, DataNodeView{Addr: dataNode.Addr, Status: dataNode.isActive, Version: dataNode.Version,
+			HealthScore: dataNode.Health.Score})
 		return true
 	})
 	return
@@ -684,7 +749,8 @@ func (c *Cluster) getAllMetaNodes() (metaNodes []MetaNodeView) {
 	metaNodes = make([]MetaNodeView, 0)
 	c.metaNodes.Range(func(addr, node interface{}) bool {
 		metaNode := node.(*MetaNode)
-		metaNodes = append(metaNodes, MetaNodeView{ID: metaNode.ID, Addr: metaNode.Addr, Status: metaNode.IsActive})
+		metaNodes = append(metaNodes, MetaNodeView{ID: metaNode.ID, Addr: metaNode.Addr, Status: metaNode.IsActive,
+			Version: metaNode.Version, HealthScore: metaNode.Health.Score})
 		return true
 	})
 	return
@@ -19,6 +19,7 @@ func (c *Cluster) putDataNodeTasks(tasks []*proto.AdminTask) {
 		if node, err := c.getDataNode(t.OperatorAddr); err != nil {
 			log.LogWarn(fmt.Sprintf("action[putTasks],nodeAddr:%v,taskID:%v,err:%v", t.OperatorAddr, t.ID, err))
 		} else {
+			c.trackAdminTask(node.Sender, t)
 			node.Sender.PutTask(t)
 		}
 	}
@@ -33,6 +34,7 @@ func (c *Cluster) putMetaNodeTasks(tasks []*proto.AdminTask) {
 		if node, err := c.getMetaNode(t.OperatorAddr); err != nil {
 			log.LogWarn(fmt.Sprintf("action[putTasks],nodeAddr:%v,taskID:%v,err:%v", t.OperatorAddr, t.ID, err.Error()))
 		} else {
+			c.trackAdminTask(node.Sender, t)
 			node.Sender.PutTask(t)
 		}
 	}
@@ -93,7 +95,7 @@ func (c *Cluster) metaPartitionOffline(volName, nodeAddr string, partitionID uin
 		goto errDeal
 	}
 
-	if newHosts, newPeers, err = c.getAvailMetaNodeHosts(mp.PersistenceHosts, 1); err != nil {
+	if newHosts, newPeers, err = c.chooseMetaHostForOffline(vol, nodeAddr, mp.PersistenceHosts); err != nil {
 		goto errDeal
 	}
 
@@ -173,6 +175,7 @@ func (c *Cluster) dealMetaNodeTaskResponse(nodeAddr string, task *proto.AdminTas
 		goto errDeal
 	}
 	metaNode.Sender.DelTask(task)
+	c.finishAdminTask(task)
 	if err = UnmarshalTaskResponse(task); err != nil {
 		goto errDeal
 	}
@@ -321,8 +324,17 @@ func (c *Cluster) dealMetaNodeHeartbeatResp(nodeAddr string, resp *proto.MetaNod
 		goto errDeal
 	}
 
+	if metaNode.ZoneName != "" && metaNode.ZoneName != zoneNameOf(resp.ZoneName) {
+		Warn(c.Name, fmt.Sprintf("ClusterID[%s] MetaNode[%v] zone from [%v] to [%v]!",
+			c.Name, nodeAddr, metaNode.ZoneName, zoneNameOf(resp.ZoneName)))
+		c.t.removeMetaNode(metaNode)
+	}
 	metaNode.updateMetric(resp, c.cfg.MetaNodeThreshold)
 	metaNode.setNodeAlive()
+	if old, score := metaNode.updateHealth(resp.Stats); old != score {
+		c.checkNodeHealth("metaNode", nodeAddr, metaNode.isInMaintenance(), old, score, metaNode.getHealth())
+	}
+	c.t.putMetaNode(metaNode)
 	c.UpdateMetaNode(metaNode, resp.MetaPartitionInfo, metaNode.isArriveThreshold())
 	metaNode.metaPartitionInfos = nil
 	logMsg = fmt.Sprintf("action[dealMetaNodeHeartbeatResp],metaNode:%v ReportTime:%v  success", metaNode.Addr, time.Now().Unix())
@@ -349,6 +361,7 @@ func (c *Cluster) dealDataNodeTaskResponse(nodeAddr string, task *proto.AdminTas
 		goto errDeal
 	}
 	dataNode.Sender.DelTask(task)
+	c.finishAdminTask(task)
 	if err = UnmarshalTaskResponse(task); err != nil {
 		goto errDeal
 	}
@@ -366,6 +379,18 @@ func (c *Cluster) dealDataNodeTaskResponse(nodeAddr string, task *proto.AdminTas
 	case proto.OpDataNodeHeartbeat:
 		response := task.Response.(*proto.DataNodeHeartBeatResponse)
 		err = c.dealDataNodeHeartbeatResp(task.OperatorAddr, response)
+	case proto.OpDecommissionDisk:
+		response := task.Response.(*proto.DecommissionDiskResponse)
+		err = c.dealDecommissionDiskResponse(task.OperatorAddr, response)
+	case proto.OpScrubDataPartition:
+		response := task.Response.(*proto.ScrubDataPartitionResponse)
+		err = c.dealScrubDataPartitionResponse(task.OperatorAddr, response)
+	case proto.OpRepairFile:
+		response := task.Response.(*proto.RepairFileResponse)
+		err = c.dealRepairFileResponse(task.OperatorAddr, response)
+	case proto.OpSetPartitionStatus:
+		response := task.Response.(*proto.SetPartitionStatusResponse)
+		err = c.dealSetPartitionStatusResponse(task.OperatorAddr, response)
 	default:
 		err = fmt.Errorf(fmt.Sprintf("unknown operate code %v", task.OpCode))
 		goto errDeal
@@ -381,6 +406,17 @@ errDeal:
 	return
 }
 
+func (c *Cluster) dealDecommissionDiskResponse(nodeAddr string, resp *proto.DecommissionDiskResponse) (err error) {
+	c.recordLocalMigrateFailures(nodeAddr, resp.DiskPath, resp.Failures)
+	if resp.Status == proto.TaskSuccess {
+		log.LogInfof("action[dealDecommissionDiskResponse] nodeAddr[%v] disk[%v] is decommissioned", nodeAddr, resp.DiskPath)
+		return
+	}
+	Warn(c.Name, fmt.Sprintf("clusterID[%v] dataNode[%v] decommission disk[%v] failed,err[%v]",
+		c.Name, nodeAddr, resp.DiskPath, resp.Result))
+	return
+}
+
 func (c *Cluster) dealCreateDataPartitionResponse(t *proto.AdminTask, resp *proto.CreateDataPartitionResponse) (err error) {
 	log.LogInfof("action[dealCreateDataPartitionResponse] receive resp from nodeAddr[%v],pid[%v]", t.OperatorAddr, resp.PartitionId)
 	if resp.Status == proto.TaskSuccess {
@@ -465,7 +501,6 @@ func (c *Cluster) dealDataNodeHeartbeatResp(nodeAddr string, resp *proto.DataNod
 	var (
 		dataNode *DataNode
 		logMsg   string
-		oldRack  *Rack
 	)
 	log.LogInfof("action[dealDataNodeHeartbeatResp] clusterID[%v] receive dataNode[%v] heartbeat, ", c.Name, nodeAddr)
 	if resp.Status != proto.TaskSuccess {
@@ -478,18 +513,17 @@ func (c *Cluster) dealDataNodeHeartbeatResp(nodeAddr string, resp *proto.DataNod
 		goto errDeal
 	}
 
-	if dataNode.RackName != "" && dataNode.RackName != resp.RackName {
-		Warn(c.Name, fmt.Sprintf("ClusterID[%s] DataNode[%v] rack from [%v] to [%v]!",
-			c.Name, nodeAddr, dataNode.RackName, resp.RackName))
-		if oldRack, err = c.t.getRack(dataNode.RackName); err == nil {
-			oldRack.RemoveDataNode(dataNode.Addr)
-		}
-		dataNode.RackName = resp.RackName
-		c.t.putDataNode(dataNode)
+	if dataNode.RackName != "" && (dataNode.RackName != resp.RackName || dataNode.ZoneName != zoneNameOf(resp.ZoneName)) {
+		Warn(c.Name, fmt.Sprintf("ClusterID[%s] DataNode[%v] zone/rack from [%v/%v] to [%v/%v]!",
+			c.Name, nodeAddr, dataNode.ZoneName, dataNode.RackName, zoneNameOf(resp.ZoneName), resp.RackName))
+		c.t.removeDataNode(dataNode)
 	}
 
 	dataNode.UpdateNodeMetric(resp)
 	dataNode.setNodeAlive()
+	if old, score := dataNode.updateHealth(resp.Stats); old != score {
+		c.checkNodeHealth("dataNode", nodeAddr, dataNode.isInMaintenance(), old, score, dataNode.getHealth())
+	}
 	c.t.putDataNode(dataNode)
 	c.UpdateDataNode(dataNode, resp.PartitionInfo)
 	dataNode.dataPartitionInfos = nil
@@ -559,7 +593,7 @@ func (c *Cluster) updateEnd(mp *MetaPartition, mr *proto.MetaPartitionReport, th
 		Warn(c.Name, fmt.Sprintf("mpid[%v],start[%v],mrStart[%v],addr[%v]", mp.PartitionID, mp.Start, mr.Start, metaNode.Addr))
 	}
 
-	hasEnough := c.hasEnoughWritableMetaHosts(int(vol.mpReplicaNum))
+	hasEnough := c.hasEnoughWritableMetaHosts(int(vol.mpReplicaNum), vol.placementPolicy)
 	if mp.End == DefaultMaxMetaPartitionInodeID && hasEnough {
 		var end uint64
 		if mr.MaxInodeID <= 0 {
@@ -40,6 +40,19 @@ const (
 	DefaultMetaPartitionWarnInterval            = 10 * 60
 	DefaultMetaPartitionThreshold       float32 = 0.75
 	DefaultMetaPartitionCountOnEachNode         = 100
+	DefaultCheckDecommissionIntervalSeconds     = 30
+	DefaultDecommissionBatchCount               = 10
+	DefaultDecommissionRetainSeconds            = 7 * 24 * 3600
+	DefaultCheckAdminTaskIntervalSeconds        = 10
+	DefaultCheckMaintenanceIntervalSeconds      = 30
+	DefaultMaintenanceSeconds                   = 60 * 60
+	DefaultMaxMaintenanceSeconds                = 24 * 3600
+	DefaultCheckScrubIntervalSeconds            = 60
+	DefaultScrubIntervalSeconds                 = 30 * 24 * 3600
+	DefaultScrubReadBytesPerSec                 = 20 * 1024 * 1024
+	DefaultScrubBatchCount                      = 5
+	DefaultScrubPartitionTimeOutSec             = 12 * 3600
+	DefaultScrubReportFileLimit                 = 1000
 )
 
 //AddrDatabase ...
@@ -59,6 +72,12 @@ type ClusterConfig struct {
 	everyLoadDataPartitionCount          int
 	replicaNum                           int
 	MetaNodeThreshold                    float32
+	DecommissionBatchCount               int
+	DecommissionRetainSeconds            int64
+	MaxMaintenanceSeconds                int64
+	ScrubIntervalSeconds                 int64
+	ScrubReadBytesPerSec                 int64
+	ScrubBatchCount                      int
 
 	peers     []raftstore.PeerAddress
 	peerAddrs []string
@@ -78,6 +97,12 @@ func NewClusterConfig() (cfg *ClusterConfig) {
 	cfg.everyLoadDataPartitionCount = DefaultEveryLoadDataPartitionCount
 	cfg.LoadDataPartitionFrequencyTime = DefaultLoadDataPartitionFrequencyTime
 	cfg.MetaNodeThreshold = DefaultMetaPartitionThreshold
+	cfg.DecommissionBatchCount = DefaultDecommissionBatchCount
+	cfg.DecommissionRetainSeconds = DefaultDecommissionRetainSeconds
+	cfg.MaxMaintenanceSeconds = DefaultMaxMaintenanceSeconds
+	cfg.ScrubIntervalSeconds = DefaultScrubIntervalSeconds
+	cfg.ScrubReadBytesPerSec = DefaultScrubReadBytesPerSec
+	cfg.ScrubBatchCount = DefaultScrubBatchCount
 	return
 }
 
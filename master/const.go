@@ -10,6 +10,44 @@ const (
 	ParaStart             = "start"
 	ParaEnable            = "enable"
 	ParaThreshold         = "threshold"
+	ParaPlacementPolicy   = "policy"
+	ParaDiskPath          = "disk"
+	ParaMigrateLocal      = "local"
+	ParaStale             = "stale"
+	ParaDuration          = "duration"
+	ParaReason            = "reason"
+	ParaRate              = "rate"
+	ParaStatus            = "status"
+	ParaReadIOPS          = "readIops"
+	ParaWriteIOPS         = "writeIops"
+	ParaReadBandwidth     = "readBandwidth"
+	ParaWriteBandwidth    = "writeBandwidth"
+	ParaMetaOPS           = "metaOps"
+	ParaColdAfterDays     = "coldAfterDays"
+	ParaCodec             = "codec"
+	ParaVersion           = "version"
+	ParaFeatures          = "features"
+	ParaECDataNum         = "ecData"
+	ParaECParityNum       = "ecParity"
+)
+
+const (
+	//the header marks a request proxied by a follower,the leader never proxies it again
+	HeaderProxiedBy = "X-Master-Proxied-By"
+	//a follower serves the stale read only if it lags behind the commit less than the entries
+	StaleReadMaxLagEntries = 100
+	//a follower serves the stale read only within the seconds since it learnt the commit of the leader
+	StaleReadLeaseSec = 10
+	//a follower asks the leader for its commit every the seconds
+	StaleReadLeaseRenewSec = 3
+)
+
+const (
+	DefaultZoneName = "default"
+
+	PlacementPolicyRack      = "rack"
+	PlacementPolicyZone      = "zone"
+	PlacementPolicyCrossZone = "crossZone"
 )
 
 const (
@@ -21,10 +21,13 @@ type DataNode struct {
 	Total                     uint64 `json:"TotalWeight"`
 	Used                      uint64 `json:"UsedWeight"`
 	Available                 uint64
+	ZoneName                  string `json:"Zone"`
 	RackName                  string `json:"Rack"`
 	Addr                      string
 	ReportTime                time.Time
 	isActive                  bool
+	Draining                  bool
+	MaintenanceEnd            int64
 	sync.RWMutex
 	Ratio              float64
 	SelectCount        uint64
@@ -32,6 +35,9 @@ type DataNode struct {
 	Sender             *AdminTaskSender
 	dataPartitionInfos []*proto.PartitionReport
 	DataPartitionCount uint32
+	Version            uint32
+	Features           uint64
+	Health             NodeHealth
 }
 
 func NewDataNode(addr, clusterID string) (dataNode *DataNode) {
@@ -40,6 +46,7 @@ func NewDataNode(addr, clusterID string) (dataNode *DataNode) {
 	dataNode.Total = 1
 	dataNode.Addr = addr
 	dataNode.Sender = NewAdminTaskSender(dataNode.Addr, clusterID)
+	dataNode.Health = newNodeHealth()
 	return
 }
 
@@ -48,6 +55,9 @@ func (dataNode *DataNode) checkHeartBeat() {
 	dataNode.Lock()
 	defer dataNode.Unlock()
 	if time.Since(dataNode.ReportTime) > time.Second*time.Duration(DefaultNodeTimeOutSec) {
+		if dataNode.isActive && !dataNode.inMaintenance() {
+			dataNode.Health.addFlap(time.Now())
+		}
 		dataNode.isActive = false
 	}
 
@@ -71,9 +81,12 @@ func (dataNode *DataNode) UpdateNodeMetric(resp *proto.DataNodeHeartBeatResponse
 	dataNode.Total = resp.Total
 	dataNode.Used = resp.Used
 	dataNode.Available = resp.Available
+	dataNode.ZoneName = zoneNameOf(resp.ZoneName)
 	dataNode.RackName = resp.RackName
 	dataNode.DataPartitionCount = resp.CreatedPartitionCnt
 	dataNode.dataPartitionInfos = resp.PartitionInfo
+	dataNode.Version = resp.Version
+	dataNode.Features = resp.Features
 	dataNode.Ratio = (float64)(dataNode.Used) / (float64)(dataNode.Total)
 	dataNode.ReportTime = time.Now()
 }
@@ -82,7 +95,7 @@ func (dataNode *DataNode) IsWriteAble() (ok bool) {
 	dataNode.RLock()
 	defer dataNode.RUnlock()
 
-	if dataNode.isActive == true && dataNode.MaxDiskAvailWeight > (uint64)(util.DefaultDataPartitionSize) &&
+	if dataNode.isActive == true && !dataNode.Draining && !dataNode.inMaintenance() && dataNode.Health.isHealthy() && dataNode.MaxDiskAvailWeight > (uint64)(util.DefaultDataPartitionSize) &&
 		dataNode.Total-dataNode.Used > (uint64)(util.DefaultDataPartitionSize)*ReservedVolCount {
 		ok = true
 	}
@@ -90,6 +103,12 @@ func (dataNode *DataNode) IsWriteAble() (ok bool) {
 	return
 }
 
+func (dataNode *DataNode) setDraining(draining bool) {
+	dataNode.Lock()
+	defer dataNode.Unlock()
+	dataNode.Draining = draining
+}
+
 func (dataNode *DataNode) IsAvailCarryNode() (ok bool) {
 	dataNode.RLock()
 	defer dataNode.RUnlock()
@@ -119,10 +138,15 @@ func (dataNode *DataNode) clean() {
 	dataNode.Sender.exitCh <- struct{}{}
 }
 
-func (dataNode *DataNode) generateHeartbeatTask(masterAddr string) (task *proto.AdminTask) {
+func (dataNode *DataNode) generateHeartbeatTask(masterAddr string, volQos map[string]*proto.VolQos,
+	compressions map[string]string, volKeys map[string][]*proto.VolKey, compact bool) (task *proto.AdminTask) {
 	request := &proto.HeartBeatRequest{
-		CurrTime:   time.Now().Unix(),
-		MasterAddr: masterAddr,
+		CurrTime:     time.Now().Unix(),
+		MasterAddr:   masterAddr,
+		VolQos:       volQos,
+		Compressions: compressions,
+		VolKeys:      volKeys,
+		Compact:      compact,
 	}
 	task = proto.NewAdminTask(proto.OpDataNodeHeartbeat, dataNode.Addr, request)
 	return
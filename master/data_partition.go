@@ -27,6 +27,8 @@ type DataPartition struct {
 	FileInCoreMap map[string]*FileInCore
 	MissNodes     map[string]int64
 	VolName       string
+	ManualStatus  int8
+	ECDataNum     uint8
 }
 
 func newDataPartition(ID uint64, replicaNum uint8, partitionType, volName string) (partition *DataPartition) {
@@ -52,16 +54,23 @@ func (partition *DataPartition) AddMember(replica *DataReplica) {
 	partition.Replicas = append(partition.Replicas, replica)
 }
 
-func (partition *DataPartition) GenerateCreateTasks() (tasks []*proto.AdminTask) {
+func (partition *DataPartition) GenerateCreateTasks(keys []*proto.VolKey) (tasks []*proto.AdminTask) {
 	tasks = make([]*proto.AdminTask, 0)
 	for _, addr := range partition.PersistenceHosts {
-		tasks = append(tasks, partition.generateCreateTask(addr))
+		tasks = append(tasks, partition.generateCreateTask(addr, keys))
 	}
 	return
 }
 
-func (partition *DataPartition) generateCreateTask(addr string) (task *proto.AdminTask) {
-	task = proto.NewAdminTask(proto.OpCreateDataPartition, addr, newCreateDataPartitionRequest(partition.PartitionType, partition.VolName, partition.PartitionID))
+//generateCreateTask carries the keys of an encrypted vol,the replica encrypts its data from the first write
+func (partition *DataPartition) generateCreateTask(addr string, keys []*proto.VolKey) (task *proto.AdminTask) {
+	req := newCreateDataPartitionRequest(partition.PartitionType, partition.VolName, partition.PartitionID)
+	req.Keys = keys
+	if partition.isEC() {
+		req.ECDataNum = partition.ECDataNum
+		req.ECParityNum = partition.ecParityNum()
+	}
+	task = proto.NewAdminTask(proto.OpCreateDataPartition, addr, req)
 	partition.resetTaskID(task)
 	return
 }
@@ -89,6 +98,9 @@ func (partition *DataPartition) hasMissOne(replicaNum int) (err error) {
 func (partition *DataPartition) canOffLine(offlineAddr string) (err error) {
 	msg := fmt.Sprintf("action[canOffLine],partitionID:%v  RocksDBHost:%v  offLine:%v ",
 		partition.PartitionID, partition.PersistenceHosts, offlineAddr)
+	if partition.isEC() {
+		return partition.canECOffLine(offlineAddr)
+	}
 	liveReplicas := partition.getLiveReplicas(DefaultDataPartitionTimeOutSec)
 	if len(liveReplicas) < 2 {
 		msg = fmt.Sprintf(msg+" err:%v  liveReplicas:%v ", CannotOffLineErr, len(liveReplicas))
@@ -193,6 +205,15 @@ func (partition *DataPartition) getReplica(addr string) (replica *DataReplica, e
 	return nil, errors.Annotatef(DataReplicaNotFound, "%v not found", addr)
 }
 
+func (partition *DataPartition) isReplicaOnDisk(addr, diskPath string) bool {
+	for _, replica := range partition.Replicas {
+		if replica.Addr == addr {
+			return replica.DiskPath == diskPath
+		}
+	}
+	return false
+}
+
 func (partition *DataPartition) convertToDataPartitionResponse() (dpr *DataPartitionResponse) {
 	dpr = new(DataPartitionResponse)
 	partition.Lock()
@@ -201,6 +222,7 @@ func (partition *DataPartition) convertToDataPartitionResponse() (dpr *DataParti
 	dpr.Status = partition.Status
 	dpr.ReplicaNum = partition.ReplicaNum
 	dpr.PartitionType = partition.PartitionType
+	dpr.ECDataNum = partition.ECDataNum
 	dpr.Hosts = make([]string, len(partition.PersistenceHosts))
 	copy(dpr.Hosts, partition.PersistenceHosts)
 	return
@@ -408,6 +430,9 @@ func (partition *DataPartition) updateForOffline(offlineAddr, newAddr, volName s
 		}
 	}
 	newHosts = append(newHosts, newAddr)
+	if partition.isEC() {
+		newHosts = partition.ecHostsForOffline(offlineAddr, newAddr)
+	}
 	partition.PersistenceHosts = newHosts
 	if err = c.syncUpdateDataPartition(volName, partition); err != nil {
 		partition.PersistenceHosts = orgHosts
@@ -437,6 +462,10 @@ func (partition *DataPartition) UpdateMetric(vr *proto.PartitionReport, dataNode
 	replica.Status = int8(vr.PartitionStatus)
 	replica.Total = vr.Total
 	replica.Used = vr.Used
+	replica.DiskPath = vr.DiskPath
+	replica.CompressedBlocks = vr.CompressedBlocks
+	replica.CompressedRawSize = vr.CompressedRawSize
+	replica.CompressedSize = vr.CompressedSize
 	replica.SetAlive()
 	partition.checkAndRemoveMissReplica(dataNode.Addr)
 }
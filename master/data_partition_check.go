@@ -11,8 +11,10 @@ func (partition *DataPartition) checkStatus(needLog bool, dpTimeOutSec int64) {
 	partition.Lock()
 	defer partition.Unlock()
 	liveReplicas := partition.getLiveReplicasByPersistenceHosts(dpTimeOutSec)
-	switch len(liveReplicas) {
-	case (int)(partition.ReplicaNum):
+	switch {
+	case partition.isEC():
+		partition.checkECStatus(liveReplicas)
+	case len(liveReplicas) == int(partition.ReplicaNum):
 		partition.Status = proto.ReadOnly
 		if partition.checkReplicaStatusOnLiveNode(liveReplicas) == true {
 			partition.Status = proto.ReadWrite
@@ -20,6 +22,7 @@ func (partition *DataPartition) checkStatus(needLog bool, dpTimeOutSec int64) {
 	default:
 		partition.Status = proto.ReadOnly
 	}
+	partition.applyManualStatus()
 	if needLog == true {
 		msg := fmt.Sprintf("action[checkStatus],partitionID:%v  replicaNum:%v  liveReplicas:%v   Status:%v  RocksDBHost:%v ",
 			partition.PartitionID, partition.ReplicaNum, len(liveReplicas), partition.Status, partition.PersistenceHosts)
@@ -27,9 +30,10 @@ func (partition *DataPartition) checkStatus(needLog bool, dpTimeOutSec int64) {
 	}
 }
 
+//the partitions with a replica on an unhealthy node are read only,the clients write to the others
 func (partition *DataPartition) checkReplicaStatusOnLiveNode(liveReplicas []*DataReplica) (equal bool) {
 	for _, replica := range liveReplicas {
-		if replica.Status != proto.ReadWrite {
+		if replica.Status != proto.ReadWrite || !replica.GetReplicaNode().isHealthy() {
 			return
 		}
 	}
@@ -56,6 +60,9 @@ func (partition *DataPartition) checkMiss(clusterID string, dataPartitionMissSec
 				lastReportTime time.Time
 			)
 			isActive := true
+			if dataNode != nil && dataNode.isInMaintenance() {
+				continue
+			}
 			if dataNode != nil {
 				lastReportTime = dataNode.ReportTime
 				isActive = dataNode.isActive
@@ -128,7 +135,7 @@ func (partition *DataPartition) checkDiskError(clusterID string) (diskErrorAddrs
 	return
 }
 
-func (partition *DataPartition) checkReplicationTask() (tasks []*proto.AdminTask) {
+func (partition *DataPartition) checkReplicationTask(keys []*proto.VolKey) (tasks []*proto.AdminTask) {
 	var msg string
 	tasks = make([]*proto.AdminTask, 0)
 	if excessAddr, task, excessErr := partition.deleteExcessReplication(); excessErr != nil {
@@ -142,7 +149,7 @@ func (partition *DataPartition) checkReplicationTask() (tasks []*proto.AdminTask
 	if partition.Status == proto.ReadWrite {
 		return
 	}
-	if lackTask, lackAddr, lackErr := partition.addLackReplication(); lackErr != nil {
+	if lackTask, lackAddr, lackErr := partition.addLackReplication(keys); lackErr != nil {
 		tasks = append(tasks, lackTask)
 		msg = fmt.Sprintf("action[%v], partitionID:%v  Lack Replication"+
 			" On :%v  Err:%v  PersistenceHosts:%v  new task to create DataReplica",
@@ -177,7 +184,7 @@ func (partition *DataPartition) deleteExcessReplication() (excessAddr string, ta
 
 /*add data partition lack replication,range all RocksDBHost if Hosts not in Replicas,
 then generator a task to OpRecoverCreateDataPartition to a new Node*/
-func (partition *DataPartition) addLackReplication() (t *proto.AdminTask, lackAddr string, err error) {
+func (partition *DataPartition) addLackReplication(keys []*proto.VolKey) (t *proto.AdminTask, lackAddr string, err error) {
 	partition.Lock()
 	defer partition.Unlock()
 	for _, addr := range partition.PersistenceHosts {
@@ -186,7 +193,7 @@ func (partition *DataPartition) addLackReplication() (t *proto.AdminTask, lackAd
 				partition.PartitionID, addr))
 			err = DataReplicaLackError
 			lackAddr = addr
-			t = partition.generateCreateTask(addr)
+			t = partition.generateCreateTask(addr, keys)
 			partition.isRecover = true
 			break
 		}
@@ -0,0 +1,188 @@
+package master
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/juju/errors"
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/ec"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+const (
+	DefaultECDataNum   = 4
+	DefaultECParityNum = 2
+)
+
+/*An ec data partition keeps one shard of every stripe on each of its k+m PersistenceHosts,
+the index of a host in PersistenceHosts is the index of its shard. ReplicaNum is k+m.
+A failure domain holds at most m shards,so the stripes survive the loss of any one domain.
+The domain is the zone under the zone policies and the rack otherwise.*/
+
+func (partition *DataPartition) isEC() bool {
+	return partition.PartitionType == proto.ECPartition
+}
+
+func (partition *DataPartition) ecParityNum() uint8 {
+	return partition.ReplicaNum - partition.ECDataNum
+}
+
+/*checkECStatus is the checkStatus of an ec partition, it is writable with k+1 of its shards,
+so a stripe written without the others still survives the loss of one more shard,
+readable with any k of them, and the data is lost below k*/
+func (partition *DataPartition) checkECStatus(liveReplicas []*DataReplica) {
+	switch {
+	case len(liveReplicas) > int(partition.ECDataNum):
+		partition.Status = proto.ReadOnly
+		if partition.checkReplicaStatusOnLiveNode(liveReplicas) == true {
+			partition.Status = proto.ReadWrite
+		}
+	case len(liveReplicas) >= int(partition.ECDataNum):
+		partition.Status = proto.ReadOnly
+	default:
+		partition.Status = proto.Unavaliable
+	}
+}
+
+//ecFailureDomain returns the failure domain of a data node under the placement policy
+func ecFailureDomain(dataNode *DataNode, policy string) string {
+	if policy == PlacementPolicyZone || policy == PlacementPolicyCrossZone {
+		return dataNode.ZoneName
+	}
+	return dataNode.ZoneName + "/" + dataNode.RackName
+}
+
+//ecMaxShardsPerDomain is the number of shards a failure domain can hold under the placement policy
+func ecMaxShardsPerDomain(parityNum int, policy string) int {
+	if policy == PlacementPolicyZone {
+		return 1
+	}
+	return parityNum
+}
+
+/*ChooseTargetECHosts chooses the k+m hosts of a new ec partition, no failure domain holds more than
+ecMaxShardsPerDomain of them*/
+func (c *Cluster) ChooseTargetECHosts(dataNum, parityNum int, policy string) (hosts []string, err error) {
+	var (
+		addrs  []string
+		zones  []*Zone
+		counts []int
+	)
+	shardNum := dataNum + parityNum
+	maxPerDomain := ecMaxShardsPerDomain(parityNum, policy)
+	hosts = make([]string, 0, shardNum)
+	if policy == PlacementPolicyZone || policy == PlacementPolicyCrossZone {
+		if zones, counts, err = c.t.allocSpreadZones(shardNum, maxPerDomain, nil, (*Zone).canWriteForDataNode); err != nil {
+			return nil, errors.Trace(err)
+		}
+		for index, zone := range zones {
+			if addrs, err = zone.getAvailDataNodeHosts(hosts, counts[index]); err != nil {
+				return nil, errors.Trace(err)
+			}
+			hosts = append(hosts, addrs...)
+		}
+	} else {
+		if zones, _, err = c.t.allocZones(policy, shardNum, nil, (*Zone).canWriteForDataNode); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if hosts, err = zones[0].getSpreadDataNodeHosts(nil, shardNum, maxPerDomain); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if len(hosts) != shardNum {
+		return nil, NoAnyDataNodeForCreateDataPartition
+	}
+	return
+}
+
+//checkECShards warns when an ec partition can not lose another shard or a failure domain holds too many shards
+func (partition *DataPartition) checkECShards(clusterID, policy string, timeOutSec int64) {
+	if !partition.isEC() {
+		return
+	}
+	partition.RLock()
+	defer partition.RUnlock()
+	partition.checkECPlacement(clusterID, policy)
+	liveShards := len(partition.getLiveReplicasByPersistenceHosts(timeOutSec))
+	if liveShards > int(partition.ECDataNum) {
+		return
+	}
+	msg := fmt.Sprintf("action[checkECShards],clusterID[%v] partitionID:%v ec[%v+%v] liveShards:%v hosts:%v",
+		clusterID, partition.PartitionID, partition.ECDataNum, partition.ecParityNum(), liveShards, partition.PersistenceHosts)
+	if liveShards < int(partition.ECDataNum) {
+		Warn(clusterID, msg+" data is unavailable")
+		return
+	}
+	Warn(clusterID, msg+" no shard can be lost any more")
+}
+
+func (partition *DataPartition) checkECPlacement(clusterID, policy string) {
+	maxPerDomain := ecMaxShardsPerDomain(int(partition.ecParityNum()), policy)
+	domains := make(map[string]int)
+	for _, addr := range partition.PersistenceHosts {
+		replica, err := partition.getReplica(addr)
+		if err != nil {
+			continue
+		}
+		domain := ecFailureDomain(replica.GetReplicaNode(), policy)
+		if domains[domain]++; domains[domain] == maxPerDomain+1 {
+			Warn(clusterID, fmt.Sprintf("action[checkECPlacement],clusterID[%v] partitionID:%v ec[%v+%v] "+
+				"failure domain[%v] holds more than %v shards,hosts:%v", clusterID, partition.PartitionID,
+				partition.ECDataNum, partition.ecParityNum(), domain, maxPerDomain, partition.PersistenceHosts))
+		}
+	}
+}
+
+//canECOffLine requires k live shards besides the offline one to rebuild its shard on the new host
+func (partition *DataPartition) canECOffLine(offlineAddr string) (err error) {
+	liveShards := 0
+	for _, replica := range partition.getLiveReplicas(DefaultDataPartitionTimeOutSec) {
+		if replica.Addr != offlineAddr {
+			liveShards++
+		}
+	}
+	if liveShards < int(partition.ECDataNum) {
+		err = fmt.Errorf("action[canECOffLine],partitionID:%v hosts:%v offLine:%v err:%v liveShards:%v less than %v",
+			partition.PartitionID, partition.PersistenceHosts, offlineAddr, CannotOffLineErr, liveShards, partition.ECDataNum)
+		log.LogError(err.Error())
+	}
+	return
+}
+
+//ecHostsForOffline replaces the offline host in place, so the other hosts keep the index of their shards
+func (partition *DataPartition) ecHostsForOffline(offlineAddr, newAddr string) (newHosts []string) {
+	newHosts = make([]string, len(partition.PersistenceHosts))
+	copy(newHosts, partition.PersistenceHosts)
+	for index, addr := range newHosts {
+		if addr == offlineAddr {
+			newHosts[index] = newAddr
+		}
+	}
+	return
+}
+
+//parseECPara reads the layout of a new ec partition, it defaults to DefaultECDataNum+DefaultECParityNum
+func parseECPara(r *http.Request) (dataNum, parityNum uint8, err error) {
+	dataNum, parityNum = DefaultECDataNum, DefaultECParityNum
+	var value uint64
+	if s := r.FormValue(ParaECDataNum); s != "" {
+		if value, err = strconv.ParseUint(s, 10, 8); err != nil {
+			err = UnMatchPara
+			return
+		}
+		dataNum = uint8(value)
+	}
+	if s := r.FormValue(ParaECParityNum); s != "" {
+		if value, err = strconv.ParseUint(s, 10, 8); err != nil {
+			err = UnMatchPara
+			return
+		}
+		parityNum = uint8(value)
+	}
+	if dataNum == 0 || parityNum == 0 || int(dataNum)+int(parityNum) > ec.MaxTotalShards {
+		err = fmt.Errorf("invalid ec layout[%v+%v]", dataNum, parityNum)
+	}
+	return
+}
@@ -15,6 +15,10 @@ type DataReplica struct {
 	LoadPartitionIsResponse bool
 	Total                   uint64 `json:"TotalSize"`
 	Used                    uint64 `json:"UsedSize"`
+	DiskPath                string
+	CompressedBlocks        uint64
+	CompressedRawSize       uint64
+	CompressedSize          uint64
 }
 
 func NewDataReplica(dataNode *DataNode) (replica *DataReplica) {
@@ -0,0 +1,407 @@
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+const (
+	DecommissionDataNode = "dataNode"
+	DecommissionMetaNode = "metaNode"
+	DecommissionDisk     = "disk"
+)
+
+const (
+	DecommissionRunning = "running"
+	DecommissionDone    = "done"
+)
+
+/*Decommission records the progress of draining a node. It is persisted in the raft state machine,
+so the new leader picks up the unfinished work after a master failover.*/
+type Decommission struct {
+	NodeType     string
+	Addr         string
+	DiskPath     string
+	MigrateLocal bool
+	Status       string
+	StartTime    int64
+	EndTime      int64
+	Total        int
+	Migrated     int
+	Remaining    int
+	Failures     map[uint64]string
+	sync.RWMutex
+}
+
+type DecommissionValue struct {
+	NodeType     string
+	Addr         string
+	DiskPath     string
+	MigrateLocal bool
+	Status       string
+	StartTime    int64
+	EndTime      int64
+	Total        int
+	Migrated     int
+	Remaining    int
+	Failures     map[uint64]string
+}
+
+//the caller must hold the lock of d unless d is not published yet,the failures are copied
+//so the value can be persisted after the lock is released
+func newDecommissionValue(d *Decommission) (dv *DecommissionValue) {
+	dv = &DecommissionValue{
+		NodeType:     d.NodeType,
+		Addr:         d.Addr,
+		DiskPath:     d.DiskPath,
+		MigrateLocal: d.MigrateLocal,
+		Status:       d.Status,
+		StartTime:    d.StartTime,
+		EndTime:      d.EndTime,
+		Total:        d.Total,
+		Migrated:     d.Migrated,
+		Remaining:    d.Remaining,
+		Failures:     make(map[uint64]string, len(d.Failures)),
+	}
+	for partitionID, msg := range d.Failures {
+		dv.Failures[partitionID] = msg
+	}
+	return
+}
+
+func newDecommissionFromValue(dv *DecommissionValue) (d *Decommission) {
+	d = &Decommission{
+		NodeType:     dv.NodeType,
+		Addr:         dv.Addr,
+		DiskPath:     dv.DiskPath,
+		MigrateLocal: dv.MigrateLocal,
+		Status:       dv.Status,
+		StartTime:    dv.StartTime,
+		EndTime:      dv.EndTime,
+		Total:        dv.Total,
+		Migrated:     dv.Migrated,
+		Remaining:    dv.Remaining,
+		Failures:     dv.Failures,
+	}
+	if d.Failures == nil {
+		d.Failures = make(map[uint64]string, 0)
+	}
+	return
+}
+
+func newDecommission(nodeType, addr, diskPath string) (d *Decommission) {
+	d = &Decommission{NodeType: nodeType, Addr: addr, DiskPath: diskPath, Status: DecommissionRunning}
+	d.StartTime = time.Now().Unix()
+	d.Failures = make(map[uint64]string, 0)
+	return
+}
+
+func (d *Decommission) isRunning() bool {
+	d.RLock()
+	defer d.RUnlock()
+	return d.Status == DecommissionRunning
+}
+
+//isExpired tells whether the record of a finished decommission has been kept long enough
+func (d *Decommission) isExpired(retainSec int64, now time.Time) bool {
+	d.RLock()
+	defer d.RUnlock()
+	return d.Status == DecommissionDone && d.EndTime > 0 && now.Unix()-d.EndTime > retainSec
+}
+
+func (d *Decommission) toJson() (body []byte, err error) {
+	d.RLock()
+	defer d.RUnlock()
+	return json.Marshal(newDecommissionValue(d))
+}
+
+func decommissionKey(nodeType, addr, diskPath string) (key string) {
+	key = nodeType + KeySeparator + addr
+	if diskPath != "" {
+		key = key + KeySeparator + diskPath
+	}
+	return
+}
+
+func (c *Cluster) getDecommission(nodeType, addr, diskPath string) (d *Decommission, err error) {
+	value, ok := c.decommissions.Load(decommissionKey(nodeType, addr, diskPath))
+	if !ok {
+		return nil, errors.Annotatef(DecommissionNotFound, "%v %v %v", nodeType, addr, diskPath)
+	}
+	return value.(*Decommission), nil
+}
+
+func (c *Cluster) putDecommission(d *Decommission) {
+	c.decommissions.Store(decommissionKey(d.NodeType, d.Addr, d.DiskPath), d)
+	c.setNodeDraining(d.NodeType, d.Addr, d.isRunning())
+}
+
+func (c *Cluster) setNodeDraining(nodeType, addr string, draining bool) {
+	switch nodeType {
+	case DecommissionDataNode:
+		if dataNode, err := c.getDataNode(addr); err == nil {
+			dataNode.setDraining(draining)
+		}
+	case DecommissionMetaNode:
+		if metaNode, err := c.getMetaNode(addr); err == nil {
+			metaNode.setDraining(draining)
+		}
+	}
+}
+
+func (c *Cluster) startDataNodeDecommission(dataNode *DataNode) (err error) {
+	_, err = c.startDecommission(DecommissionDataNode, dataNode.Addr, "", false)
+	return
+}
+
+func (c *Cluster) startMetaNodeDecommission(metaNode *MetaNode) (err error) {
+	_, err = c.startDecommission(DecommissionMetaNode, metaNode.Addr, "", false)
+	return
+}
+
+/*startDiskDecommission retires one disk of a data node. The partitions on the disk are moved
+to the other disks of the node by the data node itself when migrateLocal is set,
+otherwise the master migrates them to other nodes.*/
+func (c *Cluster) startDiskDecommission(dataNode *DataNode, diskPath string, migrateLocal bool) (err error) {
+	var d *Decommission
+	if d, err = c.startDecommission(DecommissionDisk, dataNode.Addr, diskPath, migrateLocal); err != nil {
+		return
+	}
+	d.RLock()
+	task := newDecommissionDiskTask(d.Addr, d.DiskPath, d.MigrateLocal)
+	d.RUnlock()
+	c.putDataNodeTasks([]*proto.AdminTask{task})
+	return
+}
+
+func (c *Cluster) startDecommission(nodeType, addr, diskPath string, migrateLocal bool) (d *Decommission, err error) {
+	if d, err = c.getDecommission(nodeType, addr, diskPath); err == nil && d.isRunning() {
+		return
+	}
+	d = newDecommission(nodeType, addr, diskPath)
+	d.MigrateLocal = migrateLocal
+	d.Total = len(c.getPartitionIDsOfNode(nodeType, addr, diskPath))
+	d.Remaining = d.Total
+	if err = c.syncPutDecommission(newDecommissionValue(d)); err != nil {
+		return
+	}
+	c.putDecommission(d)
+	Warn(c.Name, fmt.Sprintf("clusterID[%v] %v[%v%v] start decommission,partitions[%v]",
+		c.Name, nodeType, addr, diskPath, d.Total))
+	return
+}
+
+func newDecommissionDiskTask(addr, diskPath string, migrateLocal bool) (task *proto.AdminTask) {
+	req := &proto.DecommissionDiskRequest{DiskPath: diskPath, MigrateLocal: migrateLocal}
+	task = proto.NewAdminTask(proto.OpDecommissionDisk, addr, req)
+	task.ID = fmt.Sprintf("%v_disk[%v]", task.ID, diskPath)
+	return
+}
+
+func (c *Cluster) startCheckDecommissions() {
+	go func() {
+		for {
+			if c.partition.IsLeader() {
+				c.checkDecommissions()
+			}
+			time.Sleep(time.Second * DefaultCheckDecommissionIntervalSeconds)
+		}
+	}()
+}
+
+func (c *Cluster) checkDecommissions() {
+	now := time.Now()
+	c.decommissions.Range(func(key, value interface{}) bool {
+		d := value.(*Decommission)
+		if d.isRunning() {
+			c.doDecommission(d)
+		} else if d.isExpired(c.cfg.DecommissionRetainSeconds, now) {
+			c.expireDecommission(d)
+		}
+		return true
+	})
+}
+
+//expireDecommission drops the record of a decommission finished DecommissionRetainSeconds ago
+func (c *Cluster) expireDecommission(d *Decommission) {
+	d.RLock()
+	dv := newDecommissionValue(d)
+	d.RUnlock()
+	if err := c.syncDeleteDecommission(dv); err != nil {
+		log.LogErrorf("action[expireDecommission] %v[%v%v] delete failed,err:%v", dv.NodeType, dv.Addr, dv.DiskPath, err)
+		return
+	}
+	c.decommissions.Delete(decommissionKey(dv.NodeType, dv.Addr, dv.DiskPath))
+	log.LogInfof("action[expireDecommission] %v[%v%v] finished at %v expired",
+		dv.NodeType, dv.Addr, dv.DiskPath, time.Unix(dv.EndTime, 0))
+}
+
+/*doDecommission migrates at most DecommissionBatchCount partitions away from the node,
+and removes the node from the cluster once it holds no partition any more.
+the migrations and the raft submits run without the lock of d,which only guards the progress.*/
+func (c *Cluster) doDecommission(d *Decommission) {
+	var (
+		migrated []uint64
+		done     bool
+		err      error
+	)
+	d.RLock()
+	nodeType, addr, diskPath, migrateLocal := d.NodeType, d.Addr, d.DiskPath, d.MigrateLocal
+	d.RUnlock()
+	if nodeType == DecommissionDisk {
+		//the data node resumes a local migration stopped by a restart, and reports its failures
+		c.putDataNodeTasks([]*proto.AdminTask{newDecommissionDiskTask(addr, diskPath, migrateLocal)})
+	}
+	failures := make(map[uint64]string, 0)
+	for partitionID, volName := range c.getPartitionIDsOfNode(nodeType, addr, diskPath) {
+		if len(migrated) >= c.cfg.DecommissionBatchCount || migrateLocal {
+			break
+		}
+		if err = c.migratePartition(nodeType, addr, volName, partitionID); err != nil {
+			if errors.Cause(err) == OfflineSkipped {
+				Warn(c.Name, fmt.Sprintf("clusterID[%v] %v[%v%v] decommission skipped partitionID:%v,err:%v",
+					c.Name, nodeType, addr, diskPath, partitionID, err))
+			}
+			failures[partitionID] = err.Error()
+			continue
+		}
+		migrated = append(migrated, partitionID)
+	}
+	remaining := len(c.getPartitionIDsOfNode(nodeType, addr, diskPath))
+	if remaining == 0 {
+		if err = c.removeDecommissionedNode(nodeType, addr); err != nil {
+			log.LogErrorf("action[doDecommission] %v[%v] remove failed,err:%v", nodeType, addr, err)
+		} else {
+			done = true
+		}
+	}
+
+	d.Lock()
+	for partitionID, msg := range failures {
+		d.Failures[partitionID] = msg
+	}
+	for _, partitionID := range migrated {
+		delete(d.Failures, partitionID)
+	}
+	d.Migrated += len(migrated)
+	d.Remaining = remaining
+	if migrateLocal {
+		d.Migrated = d.Total - d.Remaining
+	}
+	if done {
+		d.Status = DecommissionDone
+		d.EndTime = time.Now().Unix()
+	}
+	dv := newDecommissionValue(d)
+	d.Unlock()
+
+	if err = c.syncPutDecommission(dv); err != nil {
+		log.LogErrorf("action[doDecommission] %v[%v] persist progress failed,err:%v", nodeType, addr, err)
+	}
+	log.LogInfof("action[doDecommission] %v[%v] status[%v] migrated[%v] remaining[%v] failures[%v]",
+		nodeType, addr, dv.Status, dv.Migrated, dv.Remaining, len(dv.Failures))
+}
+
+//recordLocalMigrateFailures keeps the partitions the data node failed to move to its other disks,
+//every response of a local migration reports all of them
+func (c *Cluster) recordLocalMigrateFailures(addr, diskPath string, failures map[uint64]string) {
+	d, err := c.getDecommission(DecommissionDisk, addr, diskPath)
+	if err != nil {
+		return
+	}
+	d.Lock()
+	if !d.MigrateLocal || d.Status != DecommissionRunning {
+		d.Unlock()
+		return
+	}
+	d.Failures = make(map[uint64]string, len(failures))
+	for partitionID, msg := range failures {
+		d.Failures[partitionID] = msg
+	}
+	dv := newDecommissionValue(d)
+	d.Unlock()
+	if err = c.syncPutDecommission(dv); err != nil {
+		log.LogErrorf("action[recordLocalMigrateFailures] disk[%v%v] persist failures failed,err:%v", addr, diskPath, err)
+	}
+}
+
+func (c *Cluster) migratePartition(nodeType, addr, volName string, partitionID uint64) (err error) {
+	var (
+		vol *Vol
+		dp  *DataPartition
+	)
+	if nodeType == DecommissionMetaNode {
+		return c.metaPartitionOffline(volName, addr, partitionID)
+	}
+	if vol, err = c.getVol(volName); err != nil {
+		return
+	}
+	if dp, err = vol.getDataPartitionByID(partitionID); err != nil {
+		return
+	}
+	return c.dataPartitionOffline(addr, volName, dp, DataNodeOfflineInfo)
+}
+
+//getPartitionIDsOfNode returns partitionID -> volName of the partitions which have a replica on addr,
+//limited to the replicas on diskPath for a disk decommission
+func (c *Cluster) getPartitionIDsOfNode(nodeType, addr, diskPath string) (partitions map[uint64]string) {
+	partitions = make(map[uint64]string, 0)
+	safeVols := c.copyVols()
+	for _, vol := range safeVols {
+		if nodeType == DecommissionMetaNode {
+			vol.mpsLock.RLock()
+			for _, mp := range vol.MetaPartitions {
+				mp.RLock()
+				if contains(mp.PersistenceHosts, addr) {
+					partitions[mp.PartitionID] = vol.Name
+				}
+				mp.RUnlock()
+			}
+			vol.mpsLock.RUnlock()
+			continue
+		}
+		vol.dataPartitions.RLock()
+		for _, dp := range vol.dataPartitions.dataPartitionMap {
+			dp.RLock()
+			if dp.isInPersistenceHosts(addr) && (nodeType != DecommissionDisk || dp.isReplicaOnDisk(addr, diskPath)) {
+				partitions[dp.PartitionID] = vol.Name
+			}
+			dp.RUnlock()
+		}
+		vol.dataPartitions.RUnlock()
+	}
+	return
+}
+
+func (c *Cluster) removeDecommissionedNode(nodeType, addr string) (err error) {
+	switch nodeType {
+	case DecommissionDisk:
+		return
+	case DecommissionDataNode:
+		var dataNode *DataNode
+		if dataNode, err = c.getDataNode(addr); err != nil {
+			return nil
+		}
+		if err = c.syncDeleteDataNode(dataNode); err != nil {
+			return
+		}
+		c.delDataNodeFromCache(dataNode)
+	case DecommissionMetaNode:
+		var metaNode *MetaNode
+		if metaNode, err = c.getMetaNode(addr); err != nil {
+			return nil
+		}
+		if err = c.syncDeleteMetaNode(metaNode); err != nil {
+			return
+		}
+		c.delMetaNodeFromCache(metaNode)
+	}
+	Warn(c.Name, fmt.Sprintf("clusterID[%v] %v[%v] decommission success", c.Name, nodeType, addr))
+	return
+}
@@ -0,0 +1,135 @@
+package master
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/raftstore"
+)
+
+// testPartition is the raft partition of a single master, it records the submitted
+// commands instead of replicating them
+type testPartition struct {
+	raftstore.Partition
+	cmds     []*Metadata
+	onSubmit func(cmd *Metadata)
+}
+
+func (p *testPartition) IsLeader() bool { return true }
+
+func (p *testPartition) Truncate(index uint64) {}
+
+func (p *testPartition) Submit(cmd []byte) (resp interface{}, err error) {
+	metadata := new(Metadata)
+	if err = metadata.Unmarshal(cmd); err != nil {
+		return
+	}
+	p.cmds = append(p.cmds, metadata)
+	if p.onSubmit != nil {
+		p.onSubmit(metadata)
+	}
+	return
+}
+
+func newTestCluster() (c *Cluster, p *testPartition) {
+	p = &testPartition{}
+	c = &Cluster{Name: "test", cfg: NewClusterConfig(), t: NewTopology(), vols: make(map[string]*Vol, 0), partition: p}
+	return
+}
+
+// addTestDataPartition puts a partition of vol with a live replica on each of the addrs
+func addTestDataPartition(c *Cluster, vol *Vol, id uint64, addrs ...string) (dp *DataPartition) {
+	dp = newDataPartition(id, uint8(len(addrs)), proto.ExtentPartition, vol.Name)
+	for _, addr := range addrs {
+		dataNode, err := c.getDataNode(addr)
+		if err != nil {
+			dataNode = newTestDataNode(addr, "z1", "r1")
+			c.dataNodes.Store(addr, dataNode)
+		}
+		dp.PersistenceHosts = append(dp.PersistenceHosts, addr)
+		dp.AddMember(NewDataReplica(dataNode))
+	}
+	vol.dataPartitions.putDataPartition(dp)
+	return
+}
+
+func TestDoDecommissionReportsSkipped(t *testing.T) {
+	c, p := newTestCluster()
+	vol := NewVol("vol", proto.ExtentPartition, PlacementPolicyRack, 3)
+	c.vols[vol.Name] = vol
+	addTestDataPartition(c, vol, 1, "a:1", "b:1", "c:1")
+	//the node reports no rack, so its partition can not be moved
+	dataNode, _ := c.getDataNode("a:1")
+	dataNode.RackName = ""
+
+	d, err := c.startDecommission(DecommissionDataNode, "a:1", "", false)
+	if err != nil || d.Total != 1 {
+		t.Fatalf("start decommission total %v err %v", d.Total, err)
+	}
+	p.onSubmit = func(cmd *Metadata) {
+		if !d.TryLock() {
+			t.Fatalf("%v submitted under the lock of the decommission", cmd.K)
+		}
+		d.Unlock()
+	}
+	c.doDecommission(d)
+	if d.Status != DecommissionRunning || d.Remaining != 1 || d.Migrated != 0 ||
+		!strings.Contains(d.Failures[1], OfflineSkipped.Error()) {
+		t.Fatalf("skipped partition reported as status %v remaining %v failures %v", d.Status, d.Remaining, d.Failures)
+	}
+	if err = c.migratePartition(DecommissionDataNode, "d:1", vol.Name, 1); errors.Cause(err) != OfflineSkipped {
+		t.Fatalf("offline of a host out of the partition: %v", err)
+	}
+}
+
+func TestDoDecommissionDoneAndExpire(t *testing.T) {
+	c, p := newTestCluster()
+	d, err := c.startDecommission(DecommissionDataNode, "a:1", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.checkDecommissions()
+	if d.Status != DecommissionDone || d.EndTime == 0 {
+		t.Fatalf("empty node not done: %v", d.Status)
+	}
+	//the record is kept for a while after the decommission is done
+	c.checkDecommissions()
+	if _, err = c.getDecommission(DecommissionDataNode, "a:1", ""); err != nil {
+		t.Fatalf("done decommission dropped at once: %v", err)
+	}
+	d.EndTime = time.Now().Unix() - c.cfg.DecommissionRetainSeconds - 1
+	c.checkDecommissions()
+	if _, err = c.getDecommission(DecommissionDataNode, "a:1", ""); errors.Cause(err) != DecommissionNotFound {
+		t.Fatalf("expired decommission kept: %v", err)
+	}
+	if last := p.cmds[len(p.cmds)-1]; last.Op != OpSyncDeleteDecommission ||
+		last.K != DecommissionPrefix+decommissionKey(DecommissionDataNode, "a:1", "") {
+		t.Fatalf("expired decommission not deleted from the store: %v %v", last.Op, last.K)
+	}
+}
+
+func TestRecordLocalMigrateFailures(t *testing.T) {
+	c, p := newTestCluster()
+	d, err := c.startDecommission(DecommissionDisk, "a:1", "/data1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &proto.DecommissionDiskResponse{DiskPath: "/data1", Status: proto.TaskFail,
+		Failures: map[uint64]string{1: "no disk", 2: "copy failed"}}
+	c.dealDecommissionDiskResponse("a:1", resp)
+	if len(d.Failures) != 2 || d.Failures[2] != "copy failed" {
+		t.Fatalf("local failures not recorded: %v", d.Failures)
+	}
+	if last := p.cmds[len(p.cmds)-1]; last.Op != OpSyncPutDecommission {
+		t.Fatalf("local failures not persisted: %v", last.Op)
+	}
+	//a later response reports all the failures left
+	resp = &proto.DecommissionDiskResponse{DiskPath: "/data1", Status: proto.TaskSuccess}
+	c.dealDecommissionDiskResponse("a:1", resp)
+	if len(d.Failures) != 0 {
+		t.Fatalf("failures kept after a successful migration: %v", d.Failures)
+	}
+}
@@ -10,8 +10,14 @@ var (
 	NoAvailDataPartition  = errors.New("no avail data partition")
 	DataPartitionNotFound = errors.New("data partition not found")
 	RackNotFound          = errors.New("rack not found")
+	ZoneNotFound          = errors.New("zone not found")
 	DataNodeNotFound      = errors.New("data node not found")
 	MetaNodeNotFound      = errors.New("meta node not found")
+	DecommissionNotFound  = errors.New("decommission not found")
+	OfflineSkipped        = errors.New("data partition offline skipped")
+	AdminTaskNotFound     = errors.New("admin task not found")
+	MaintenanceNotFound   = errors.New("maintenance not found")
+	ScrubReportNotFound   = errors.New("scrub report not found")
 	VolNotFound           = errors.New("vol not found")
 	MetaPartitionNotFound = errors.New("meta partition not found")
 	DataReplicaNotFound   = errors.New("data replica not found")
@@ -26,13 +32,16 @@ var (
 	CannotOffLineErr                    = errors.New("cannot offline because avail data replica <0")
 	NoAnyDataNodeForCreateDataPartition = errors.New("no have enough data server for create data partition")
 	NoRackForCreateDataPartition        = errors.New("no rack for create data partition")
+	NoZoneForCreatePartition            = errors.New("no zone for create partition")
 	NoAnyMetaNodeForCreateMetaPartition = errors.New("no have enough meta server for create meta partition")
 	MetaReplicaExcessError              = errors.New("meta partition Replication Excess error")
 	NoHaveMajorityReplica               = errors.New("no have majority replica error")
 	NoLeader                            = errors.New("no leader")
 	ErrBadConfFile                      = errors.New("BadConfFile")
-	InvalidDataPartitionType            = errors.New("invalid data partition type. extent or tiny")
+	InvalidDataPartitionType            = errors.New("invalid data partition type. extent, tiny or ec")
+	InvalidPlacementPolicy              = errors.New("invalid placement policy. rack, zone or crossZone")
 	ParaEnableNotFound                  = errors.New("para enable not found")
+	NoMasterKey                         = errors.New("master key of the cluster is not configured")
 )
 
 func paraNotFound(name string) (err error) {
@@ -18,6 +18,8 @@ func (partition *DataPartition) checkFile(clusterID string) {
 		partition.checkExtentFile(liveReplicas, clusterID)
 	case proto.TinyPartition:
 		partition.checkChunkFile(liveReplicas, clusterID)
+	case proto.ECPartition:
+		//the shards of a stripe differ,the data nodes check them by rebuilding the missing ones
 	}
 
 	return
@@ -9,6 +9,7 @@ import (
 
 	"bytes"
 	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/log"
 	"io/ioutil"
 	"strings"
 )
@@ -24,17 +25,25 @@ type ClusterView struct {
 	Vols               []string
 	MetaNodes          []MetaNodeView
 	DataNodes          []DataNodeView
+	Maintenances       []*Maintenance
+	MinVersion         uint32
+	Features           uint64
+	UnhealthyNodes     []string
 }
 
 type DataNodeView struct {
-	Addr   string
-	Status bool
+	Addr        string
+	Status      bool
+	Version     uint32
+	HealthScore float64
 }
 
 type MetaNodeView struct {
-	ID     uint64
-	Addr   string
-	Status bool
+	ID          uint64
+	Addr        string
+	Status      bool
+	Version     uint32
+	HealthScore float64
 }
 
 func (m *Master) setMetaNodeThreshold(w http.ResponseWriter, r *http.Request) {
@@ -94,11 +103,15 @@ func (m *Master) getCluster(w http.ResponseWriter, r *http.Request) {
 		Vols:               make([]string, 0),
 		MetaNodes:          make([]MetaNodeView, 0),
 		DataNodes:          make([]DataNodeView, 0),
+		Maintenances:       make([]*Maintenance, 0),
 	}
 
 	cv.Vols = m.cluster.getAllVols()
 	cv.MetaNodes = m.cluster.getAllMetaNodes()
 	cv.DataNodes = m.cluster.getAllDataNodes()
+	cv.Maintenances = m.cluster.getMaintenances()
+	cv.MinVersion, cv.Features = m.cluster.getClusterVersion()
+	cv.UnhealthyNodes = m.cluster.getUnhealthyNodes()
 	if body, err = json.Marshal(cv); err != nil {
 		goto errDeal
 	}
@@ -154,6 +167,8 @@ func (m *Master) createDataPartition(w http.ResponseWriter, r *http.Request) {
 		rstMsg                  string
 		volName                 string
 		partitionType           string
+		ecDataNum               uint8
+		ecParityNum             uint8
 		vol                     *Vol
 		reqCreateCount          int
 		capacity                int
@@ -164,6 +179,11 @@ func (m *Master) createDataPartition(w http.ResponseWriter, r *http.Request) {
 	if reqCreateCount, volName, partitionType, err = parseCreateDataPartitionPara(r); err != nil {
 		goto errDeal
 	}
+	if partitionType == proto.ECPartition {
+		if ecDataNum, ecParityNum, err = parseECPara(r); err != nil {
+			goto errDeal
+		}
+	}
 
 	if vol, err = m.cluster.getVol(volName); err != nil {
 		goto errDeal
@@ -174,7 +194,7 @@ func (m *Master) createDataPartition(w http.ResponseWriter, r *http.Request) {
 		if (reqCreateCount+lastTotalDataPartitions) < len(vol.dataPartitions.dataPartitions) || int(m.cluster.idAlloc.dataPartitionID) >= capacity {
 			break
 		}
-		if _, err = m.cluster.createDataPartition(volName, partitionType); err != nil {
+		if _, err = m.cluster.createDataPartition(volName, partitionType, ecDataNum, ecParityNum); err != nil {
 			goto errDeal
 		}
 	}
@@ -215,6 +235,50 @@ errDeal:
 	return
 }
 
+/*freezeDataPartition sets one data partition, or all the data partitions on a data node
+or of a vol, read only or unavailable, enable=false releases them.*/
+func (m *Master) freezeDataPartition(w http.ResponseWriter, r *http.Request) {
+	var (
+		partitionIDs []uint64
+		target       string
+		status       int8
+		changed      int
+		vol          *Vol
+		err          error
+	)
+	partitionID, nodeAddr, volName, status, err := parseFreezePara(r)
+	if err != nil {
+		goto errDeal
+	}
+	switch {
+	case partitionID != 0:
+		partitionIDs = []uint64{partitionID}
+		target = fmt.Sprintf("dataPartition[%v]", partitionID)
+	case nodeAddr != "":
+		if _, err = m.cluster.getDataNode(nodeAddr); err != nil {
+			goto errDeal
+		}
+		partitionIDs = m.cluster.getDataPartitionIDsOfNode(nodeAddr)
+		target = fmt.Sprintf("dataNode[%v]", nodeAddr)
+	default:
+		if vol, err = m.cluster.getVol(volName); err != nil {
+			goto errDeal
+		}
+		partitionIDs = vol.getDataPartitionIDs()
+		target = fmt.Sprintf("vol[%v]", volName)
+	}
+	if changed, err = m.cluster.setDataPartitionsManualStatus(partitionIDs, status); err != nil && changed == 0 {
+		goto errDeal
+	}
+	io.WriteString(w, fmt.Sprintf("set manual status[%v] of %v partitions[%v/%v] SUCCESS",
+		status, target, changed, len(partitionIDs)))
+	return
+errDeal:
+	logMsg := getReturnMessage(AdminFreezeDataPartition, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
 func (m *Master) loadDataPartition(w http.ResponseWriter, r *http.Request) {
 	var (
 		volName     string
@@ -267,7 +331,9 @@ func (m *Master) dataPartitionOffline(w http.ResponseWriter, r *http.Request) {
 	if dp, err = vol.getDataPartitionByID(partitionID); err != nil {
 		goto errDeal
 	}
-	m.cluster.dataPartitionOffline(addr, volName, dp, HandleDataPartitionOfflineErr)
+	if err = m.cluster.dataPartitionOffline(addr, volName, dp, HandleDataPartitionOfflineErr); err != nil {
+		goto errDeal
+	}
 	rstMsg = fmt.Sprintf(AdminDataPartitionOffline+" dataPartitionID :%v  on node:%v  has offline success", partitionID, addr)
 	io.WriteString(w, rstMsg)
 	return
@@ -283,13 +349,14 @@ func (m *Master) createVol(w http.ResponseWriter, r *http.Request) {
 		err        error
 		msg        string
 		volType    string
+		policy     string
 		replicaNum int
 	)
 
-	if name, volType, replicaNum, err = parseCreateVolPara(r); err != nil {
+	if name, volType, policy, replicaNum, err = parseCreateVolPara(r); err != nil {
 		goto errDeal
 	}
-	if err = m.cluster.createVol(name, volType, uint8(replicaNum)); err != nil {
+	if err = m.cluster.createVol(name, volType, policy, uint8(replicaNum)); err != nil {
 		goto errDeal
 	}
 	msg = fmt.Sprintf("create vol[%v] successed\n", name)
@@ -305,13 +372,15 @@ errDeal:
 func (m *Master) addDataNode(w http.ResponseWriter, r *http.Request) {
 	var (
 		nodeAddr string
+		version  uint32
+		features uint64
 		err      error
 	)
-	if nodeAddr, err = parseAddDataNodePara(r); err != nil {
+	if nodeAddr, version, features, err = parseAddDataNodePara(r); err != nil {
 		goto errDeal
 	}
 
-	if err = m.cluster.addDataNode(nodeAddr); err != nil {
+	if err = m.cluster.addDataNode(nodeAddr, version, features); err != nil {
 		goto errDeal
 	}
 	io.WriteString(w, fmt.Sprintf("addDataNode %v successed\n", nodeAddr))
@@ -363,8 +432,10 @@ func (m *Master) dataNodeOffline(w http.ResponseWriter, r *http.Request) {
 	if node, err = m.cluster.getDataNode(offLineAddr); err != nil {
 		goto errDeal
 	}
-	m.cluster.dataNodeOffLine(node)
-	rstMsg = fmt.Sprintf("dataNodeOffline node [%v] has offline SUCCESS", offLineAddr)
+	if err = m.cluster.startDataNodeDecommission(node); err != nil {
+		goto errDeal
+	}
+	rstMsg = fmt.Sprintf("dataNodeOffline node [%v] start decommission SUCCESS", offLineAddr)
 	io.WriteString(w, rstMsg)
 	return
 errDeal:
@@ -402,17 +473,41 @@ errDeal:
 	return
 }
 
+func (m *Master) dataNodeCorruption(w http.ResponseWriter, r *http.Request) {
+	var (
+		report *proto.CorruptionReport
+		err    error
+	)
+	if report, err = parseCorruptionReport(r); err != nil {
+		goto errDeal
+	}
+	if _, err = m.cluster.getDataNode(report.Addr); err != nil {
+		goto errDeal
+	}
+	if err = m.cluster.dealCorruptionReport(report); err != nil {
+		goto errDeal
+	}
+	io.WriteString(w, fmt.Sprintf("%v", http.StatusOK))
+	return
+errDeal:
+	logMsg := getReturnMessage(DataNodeCorruption, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
 func (m *Master) addMetaNode(w http.ResponseWriter, r *http.Request) {
 	var (
 		nodeAddr string
 		id       uint64
+		version  uint32
+		features uint64
 		err      error
 	)
-	if nodeAddr, err = parseAddMetaNodePara(r); err != nil {
+	if nodeAddr, version, features, err = parseAddMetaNodePara(r); err != nil {
 		goto errDeal
 	}
 
-	if id, err = m.cluster.addMetaNode(nodeAddr); err != nil {
+	if id, err = m.cluster.addMetaNode(nodeAddr, version, features); err != nil {
 		goto errDeal
 	}
 	io.WriteString(w, fmt.Sprintf("%v", id))
@@ -423,14 +518,22 @@ errDeal:
 	return
 }
 
-func parseAddMetaNodePara(r *http.Request) (nodeAddr string, err error) {
+func parseAddMetaNodePara(r *http.Request) (nodeAddr string, version uint32, features uint64, err error) {
 	r.ParseForm()
-	return checkNodeAddr(r)
+	if nodeAddr, err = checkNodeAddr(r); err != nil {
+		return
+	}
+	version, features, err = parseVersionPara(r)
+	return
 }
 
-func parseAddDataNodePara(r *http.Request) (nodeAddr string, err error) {
+func parseAddDataNodePara(r *http.Request) (nodeAddr string, version uint32, features uint64, err error) {
 	r.ParseForm()
-	return checkNodeAddr(r)
+	if nodeAddr, err = checkNodeAddr(r); err != nil {
+		return
+	}
+	version, features, err = parseVersionPara(r)
+	return
 }
 
 func (m *Master) getMetaNode(w http.ResponseWriter, r *http.Request) {
@@ -528,8 +631,10 @@ func (m *Master) metaNodeOffline(w http.ResponseWriter, r *http.Request) {
 	if metaNode, err = m.cluster.getMetaNode(offLineAddr); err != nil {
 		goto errDeal
 	}
-	m.cluster.metaNodeOffLine(metaNode)
-	rstMsg = fmt.Sprintf("metaNodeOffline metaNode [%v] has offline SUCCESS", offLineAddr)
+	if err = m.cluster.startMetaNodeDecommission(metaNode); err != nil {
+		goto errDeal
+	}
+	rstMsg = fmt.Sprintf("metaNodeOffline metaNode [%v] start decommission SUCCESS", offLineAddr)
 	io.WriteString(w, rstMsg)
 	return
 errDeal:
@@ -538,6 +643,251 @@ errDeal:
 	return
 }
 
+func (m *Master) dataNodeDecommissionProgress(w http.ResponseWriter, r *http.Request) {
+	m.getDecommissionProgress(w, r, DecommissionDataNode)
+}
+
+func (m *Master) metaNodeDecommissionProgress(w http.ResponseWriter, r *http.Request) {
+	m.getDecommissionProgress(w, r, DecommissionMetaNode)
+}
+
+func (m *Master) dataNodeMaintenance(w http.ResponseWriter, r *http.Request) {
+	m.setNodeMaintenance(w, r, DecommissionDataNode)
+}
+
+func (m *Master) metaNodeMaintenance(w http.ResponseWriter, r *http.Request) {
+	m.setNodeMaintenance(w, r, DecommissionMetaNode)
+}
+
+func (m *Master) setNodeMaintenance(w http.ResponseWriter, r *http.Request, nodeType string) {
+	var (
+		nodeAddr string
+		reason   string
+		enable   bool
+		seconds  int64
+		mt       *Maintenance
+		rstMsg   string
+		err      error
+	)
+	if nodeAddr, enable, seconds, reason, err = parseMaintenancePara(r); err != nil {
+		goto errDeal
+	}
+	if !enable {
+		if err = m.cluster.stopMaintenance(nodeType, nodeAddr); err != nil {
+			goto errDeal
+		}
+		rstMsg = fmt.Sprintf("%v [%v] leave maintenance SUCCESS", nodeType, nodeAddr)
+		io.WriteString(w, rstMsg)
+		return
+	}
+	if mt, err = m.cluster.startMaintenance(nodeType, nodeAddr, reason, seconds); err != nil {
+		goto errDeal
+	}
+	rstMsg = fmt.Sprintf("%v [%v] enter maintenance until %v SUCCESS", nodeType, nodeAddr, mt.EndTime)
+	io.WriteString(w, rstMsg)
+	return
+errDeal:
+	logMsg := getReturnMessage("setNodeMaintenance", r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+func (m *Master) getDecommissionProgress(w http.ResponseWriter, r *http.Request, nodeType string) {
+	var (
+		nodeAddr string
+		d        *Decommission
+		body     []byte
+		err      error
+	)
+	if nodeAddr, err = parseDataNodeOfflinePara(r); err != nil {
+		goto errDeal
+	}
+	if d, err = m.cluster.getDecommission(nodeType, nodeAddr, ""); err != nil {
+		goto errDeal
+	}
+	if body, err = d.toJson(); err != nil {
+		goto errDeal
+	}
+	io.WriteString(w, string(body))
+	return
+errDeal:
+	logMsg := getReturnMessage("getDecommissionProgress", r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+func (m *Master) listAdminTasks(w http.ResponseWriter, r *http.Request) {
+	var (
+		body []byte
+		err  error
+	)
+	r.ParseForm()
+	if body, err = json.Marshal(m.cluster.getAdminTasks(r.FormValue(ParaNodeAddr))); err != nil {
+		goto errDeal
+	}
+	io.WriteString(w, string(body))
+	return
+errDeal:
+	logMsg := getReturnMessage("listAdminTasks", r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+func (m *Master) getAdminTask(w http.ResponseWriter, r *http.Request) {
+	var (
+		id   string
+		task *proto.AdminTask
+		body []byte
+		err  error
+	)
+	if id, err = parseAdminTaskPara(r); err != nil {
+		goto errDeal
+	}
+	if task, err = m.cluster.getAdminTask(id); err != nil {
+		goto errDeal
+	}
+	if body, err = json.Marshal(task); err != nil {
+		goto errDeal
+	}
+	io.WriteString(w, string(body))
+	return
+errDeal:
+	logMsg := getReturnMessage("getAdminTask", r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+func (m *Master) cancelAdminTask(w http.ResponseWriter, r *http.Request) {
+	var (
+		id     string
+		rstMsg string
+		err    error
+	)
+	if id, err = parseAdminTaskPara(r); err != nil {
+		goto errDeal
+	}
+	if err = m.cluster.cancelAdminTask(id); err != nil {
+		goto errDeal
+	}
+	rstMsg = fmt.Sprintf("cancel admin task[%v] success", id)
+	io.WriteString(w, rstMsg)
+	return
+errDeal:
+	logMsg := getReturnMessage("cancelAdminTask", r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+//backupMetadata streams a consistent snapshot of the master store,
+//the status code can not be changed once the body is written,so a failure is only logged
+func (m *Master) backupMetadata(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	count, err := m.fsm.backup(w)
+	if err != nil {
+		log.LogErrorf("action[backupMetadata] from[%v] failed after %v records,err:%v", r.RemoteAddr, count, err)
+		return
+	}
+	log.LogInfof("action[backupMetadata] from[%v] %v records", r.RemoteAddr, count)
+}
+
+func (m *Master) decommissionDisk(w http.ResponseWriter, r *http.Request) {
+	var (
+		node         *DataNode
+		rstMsg       string
+		nodeAddr     string
+		diskPath     string
+		migrateLocal bool
+		err          error
+	)
+	if nodeAddr, diskPath, migrateLocal, err = parseDecommissionDiskPara(r); err != nil {
+		goto errDeal
+	}
+	if node, err = m.cluster.getDataNode(nodeAddr); err != nil {
+		goto errDeal
+	}
+	if err = m.cluster.startDiskDecommission(node, diskPath, migrateLocal); err != nil {
+		goto errDeal
+	}
+	rstMsg = fmt.Sprintf("decommissionDisk node [%v] disk [%v] start decommission SUCCESS", nodeAddr, diskPath)
+	io.WriteString(w, rstMsg)
+	return
+errDeal:
+	logMsg := getReturnMessage(AdminDecommissionDisk, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+func (m *Master) diskDecommissionProgress(w http.ResponseWriter, r *http.Request) {
+	var (
+		nodeAddr string
+		diskPath string
+		d        *Decommission
+		body     []byte
+		err      error
+	)
+	if nodeAddr, diskPath, _, err = parseDecommissionDiskPara(r); err != nil {
+		goto errDeal
+	}
+	if d, err = m.cluster.getDecommission(DecommissionDisk, nodeAddr, diskPath); err != nil {
+		goto errDeal
+	}
+	if body, err = d.toJson(); err != nil {
+		goto errDeal
+	}
+	io.WriteString(w, string(body))
+	return
+errDeal:
+	logMsg := getReturnMessage(AdminDiskDecommission, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+func (m *Master) scrubVol(w http.ResponseWriter, r *http.Request) {
+	var (
+		volName     string
+		bytesPerSec int64
+		report      *ScrubReport
+		err         error
+	)
+	if volName, bytesPerSec, err = parseScrubPara(r); err != nil {
+		goto errDeal
+	}
+	if report, err = m.cluster.startScrub(volName, bytesPerSec); err != nil {
+		goto errDeal
+	}
+	io.WriteString(w, fmt.Sprintf("scrub vol[%v] partitions[%v] is running", volName, report.TotalPartitions))
+	return
+errDeal:
+	logMsg := getReturnMessage(AdminScrubVol, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+func (m *Master) getScrubReport(w http.ResponseWriter, r *http.Request) {
+	var (
+		volName string
+		report  *ScrubReport
+		body    []byte
+		err     error
+	)
+	r.ParseForm()
+	if volName, err = checkVolPara(r); err != nil {
+		goto errDeal
+	}
+	if report, err = m.cluster.getScrubReport(volName); err != nil {
+		goto errDeal
+	}
+	if body, err = report.toJson(); err != nil {
+		goto errDeal
+	}
+	io.WriteString(w, string(body))
+	return
+errDeal:
+	logMsg := getReturnMessage(AdminScrubReport, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
 func (m *Master) metaNodeTaskResponse(w http.ResponseWriter, r *http.Request) {
 	var (
 		metaNode *MetaNode
@@ -638,11 +988,109 @@ func parseGetDataNodePara(r *http.Request) (nodeAddr string, err error) {
 	return checkNodeAddr(r)
 }
 
+func parseAdminTaskPara(r *http.Request) (id string, err error) {
+	r.ParseForm()
+	if id = r.FormValue(ParaId); id == "" {
+		err = paraNotFound(ParaId)
+	}
+	return
+}
+
 func parseDataNodeOfflinePara(r *http.Request) (nodeAddr string, err error) {
 	r.ParseForm()
 	return checkNodeAddr(r)
 }
 
+func parseDecommissionDiskPara(r *http.Request) (nodeAddr, diskPath string, migrateLocal bool, err error) {
+	r.ParseForm()
+	if nodeAddr, err = checkNodeAddr(r); err != nil {
+		return
+	}
+	if diskPath = r.FormValue(ParaDiskPath); diskPath == "" {
+		err = paraNotFound(ParaDiskPath)
+		return
+	}
+	if local := r.FormValue(ParaMigrateLocal); local != "" {
+		if migrateLocal, err = strconv.ParseBool(local); err != nil {
+			err = UnMatchPara
+			return
+		}
+	}
+	return
+}
+
+func parseMaintenancePara(r *http.Request) (nodeAddr string, enable bool, seconds int64, reason string, err error) {
+	r.ParseForm()
+	if nodeAddr, err = checkNodeAddr(r); err != nil {
+		return
+	}
+	enable = true
+	if value := r.FormValue(ParaEnable); value != "" {
+		if enable, err = strconv.ParseBool(value); err != nil {
+			err = UnMatchPara
+			return
+		}
+	}
+	seconds = DefaultMaintenanceSeconds
+	if value := r.FormValue(ParaDuration); value != "" {
+		if seconds, err = strconv.ParseInt(value, 10, 64); err != nil {
+			err = UnMatchPara
+			return
+		}
+	}
+	reason = r.FormValue(ParaReason)
+	return
+}
+
+func parseScrubPara(r *http.Request) (volName string, bytesPerSec int64, err error) {
+	r.ParseForm()
+	if volName, err = checkVolPara(r); err != nil {
+		return
+	}
+	if value := r.FormValue(ParaRate); value != "" {
+		if bytesPerSec, err = strconv.ParseInt(value, 10, 64); err != nil {
+			err = UnMatchPara
+			return
+		}
+	}
+	return
+}
+
+//parseFreezePara requires exactly one of id,addr and name, the status is zero to release
+func parseFreezePara(r *http.Request) (partitionID uint64, nodeAddr, volName string, status int8, err error) {
+	r.ParseForm()
+	targets := 0
+	if value := r.FormValue(ParaId); value != "" {
+		if partitionID, err = strconv.ParseUint(value, 10, 64); err != nil {
+			err = UnMatchPara
+			return
+		}
+		targets++
+	}
+	if nodeAddr = r.FormValue(ParaNodeAddr); nodeAddr != "" {
+		targets++
+	}
+	if volName = r.FormValue(ParaName); volName != "" {
+		targets++
+	}
+	if targets != 1 {
+		err = fmt.Errorf("one of %v,%v and %v is required", ParaId, ParaNodeAddr, ParaName)
+		return
+	}
+	enable := true
+	if value := r.FormValue(ParaEnable); value != "" {
+		if enable, err = strconv.ParseBool(value); err != nil {
+			err = UnMatchPara
+			return
+		}
+	}
+	if !enable {
+		return
+	}
+	status, err = parseManualStatus(r.FormValue(ParaStatus))
+	return
+}
+
 func parseTaskResponse(r *http.Request) (tr *proto.AdminTask, err error) {
 	var body []byte
 	r.ParseForm()
@@ -657,7 +1105,22 @@ func parseTaskResponse(r *http.Request) (tr *proto.AdminTask, err error) {
 	return
 }
 
-func parseCreateVolPara(r *http.Request) (name, volType string, replicaNum int, err error) {
+func parseCorruptionReport(r *http.Request) (report *proto.CorruptionReport, err error) {
+	var body []byte
+	if body, err = ioutil.ReadAll(r.Body); err != nil {
+		return
+	}
+	report = &proto.CorruptionReport{}
+	if err = json.Unmarshal(body, report); err != nil {
+		return
+	}
+	if report.Addr == "" || len(report.Files) == 0 {
+		err = UnMatchPara
+	}
+	return
+}
+
+func parseCreateVolPara(r *http.Request) (name, volType, policy string, replicaNum int, err error) {
 	r.ParseForm()
 	if name, err = checkVolPara(r); err != nil {
 		return
@@ -671,6 +1134,25 @@ func parseCreateVolPara(r *http.Request) (name, volType string, replicaNum int,
 	if volType, err = parseDataPartitionType(r); err != nil {
 		return
 	}
+	//the ec partitions are added to an extent vol,the clients write its new files to the replicated ones
+	if volType == proto.ECPartition {
+		err = InvalidDataPartitionType
+		return
+	}
+	if policy, err = parsePlacementPolicy(r); err != nil {
+		return
+	}
+	return
+}
+
+func parsePlacementPolicy(r *http.Request) (policy string, err error) {
+	if policy = r.FormValue(ParaPlacementPolicy); policy == "" {
+		policy = PlacementPolicyRack
+		return
+	}
+	if !(policy == PlacementPolicyRack || policy == PlacementPolicyZone || policy == PlacementPolicyCrossZone) {
+		err = InvalidPlacementPolicy
+	}
 	return
 }
 
@@ -698,7 +1180,8 @@ func parseDataPartitionType(r *http.Request) (partitionType string, err error) {
 		return
 	}
 
-	if !(strings.TrimSpace(partitionType) == proto.ExtentPartition || strings.TrimSpace(partitionType) == proto.TinyPartition) {
+	partitionType = strings.TrimSpace(partitionType)
+	if !(partitionType == proto.ExtentPartition || partitionType == proto.TinyPartition || partitionType == proto.ECPartition) {
 		err = InvalidDataPartitionType
 		return
 	}
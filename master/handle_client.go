@@ -20,6 +20,7 @@ type DataPartitionResponse struct {
 	Status        int8
 	ReplicaNum    uint8
 	PartitionType string
+	ECDataNum     uint8
 	Hosts         []string
 }
 
@@ -47,6 +48,8 @@ type VolView struct {
 	VolType        string
 	MetaPartitions []*MetaPartitionView
 	DataPartitions []*DataPartitionResponse
+	MinVersion     uint32
+	Features       uint64
 }
 
 func NewVolView(name, volType string) (view *VolView) {
@@ -154,6 +157,7 @@ errDeal:
 
 func (m *Master) getVolView(vol *Vol) (view *VolView) {
 	view = NewVolView(vol.Name, vol.VolType)
+	view.MinVersion, view.Features = m.cluster.getClusterVersion()
 	setMetaPartitions(vol, view, m.cluster.getLiveMetaNodesRate())
 	setDataPartitions(vol, view, m.cluster.getLiveDataNodesRate())
 	return
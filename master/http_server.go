@@ -3,9 +3,11 @@ package master
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/tiglabs/baudstorage/util/log"
+	"github.com/tiglabs/baudstorage/util/metrics"
 )
 
 const (
@@ -15,12 +17,30 @@ const (
 	AdminLoadDataPartition    = "/dataPartition/load"
 	AdminCreateDataPartition  = "/dataPartition/create"
 	AdminDataPartitionOffline = "/dataPartition/offline"
+	AdminFreezeDataPartition  = "/dataPartition/freeze"
 	AdminCreateVol            = "/admin/createVol"
 	AdminGetIp                = "/admin/getIp"
 	AdminCreateMP             = "/metaPartition/create"
 	AdminSetCompactStatus     = "/compactStatus/set"
 	AdminGetCompactStatus     = "/compactStatus/get"
 	AdminSetMetaNodeThreshold = "/threshold/set"
+	AdminDecommissionDisk     = "/disk/decommission"
+	AdminDiskDecommission     = "/disk/decommissionProgress"
+	AdminListTasks            = "/adminTask/list"
+	AdminGetTask              = "/adminTask/get"
+	AdminCancelTask           = "/adminTask/cancel"
+	AdminBackupMetadata       = "/admin/backupMetadata"
+	AdminScrubVol             = "/vol/scrub"
+	AdminScrubReport          = "/vol/scrubReport"
+	AdminSetVolQos            = "/vol/setQos"
+	AdminGetVolQos            = "/vol/qos"
+	AdminSetVolLifecycle      = "/vol/setLifecycle"
+	AdminGetVolLifecycle      = "/vol/lifecycle"
+	AdminSetVolCompression    = "/vol/setCompression"
+	AdminGetVolCompression    = "/vol/compression"
+	AdminRotateVolKey         = "/vol/rotateKey"
+	AdminGetVolEncryption     = "/vol/encryption"
+	AdminGetRaftCommit        = "/admin/getRaftCommit"
 
 	// Client APIs
 	ClientDataPartitions = "/client/dataPartitions"
@@ -36,15 +56,20 @@ const (
 	AddDataNode               = "/dataNode/add"
 	DataNodeOffline           = "/dataNode/offline"
 	GetDataNode               = "/dataNode/get"
+	DataNodeDecommission      = "/dataNode/decommissionProgress"
+	DataNodeMaintenance       = "/dataNode/maintenance"
 	AddMetaNode               = "/metaNode/add"
 	MetaNodeOffline           = "/metaNode/offline"
 	GetMetaNode               = "/metaNode/get"
+	MetaNodeDecommission      = "/metaNode/decommissionProgress"
+	MetaNodeMaintenance       = "/metaNode/maintenance"
 	AdminLoadMetaPartition    = "/metaPartition/load"
 	AdminMetaPartitionOffline = "/metaPartition/offline"
 
 	// Operation response
-	MetaNodeResponse = "/metaNode/response" // Method: 'POST', ContentType: 'application/json'
-	DataNodeResponse = "/dataNode/response" // Method: 'POST', ContentType: 'application/json'
+	MetaNodeResponse   = "/metaNode/response"   // Method: 'POST', ContentType: 'application/json'
+	DataNodeResponse   = "/dataNode/response"   // Method: 'POST', ContentType: 'application/json'
+	DataNodeCorruption = "/dataNode/corruption" // Method: 'POST', ContentType: 'application/json'
 )
 
 func (m *Master) startHttpService() (err error) {
@@ -57,11 +82,13 @@ func (m *Master) startHttpService() (err error) {
 
 func (m *Master) handleFunctions() {
 	http.HandleFunc(AdminGetIp, m.getIpAndClusterName)
+	metrics.RegisterHandler()
 	http.HandleFunc(AdminGetCluster, m.getCluster)
 	http.Handle(AdminGetDataPartition, m.handlerWithInterceptor())
 	http.Handle(AdminCreateDataPartition, m.handlerWithInterceptor())
 	http.Handle(AdminLoadDataPartition, m.handlerWithInterceptor())
 	http.Handle(AdminDataPartitionOffline, m.handlerWithInterceptor())
+	http.Handle(AdminFreezeDataPartition, m.handlerWithInterceptor())
 	http.Handle(AdminCreateVol, m.handlerWithInterceptor())
 	http.Handle(AddDataNode, m.handlerWithInterceptor())
 	http.Handle(AddMetaNode, m.handlerWithInterceptor())
@@ -69,12 +96,17 @@ func (m *Master) handleFunctions() {
 	http.Handle(MetaNodeOffline, m.handlerWithInterceptor())
 	http.Handle(GetDataNode, m.handlerWithInterceptor())
 	http.Handle(GetMetaNode, m.handlerWithInterceptor())
+	http.Handle(DataNodeDecommission, m.handlerWithInterceptor())
+	http.Handle(MetaNodeDecommission, m.handlerWithInterceptor())
+	http.Handle(DataNodeMaintenance, m.handlerWithInterceptor())
+	http.Handle(MetaNodeMaintenance, m.handlerWithInterceptor())
 	//http.Handle(AdminLoadMetaPartition, m.handlerWithInterceptor())
 	http.Handle(AdminMetaPartitionOffline, m.handlerWithInterceptor())
 	http.Handle(ClientDataPartitions, m.handlerWithInterceptor())
 	http.Handle(ClientVol, m.handlerWithInterceptor())
 	http.Handle(ClientMetaPartition, m.handlerWithInterceptor())
 	http.Handle(DataNodeResponse, m.handlerWithInterceptor())
+	http.Handle(DataNodeCorruption, m.handlerWithInterceptor())
 	http.Handle(MetaNodeResponse, m.handlerWithInterceptor())
 	http.Handle(AdminCreateMP, m.handlerWithInterceptor())
 	http.Handle(ClientVolStat, m.handlerWithInterceptor())
@@ -83,6 +115,23 @@ func (m *Master) handleFunctions() {
 	http.Handle(AdminSetCompactStatus, m.handlerWithInterceptor())
 	http.Handle(AdminGetCompactStatus, m.handlerWithInterceptor())
 	http.Handle(AdminSetMetaNodeThreshold, m.handlerWithInterceptor())
+	http.Handle(AdminDecommissionDisk, m.handlerWithInterceptor())
+	http.Handle(AdminDiskDecommission, m.handlerWithInterceptor())
+	http.Handle(AdminListTasks, m.handlerWithInterceptor())
+	http.Handle(AdminGetTask, m.handlerWithInterceptor())
+	http.Handle(AdminCancelTask, m.handlerWithInterceptor())
+	http.Handle(AdminBackupMetadata, m.handlerWithInterceptor())
+	http.Handle(AdminScrubVol, m.handlerWithInterceptor())
+	http.Handle(AdminScrubReport, m.handlerWithInterceptor())
+	http.Handle(AdminSetVolQos, m.handlerWithInterceptor())
+	http.Handle(AdminGetVolQos, m.handlerWithInterceptor())
+	http.Handle(AdminSetVolLifecycle, m.handlerWithInterceptor())
+	http.Handle(AdminGetVolLifecycle, m.handlerWithInterceptor())
+	http.Handle(AdminSetVolCompression, m.handlerWithInterceptor())
+	http.Handle(AdminGetVolCompression, m.handlerWithInterceptor())
+	http.Handle(AdminRotateVolKey, m.handlerWithInterceptor())
+	http.Handle(AdminGetVolEncryption, m.handlerWithInterceptor())
+	http.Handle(AdminGetRaftCommit, m.handlerWithInterceptor())
 
 	return
 }
@@ -90,10 +139,12 @@ func (m *Master) handleFunctions() {
 func (m *Master) handlerWithInterceptor() http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			if m.partition.IsLeader() {
+			if m.partition.IsLeader() || m.canServeStaleRead(r) {
+				start := time.Now()
 				m.ServeHTTP(w, r)
+				observeRequest(r.URL.Path, start)
 			} else {
-				http.Error(w, m.leaderInfo.addr, http.StatusForbidden)
+				m.proxyToLeader(w, r)
 			}
 		})
 }
@@ -110,6 +161,8 @@ func (m *Master) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		m.loadDataPartition(w, r)
 	case AdminDataPartitionOffline:
 		m.dataPartitionOffline(w, r)
+	case AdminFreezeDataPartition:
+		m.freezeDataPartition(w, r)
 	case AdminCreateVol:
 		m.createVol(w, r)
 	case AddDataNode:
@@ -118,14 +171,24 @@ func (m *Master) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		m.getDataNode(w, r)
 	case DataNodeOffline:
 		m.dataNodeOffline(w, r)
+	case DataNodeDecommission:
+		m.dataNodeDecommissionProgress(w, r)
+	case DataNodeMaintenance:
+		m.dataNodeMaintenance(w, r)
 	case DataNodeResponse:
 		m.dataNodeTaskResponse(w, r)
+	case DataNodeCorruption:
+		m.dataNodeCorruption(w, r)
 	case AddMetaNode:
 		m.addMetaNode(w, r)
 	case GetMetaNode:
 		m.getMetaNode(w, r)
 	case MetaNodeOffline:
 		m.metaNodeOffline(w, r)
+	case MetaNodeDecommission:
+		m.metaNodeDecommissionProgress(w, r)
+	case MetaNodeMaintenance:
+		m.metaNodeMaintenance(w, r)
 	case MetaNodeResponse:
 		m.metaNodeTaskResponse(w, r)
 	case ClientDataPartitions:
@@ -152,6 +215,40 @@ func (m *Master) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		m.getCompactStatus(w, r)
 	case AdminSetMetaNodeThreshold:
 		m.setMetaNodeThreshold(w, r)
+	case AdminDecommissionDisk:
+		m.decommissionDisk(w, r)
+	case AdminDiskDecommission:
+		m.diskDecommissionProgress(w, r)
+	case AdminListTasks:
+		m.listAdminTasks(w, r)
+	case AdminGetTask:
+		m.getAdminTask(w, r)
+	case AdminCancelTask:
+		m.cancelAdminTask(w, r)
+	case AdminBackupMetadata:
+		m.backupMetadata(w, r)
+	case AdminScrubVol:
+		m.scrubVol(w, r)
+	case AdminScrubReport:
+		m.getScrubReport(w, r)
+	case AdminSetVolQos:
+		m.setVolQos(w, r)
+	case AdminGetVolQos:
+		m.getVolQos(w, r)
+	case AdminSetVolLifecycle:
+		m.setVolLifecycle(w, r)
+	case AdminGetVolLifecycle:
+		m.getVolLifecycle(w, r)
+	case AdminSetVolCompression:
+		m.setVolCompression(w, r)
+	case AdminGetVolCompression:
+		m.getVolCompression(w, r)
+	case AdminRotateVolKey:
+		m.rotateVolKey(w, r)
+	case AdminGetVolEncryption:
+		m.getVolEncryption(w, r)
+	case AdminGetRaftCommit:
+		m.getRaftCommit(w, r)
 	default:
 
 	}
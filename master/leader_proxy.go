@@ -0,0 +1,153 @@
+package master
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+func (m *Master) selfAddr() string {
+	return m.ip + ColonSplit + m.port
+}
+
+/*proxyToLeader forwards the request of a follower to the leader.
+the request which has been proxied once, or the leader is unknown or is this master itself,
+is answered with 403 and the leader address as before,the caller finds the leader by itself*/
+func (m *Master) proxyToLeader(w http.ResponseWriter, r *http.Request) {
+	leaderAddr := m.leaderInfo.addr
+	if leaderAddr == "" || leaderAddr == m.selfAddr() || r.Header.Get(HeaderProxiedBy) != "" {
+		http.Error(w, leaderAddr, http.StatusForbidden)
+		return
+	}
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = "http"
+			req.URL.Host = leaderAddr
+			req.Header.Set(HeaderProxiedBy, m.selfAddr())
+		},
+	}
+	log.LogDebugf("action[proxyToLeader] proxy %v from %v to leader %v", r.URL.Path, r.RemoteAddr, leaderAddr)
+	proxy.ServeHTTP(w, r)
+}
+
+/*canServeStaleRead returns true if the caller accepts a stale view and the follower
+lags behind the leader less than StaleReadMaxLagEntries,only the read-only client views are allowed.
+the commit of the follower itself stops advancing once it loses the leader,so the lag is also
+measured against the commit the leader reported within the last StaleReadLeaseSec*/
+func (m *Master) canServeStaleRead(r *http.Request) bool {
+	if r.URL.Path != ClientVol && r.URL.Path != ClientDataPartitions {
+		return false
+	}
+	if stale, err := strconv.ParseBool(r.URL.Query().Get(ParaStale)); err != nil || !stale {
+		return false
+	}
+	status := m.partition.Status()
+	if status == nil || status.Leader == 0 || status.RestoringSnapshot || status.Stopped {
+		return false
+	}
+	if status.Commit > status.Applied+StaleReadMaxLagEntries {
+		return false
+	}
+	return m.staleLease.allows(status.Applied, time.Now())
+}
+
+/*staleReadLease is the commit of the leader a follower learnt last and the time it asked for it,
+the reads of a follower are at most StaleReadLeaseSec and StaleReadMaxLagEntries behind the leader*/
+type staleReadLease struct {
+	sync.RWMutex
+	leaderCommit uint64
+	renewTime    time.Time
+}
+
+func (lease *staleReadLease) renew(leaderCommit uint64, renewTime time.Time) {
+	lease.Lock()
+	defer lease.Unlock()
+	lease.leaderCommit = leaderCommit
+	lease.renewTime = renewTime
+}
+
+func (lease *staleReadLease) reset() {
+	lease.renew(0, time.Time{})
+}
+
+func (lease *staleReadLease) allows(applied uint64, now time.Time) bool {
+	lease.RLock()
+	defer lease.RUnlock()
+	if lease.renewTime.IsZero() || now.Sub(lease.renewTime) > StaleReadLeaseSec*time.Second {
+		return false
+	}
+	return lease.leaderCommit <= applied+StaleReadMaxLagEntries
+}
+
+func (m *Master) scheduleRenewStaleReadLease() {
+	go func() {
+		for {
+			if !m.partition.IsLeader() {
+				m.renewStaleReadLease()
+			}
+			time.Sleep(time.Second * StaleReadLeaseRenewSec)
+		}
+	}()
+}
+
+/*renewStaleReadLease asks the leader for its commit,the lease counts from the time the
+request was sent as the commit may be older than the reply*/
+func (m *Master) renewStaleReadLease() (err error) {
+	var commit uint64
+	leaderAddr := m.leaderInfo.addr
+	if leaderAddr == "" || leaderAddr == m.selfAddr() {
+		return
+	}
+	start := time.Now()
+	if commit, err = m.getLeaderCommit(leaderAddr); err != nil {
+		log.LogWarnf("action[renewStaleReadLease] leader[%v] err[%v]", leaderAddr, err)
+		return
+	}
+	if leaderAddr == m.leaderInfo.addr {
+		m.staleLease.renew(commit, start)
+	}
+	return
+}
+
+func (m *Master) getLeaderCommit(leaderAddr string) (commit uint64, err error) {
+	var (
+		req  *http.Request
+		resp *http.Response
+		body []byte
+	)
+	if req, err = http.NewRequest(http.MethodGet, "http://"+leaderAddr+AdminGetRaftCommit, nil); err != nil {
+		return
+	}
+	//a master which is not the leader any more answers 403 instead of proxying
+	req.Header.Set(HeaderProxiedBy, m.selfAddr())
+	client := &http.Client{Timeout: time.Second * StaleReadLeaseRenewSec}
+	if resp, err = client.Do(req); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if body, err = ioutil.ReadAll(resp.Body); err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("status[%v] body[%v]", resp.StatusCode, string(body))
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(body)), 10, 64)
+}
+
+//getRaftCommit answers the commit of the leader to the followers renewing their stale read lease
+func (m *Master) getRaftCommit(w http.ResponseWriter, r *http.Request) {
+	status := m.partition.Status()
+	if status == nil {
+		http.Error(w, "raft partition has no status", http.StatusInternalServerError)
+		return
+	}
+	io.WriteString(w, strconv.FormatUint(status.Commit, 10))
+}
@@ -0,0 +1,67 @@
+package master
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStaleReadLease(t *testing.T) {
+	var lease staleReadLease
+	now := time.Now()
+	if lease.allows(1000, now) {
+		t.Fatal("stale read allowed before the lease was renewed")
+	}
+	lease.renew(1000+StaleReadMaxLagEntries, now)
+	if !lease.allows(1000, now.Add(time.Second)) {
+		t.Fatal("stale read refused within the lag and the lease")
+	}
+	if lease.allows(999, now) {
+		t.Fatal("stale read allowed beyond the lag behind the leader commit")
+	}
+	//a follower cut off from the leader stops serving once the lease expires
+	if lease.allows(1000, now.Add(StaleReadLeaseSec*time.Second+time.Millisecond)) {
+		t.Fatal("stale read allowed after the lease expired")
+	}
+	lease.reset()
+	if lease.allows(1000, now) {
+		t.Fatal("stale read allowed after the leader changed")
+	}
+}
+
+func TestRenewStaleReadLease(t *testing.T) {
+	var proxiedBy string
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != AdminGetRaftCommit {
+			http.NotFound(w, r)
+			return
+		}
+		proxiedBy = r.Header.Get(HeaderProxiedBy)
+		io.WriteString(w, "4242")
+	}))
+	defer leader.Close()
+	m := &Master{ip: "127.0.0.1", port: "1", leaderInfo: &LeaderInfo{addr: strings.TrimPrefix(leader.URL, "http://")}}
+	if err := m.renewStaleReadLease(); err != nil {
+		t.Fatal(err)
+	}
+	if proxiedBy != m.selfAddr() {
+		t.Fatalf("the lease request may be proxied again, header %q", proxiedBy)
+	}
+	if m.staleLease.leaderCommit != 4242 || !m.staleLease.allows(4242-StaleReadMaxLagEntries, time.Now()) {
+		t.Fatalf("lease commit %v renewed at %v", m.staleLease.leaderCommit, m.staleLease.renewTime)
+	}
+
+	//a master which lost the leadership renews nothing
+	follower := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "", http.StatusForbidden)
+	}))
+	defer follower.Close()
+	m.staleLease.reset()
+	m.leaderInfo.addr = strings.TrimPrefix(follower.URL, "http://")
+	if err := m.renewStaleReadLease(); err == nil || m.staleLease.allows(4242, time.Now()) {
+		t.Fatalf("lease renewed by a follower: %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package master
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+/*Maintenance marks a node which is taken down on purpose for a bounded window, e.g. a kernel upgrade.
+While the window is open no new partition is placed on the node, and the master does not
+repair or offline its partitions because the node stops reporting. It is persisted in the raft
+state machine, and the records are immutable, a change stores a new record.*/
+type Maintenance struct {
+	NodeType  string
+	Addr      string
+	Reason    string
+	StartTime int64
+	EndTime   int64
+}
+
+func newMaintenance(nodeType, addr, reason string, seconds int64) (mt *Maintenance) {
+	mt = &Maintenance{NodeType: nodeType, Addr: addr, Reason: reason}
+	mt.StartTime = time.Now().Unix()
+	mt.EndTime = mt.StartTime + seconds
+	return
+}
+
+func (mt *Maintenance) isExpired() bool {
+	return time.Now().Unix() >= mt.EndTime
+}
+
+func maintenanceKey(nodeType, addr string) (key string) {
+	return nodeType + KeySeparator + addr
+}
+
+func inMaintenanceWindow(endTime int64) bool {
+	return time.Now().Unix() < endTime
+}
+
+func (dataNode *DataNode) setMaintenanceEnd(endTime int64) {
+	dataNode.Lock()
+	defer dataNode.Unlock()
+	dataNode.MaintenanceEnd = endTime
+}
+
+//the caller must hold the lock of dataNode
+func (dataNode *DataNode) inMaintenance() bool {
+	return inMaintenanceWindow(dataNode.MaintenanceEnd)
+}
+
+func (dataNode *DataNode) isInMaintenance() bool {
+	dataNode.RLock()
+	defer dataNode.RUnlock()
+	return dataNode.inMaintenance()
+}
+
+func (metaNode *MetaNode) setMaintenanceEnd(endTime int64) {
+	metaNode.Lock()
+	defer metaNode.Unlock()
+	metaNode.MaintenanceEnd = endTime
+}
+
+//the caller must hold the lock of metaNode
+func (metaNode *MetaNode) inMaintenance() bool {
+	return inMaintenanceWindow(metaNode.MaintenanceEnd)
+}
+
+func (metaNode *MetaNode) isInMaintenance() bool {
+	metaNode.RLock()
+	defer metaNode.RUnlock()
+	return metaNode.inMaintenance()
+}
+
+func (c *Cluster) getMaintenance(nodeType, addr string) (mt *Maintenance, err error) {
+	value, ok := c.maintenances.Load(maintenanceKey(nodeType, addr))
+	if !ok {
+		return nil, errors.Annotatef(MaintenanceNotFound, "%v %v", nodeType, addr)
+	}
+	return value.(*Maintenance), nil
+}
+
+func (c *Cluster) getMaintenances() (mts []*Maintenance) {
+	mts = make([]*Maintenance, 0)
+	c.maintenances.Range(func(key, value interface{}) bool {
+		mts = append(mts, value.(*Maintenance))
+		return true
+	})
+	return
+}
+
+func (c *Cluster) putMaintenance(mt *Maintenance) {
+	c.maintenances.Store(maintenanceKey(mt.NodeType, mt.Addr), mt)
+	c.setNodeMaintenanceEnd(mt.NodeType, mt.Addr, mt.EndTime)
+}
+
+func (c *Cluster) deleteMaintenance(nodeType, addr string) {
+	c.maintenances.Delete(maintenanceKey(nodeType, addr))
+	c.setNodeMaintenanceEnd(nodeType, addr, 0)
+}
+
+func (c *Cluster) setNodeMaintenanceEnd(nodeType, addr string, endTime int64) {
+	switch nodeType {
+	case DecommissionDataNode:
+		if dataNode, err := c.getDataNode(addr); err == nil {
+			dataNode.setMaintenanceEnd(endTime)
+		}
+	case DecommissionMetaNode:
+		if metaNode, err := c.getMetaNode(addr); err == nil {
+			metaNode.setMaintenanceEnd(endTime)
+		}
+	}
+}
+
+func (c *Cluster) isDataNodeInMaintenance(addr string) bool {
+	dataNode, err := c.getDataNode(addr)
+	if err != nil {
+		return false
+	}
+	return dataNode.isInMaintenance()
+}
+
+func (c *Cluster) isMetaNodeInMaintenance(addr string) bool {
+	metaNode, err := c.getMetaNode(addr)
+	if err != nil {
+		return false
+	}
+	return metaNode.isInMaintenance()
+}
+
+/*startMaintenance opens or extends the maintenance window of a node, the window is
+counted from now and can not be longer than MaxMaintenanceSeconds.*/
+func (c *Cluster) startMaintenance(nodeType, addr, reason string, seconds int64) (mt *Maintenance, err error) {
+	if seconds <= 0 || seconds > c.cfg.MaxMaintenanceSeconds {
+		return nil, fmt.Errorf("maintenance duration[%v] must be in (0,%v] seconds", seconds, c.cfg.MaxMaintenanceSeconds)
+	}
+	switch nodeType {
+	case DecommissionDataNode:
+		_, err = c.getDataNode(addr)
+	case DecommissionMetaNode:
+		_, err = c.getMetaNode(addr)
+	}
+	if err != nil {
+		return
+	}
+	mt = newMaintenance(nodeType, addr, reason, seconds)
+	if err = c.syncPutMaintenance(mt); err != nil {
+		return
+	}
+	c.putMaintenance(mt)
+	Warn(c.Name, fmt.Sprintf("clusterID[%v] %v[%v] enter maintenance until %v,reason[%v]",
+		c.Name, nodeType, addr, time.Unix(mt.EndTime, 0).Format(time.RFC3339), reason))
+	return
+}
+
+func (c *Cluster) stopMaintenance(nodeType, addr string) (err error) {
+	var mt *Maintenance
+	if mt, err = c.getMaintenance(nodeType, addr); err != nil {
+		return
+	}
+	if err = c.syncDeleteMaintenance(mt); err != nil {
+		return
+	}
+	c.deleteMaintenance(nodeType, addr)
+	Warn(c.Name, fmt.Sprintf("clusterID[%v] %v[%v] leave maintenance", c.Name, nodeType, addr))
+	return
+}
+
+func (c *Cluster) startCheckMaintenances() {
+	go func() {
+		for {
+			if c.partition.IsLeader() {
+				c.checkMaintenances()
+			}
+			time.Sleep(time.Second * DefaultCheckMaintenanceIntervalSeconds)
+		}
+	}()
+}
+
+/*checkMaintenances closes the expired windows, so a node which does not come back in time
+is handled as a failed node again.*/
+func (c *Cluster) checkMaintenances() {
+	for _, mt := range c.getMaintenances() {
+		if !mt.isExpired() {
+			continue
+		}
+		if err := c.syncDeleteMaintenance(mt); err != nil {
+			log.LogErrorf("action[checkMaintenances] %v[%v] delete failed,err:%v", mt.NodeType, mt.Addr, err)
+			continue
+		}
+		c.deleteMaintenance(mt.NodeType, mt.Addr)
+		Warn(c.Name, fmt.Sprintf("clusterID[%v] %v[%v] maintenance window expired", c.Name, mt.NodeType, mt.Addr))
+	}
+}
+
+//skipDataNodeTasksInMaintenance drops the repair tasks sent to data nodes in maintenance
+func (c *Cluster) skipDataNodeTasksInMaintenance(tasks []*proto.AdminTask) (remain []*proto.AdminTask) {
+	remain = make([]*proto.AdminTask, 0, len(tasks))
+	for _, t := range tasks {
+		if t != nil && c.isDataNodeInMaintenance(t.OperatorAddr) {
+			log.LogInfof("action[skipDataNodeTasksInMaintenance] skip task[%v] of node in maintenance", t.ID)
+			continue
+		}
+		remain = append(remain, t)
+	}
+	return
+}
+
+//skipMetaNodeTasksInMaintenance drops the repair tasks sent to meta nodes in maintenance
+func (c *Cluster) skipMetaNodeTasksInMaintenance(tasks []*proto.AdminTask) (remain []*proto.AdminTask) {
+	remain = make([]*proto.AdminTask, 0, len(tasks))
+	for _, t := range tasks {
+		if t != nil && c.isMetaNodeInMaintenance(t.OperatorAddr) {
+			log.LogInfof("action[skipMetaNodeTasksInMaintenance] skip task[%v] of node in maintenance", t.ID)
+			continue
+		}
+		remain = append(remain, t)
+	}
+	return
+}
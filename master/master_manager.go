@@ -16,6 +16,7 @@ func (m *Master) handleLeaderChange(leader uint64) {
 		log.LogWarnf("action[handleLeaderChange] but no leader")
 	}
 	m.leaderInfo.addr = AddrDatabase[leader]
+	m.staleLease.reset()
 	//Once switched to the master, the checkHeartbeat is executed
 	if m.id == leader {
 		Warn(m.clusterName, fmt.Sprintf("clusterID[%v] leader is changed to %v",
@@ -23,6 +24,7 @@ func (m *Master) handleLeaderChange(leader uint64) {
 		//m.loadMetadata()
 		m.cluster.checkDataNodeHeartbeat()
 		m.cluster.checkMetaNodeHeartbeat()
+		m.cluster.restoreAdminTasks()
 	}
 }
 
@@ -78,6 +80,22 @@ func (m *Master) loadMetadata() {
 		panic(err)
 	}
 
+	if err = m.cluster.loadDecommissions(); err != nil {
+		panic(err)
+	}
+
+	if err = m.cluster.loadAdminTasks(); err != nil {
+		panic(err)
+	}
+
+	if err = m.cluster.loadMaintenances(); err != nil {
+		panic(err)
+	}
+
+	if err = m.cluster.loadScrubReports(); err != nil {
+		panic(err)
+	}
+
 	if err = m.cluster.loadVols(); err != nil {
 		panic(err)
 	}
@@ -12,7 +12,10 @@ type MetaNode struct {
 	ID                 uint64
 	Addr               string
 	IsActive           bool
+	Draining           bool
+	MaintenanceEnd     int64
 	Sender             *AdminTaskSender
+	ZoneName           string `json:"Zone"`
 	RackName           string `json:"Rack"`
 	MaxMemAvailWeight  uint64 `json:"MaxMemAvailWeight"`
 	Total              uint64 `json:"TotalWeight"`
@@ -24,6 +27,9 @@ type MetaNode struct {
 	ReportTime         time.Time
 	metaPartitionInfos []*proto.MetaPartitionReport
 	MetaPartitionCount int
+	Version            uint32
+	Features           uint64
+	Health             NodeHealth
 	sync.RWMutex
 }
 
@@ -32,6 +38,7 @@ func NewMetaNode(addr, clusterID string) (node *MetaNode) {
 		Addr:   addr,
 		Sender: NewAdminTaskSender(addr, clusterID),
 		Carry:  rand.Float64(),
+		Health: newNodeHealth(),
 	}
 }
 
@@ -58,13 +65,19 @@ func (metaNode *MetaNode) SelectNodeForWrite() {
 func (metaNode *MetaNode) IsWriteAble() (ok bool) {
 	metaNode.RLock()
 	defer metaNode.RUnlock()
-	if metaNode.IsActive && metaNode.MaxMemAvailWeight > DefaultMetaNodeReservedMem &&
+	if metaNode.IsActive && !metaNode.Draining && !metaNode.inMaintenance() && metaNode.Health.isHealthy() && metaNode.MaxMemAvailWeight > DefaultMetaNodeReservedMem &&
 		!metaNode.isArriveThreshold() && metaNode.MetaPartitionCount < DefaultMetaPartitionCountOnEachNode {
 		ok = true
 	}
 	return
 }
 
+func (metaNode *MetaNode) setDraining(draining bool) {
+	metaNode.Lock()
+	defer metaNode.Unlock()
+	metaNode.Draining = draining
+}
+
 func (metaNode *MetaNode) IsAvailCarryNode() (ok bool) {
 	metaNode.RLock()
 	defer metaNode.RUnlock()
@@ -87,8 +100,11 @@ func (metaNode *MetaNode) updateMetric(resp *proto.MetaNodeHeartbeatResponse, th
 	metaNode.Used = resp.Used
 	metaNode.Ratio = float64(resp.Used) / float64(resp.Total)
 	metaNode.MaxMemAvailWeight = resp.Total - resp.Used
+	metaNode.ZoneName = zoneNameOf(resp.ZoneName)
 	metaNode.RackName = resp.RackName
 	metaNode.Threshold = threshold
+	metaNode.Version = resp.Version
+	metaNode.Features = resp.Features
 }
 
 func (metaNode *MetaNode) isArriveThreshold() bool {
@@ -98,10 +114,14 @@ func (metaNode *MetaNode) isArriveThreshold() bool {
 	return float32(float64(metaNode.Used)/float64(metaNode.Total)) > metaNode.Threshold
 }
 
-func (metaNode *MetaNode) generateHeartbeatTask(masterAddr string) (task *proto.AdminTask) {
+func (metaNode *MetaNode) generateHeartbeatTask(masterAddr string, volQos map[string]*proto.VolQos,
+	lifecycles map[string]*proto.VolLifecycle, features uint64) (task *proto.AdminTask) {
 	request := &proto.HeartBeatRequest{
 		CurrTime:   time.Now().Unix(),
 		MasterAddr: masterAddr,
+		VolQos:     volQos,
+		Lifecycles: lifecycles,
+		Features:   features,
 	}
 	task = proto.NewAdminTask(proto.OpMetaNodeHeartbeat, metaNode.Addr, request)
 	return
@@ -111,6 +131,9 @@ func (metaNode *MetaNode) checkHeartbeat() {
 	metaNode.Lock()
 	defer metaNode.Unlock()
 	if time.Since(metaNode.ReportTime) > time.Second*time.Duration(DefaultNodeTimeOutSec) {
+		if metaNode.IsActive && !metaNode.inMaintenance() {
+			metaNode.Health.addFlap(time.Now())
+		}
 		metaNode.IsActive = false
 	}
 }
@@ -370,6 +370,9 @@ func (mp *MetaPartition) checkReplicaMiss(clusterID string, partitionMissSec, wa
 				lastReportTime time.Time
 			)
 			isActive := true
+			if metaNode != nil && metaNode.isInMaintenance() {
+				continue
+			}
 			if metaNode != nil {
 				lastReportTime = metaNode.ReportTime
 				isActive = metaNode.IsActive
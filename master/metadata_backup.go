@@ -0,0 +1,204 @@
+package master
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/juju/errors"
+	"github.com/tiglabs/baudstorage/raftstore"
+	"github.com/tiglabs/baudstorage/util/log"
+	"github.com/tiglabs/raft/proto"
+)
+
+/*a backup file is a sequence of records,every record is the length of the marshaled Metadata
+in big endian uint32 followed by the marshaled Metadata.
+the applied index is not exported,the restored store starts a new raft log from zero.
+a backup is identified by the sha1 of the file,the store restored from it keeps the id under
+RestoredBackup,so a master restarted with the same restoreFile keeps its store*/
+
+const (
+	MaxBackupRecordSize = 64 * 1024 * 1024
+	RestoredBackup      = "restoredBackup"
+)
+
+func writeBackupRecord(w io.Writer, data []byte) (err error) {
+	var head [4]byte
+	binary.BigEndian.PutUint32(head[:], uint32(len(data)))
+	if _, err = w.Write(head[:]); err != nil {
+		return
+	}
+	_, err = w.Write(data)
+	return
+}
+
+func readBackupRecord(r io.Reader) (data []byte, err error) {
+	var head [4]byte
+	if _, err = io.ReadFull(r, head[:]); err != nil {
+		return
+	}
+	size := binary.BigEndian.Uint32(head[:])
+	if size > MaxBackupRecordSize {
+		return nil, fmt.Errorf("record size[%v] exceeds limit[%v]", size, MaxBackupRecordSize)
+	}
+	data = make([]byte, size)
+	if _, err = io.ReadFull(r, data); err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+//backup writes all the records of a rocksdb snapshot to w
+func (mf *MetadataFsm) backup(w io.Writer) (count int, err error) {
+	var (
+		data []byte
+		snap proto.Snapshot
+	)
+	if snap, err = mf.Snapshot(); err != nil {
+		return
+	}
+	defer snap.Close()
+	for {
+		if data, err = snap.Next(); err != nil {
+			break
+		}
+		cmd := new(Metadata)
+		if err = cmd.Unmarshal(data); err != nil {
+			return
+		}
+		if cmd.K == Applied || cmd.K == RestoredBackup {
+			continue
+		}
+		if err = writeBackupRecord(w, data); err != nil {
+			return
+		}
+		count++
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return
+}
+
+/*restoreFromBackup puts the records of the backup file into the store,the store must be empty.
+the id of the backup is put last,a store without it is a restore which has not completed*/
+func (mf *MetadataFsm) restoreFromBackup(path, id string) (count int, err error) {
+	var (
+		f    *os.File
+		data []byte
+	)
+	if f, err = os.Open(path); err != nil {
+		return
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		if data, err = readBackupRecord(r); err != nil {
+			break
+		}
+		cmd := new(Metadata)
+		if err = cmd.Unmarshal(data); err != nil {
+			return
+		}
+		if _, err = mf.store.Put(cmd.K, cmd.V); err != nil {
+			return
+		}
+		count++
+	}
+	if err != io.EOF {
+		return
+	}
+	_, err = mf.store.Put(RestoredBackup, []byte(id))
+	return
+}
+
+//restoredBackupID returns the id of the backup the store is restored from,empty if it is not restored
+func (mf *MetadataFsm) restoredBackupID() (id string, err error) {
+	var value interface{}
+	if value, err = mf.Get(RestoredBackup); err != nil {
+		return
+	}
+	return string(value.([]byte)), nil
+}
+
+func backupID(path string) (id string, err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return
+	}
+	defer f.Close()
+	h := sha1.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func isEmptyDir(dir string) (empty bool, err error) {
+	var fileInfos []os.FileInfo
+	if fileInfos, err = ioutil.ReadDir(dir); err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return
+	}
+	return len(fileInfos) == 0, nil
+}
+
+/*checkRestore keeps only this master in the peers,so the restored master forms a single-member
+raft group and elects itself as leader.the other masters join with /raftNode/add afterwards.
+whether the dirs are empty is checked by restoreStore once the store is opened*/
+func (m *Master) checkRestore() (err error) {
+	var empty bool
+	if m.restoreID, err = backupID(m.restoreFile); err != nil {
+		return errors.Annotatef(err, "read backup[%v] failed", m.restoreFile)
+	}
+	m.restoreIntoEmpty = true
+	for _, dir := range []string{m.walDir, m.storeDir} {
+		if empty, err = isEmptyDir(dir); err != nil {
+			return errors.Annotatef(err, "check dir[%v] failed", dir)
+		}
+		m.restoreIntoEmpty = m.restoreIntoEmpty && empty
+	}
+	peers := make([]raftstore.PeerAddress, 0)
+	for _, peer := range m.config.peers {
+		if peer.ID == m.id {
+			peers = append(peers, peer)
+		}
+	}
+	if len(peers) == 0 {
+		return fmt.Errorf("restore from %v,but id[%v] is not in peers", m.restoreFile, m.id)
+	}
+	m.config.peers = peers
+	log.LogWarnf("action[checkRestore] restore from %v,boot single member raft group,peers[%v]", m.restoreFile, peers)
+	return
+}
+
+/*restoreStore restores the backup into the empty store once,the store restored from the same backup
+before is kept as it is,and a store which is not restored from it is never overwritten*/
+func (m *Master) restoreStore(fsm *MetadataFsm) (err error) {
+	var (
+		id    string
+		count int
+	)
+	if id, err = fsm.restoredBackupID(); err != nil {
+		return
+	}
+	if id == m.restoreID {
+		log.LogWarnf("action[restoreStore] store is restored from %v[%v] already", m.restoreFile, id)
+		return
+	}
+	if !m.restoreIntoEmpty {
+		return fmt.Errorf("restore from %v,but dir[%v] or dir[%v] is not empty", m.restoreFile, m.walDir, m.storeDir)
+	}
+	if count, err = fsm.restoreFromBackup(m.restoreFile, m.restoreID); err != nil {
+		return
+	}
+	log.LogWarnf("action[restoreStore] restore %v records from %v[%v]", count, m.restoreFile, m.restoreID)
+	return
+}
@@ -93,6 +93,18 @@ func (mf *MetadataFsm) Apply(command []byte, index uint64) (resp interface{}, er
 		if err = mf.DelKeyAndPutIndex(cmd.K, cmdMap); err != nil {
 			return
 		}
+	case OpSyncDeleteAdminTask:
+		if err = mf.DelKeyAndPutIndex(cmd.K, cmdMap); err != nil {
+			return
+		}
+	case OpSyncDeleteMaintenance:
+		if err = mf.DelKeyAndPutIndex(cmd.K, cmdMap); err != nil {
+			return
+		}
+	case OpSyncDeleteDecommission:
+		if err = mf.DelKeyAndPutIndex(cmd.K, cmdMap); err != nil {
+			return
+		}
 	default:
 		if err = mf.BatchPut(cmdMap); err != nil {
 			return
@@ -25,6 +25,14 @@ const (
 	OpSyncAllocMetaPartitionID uint32 = 0x0B
 	OpSyncAllocMetaNodeID      uint32 = 0x0C
 	OPSyncPutCluster           uint32 = 0x0D
+	OpSyncPutDecommission      uint32 = 0x0E
+	OpSyncPutAdminTask         uint32 = 0x0F
+	OpSyncDeleteAdminTask      uint32 = 0x10
+	OpSyncPutMaintenance       uint32 = 0x11
+	OpSyncDeleteMaintenance    uint32 = 0x12
+	OpSyncPutScrubReport       uint32 = 0x13
+	OpSyncUpdateVol            uint32 = 0x14
+	OpSyncDeleteDecommission   uint32 = 0x15
 )
 
 const (
@@ -35,12 +43,20 @@ const (
 	MetaPartitionAcronym = "mp"
 	VolAcronym           = "vol"
 	ClusterAcronym       = "c"
+	DecommissionAcronym  = "dc"
+	AdminTaskAcronym     = "task"
+	MaintenanceAcronym   = "mt"
+	ScrubReportAcronym   = "scrub"
 	MetaNodePrefix       = KeySeparator + MetaNodeAcronym + KeySeparator
 	DataNodePrefix       = KeySeparator + DataNodeAcronym + KeySeparator
 	DataPartitionPrefix  = KeySeparator + DataPartitionAcronym + KeySeparator
 	VolPrefix            = KeySeparator + VolAcronym + KeySeparator
 	MetaPartitionPrefix  = KeySeparator + MetaPartitionAcronym + KeySeparator
 	ClusterPrefix        = KeySeparator + ClusterAcronym + KeySeparator
+	DecommissionPrefix   = KeySeparator + DecommissionAcronym + KeySeparator
+	AdminTaskPrefix      = KeySeparator + AdminTaskAcronym + KeySeparator
+	MaintenancePrefix    = KeySeparator + MaintenanceAcronym + KeySeparator
+	ScrubReportPrefix    = KeySeparator + ScrubReportAcronym + KeySeparator
 )
 
 type MetaPartitionValue struct {
@@ -69,6 +85,8 @@ type DataPartitionValue struct {
 	ReplicaNum    uint8
 	Hosts         string
 	PartitionType string
+	ManualStatus  int8
+	ECDataNum     uint8
 }
 
 func newDataPartitionValue(dp *DataPartition) (dpv *DataPartitionValue) {
@@ -77,19 +95,31 @@ func newDataPartitionValue(dp *DataPartition) (dpv *DataPartitionValue) {
 		ReplicaNum:    dp.ReplicaNum,
 		Hosts:         dp.HostsToString(),
 		PartitionType: dp.PartitionType,
+		ManualStatus:  dp.ManualStatus,
+		ECDataNum:     dp.ECDataNum,
 	}
 	return
 }
 
 type VolValue struct {
-	VolType    string
-	ReplicaNum uint8
+	VolType         string
+	ReplicaNum      uint8
+	PlacementPolicy string
+	Qos             bsProto.VolQos
+	Lifecycle       bsProto.VolLifecycle
+	Compression     string
+	Keys            []*bsProto.VolKey
 }
 
 func newVolValue(vol *Vol) (vv *VolValue) {
 	vv = &VolValue{
-		VolType:    vol.VolType,
-		ReplicaNum: vol.dpReplicaNum,
+		VolType:         vol.VolType,
+		ReplicaNum:      vol.dpReplicaNum,
+		PlacementPolicy: vol.placementPolicy,
+		Qos:             vol.getQos(),
+		Lifecycle:       vol.getLifecycle(),
+		Compression:     vol.getCompression(),
+		Keys:            vol.getKeys(),
 	}
 	return
 }
@@ -139,6 +169,14 @@ func (m *Metadata) setOpType() {
 		m.Op = OpSyncAddVol
 	case ClusterAcronym:
 		m.Op = OPSyncPutCluster
+	case DecommissionAcronym:
+		m.Op = OpSyncPutDecommission
+	case AdminTaskAcronym:
+		m.Op = OpSyncPutAdminTask
+	case MaintenanceAcronym:
+		m.Op = OpSyncPutMaintenance
+	case ScrubReportAcronym:
+		m.Op = OpSyncPutScrubReport
 	default:
 		log.LogWarnf("action[setOpType] unknown opCode[%v]", keyArr[1])
 	}
@@ -161,10 +199,14 @@ func (c *Cluster) syncUpdateDataPartition(volName string, dp *DataPartition) (er
 }
 
 func (c *Cluster) putDataPartitionInfo(opType uint32, volName string, dp *DataPartition) (err error) {
+	return c.putDataPartitionValue(opType, volName, newDataPartitionValue(dp))
+}
+
+//putDataPartitionValue submits a copy of the partition,so the partition need not be locked across the submit
+func (c *Cluster) putDataPartitionValue(opType uint32, volName string, dpv *DataPartitionValue) (err error) {
 	metadata := new(Metadata)
 	metadata.Op = opType
-	metadata.K = DataPartitionPrefix + volName + KeySeparator + strconv.FormatUint(dp.PartitionID, 10)
-	dpv := newDataPartitionValue(dp)
+	metadata.K = DataPartitionPrefix + volName + KeySeparator + strconv.FormatUint(dpv.PartitionID, 10)
 	metadata.V, err = json.Marshal(dpv)
 	if err != nil {
 		return
@@ -186,8 +228,16 @@ func (c *Cluster) submit(metadata *Metadata) (err error) {
 
 //key=#ns#volName,value=json.Marshal(vv)
 func (c *Cluster) syncAddVol(vol *Vol) (err error) {
+	return c.putVolInfo(OpSyncAddVol, vol)
+}
+
+func (c *Cluster) syncUpdateVol(vol *Vol) (err error) {
+	return c.putVolInfo(OpSyncUpdateVol, vol)
+}
+
+func (c *Cluster) putVolInfo(opType uint32, vol *Vol) (err error) {
 	metadata := new(Metadata)
-	metadata.Op = OpSyncAddVol
+	metadata.Op = opType
 	metadata.K = VolPrefix + vol.Name
 	vv := newVolValue(vol)
 	if metadata.V, err = json.Marshal(vv); err != nil {
@@ -247,6 +297,69 @@ func (c *Cluster) syncDeleteDataNode(dataNode *DataNode) (err error) {
 	return c.submit(metadata)
 }
 
+//key=#dc#nodeType#addr[#diskPath],value=json.Marshal(DecommissionValue)
+func (c *Cluster) syncPutDecommission(dv *DecommissionValue) (err error) {
+	metadata := new(Metadata)
+	metadata.Op = OpSyncPutDecommission
+	metadata.K = DecommissionPrefix + decommissionKey(dv.NodeType, dv.Addr, dv.DiskPath)
+	if metadata.V, err = json.Marshal(dv); err != nil {
+		return errors.New(err.Error())
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) syncDeleteDecommission(dv *DecommissionValue) (err error) {
+	metadata := new(Metadata)
+	metadata.Op = OpSyncDeleteDecommission
+	metadata.K = DecommissionPrefix + decommissionKey(dv.NodeType, dv.Addr, dv.DiskPath)
+	return c.submit(metadata)
+}
+
+//key=#task#taskID,value=json.Marshal(AdminTask) without response
+func (c *Cluster) syncPutAdminTask(t *bsProto.AdminTask) (err error) {
+	metadata := new(Metadata)
+	metadata.Op = OpSyncPutAdminTask
+	metadata.K = AdminTaskPrefix + t.ID
+	if metadata.V, err = json.Marshal(t); err != nil {
+		return errors.New(err.Error())
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) syncDeleteAdminTask(t *bsProto.AdminTask) (err error) {
+	metadata := new(Metadata)
+	metadata.Op = OpSyncDeleteAdminTask
+	metadata.K = AdminTaskPrefix + t.ID
+	return c.submit(metadata)
+}
+
+//key=#mt#nodeType#addr,value=json.Marshal(Maintenance)
+func (c *Cluster) syncPutMaintenance(mt *Maintenance) (err error) {
+	metadata := new(Metadata)
+	metadata.Op = OpSyncPutMaintenance
+	metadata.K = MaintenancePrefix + maintenanceKey(mt.NodeType, mt.Addr)
+	if metadata.V, err = json.Marshal(mt); err != nil {
+		return errors.New(err.Error())
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) syncDeleteMaintenance(mt *Maintenance) (err error) {
+	metadata := new(Metadata)
+	metadata.Op = OpSyncDeleteMaintenance
+	metadata.K = MaintenancePrefix + maintenanceKey(mt.NodeType, mt.Addr)
+	return c.submit(metadata)
+}
+
+//key=#scrub#volName,value=json.Marshal(ScrubReportValue)
+func (c *Cluster) syncPutScrubReport(volName string, value []byte) (err error) {
+	metadata := new(Metadata)
+	metadata.Op = OpSyncPutScrubReport
+	metadata.K = ScrubReportPrefix + volName
+	metadata.V = value
+	return c.submit(metadata)
+}
+
 func (c *Cluster) addRaftNode(nodeID uint64, addr string) (err error) {
 	peer := proto.Peer{ID: nodeID}
 	_, err = c.partition.ChangeMember(proto.ConfAddNode, peer, []byte(addr))
@@ -284,6 +397,8 @@ func (c *Cluster) handleApply(cmd *Metadata) (err error) {
 		err = c.applyAddMetaNode(cmd)
 	case OpSyncAddVol:
 		c.applyAddVol(cmd)
+	case OpSyncUpdateVol:
+		c.applyUpdateVol(cmd)
 	case OpSyncAddMetaPartition:
 		c.applyAddMetaPartition(cmd)
 	case OpSyncUpdateMetaPartition:
@@ -298,6 +413,20 @@ func (c *Cluster) handleApply(cmd *Metadata) (err error) {
 		c.applyDeleteDataNode(cmd)
 	case OPSyncPutCluster:
 		c.applyPutCluster(cmd)
+	case OpSyncPutDecommission:
+		c.applyPutDecommission(cmd)
+	case OpSyncDeleteDecommission:
+		c.applyDeleteDecommission(cmd)
+	case OpSyncPutAdminTask:
+		c.applyPutAdminTask(cmd)
+	case OpSyncDeleteAdminTask:
+		c.applyDeleteAdminTask(cmd)
+	case OpSyncPutMaintenance:
+		c.applyPutMaintenance(cmd)
+	case OpSyncDeleteMaintenance:
+		c.applyDeleteMaintenance(cmd)
+	case OpSyncPutScrubReport:
+		c.applyPutScrubReport(cmd)
 	case OpSyncAllocMetaNodeID:
 		c.idAlloc.increaseMetaNodeID()
 	case OpSyncAllocDataPartitionID:
@@ -321,6 +450,89 @@ func (c *Cluster) applyPutCluster(cmd *Metadata) {
 	c.compactStatus = status
 }
 
+func (c *Cluster) applyPutDecommission(cmd *Metadata) {
+	log.LogInfof("action[applyPutDecommission] cmd:%v", cmd.K)
+	keys := strings.Split(cmd.K, KeySeparator)
+	if keys[1] != DecommissionAcronym {
+		return
+	}
+	dv := &DecommissionValue{}
+	if err := json.Unmarshal(cmd.V, dv); err != nil {
+		log.LogError(fmt.Sprintf("action[applyPutDecommission] failed,err:%v", err))
+		return
+	}
+	c.putDecommission(newDecommissionFromValue(dv))
+}
+
+func (c *Cluster) applyDeleteDecommission(cmd *Metadata) {
+	log.LogInfof("action[applyDeleteDecommission] cmd:%v", cmd.K)
+	keys := strings.SplitN(cmd.K, KeySeparator, 3)
+	if keys[1] != DecommissionAcronym || len(keys) < 3 {
+		return
+	}
+	c.decommissions.Delete(keys[2])
+}
+
+func (c *Cluster) applyPutAdminTask(cmd *Metadata) {
+	log.LogInfof("action[applyPutAdminTask] cmd:%v", cmd.K)
+	keys := strings.Split(cmd.K, KeySeparator)
+	if keys[1] != AdminTaskAcronym {
+		return
+	}
+	t := &bsProto.AdminTask{}
+	if err := json.Unmarshal(cmd.V, t); err != nil {
+		log.LogError(fmt.Sprintf("action[applyPutAdminTask] failed,err:%v", err))
+		return
+	}
+	c.adminTasks.Store(t.ID, t)
+}
+
+func (c *Cluster) applyDeleteAdminTask(cmd *Metadata) {
+	log.LogInfof("action[applyDeleteAdminTask] cmd:%v", cmd.K)
+	keys := strings.Split(cmd.K, KeySeparator)
+	if keys[1] != AdminTaskAcronym {
+		return
+	}
+	c.adminTasks.Delete(strings.TrimPrefix(cmd.K, AdminTaskPrefix))
+}
+
+func (c *Cluster) applyPutMaintenance(cmd *Metadata) {
+	log.LogInfof("action[applyPutMaintenance] cmd:%v", cmd.K)
+	keys := strings.Split(cmd.K, KeySeparator)
+	if keys[1] != MaintenanceAcronym {
+		return
+	}
+	mt := &Maintenance{}
+	if err := json.Unmarshal(cmd.V, mt); err != nil {
+		log.LogError(fmt.Sprintf("action[applyPutMaintenance] failed,err:%v", err))
+		return
+	}
+	c.putMaintenance(mt)
+}
+
+func (c *Cluster) applyDeleteMaintenance(cmd *Metadata) {
+	log.LogInfof("action[applyDeleteMaintenance] cmd:%v", cmd.K)
+	keys := strings.Split(cmd.K, KeySeparator)
+	if keys[1] != MaintenanceAcronym || len(keys) < 4 {
+		return
+	}
+	c.deleteMaintenance(keys[2], keys[3])
+}
+
+func (c *Cluster) applyPutScrubReport(cmd *Metadata) {
+	log.LogInfof("action[applyPutScrubReport] cmd:%v", cmd.K)
+	keys := strings.Split(cmd.K, KeySeparator)
+	if keys[1] != ScrubReportAcronym {
+		return
+	}
+	rv := &ScrubReportValue{}
+	if err := json.Unmarshal(cmd.V, rv); err != nil {
+		log.LogError(fmt.Sprintf("action[applyPutScrubReport] failed,err:%v", err))
+		return
+	}
+	c.putScrubReport(newScrubReportFromValue(rv))
+}
+
 func (c *Cluster) applyDeleteDataNode(cmd *Metadata) {
 	log.LogInfof("action[applyDeleteDataNode] cmd:%v", cmd.K)
 	keys := strings.Split(cmd.K, KeySeparator)
@@ -385,11 +597,37 @@ func (c *Cluster) applyAddVol(cmd *Metadata) {
 			log.LogError(fmt.Sprintf("action[applyAddVol] failed,err:%v", err))
 			return
 		}
-		vol := NewVol(keys[2], vv.VolType, vv.ReplicaNum)
+		vol := NewVol(keys[2], vv.VolType, vv.PlacementPolicy, vv.ReplicaNum)
+		vol.setQos(vv.Qos)
+		vol.setLifecycle(vv.Lifecycle)
+		vol.setCompression(vv.Compression)
+		vol.setKeys(vv.Keys)
 		c.putVol(vol)
 	}
 }
 
+func (c *Cluster) applyUpdateVol(cmd *Metadata) {
+	log.LogInfof("action[applyUpdateVol] cmd:%v", cmd.K)
+	keys := strings.Split(cmd.K, KeySeparator)
+	if keys[1] != VolAcronym {
+		return
+	}
+	vv := &VolValue{}
+	if err := json.Unmarshal(cmd.V, vv); err != nil {
+		log.LogError(fmt.Sprintf("action[applyUpdateVol] failed,err:%v", err))
+		return
+	}
+	vol, err := c.getVol(keys[2])
+	if err != nil {
+		log.LogError(fmt.Sprintf("action[applyUpdateVol] failed,err:%v", err))
+		return
+	}
+	vol.setQos(vv.Qos)
+	vol.setLifecycle(vv.Lifecycle)
+	vol.setCompression(vv.Compression)
+	vol.setKeys(vv.Keys)
+}
+
 func (c *Cluster) applyAddMetaPartition(cmd *Metadata) {
 	log.LogInfof("action[applyAddMetaPartition] cmd:%v", cmd.K)
 	keys := strings.Split(cmd.K, KeySeparator)
@@ -437,6 +675,8 @@ func (c *Cluster) applyAddDataPartition(cmd *Metadata) {
 		vol, _ := c.getVol(keys[2])
 		dp := newDataPartition(dpv.PartitionID, dpv.ReplicaNum, dpv.PartitionType, vol.Name)
 		dp.PersistenceHosts = strings.Split(dpv.Hosts, UnderlineSeparator)
+		dp.ManualStatus = dpv.ManualStatus
+		dp.ECDataNum = dpv.ECDataNum
 		vol.dataPartitions.putDataPartitionByRaft(dp)
 	}
 }
@@ -448,13 +688,18 @@ func (c *Cluster) applyUpdateDataPartition(cmd *Metadata) {
 		dpv := &DataPartitionValue{}
 		json.Unmarshal(cmd.V, dpv)
 		vol, _ := c.getVol(keys[2])
-		if _, err := vol.getDataPartitionByID(dpv.PartitionID); err != nil {
+		dp, err := vol.getDataPartitionByID(dpv.PartitionID)
+		if err != nil {
 			log.LogError(fmt.Sprintf("action[applyUpdateDataPartition] failed,err:%v", err))
 			return
 		}
-		dp := newDataPartition(dpv.PartitionID, dpv.ReplicaNum, dpv.PartitionType, vol.Name)
+		//the partition is updated in place,the replicas and the tasks it keeps stay valid
+		dp.Lock()
+		dp.ReplicaNum = dpv.ReplicaNum
 		dp.PersistenceHosts = strings.Split(dpv.Hosts, UnderlineSeparator)
-		vol.dataPartitions.putDataPartitionByRaft(dp)
+		dp.ManualStatus = dpv.ManualStatus
+		dp.ECDataNum = dpv.ECDataNum
+		dp.Unlock()
 	}
 }
 
@@ -555,6 +800,102 @@ func (c *Cluster) loadMetaNodes() (err error) {
 	return
 }
 
+func (c *Cluster) loadDecommissions() (err error) {
+	snapshot := c.fsm.store.RocksDBSnapshot()
+	it := c.fsm.store.Iterator(snapshot)
+	defer func() {
+		it.Close()
+		c.fsm.store.ReleaseSnapshot(snapshot)
+	}()
+	prefixKey := []byte(DecommissionPrefix)
+	it.Seek(prefixKey)
+	for ; it.ValidForPrefix(prefixKey); it.Next() {
+		encodedKey := it.Key()
+		encodedValue := it.Value()
+		dv := &DecommissionValue{}
+		if err = json.Unmarshal(encodedValue.Data(), dv); err != nil {
+			err = fmt.Errorf("action[loadDecommissions],value:%v,err:%v", encodedValue.Data(), err)
+			return err
+		}
+		c.putDecommission(newDecommissionFromValue(dv))
+		encodedKey.Free()
+		encodedValue.Free()
+	}
+	return
+}
+
+func (c *Cluster) loadAdminTasks() (err error) {
+	snapshot := c.fsm.store.RocksDBSnapshot()
+	it := c.fsm.store.Iterator(snapshot)
+	defer func() {
+		it.Close()
+		c.fsm.store.ReleaseSnapshot(snapshot)
+	}()
+	prefixKey := []byte(AdminTaskPrefix)
+	it.Seek(prefixKey)
+	for ; it.ValidForPrefix(prefixKey); it.Next() {
+		encodedKey := it.Key()
+		encodedValue := it.Value()
+		t := &bsProto.AdminTask{}
+		if err = json.Unmarshal(encodedValue.Data(), t); err != nil {
+			err = fmt.Errorf("action[loadAdminTasks],value:%v,err:%v", encodedValue.Data(), err)
+			return err
+		}
+		c.adminTasks.Store(t.ID, t)
+		encodedKey.Free()
+		encodedValue.Free()
+	}
+	return
+}
+
+func (c *Cluster) loadMaintenances() (err error) {
+	snapshot := c.fsm.store.RocksDBSnapshot()
+	it := c.fsm.store.Iterator(snapshot)
+	defer func() {
+		it.Close()
+		c.fsm.store.ReleaseSnapshot(snapshot)
+	}()
+	prefixKey := []byte(MaintenancePrefix)
+	it.Seek(prefixKey)
+	for ; it.ValidForPrefix(prefixKey); it.Next() {
+		encodedKey := it.Key()
+		encodedValue := it.Value()
+		mt := &Maintenance{}
+		if err = json.Unmarshal(encodedValue.Data(), mt); err != nil {
+			err = fmt.Errorf("action[loadMaintenances],value:%v,err:%v", encodedValue.Data(), err)
+			return err
+		}
+		c.putMaintenance(mt)
+		encodedKey.Free()
+		encodedValue.Free()
+	}
+	return
+}
+
+func (c *Cluster) loadScrubReports() (err error) {
+	snapshot := c.fsm.store.RocksDBSnapshot()
+	it := c.fsm.store.Iterator(snapshot)
+	defer func() {
+		it.Close()
+		c.fsm.store.ReleaseSnapshot(snapshot)
+	}()
+	prefixKey := []byte(ScrubReportPrefix)
+	it.Seek(prefixKey)
+	for ; it.ValidForPrefix(prefixKey); it.Next() {
+		encodedKey := it.Key()
+		encodedValue := it.Value()
+		rv := &ScrubReportValue{}
+		if err = json.Unmarshal(encodedValue.Data(), rv); err != nil {
+			err = fmt.Errorf("action[loadScrubReports],value:%v,err:%v", encodedValue.Data(), err)
+			return err
+		}
+		c.putScrubReport(newScrubReportFromValue(rv))
+		encodedKey.Free()
+		encodedValue.Free()
+	}
+	return
+}
+
 func (c *Cluster) loadVols() (err error) {
 	snapshot := c.fsm.store.RocksDBSnapshot()
 	it := c.fsm.store.Iterator(snapshot)
@@ -577,7 +918,11 @@ func (c *Cluster) loadVols() (err error) {
 			err = fmt.Errorf("action[loadVols],value:%v,err:%v", encodedValue.Data(), err)
 			return err
 		}
-		vol := NewVol(volName, vv.VolType, vv.ReplicaNum)
+		vol := NewVol(volName, vv.VolType, vv.PlacementPolicy, vv.ReplicaNum)
+		vol.setQos(vv.Qos)
+		vol.setLifecycle(vv.Lifecycle)
+		vol.setCompression(vv.Compression)
+		vol.setKeys(vv.Keys)
 		c.putVol(vol)
 		encodedKey.Free()
 	}
@@ -645,6 +990,8 @@ func (c *Cluster) loadDataPartitions() (err error) {
 		dp := newDataPartition(dpv.PartitionID, dpv.ReplicaNum, dpv.PartitionType, volName)
 		dp.Lock()
 		dp.PersistenceHosts = strings.Split(dpv.Hosts, UnderlineSeparator)
+		dp.ManualStatus = dpv.ManualStatus
+		dp.ECDataNum = dpv.ECDataNum
 		dp.Unlock()
 		vol.dataPartitions.putDataPartition(dp)
 		encodedKey.Free()
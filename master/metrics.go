@@ -0,0 +1,118 @@
+package master
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/tiglabs/baudstorage/util/metrics"
+)
+
+const (
+	MetricsSubsystem = "master"
+)
+
+var (
+	requestMetrics = metrics.NewHistogramVec(MetricsSubsystem, "request_duration_seconds",
+		"Latency of the http requests by path.", metrics.DefLatencyBuckets, "path")
+)
+
+func observeRequest(path string, start time.Time) {
+	requestMetrics.Observe(metrics.Since(start), path)
+}
+
+func boolLabel(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+/*registerMetrics exports the state kept by the cluster,the values are collected on every scrape.
+the cluster view is only accurate on the leader,followers report is_leader 0*/
+func (m *Master) registerMetrics() {
+	c := m.cluster
+	metrics.NewGaugeFunc(MetricsSubsystem, "raft_is_leader", "1 if this master is the raft leader.", nil,
+		func(report func(v float64, labelValues ...string)) {
+			var leader float64
+			if m.partition.IsLeader() {
+				leader = 1
+			}
+			report(leader)
+		})
+	metrics.NewGaugeFunc(MetricsSubsystem, "raft_applied_index", "Raft applied index of the master.", nil,
+		func(report func(v float64, labelValues ...string)) {
+			report(float64(m.fsm.applied))
+		})
+	metrics.NewGaugeFunc(MetricsSubsystem, "raft_term", "Raft term of the master.", nil,
+		func(report func(v float64, labelValues ...string)) {
+			_, term := m.partition.LeaderTerm()
+			report(float64(term))
+		})
+	metrics.NewGaugeFunc(MetricsSubsystem, "data_nodes", "Count of the data nodes by liveness.", []string{"active"},
+		func(report func(v float64, labelValues ...string)) {
+			counts := make(map[bool]int)
+			c.dataNodes.Range(func(key, value interface{}) bool {
+				dataNode := value.(*DataNode)
+				dataNode.RLock()
+				counts[dataNode.isActive]++
+				dataNode.RUnlock()
+				return true
+			})
+			report(float64(counts[true]), boolLabel(true))
+			report(float64(counts[false]), boolLabel(false))
+		})
+	metrics.NewGaugeFunc(MetricsSubsystem, "meta_nodes", "Count of the meta nodes by liveness.", []string{"active"},
+		func(report func(v float64, labelValues ...string)) {
+			counts := make(map[bool]int)
+			c.metaNodes.Range(func(key, value interface{}) bool {
+				metaNode := value.(*MetaNode)
+				metaNode.RLock()
+				counts[metaNode.IsActive]++
+				metaNode.RUnlock()
+				return true
+			})
+			report(float64(counts[true]), boolLabel(true))
+			report(float64(counts[false]), boolLabel(false))
+		})
+	metrics.NewGaugeFunc(MetricsSubsystem, "vol_data_partitions", "Count of the data partitions of the vol by status.",
+		[]string{"vol", "status"},
+		func(report func(v float64, labelValues ...string)) {
+			for name, vol := range c.copyVols() {
+				counts := make(map[int8]int)
+				vol.dataPartitions.RLock()
+				for _, dp := range vol.dataPartitions.dataPartitionMap {
+					counts[dp.Status]++
+				}
+				vol.dataPartitions.RUnlock()
+				for status, count := range counts {
+					report(float64(count), name, strconv.Itoa(int(status)))
+				}
+			}
+		})
+	metrics.NewGaugeFunc(MetricsSubsystem, "vol_meta_partitions", "Count of the meta partitions of the vol.", []string{"vol"},
+		func(report func(v float64, labelValues ...string)) {
+			for name, vol := range c.copyVols() {
+				report(float64(len(vol.cloneMetaPartitionMap())), name)
+			}
+		})
+	metrics.NewGaugeFunc(MetricsSubsystem, "decommission_remaining_partitions",
+		"Partitions still to be migrated by the running decommissions.", []string{"type", "addr", "disk"},
+		func(report func(v float64, labelValues ...string)) {
+			c.decommissions.Range(func(key, value interface{}) bool {
+				d := value.(*Decommission)
+				d.RLock()
+				if d.Status == DecommissionRunning {
+					report(float64(d.Remaining), d.NodeType, d.Addr, d.DiskPath)
+				}
+				d.RUnlock()
+				return true
+			})
+		})
+	metrics.NewGaugeFunc(MetricsSubsystem, "admin_tasks", "Count of the recorded admin tasks by status.", []string{"status"},
+		func(report func(v float64, labelValues ...string)) {
+			counts := make(map[int8]int)
+			for _, t := range c.getAdminTasks("") {
+				counts[t.Status]++
+			}
+			for status, count := range counts {
+				report(float64(count), strconv.Itoa(int(status)))
+			}
+		})
+}
@@ -0,0 +1,213 @@
+package master
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+const (
+	MaxHealthScore = 100
+	//no new partition is placed on a node whose score is lower
+	UnhealthyNodeScore = 40
+	//the average latency above which a node starts to lose score
+	DefaultSlowNodeLatencyMs = 50
+	//the inactive periods of a node are counted as flaps in the window
+	DefaultNodeFlapWindowSec = 600
+	healthSmoothFactor       = 0.3
+	latencyPenaltyStep       = 25
+	maxLatencyPenalty        = 70
+	maxErrorPenalty          = 40
+	flapPenalty              = 10
+	maxFlapPenalty           = 40
+)
+
+/*NodeHealth scores a node from the op statistics it reports in the heartbeats and from how often
+it flaps between active and inactive. The latency is smoothed over the heartbeats by class of the ops,
+so the fast reads do not hide the slow writes, and AvgLatencyMs is the one of the slowest class.
+A single slow heartbeat does not make a node unhealthy.*/
+type NodeHealth struct {
+	Score          float64
+	AvgLatencyMs   float64
+	MaxLatencyMs   float64
+	ErrorRate      float64
+	Flaps          int
+	flapTimes      []time.Time
+	classLatencyMs map[string]float64
+}
+
+func newNodeHealth() NodeHealth {
+	return NodeHealth{Score: MaxHealthScore, flapTimes: make([]time.Time, 0), classLatencyMs: make(map[string]float64)}
+}
+
+func (h *NodeHealth) observe(stats map[string]proto.NodeStats) {
+	if h.classLatencyMs == nil {
+		h.classLatencyMs = make(map[string]float64)
+	}
+	var opCount, errCount uint64
+	h.MaxLatencyMs = 0
+	for class, s := range stats {
+		if s.OpCount == 0 {
+			continue
+		}
+		opCount += s.OpCount
+		errCount += s.ErrCount
+		h.MaxLatencyMs = maxFloat(h.MaxLatencyMs, float64(s.MaxLatencyUs)/1000)
+		h.classLatencyMs[class] = h.classLatencyMs[class]*(1-healthSmoothFactor) +
+			float64(s.AvgLatencyUs)/1000*healthSmoothFactor
+	}
+	h.AvgLatencyMs = 0
+	for class, latency := range h.classLatencyMs {
+		if s, ok := stats[class]; !ok || s.OpCount == 0 {
+			//an idle class recovers slowly
+			latency = latency * (1 - healthSmoothFactor)
+			h.classLatencyMs[class] = latency
+		}
+		h.AvgLatencyMs = maxFloat(h.AvgLatencyMs, latency)
+	}
+	if opCount == 0 {
+		h.ErrorRate = h.ErrorRate * (1 - healthSmoothFactor)
+		return
+	}
+	errorRate := float64(errCount) / float64(opCount)
+	h.ErrorRate = h.ErrorRate*(1-healthSmoothFactor) + errorRate*healthSmoothFactor
+}
+
+func (h *NodeHealth) addFlap(now time.Time) {
+	h.flapTimes = append(h.flapTimes, now)
+}
+
+func (h *NodeHealth) computeScore(now time.Time) {
+	flapTimes := make([]time.Time, 0, len(h.flapTimes))
+	for _, t := range h.flapTimes {
+		if now.Sub(t) < DefaultNodeFlapWindowSec*time.Second {
+			flapTimes = append(flapTimes, t)
+		}
+	}
+	h.flapTimes = flapTimes
+	h.Flaps = len(flapTimes)
+
+	score := float64(MaxHealthScore)
+	if h.AvgLatencyMs > DefaultSlowNodeLatencyMs {
+		score -= minFloat(maxLatencyPenalty, (h.AvgLatencyMs/DefaultSlowNodeLatencyMs-1)*latencyPenaltyStep)
+	}
+	score -= minFloat(maxErrorPenalty, h.ErrorRate*maxErrorPenalty*10)
+	score -= minFloat(maxFlapPenalty, float64(h.Flaps*flapPenalty))
+	if score < 0 {
+		score = 0
+	}
+	h.Score = score
+}
+
+//weight scales down the chance of a slow node to be selected for a new partition
+func (h *NodeHealth) weight() float64 {
+	return h.Score / MaxHealthScore
+}
+
+func (h *NodeHealth) isHealthy() bool {
+	return h.Score >= UnhealthyNodeScore
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+//updateHealth scores the data node with the stats of the heartbeat, it returns the score before and after
+func (dataNode *DataNode) updateHealth(stats map[string]proto.NodeStats) (old, score float64) {
+	dataNode.Lock()
+	defer dataNode.Unlock()
+	old = dataNode.Health.Score
+	dataNode.Health.observe(stats)
+	dataNode.Health.computeScore(time.Now())
+	return old, dataNode.Health.Score
+}
+
+func (metaNode *MetaNode) updateHealth(stats map[string]proto.NodeStats) (old, score float64) {
+	metaNode.Lock()
+	defer metaNode.Unlock()
+	old = metaNode.Health.Score
+	metaNode.Health.observe(stats)
+	metaNode.Health.computeScore(time.Now())
+	return old, metaNode.Health.Score
+}
+
+func (dataNode *DataNode) getHealth() NodeHealth {
+	dataNode.RLock()
+	defer dataNode.RUnlock()
+	return dataNode.Health
+}
+
+func (metaNode *MetaNode) getHealth() NodeHealth {
+	metaNode.RLock()
+	defer metaNode.RUnlock()
+	return metaNode.Health
+}
+
+func (dataNode *DataNode) isHealthy() bool {
+	dataNode.RLock()
+	defer dataNode.RUnlock()
+	return dataNode.Health.isHealthy()
+}
+
+func (dataNode *DataNode) healthWeight() float64 {
+	dataNode.RLock()
+	defer dataNode.RUnlock()
+	return dataNode.Health.weight()
+}
+
+func (metaNode *MetaNode) healthWeight() float64 {
+	metaNode.RLock()
+	defer metaNode.RUnlock()
+	return metaNode.Health.weight()
+}
+
+func (c *Cluster) checkNodeHealth(nodeType, addr string, inMaintenance bool, old, score float64, h NodeHealth) {
+	if inMaintenance {
+		return
+	}
+	msg := fmt.Sprintf("clusterID[%v] %v[%v] health score[%.1f] -> [%.1f] avgLatency[%.1fms] errorRate[%.3f] flaps[%v]",
+		c.Name, nodeType, addr, old, score, h.AvgLatencyMs, h.ErrorRate, h.Flaps)
+	switch {
+	case old >= UnhealthyNodeScore && score < UnhealthyNodeScore:
+		Warn(c.Name, msg+", no new partition is placed on it and its data partitions are read only")
+	case old < UnhealthyNodeScore && score >= UnhealthyNodeScore:
+		Warn(c.Name, msg+", recovered")
+	default:
+		log.LogDebugf("action[checkNodeHealth] %v", msg)
+	}
+}
+
+func (c *Cluster) getUnhealthyNodes() (nodes []string) {
+	nodes = make([]string, 0)
+	c.dataNodes.Range(func(addr, node interface{}) bool {
+		dataNode := node.(*DataNode)
+		dataNode.RLock()
+		if !dataNode.Health.isHealthy() {
+			nodes = append(nodes, dataNode.Addr)
+		}
+		dataNode.RUnlock()
+		return true
+	})
+	c.metaNodes.Range(func(addr, node interface{}) bool {
+		metaNode := node.(*MetaNode)
+		metaNode.RLock()
+		if !metaNode.Health.isHealthy() {
+			nodes = append(nodes, metaNode.Addr)
+		}
+		metaNode.RUnlock()
+		return true
+	})
+	return
+}
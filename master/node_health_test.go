@@ -0,0 +1,74 @@
+package master
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tiglabs/baudstorage/proto"
+)
+
+func observeHeartbeats(h *NodeHealth, count int, stats map[string]proto.NodeStats, now time.Time) {
+	for i := 0; i < count; i++ {
+		h.observe(stats)
+		h.computeScore(now)
+	}
+}
+
+func TestNodeHealthScore(t *testing.T) {
+	now := time.Now()
+	h := newNodeHealth()
+	fastReads := proto.NodeStats{OpCount: 1000, AvgLatencyUs: 2000, MaxLatencyUs: 10000}
+	observeHeartbeats(&h, 10, map[string]proto.NodeStats{"read": fastReads}, now)
+	if h.Score != MaxHealthScore {
+		t.Fatalf("fast node scored %v", h.Score)
+	}
+
+	//the slow writes are not hidden by the many fast reads
+	slowWrites := proto.NodeStats{OpCount: 10, AvgLatencyUs: 400000, MaxLatencyUs: 900000}
+	observeHeartbeats(&h, 10, map[string]proto.NodeStats{"read": fastReads, "write": slowWrites}, now)
+	if h.isHealthy() || h.AvgLatencyMs < 300 || h.MaxLatencyMs != 900 {
+		t.Fatalf("slow node scored %v latency %v max %v", h.Score, h.AvgLatencyMs, h.MaxLatencyMs)
+	}
+
+	//an idle node recovers
+	observeHeartbeats(&h, 20, nil, now)
+	if !h.isHealthy() {
+		t.Fatalf("idle node scored %v latency %v", h.Score, h.AvgLatencyMs)
+	}
+
+	h = newNodeHealth()
+	failing := proto.NodeStats{OpCount: 100, ErrCount: 20, AvgLatencyUs: 2000}
+	observeHeartbeats(&h, 10, map[string]proto.NodeStats{"write": failing}, now)
+	if h.ErrorRate < 0.15 || h.Score > MaxHealthScore-maxErrorPenalty+1 {
+		t.Fatalf("failing node scored %v error rate %v", h.Score, h.ErrorRate)
+	}
+}
+
+func TestNodeHealthFlapDecay(t *testing.T) {
+	now := time.Now()
+	h := newNodeHealth()
+	for i := 0; i < 3; i++ {
+		h.addFlap(now.Add(-time.Duration(i) * time.Minute))
+	}
+	h.computeScore(now)
+	if h.Flaps != 3 || h.Score != MaxHealthScore-3*flapPenalty {
+		t.Fatalf("flaps %v score %v", h.Flaps, h.Score)
+	}
+	for i := 0; i < 5; i++ {
+		h.addFlap(now)
+	}
+	h.computeScore(now)
+	if h.Score != MaxHealthScore-maxFlapPenalty || !h.isHealthy() {
+		t.Fatalf("flap penalty not capped, score %v", h.Score)
+	}
+
+	//the flaps leave the window one by one
+	h.computeScore(now.Add(DefaultNodeFlapWindowSec*time.Second - 90*time.Second))
+	if h.Flaps != 7 {
+		t.Fatalf("flaps %v after the oldest left the window", h.Flaps)
+	}
+	h.computeScore(now.Add(DefaultNodeFlapWindowSec * time.Second))
+	if h.Flaps != 0 || h.Score != MaxHealthScore {
+		t.Fatalf("flaps %v score %v after the window", h.Flaps, h.Score)
+	}
+}
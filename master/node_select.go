@@ -55,18 +55,7 @@ func (nodeTabs NodeTabArrSorterByCarry) SetNodeTabCarry(availCarryCount, replica
 	}
 }
 
-func (c *Cluster) GetMetaNodeMaxTotal() (maxTotal uint64) {
-	c.metaNodes.Range(func(key, value interface{}) bool {
-		metaNode := value.(*MetaNode)
-		if metaNode.Total > maxTotal {
-			maxTotal = metaNode.Total
-		}
-		return true
-	})
-	return
-}
-
-func (c *Cluster) getAvailMetaNodeHosts(excludeHosts []string, replicaNum int) (newHosts []string, peers []proto.Peer, err error) {
+func (zone *Zone) getAvailMetaNodeHosts(excludeHosts []string, replicaNum int) (newHosts []string, peers []proto.Peer, err error) {
 	orderHosts := make([]string, 0)
 	newHosts = make([]string, 0)
 	peers = make([]proto.Peer, 0)
@@ -74,11 +63,11 @@ func (c *Cluster) getAvailMetaNodeHosts(excludeHosts []string, replicaNum int) (
 		return
 	}
 
-	maxTotal := c.GetMetaNodeMaxTotal()
-	nodeTabs, availCarryCount := c.GetAvailCarryMetaNodeTab(maxTotal, excludeHosts)
+	maxTotal := zone.GetMetaNodeMaxTotal()
+	nodeTabs, availCarryCount := zone.GetAvailCarryMetaNodeTab(maxTotal, excludeHosts)
 	if len(nodeTabs) < replicaNum {
-		err = fmt.Errorf(GetAvailMetaNodeHostsErr+" err:%v ,ActiveNodeCount:%v  MatchNodeCount:%v  ",
-			NoHaveAnyMetaNodeToWrite, c.DataNodeCount(), len(nodeTabs))
+		err = fmt.Errorf(GetAvailMetaNodeHostsErr+" err:%v ,zone:%v ActiveNodeCount:%v  MatchNodeCount:%v  ",
+			NoHaveAnyMetaNodeToWrite, zone.name, zone.MetaNodeCount(), len(nodeTabs))
 		return
 	}
 
@@ -93,16 +82,16 @@ func (c *Cluster) getAvailMetaNodeHosts(excludeHosts []string, replicaNum int) (
 		peers = append(peers, peer)
 	}
 
-	if newHosts, err = c.DisOrderArray(orderHosts); err != nil {
+	if newHosts, err = zone.DisOrderArray(orderHosts); err != nil {
 		err = fmt.Errorf(GetAvailMetaNodeHostsErr+"err:%v  orderHosts is nil", err.Error())
 		return
 	}
 	return
 }
 
-func (c *Cluster) GetAvailCarryMetaNodeTab(maxTotal uint64, excludeHosts []string) (nodeTabs NodeTabArrSorterByCarry, availCount int) {
+func (zone *Zone) GetAvailCarryMetaNodeTab(maxTotal uint64, excludeHosts []string) (nodeTabs NodeTabArrSorterByCarry, availCount int) {
 	nodeTabs = make(NodeTabArrSorterByCarry, 0)
-	c.metaNodes.Range(func(key, value interface{}) bool {
+	zone.metaNodes.Range(func(key, value interface{}) bool {
 		metaNode := value.(*MetaNode)
 		if contains(excludeHosts, metaNode.Addr) == true {
 			return true
@@ -120,6 +109,7 @@ func (c *Cluster) GetAvailCarryMetaNodeTab(maxTotal uint64, excludeHosts []strin
 		} else {
 			nt.Weight = (float64)(maxTotal-metaNode.Used) / (float64)(maxTotal)
 		}
+		nt.Weight = nt.Weight * metaNode.healthWeight()
 		nt.Ptr = metaNode
 		nodeTabs = append(nodeTabs, nt)
 
@@ -129,7 +119,7 @@ func (c *Cluster) GetAvailCarryMetaNodeTab(maxTotal uint64, excludeHosts []strin
 	return
 }
 
-func (c *Cluster) DisOrderArray(oldHosts []string) (newHosts []string, err error) {
+func (zone *Zone) DisOrderArray(oldHosts []string) (newHosts []string, err error) {
 	var (
 		newCurrPos int
 	)
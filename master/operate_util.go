@@ -51,6 +51,14 @@ func UnmarshalTaskResponse(task *proto.AdminTask) (err error) {
 		response = &proto.LoadDataPartitionResponse{}
 	case proto.OpDeleteFile:
 		response = &proto.DeleteFileResponse{}
+	case proto.OpDecommissionDisk:
+		response = &proto.DecommissionDiskResponse{}
+	case proto.OpScrubDataPartition:
+		response = &proto.ScrubDataPartitionResponse{}
+	case proto.OpRepairFile:
+		response = &proto.RepairFileResponse{}
+	case proto.OpSetPartitionStatus:
+		response = &proto.SetPartitionStatusResponse{}
 	case proto.OpMetaNodeHeartbeat:
 		response = &proto.MetaNodeHeartbeatResponse{}
 	case proto.OpCreateMetaPartition:
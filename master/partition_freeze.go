@@ -0,0 +1,132 @@
+package master
+
+import (
+	"fmt"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+const (
+	ManualStatusReadOnly    = "readOnly"
+	ManualStatusUnavailable = "unavailable"
+	MaxManualStatusRetry    = 3
+)
+
+func parseManualStatus(value string) (status int8, err error) {
+	switch value {
+	case "", ManualStatusReadOnly:
+		status = proto.ReadOnly
+	case ManualStatusUnavailable:
+		status = proto.Unavaliable
+	default:
+		err = fmt.Errorf("manual status[%v] must be %v or %v", value, ManualStatusReadOnly, ManualStatusUnavailable)
+	}
+	return
+}
+
+//the caller must hold the lock of partition
+func (partition *DataPartition) applyManualStatus() {
+	if partition.ManualStatus != 0 && partition.ManualStatus < partition.Status {
+		partition.Status = partition.ManualStatus
+	}
+}
+
+func (partition *DataPartition) generateSetStatusTasks() (tasks []*proto.AdminTask) {
+	partition.RLock()
+	defer partition.RUnlock()
+	for _, addr := range partition.PersistenceHosts {
+		req := &proto.SetPartitionStatusRequest{
+			PartitionType: partition.PartitionType,
+			PartitionId:   partition.PartitionID,
+			ManualStatus:  partition.ManualStatus,
+		}
+		task := proto.NewAdminTask(proto.OpSetPartitionStatus, addr, req)
+		partition.resetTaskID(task)
+		tasks = append(tasks, task)
+	}
+	return
+}
+
+/*setDataPartitionManualStatus freezes the partition to status, a zero status releases it.
+A frozen partition keeps the status whatever the replicas report. The status is persisted with
+the partition in the raft state machine, and sent to the data nodes, which refuse the writes.
+The copy of the partition is submitted without its lock,it is submitted again if the hosts
+changed meanwhile so the hosts of another update are not overwritten.*/
+func (c *Cluster) setDataPartitionManualStatus(dp *DataPartition, status int8) (err error) {
+	var (
+		vol *Vol
+		dpv *DataPartitionValue
+		old int8
+	)
+	if vol, err = c.getVol(dp.VolName); err != nil {
+		return
+	}
+	for i := 0; ; i++ {
+		dp.RLock()
+		dpv = newDataPartitionValue(dp)
+		old = dp.ManualStatus
+		dp.RUnlock()
+		dpv.ManualStatus = status
+		if err = c.putDataPartitionValue(OpSyncUpdateDataPartition, dp.VolName, dpv); err != nil {
+			return
+		}
+		dp.Lock()
+		if dp.HostsToString() == dpv.Hosts && dp.ReplicaNum == dpv.ReplicaNum {
+			dp.ManualStatus = status
+			dp.Unlock()
+			break
+		}
+		dp.Unlock()
+		if i+1 >= MaxManualStatusRetry {
+			return fmt.Errorf("partition[%v] kept changing while its manual status was set", dp.PartitionID)
+		}
+	}
+	dp.checkStatus(false, c.cfg.DataPartitionTimeOutSec)
+	vol.dataPartitions.updateDataPartitionResponseCache(true, 0)
+	c.putDataNodeTasks(dp.generateSetStatusTasks())
+	log.LogInfof("action[setDataPartitionManualStatus] vol[%v] partition[%v] manual status[%v] -> [%v]",
+		dp.VolName, dp.PartitionID, old, status)
+	return
+}
+
+/*setDataPartitionsManualStatus freezes or releases a batch of partitions, the partitions which
+fail are skipped and the count of the changed ones is returned with the last error.*/
+func (c *Cluster) setDataPartitionsManualStatus(partitionIDs []uint64, status int8) (changed int, err error) {
+	if status != 0 {
+		if err = c.checkFeature(proto.FeatureManualStatus); err != nil {
+			return
+		}
+	}
+	for _, partitionID := range partitionIDs {
+		dp, err1 := c.getDataPartitionByID(partitionID)
+		if err1 == nil {
+			err1 = c.setDataPartitionManualStatus(dp, status)
+		}
+		if err1 != nil {
+			err = err1
+			log.LogErrorf("action[setDataPartitionsManualStatus] partition[%v] failed,err:%v", partitionID, err1)
+			continue
+		}
+		changed++
+	}
+	return
+}
+
+//getDataPartitionIDsOfNode returns the data partitions which have a replica on the data node
+func (c *Cluster) getDataPartitionIDsOfNode(addr string) (partitionIDs []uint64) {
+	partitionIDs = make([]uint64, 0)
+	for partitionID := range c.getPartitionIDsOfNode(DecommissionDataNode, addr, "") {
+		partitionIDs = append(partitionIDs, partitionID)
+	}
+	return
+}
+
+func (c *Cluster) dealSetPartitionStatusResponse(nodeAddr string, resp *proto.SetPartitionStatusResponse) (err error) {
+	if resp.Status == proto.TaskSuccess {
+		return
+	}
+	Warn(c.Name, fmt.Sprintf("clusterID[%v] dataNode[%v] set status of partition[%v] failed,err[%v]",
+		c.Name, nodeAddr, resp.PartitionId, resp.Result))
+	return
+}
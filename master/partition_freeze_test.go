@@ -0,0 +1,81 @@
+package master
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tiglabs/baudstorage/proto"
+)
+
+func newTestFrozenPartition(c *Cluster) (dp *DataPartition) {
+	vol := NewVol("vol", proto.ExtentPartition, PlacementPolicyRack, 3)
+	c.vols[vol.Name] = vol
+	dp = newDataPartition(1, 3, proto.ExtentPartition, vol.Name)
+	dp.PersistenceHosts = []string{"a:1", "b:1", "c:1"}
+	vol.dataPartitions.putDataPartition(dp)
+	return
+}
+
+func submittedPartition(t *testing.T, cmd *Metadata) (dpv *DataPartitionValue) {
+	dpv = &DataPartitionValue{}
+	if err := json.Unmarshal(cmd.V, dpv); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestSetManualStatusSubmitsWithoutLock(t *testing.T) {
+	c, p := newTestCluster()
+	dp := newTestFrozenPartition(c)
+	p.onSubmit = func(cmd *Metadata) {
+		if !dp.TryLock() {
+			t.Fatalf("%v submitted under the lock of the partition", cmd.K)
+		}
+		dp.Unlock()
+	}
+	if err := c.setDataPartitionManualStatus(dp, proto.ReadOnly); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.cmds) != 1 || submittedPartition(t, p.cmds[0]).ManualStatus != proto.ReadOnly {
+		t.Fatalf("manual status not persisted: %v", p.cmds)
+	}
+	if dp.ManualStatus != proto.ReadOnly || dp.Status != proto.ReadOnly {
+		t.Fatalf("partition manual status %v status %v", dp.ManualStatus, dp.Status)
+	}
+}
+
+func TestSetManualStatusKeepsConcurrentHosts(t *testing.T) {
+	c, p := newTestCluster()
+	dp := newTestFrozenPartition(c)
+	p.onSubmit = func(cmd *Metadata) {
+		if len(p.cmds) == 1 {
+			//an offline replaces a host while the status is submitted
+			dp.Lock()
+			dp.PersistenceHosts = []string{"a:1", "b:1", "d:1"}
+			dp.Unlock()
+		}
+	}
+	if err := c.setDataPartitionManualStatus(dp, proto.Unavaliable); err != nil {
+		t.Fatal(err)
+	}
+	last := submittedPartition(t, p.cmds[len(p.cmds)-1])
+	if len(p.cmds) != 2 || !strings.Contains(last.Hosts, "d:1") || last.ManualStatus != proto.Unavaliable {
+		t.Fatalf("the last submit %v of %v overwrites the new hosts", last, len(p.cmds))
+	}
+}
+
+func TestApplyUpdateDataPartitionInPlace(t *testing.T) {
+	c, _ := newTestCluster()
+	dp := newTestFrozenPartition(c)
+	dp.AddMember(NewDataReplica(newTestDataNode("a:1", "z1", "r1")))
+	dpv := newDataPartitionValue(dp)
+	dpv.ManualStatus = proto.ReadOnly
+	cmd := &Metadata{Op: OpSyncUpdateDataPartition, K: DataPartitionPrefix + "vol" + KeySeparator + "1"}
+	cmd.V, _ = json.Marshal(dpv)
+	c.applyUpdateDataPartition(cmd)
+	got, err := c.vols["vol"].getDataPartitionByID(1)
+	if err != nil || got != dp || dp.ManualStatus != proto.ReadOnly || len(dp.Replicas) != 1 {
+		t.Fatalf("partition replaced by the apply: %p %p status %v replicas %v", got, dp, dp.ManualStatus, len(dp.Replicas))
+	}
+}
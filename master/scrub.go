@@ -0,0 +1,596 @@
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+const (
+	ScrubRunning = "running"
+	ScrubDone    = "done"
+)
+
+const (
+	ScrubFileRepairing    = "repairing"
+	ScrubFileRepaired     = "repaired"
+	ScrubFileRepairFailed = "repairFailed"
+	ScrubFileUnrepairable = "unrepairable"
+	ScrubFileSkipped      = "skipped"
+)
+
+/*ScrubFileRecord is a replica of a file whose data does not match its crc or the other replicas.
+Bad are the block numbers of an extent, or the object ids of a tiny chunk, to fetch from Source.*/
+type ScrubFileRecord struct {
+	PartitionID uint64
+	Name        string
+	StoreMode   uint8
+	Addr        string
+	Source      string
+	Bad         []uint64
+	Status      string
+	Reason      string
+}
+
+type ScrubReportValue struct {
+	VolName           string
+	Status            string
+	StartTime         int64
+	EndTime           int64
+	ReadBytesPerSec   int64
+	TotalPartitions   int
+	CheckedPartitions int
+	CheckedFiles      int
+	MismatchFiles     int
+	RepairedFiles     int
+	Remaining         []uint64
+	Failures          map[uint64]string
+	Files             []*ScrubFileRecord
+}
+
+/*ScrubReport records the progress and the result of the last scrub of a vol. The data nodes
+re-read every file of a partition, the master compares the replicas and repairs the bad ones
+from a healthy replica. It is persisted in the raft state machine, so the new leader resumes
+the partitions which are not checked yet after a master failover.*/
+type ScrubReport struct {
+	ScrubReportValue
+	inflight map[uint64]*scrubPartition
+	//changed tells whether the value has changed since it was persisted
+	changed     bool
+	persistLock sync.Mutex
+	sync.RWMutex
+}
+
+//scrubPartition collects the results of the replicas of a partition being scrubbed
+type scrubPartition struct {
+	hosts     []string
+	sendTime  int64
+	responses map[string]*proto.ScrubDataPartitionResponse
+}
+
+func newScrubReport(volName string, partitionIDs []uint64, bytesPerSec int64) (r *ScrubReport) {
+	r = new(ScrubReport)
+	r.VolName = volName
+	r.Status = ScrubRunning
+	r.StartTime = time.Now().Unix()
+	r.ReadBytesPerSec = bytesPerSec
+	r.TotalPartitions = len(partitionIDs)
+	r.Remaining = partitionIDs
+	r.Failures = make(map[uint64]string, 0)
+	r.Files = make([]*ScrubFileRecord, 0)
+	r.inflight = make(map[uint64]*scrubPartition, 0)
+	r.changed = true
+	return
+}
+
+func newScrubReportFromValue(rv *ScrubReportValue) (r *ScrubReport) {
+	r = &ScrubReport{ScrubReportValue: *rv}
+	if r.Failures == nil {
+		r.Failures = make(map[uint64]string, 0)
+	}
+	if r.Files == nil {
+		r.Files = make([]*ScrubFileRecord, 0)
+	}
+	r.inflight = make(map[uint64]*scrubPartition, 0)
+	return
+}
+
+func (r *ScrubReport) isRunning() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.Status == ScrubRunning
+}
+
+func (r *ScrubReport) getStartTime() int64 {
+	r.RLock()
+	defer r.RUnlock()
+	return r.StartTime
+}
+
+func (r *ScrubReport) toJson() (body []byte, err error) {
+	r.RLock()
+	defer r.RUnlock()
+	return json.Marshal(r.ScrubReportValue)
+}
+
+//the caller must hold the lock of r
+func (r *ScrubReport) finishPartition(partitionID uint64) {
+	r.changed = true
+	delete(r.inflight, partitionID)
+	for i, id := range r.Remaining {
+		if id == partitionID {
+			r.Remaining = append(r.Remaining[:i], r.Remaining[i+1:]...)
+			break
+		}
+	}
+}
+
+//the caller must hold the lock of r
+func (r *ScrubReport) addFileRecord(record *ScrubFileRecord) {
+	r.changed = true
+	if len(r.Files) >= DefaultScrubReportFileLimit {
+		return
+	}
+	r.Files = append(r.Files, record)
+}
+
+/*persistScrubReport persists the report if it has changed since it was persisted last time.
+The value is taken under the lock of r and submitted after it is released, the submits of
+a report are kept in order by its persistLock.*/
+func (c *Cluster) persistScrubReport(r *ScrubReport) (err error) {
+	r.persistLock.Lock()
+	defer r.persistLock.Unlock()
+	r.Lock()
+	if !r.changed {
+		r.Unlock()
+		return
+	}
+	r.changed = false
+	value, err := json.Marshal(r.ScrubReportValue)
+	r.Unlock()
+	if err != nil {
+		return
+	}
+	if err = c.syncPutScrubReport(r.VolName, value); err != nil {
+		r.Lock()
+		r.changed = true
+		r.Unlock()
+	}
+	return
+}
+
+func (c *Cluster) getScrubReport(volName string) (r *ScrubReport, err error) {
+	value, ok := c.scrubReports.Load(volName)
+	if !ok {
+		return nil, errors.Annotatef(ScrubReportNotFound, "%v", volName)
+	}
+	return value.(*ScrubReport), nil
+}
+
+func (c *Cluster) putScrubReport(r *ScrubReport) {
+	c.scrubReports.Store(r.VolName, r)
+}
+
+//startScrub starts to scrub all data partitions of the vol, a running scrub is returned as it is
+func (c *Cluster) startScrub(volName string, bytesPerSec int64) (r *ScrubReport, err error) {
+	var vol *Vol
+	if r, err = c.getScrubReport(volName); err == nil && r.isRunning() {
+		return
+	}
+	if vol, err = c.getVol(volName); err != nil {
+		return
+	}
+	if err = c.checkFeature(proto.FeatureScrub); err != nil {
+		return
+	}
+	if bytesPerSec <= 0 {
+		bytesPerSec = c.cfg.ScrubReadBytesPerSec
+	}
+	r = newScrubReport(volName, vol.getDataPartitionIDs(), bytesPerSec)
+	if err = c.persistScrubReport(r); err != nil {
+		return
+	}
+	c.putScrubReport(r)
+	log.LogInfof("action[startScrub] vol[%v] partitions[%v] readBytesPerSec[%v]", volName, r.TotalPartitions, bytesPerSec)
+	return
+}
+
+func (vol *Vol) getDataPartitionIDs() (partitionIDs []uint64) {
+	vol.dataPartitions.RLock()
+	defer vol.dataPartitions.RUnlock()
+	partitionIDs = make([]uint64, 0, len(vol.dataPartitions.dataPartitionMap))
+	for id, dp := range vol.dataPartitions.dataPartitionMap {
+		//the replicas of an ec partition hold different shards,they can not be compared
+		if dp.isEC() {
+			continue
+		}
+		partitionIDs = append(partitionIDs, id)
+	}
+	sort.Slice(partitionIDs, func(i, j int) bool { return partitionIDs[i] < partitionIDs[j] })
+	return
+}
+
+func (c *Cluster) startCheckScrubs() {
+	go func() {
+		for {
+			if c.partition.IsLeader() {
+				c.checkScrubs()
+			}
+			time.Sleep(time.Second * DefaultCheckScrubIntervalSeconds)
+		}
+	}()
+}
+
+/*checkScrubs drives the running scrubs, and starts a new scrub of the vol whose last scrub
+is older than ScrubIntervalSeconds.*/
+func (c *Cluster) checkScrubs() {
+	for volName := range c.copyVols() {
+		r, err := c.getScrubReport(volName)
+		if err == nil && r.isRunning() {
+			c.doScrub(r)
+			continue
+		}
+		if c.cfg.ScrubIntervalSeconds <= 0 {
+			continue
+		}
+		if err == nil && time.Now().Unix()-r.getStartTime() < c.cfg.ScrubIntervalSeconds {
+			continue
+		}
+		if r, err = c.startScrub(volName, c.cfg.ScrubReadBytesPerSec); err != nil {
+			log.LogErrorf("action[checkScrubs] vol[%v] start scrub failed,err:%v", volName, err)
+			continue
+		}
+		c.doScrub(r)
+	}
+}
+
+/*doScrub keeps at most ScrubBatchCount partitions of the vol being scrubbed at the same time,
+and gives up the partitions which are not answered by all replicas in time.*/
+func (c *Cluster) doScrub(r *ScrubReport) {
+	tasks := make([]*proto.AdminTask, 0)
+	r.Lock()
+	now := time.Now().Unix()
+	for partitionID, p := range r.inflight {
+		if now-p.sendTime > DefaultScrubPartitionTimeOutSec {
+			r.Failures[partitionID] = "scrub time out"
+			r.finishPartition(partitionID)
+		}
+	}
+	for _, partitionID := range append([]uint64(nil), r.Remaining...) {
+		if len(r.inflight) >= c.cfg.ScrubBatchCount {
+			break
+		}
+		if _, ok := r.inflight[partitionID]; ok {
+			continue
+		}
+		dp, err := c.getDataPartitionByID(partitionID)
+		if err != nil {
+			r.finishPartition(partitionID)
+			continue
+		}
+		hosts := c.getScrubHosts(dp)
+		if len(hosts) < 2 {
+			r.Failures[partitionID] = "lack of live replicas to compare"
+			r.finishPartition(partitionID)
+			continue
+		}
+		r.inflight[partitionID] = &scrubPartition{hosts: hosts, sendTime: now,
+			responses: make(map[string]*proto.ScrubDataPartitionResponse, 0)}
+		for _, addr := range hosts {
+			tasks = append(tasks, dp.generateScrubTask(addr, r.ReadBytesPerSec))
+		}
+	}
+	if len(r.Remaining) == 0 && len(r.inflight) == 0 {
+		r.changed = true
+		r.Status = ScrubDone
+		r.EndTime = time.Now().Unix()
+		if r.MismatchFiles > 0 || len(r.Failures) > 0 {
+			Warn(c.Name, fmt.Sprintf("clusterID[%v] vol[%v] scrub done,checkedFiles[%v] mismatchFiles[%v] repairedFiles[%v] failedPartitions[%v]",
+				c.Name, r.VolName, r.CheckedFiles, r.MismatchFiles, r.RepairedFiles, len(r.Failures)))
+		}
+	}
+	r.Unlock()
+	if err := c.persistScrubReport(r); err != nil {
+		log.LogErrorf("action[doScrub] vol[%v] persist progress failed,err:%v", r.VolName, err)
+	}
+	c.putDataNodeTasks(tasks)
+}
+
+//getScrubHosts returns the live replicas of the partition, the nodes in maintenance are excluded
+func (c *Cluster) getScrubHosts(dp *DataPartition) (hosts []string) {
+	dp.RLock()
+	defer dp.RUnlock()
+	hosts = make([]string, 0, len(dp.PersistenceHosts))
+	for _, addr := range dp.PersistenceHosts {
+		replica, err := dp.getReplica(addr)
+		if err != nil || !replica.IsLive(c.cfg.DataPartitionTimeOutSec) || c.isDataNodeInMaintenance(addr) {
+			continue
+		}
+		hosts = append(hosts, addr)
+	}
+	return
+}
+
+func (partition *DataPartition) generateScrubTask(addr string, bytesPerSec int64) (task *proto.AdminTask) {
+	req := &proto.ScrubDataPartitionRequest{
+		PartitionType:   partition.PartitionType,
+		PartitionId:     partition.PartitionID,
+		ReadBytesPerSec: bytesPerSec,
+	}
+	task = proto.NewAdminTask(proto.OpScrubDataPartition, addr, req)
+	partition.resetTaskID(task)
+	return
+}
+
+func (partition *DataPartition) generateRepairFileTask(record *ScrubFileRecord) (task *proto.AdminTask) {
+	req := &proto.RepairFileRequest{
+		PartitionType: partition.PartitionType,
+		PartitionId:   partition.PartitionID,
+		Name:          record.Name,
+		StoreMode:     record.StoreMode,
+		Source:        record.Source,
+		Bad:           record.Bad,
+	}
+	task = proto.NewAdminTask(proto.OpRepairFile, record.Addr, req)
+	partition.resetTaskID(task)
+	task.ID = fmt.Sprintf("%v_file[%v]", task.ID, record.Name)
+	return
+}
+
+func (c *Cluster) dealScrubDataPartitionResponse(nodeAddr string, resp *proto.ScrubDataPartitionResponse) (err error) {
+	var (
+		dp *DataPartition
+		r  *ScrubReport
+	)
+	if dp, err = c.getDataPartitionByID(resp.PartitionId); err != nil {
+		return
+	}
+	if r, err = c.getScrubReport(dp.VolName); err != nil {
+		return
+	}
+	r.Lock()
+	p, ok := r.inflight[resp.PartitionId]
+	if !ok {
+		r.Unlock()
+		log.LogWarnf("action[dealScrubDataPartitionResponse] partition[%v] of [%v] is not being scrubbed", resp.PartitionId, nodeAddr)
+		return
+	}
+	p.responses[nodeAddr] = resp
+	if len(p.responses) < len(p.hosts) {
+		r.Unlock()
+		return
+	}
+	records := c.compareScrubResults(r, resp.PartitionId, p)
+	r.CheckedPartitions++
+	r.finishPartition(resp.PartitionId)
+	r.Unlock()
+	if err = c.persistScrubReport(r); err != nil {
+		log.LogErrorf("action[dealScrubDataPartitionResponse] vol[%v] persist progress failed,err:%v", r.VolName, err)
+	}
+	tasks := make([]*proto.AdminTask, 0, len(records))
+	for _, record := range records {
+		tasks = append(tasks, dp.generateRepairFileTask(record))
+	}
+	c.putDataNodeTasks(tasks)
+	return nil
+}
+
+/*compareScrubResults compares the files which have the same size on all replicas. A replica is bad if
+its data does not match its own crc, or its crc differs from the majority of the good replicas.
+The records to repair are returned, the caller must hold the lock of r.*/
+func (c *Cluster) compareScrubResults(r *ScrubReport, partitionID uint64, p *scrubPartition) (repairs []*ScrubFileRecord) {
+	repairs = make([]*ScrubFileRecord, 0)
+	files := make(map[string]map[string]*proto.ScrubFile, 0)
+	replicas := 0
+	for addr, resp := range p.responses {
+		if resp.Status != proto.TaskSuccess {
+			r.Failures[partitionID] = fmt.Sprintf("%v:%v", addr, resp.Result)
+			continue
+		}
+		replicas++
+		for _, f := range resp.Files {
+			if files[f.Name] == nil {
+				files[f.Name] = make(map[string]*proto.ScrubFile, 0)
+			}
+			files[f.Name][addr] = f
+		}
+	}
+	if replicas < 2 {
+		return
+	}
+	for name, replicaFiles := range files {
+		if len(replicaFiles) != replicas || !isSameScrubSize(replicaFiles) {
+			//the file is still being written or repaired,it is checked next time
+			continue
+		}
+		r.CheckedFiles++
+		source, crc := pickHealthyReplica(replicaFiles)
+		if source == "" {
+			Warn(c.Name, fmt.Sprintf("clusterID[%v] vol[%v] partition[%v] file[%v] no majority of the replicas agree,it is unrepairable",
+				c.Name, r.VolName, partitionID, name))
+		}
+		bads := make([]*ScrubFileRecord, 0)
+		for addr, f := range replicaFiles {
+			if source != "" && len(f.Bad) == 0 && f.Crc == crc {
+				continue
+			}
+			record := &ScrubFileRecord{PartitionID: partitionID, Name: name, StoreMode: f.StoreMode, Addr: addr, Source: source, Bad: f.Bad}
+			bads = append(bads, record)
+		}
+		if len(bads) == 0 {
+			continue
+		}
+		r.MismatchFiles++
+		for _, record := range bads {
+			c.planFileRepair(record, replicaFiles[record.Addr])
+			if record.Status == ScrubFileRepairing {
+				repairs = append(repairs, record)
+			}
+			r.addFileRecord(record)
+		}
+	}
+	return
+}
+
+//planFileRepair decides whether the bad replica can be repaired from the source
+func (c *Cluster) planFileRepair(record *ScrubFileRecord, f *proto.ScrubFile) {
+	switch {
+	case record.Source == "":
+		record.Status = ScrubFileUnrepairable
+		record.Reason = "no healthy replica"
+	case c.isDataNodeInMaintenance(record.Addr):
+		record.Status = ScrubFileSkipped
+		record.Reason = "node in maintenance"
+	case len(record.Bad) == 0 && record.StoreMode == proto.TinyStoreMode:
+		record.Status = ScrubFileUnrepairable
+		record.Reason = "crc differs from the healthy replica"
+	case len(record.Bad) == 0:
+		//the bad blocks of the extent are unknown,fetch all of them
+		record.Bad = make([]uint64, 0, f.Count)
+		for i := 0; i < f.Count; i++ {
+			record.Bad = append(record.Bad, uint64(i))
+		}
+		record.Status = ScrubFileRepairing
+		record.Reason = "crc differs from the healthy replica"
+	default:
+		record.Status = ScrubFileRepairing
+		record.Reason = "data does not match the crc"
+	}
+}
+
+func isSameScrubSize(replicaFiles map[string]*proto.ScrubFile) bool {
+	var first *proto.ScrubFile
+	for _, f := range replicaFiles {
+		if first == nil {
+			first = f
+			continue
+		}
+		if f.Size != first.Size || f.Count != first.Count {
+			return false
+		}
+	}
+	return true
+}
+
+/*pickHealthyReplica returns a replica holding the crc shared by a majority of all the replicas without bad data,
+no replica is returned if there is no majority.*/
+func pickHealthyReplica(replicaFiles map[string]*proto.ScrubFile) (source string, crc uint32) {
+	votes := make(map[uint32]int, 0)
+	for _, f := range replicaFiles {
+		if len(f.Bad) == 0 {
+			votes[f.Crc]++
+		}
+	}
+	maxVotes := 0
+	for value, count := range votes {
+		if count > maxVotes {
+			crc, maxVotes = value, count
+		}
+	}
+	if maxVotes <= len(replicaFiles)/2 {
+		return "", 0
+	}
+	addrs := make([]string, 0)
+	for addr, f := range replicaFiles {
+		if len(f.Bad) == 0 && f.Crc == crc {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	source = addrs[0]
+	return
+}
+
+/*dealCorruptionReport repairs the files found bad by the background scrub of a data node from another
+live replica. The data node refuses a copy of a block which does not match the crc it stored, so a
+repair from a bad source fails instead of spreading it. The records are added to the last scrub
+report of the vol, if any.*/
+func (c *Cluster) dealCorruptionReport(report *proto.CorruptionReport) (err error) {
+	var dp *DataPartition
+	if dp, err = c.getDataPartitionByID(report.PartitionId); err != nil {
+		return
+	}
+	if dp.isEC() {
+		return fmt.Errorf("partition[%v] is an ec partition", report.PartitionId)
+	}
+	var source string
+	for _, addr := range c.getScrubHosts(dp) {
+		if addr != report.Addr {
+			source = addr
+			break
+		}
+	}
+	records := make([]*ScrubFileRecord, 0, len(report.Files))
+	tasks := make([]*proto.AdminTask, 0, len(report.Files))
+	for _, f := range report.Files {
+		record := &ScrubFileRecord{PartitionID: report.PartitionId, Name: f.Name, StoreMode: f.StoreMode,
+			Addr: report.Addr, Source: source, Bad: f.Bad}
+		c.planFileRepair(record, f)
+		Warn(c.Name, fmt.Sprintf("clusterID[%v] dataNode[%v] partition[%v] file[%v] bad%v found by scrub,%v",
+			c.Name, report.Addr, report.PartitionId, f.Name, f.Bad, record.Status))
+		if record.Status == ScrubFileRepairing {
+			tasks = append(tasks, dp.generateRepairFileTask(record))
+		}
+		records = append(records, record)
+	}
+	if r, e := c.getScrubReport(dp.VolName); e == nil {
+		r.Lock()
+		r.MismatchFiles += len(records)
+		for _, record := range records {
+			r.addFileRecord(record)
+		}
+		r.Unlock()
+		if e = c.persistScrubReport(r); e != nil {
+			log.LogErrorf("action[dealCorruptionReport] vol[%v] persist progress failed,err:%v", r.VolName, e)
+		}
+	}
+	c.putDataNodeTasks(tasks)
+	return
+}
+
+func (c *Cluster) dealRepairFileResponse(nodeAddr string, resp *proto.RepairFileResponse) (err error) {
+	var (
+		dp *DataPartition
+		r  *ScrubReport
+	)
+	if dp, err = c.getDataPartitionByID(resp.PartitionId); err != nil {
+		return
+	}
+	if resp.Status != proto.TaskSuccess {
+		Warn(c.Name, fmt.Sprintf("clusterID[%v] dataNode[%v] repair partition[%v] file[%v] failed,err[%v]",
+			c.Name, nodeAddr, resp.PartitionId, resp.Name, resp.Result))
+	}
+	//the file reported by the background scrub of a data node may have no scrub report to update
+	if r, err = c.getScrubReport(dp.VolName); err != nil {
+		return nil
+	}
+	r.Lock()
+	r.changed = true
+	if resp.Status == proto.TaskSuccess {
+		r.RepairedFiles++
+	}
+	//the record may be absent if the report has reached DefaultScrubReportFileLimit
+	for _, record := range r.Files {
+		if record.PartitionID != resp.PartitionId || record.Name != resp.Name ||
+			record.Addr != nodeAddr || record.Status != ScrubFileRepairing {
+			continue
+		}
+		if resp.Status == proto.TaskSuccess {
+			record.Status = ScrubFileRepaired
+		} else {
+			record.Status = ScrubFileRepairFailed
+			record.Reason = resp.Result
+		}
+	}
+	r.Unlock()
+	if err = c.persistScrubReport(r); err != nil {
+		log.LogErrorf("action[dealRepairFileResponse] vol[%v] persist progress failed,err:%v", r.VolName, err)
+	}
+	return nil
+}
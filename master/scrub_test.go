@@ -0,0 +1,28 @@
+package master
+
+import (
+	"testing"
+
+	"github.com/tiglabs/baudstorage/proto"
+)
+
+func TestPickHealthyReplica(t *testing.T) {
+	files := map[string]*proto.ScrubFile{
+		"a:1": {Crc: 1},
+		"b:1": {Crc: 2},
+		"c:1": {Crc: 2},
+	}
+	if source, crc := pickHealthyReplica(files); source != "b:1" || crc != 2 {
+		t.Fatalf("majority picked %v %v", source, crc)
+	}
+	//a tie is not broken by the crc
+	delete(files, "c:1")
+	if source, _ := pickHealthyReplica(files); source != "" {
+		t.Fatalf("tie picked %v", source)
+	}
+	//the replicas with bad data vote for nothing
+	files["c:1"] = &proto.ScrubFile{Crc: 1, Bad: []uint64{3}}
+	if source, _ := pickHealthyReplica(files); source != "" {
+		t.Fatalf("minority picked %v", source)
+	}
+}
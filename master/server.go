@@ -5,6 +5,7 @@ import (
 	"github.com/juju/errors"
 	"github.com/tiglabs/baudstorage/raftstore"
 	"github.com/tiglabs/baudstorage/util/config"
+	"github.com/tiglabs/baudstorage/util/encrypt"
 	"github.com/tiglabs/baudstorage/util/log"
 	"github.com/tiglabs/baudstorage/util/ump"
 	"strconv"
@@ -23,24 +24,32 @@ const (
 	GroupId           = 1
 	UmpModuleName     = "master"
 	CfgRetainLogs     = "retainLogs"
+	CfgRestoreFile    = "restoreFile"
+	CfgMasterKeyFile  = "masterKeyFile"
 	DefaultRetainLogs = 20000
 )
 
 type Master struct {
-	id          uint64
-	clusterName string
-	ip          string
-	port        string
-	walDir      string
-	storeDir    string
-	retainLogs  uint64
-	leaderInfo  *LeaderInfo
-	config      *ClusterConfig
-	cluster     *Cluster
-	raftStore   raftstore.RaftStore
-	fsm         *MetadataFsm
-	partition   raftstore.Partition
-	wg          sync.WaitGroup
+	id               uint64
+	clusterName      string
+	ip               string
+	port             string
+	walDir           string
+	storeDir         string
+	retainLogs       uint64
+	restoreFile      string
+	//restoreID identifies the content of restoreFile,restoreIntoEmpty tells whether the dirs were empty
+	restoreID        string
+	restoreIntoEmpty bool
+	masterKey        []byte
+	leaderInfo       *LeaderInfo
+	staleLease       staleReadLease
+	config           *ClusterConfig
+	cluster          *Cluster
+	raftStore        raftstore.RaftStore
+	fsm              *MetadataFsm
+	partition        raftstore.Partition
+	wg               sync.WaitGroup
 }
 
 func NewServer() *Master {
@@ -55,15 +64,28 @@ func (m *Master) Start(cfg *config.Config) (err error) {
 		log.LogError(errors.ErrorStack(err))
 		return
 	}
+	if err = ump.ConfigSink(cfg); err != nil {
+		log.LogError(errors.ErrorStack(err))
+		return
+	}
 	ump.InitUmp(fmt.Sprintf("%v_%v", m.clusterName, UmpModuleName))
+	if m.restoreFile != "" {
+		if err = m.checkRestore(); err != nil {
+			log.LogError(errors.ErrorStack(err))
+			return
+		}
+	}
 	if err = m.createRaftServer(); err != nil {
 		log.LogError(errors.ErrorStack(err))
 		return
 	}
 	m.cluster = newCluster(m.clusterName, m.leaderInfo, m.fsm, m.partition)
 	m.cluster.retainLogs = m.retainLogs
+	m.cluster.masterKey = m.masterKey
 	m.loadMetadata()
+	m.registerMetrics()
 	m.startHttpService()
+	m.scheduleRenewStaleReadLease()
 	m.wg.Add(1)
 	return nil
 }
@@ -87,6 +109,7 @@ func (m *Master) checkConfig(cfg *config.Config) (err error) {
 	replicaNum := cfg.GetString(ReplicaNum)
 	m.walDir = cfg.GetString(WalDir)
 	m.storeDir = cfg.GetString(StoreDir)
+	m.restoreFile = cfg.GetString(CfgRestoreFile)
 	peerAddrs := cfg.GetString(CfgPeers)
 	if m.retainLogs, err = strconv.ParseUint(cfg.GetString(CfgRetainLogs), 10, 64); err != nil {
 		return fmt.Errorf("%v,err:%v", ErrBadConfFile, err.Error())
@@ -141,6 +164,12 @@ func (m *Master) checkConfig(cfg *config.Config) (err error) {
 	if m.config.everyLoadDataPartitionCount <= 40 {
 		m.config.everyLoadDataPartitionCount = 40
 	}
+	//the vols can be encrypted only if the master key of the cluster is configured
+	if masterKeyFile := cfg.GetString(CfgMasterKeyFile); masterKeyFile != "" {
+		if m.masterKey, err = encrypt.LoadMasterKey(masterKeyFile); err != nil {
+			return fmt.Errorf("%v,err:%v", ErrBadConfFile, err.Error())
+		}
+	}
 
 	return
 }
@@ -155,6 +184,11 @@ func (m *Master) createRaftServer() (err error) {
 	fsm.RegisterPeerChangeHandler(m.handlePeerChange)
 	fsm.RegisterApplyHandler(m.handleApply)
 	fsm.RegisterApplySnapshotHandler(m.handleApplySnapshot)
+	if m.restoreFile != "" {
+		if err = m.restoreStore(fsm); err != nil {
+			return errors.Annotatef(err, "restore from %v failed", m.restoreFile)
+		}
+	}
 	fsm.restore()
 	m.fsm = fsm
 	fmt.Println(m.config.peers)
@@ -11,16 +11,32 @@ import (
 )
 
 type Topology struct {
+	zoneIndex int
+	zoneMap   map[string]*Zone
+	zones     []string
+	zoneLock  sync.RWMutex
+}
+
+func NewTopology() (t *Topology) {
+	t = new(Topology)
+	t.zoneMap = make(map[string]*Zone)
+	t.zones = make([]string, 0)
+	return
+}
+
+type Zone struct {
+	name      string
 	rackIndex int
 	rackMap   map[string]*Rack
 	racks     []string
 	rackLock  sync.RWMutex
+	metaNodes sync.Map
 }
 
-func NewTopology() (t *Topology) {
-	t = new(Topology)
-	t.rackMap = make(map[string]*Rack)
-	t.racks = make([]string, 0)
+func NewZone(name string) (zone *Zone) {
+	zone = &Zone{name: name}
+	zone.rackMap = make(map[string]*Rack)
+	zone.racks = make([]string, 0)
 	return
 }
 
@@ -34,33 +50,212 @@ func NewRack(name string) (rack *Rack) {
 	return &Rack{name: name}
 }
 
-func (t *Topology) isSingleRack() bool {
-	t.rackLock.RLock()
-	defer t.rackLock.RUnlock()
-	return len(t.rackMap) == 1
+func (t *Topology) getZone(name string) (zone *Zone, err error) {
+	t.zoneLock.RLock()
+	defer t.zoneLock.RUnlock()
+	zone, ok := t.zoneMap[name]
+	if !ok {
+		return nil, errors.Annotatef(ZoneNotFound, "%v not found", name)
+	}
+	return
+}
+
+func (t *Topology) putZone(zone *Zone) {
+	t.zoneLock.Lock()
+	defer t.zoneLock.Unlock()
+	t.zoneMap[zone.name] = zone
+	if ok := contains(t.zones, zone.name); !ok {
+		t.zones = append(t.zones, zone.name)
+	}
+}
+
+func (t *Topology) getOrCreateZone(name string) (zone *Zone) {
+	name = zoneNameOf(name)
+	t.zoneLock.Lock()
+	defer t.zoneLock.Unlock()
+	zone, ok := t.zoneMap[name]
+	if !ok {
+		zone = NewZone(name)
+		t.zoneMap[name] = zone
+		t.zones = append(t.zones, name)
+	}
+	return
+}
+
+//nodes reporting no zone belong to the default zone
+func zoneNameOf(name string) string {
+	if name == "" {
+		return DefaultZoneName
+	}
+	return name
+}
+
+func (t *Topology) putDataNode(dataNode *DataNode) {
+	zone := t.getOrCreateZone(dataNode.ZoneName)
+	zone.putDataNode(dataNode)
+}
+
+func (t *Topology) removeDataNode(dataNode *DataNode) {
+	zone, err := t.getZone(dataNode.ZoneName)
+	if err != nil {
+		return
+	}
+	if rack, err := zone.getRack(dataNode.RackName); err == nil {
+		rack.RemoveDataNode(dataNode.Addr)
+	}
+}
+
+func (t *Topology) putMetaNode(metaNode *MetaNode) {
+	zone := t.getOrCreateZone(metaNode.ZoneName)
+	zone.putMetaNode(metaNode)
+}
+
+func (t *Topology) removeMetaNode(metaNode *MetaNode) {
+	if zone, err := t.getZone(metaNode.ZoneName); err == nil {
+		zone.RemoveMetaNode(metaNode.Addr)
+	}
+}
+
+/*allocZones returns the zones a new partition should be placed in, together with
+the number of replicas each of them has to hold under the given placement policy.
+canWrite reports whether a zone can take the given number of replicas. The crossZone
+policy spreads the replicas over enough zones that losing any one of them leaves a
+majority of the replicas, e.g. 3 replicas need 3 zones.*/
+func (t *Topology) allocZones(policy string, replicaNum int, excludeZones []string,
+	canWrite func(zone *Zone, replicaNum int) bool) (zones []*Zone, counts []int, err error) {
+	zones = make([]*Zone, 0)
+	counts = make([]int, 0)
+	candidates := t.candidateZones(excludeZones)
+	switch policy {
+	case PlacementPolicyZone:
+		for _, zone := range candidates {
+			if len(zones) >= replicaNum {
+				break
+			}
+			if canWrite(zone, 1) {
+				zones = append(zones, zone)
+				counts = append(counts, 1)
+			}
+		}
+		if len(zones) < replicaNum {
+			err = NoZoneForCreatePartition
+		}
+	case PlacementPolicyCrossZone:
+		zones, counts, err = spreadZones(candidates, replicaNum, crossZoneNum(replicaNum), canWrite)
+	default:
+		for _, zone := range candidates {
+			if canWrite(zone, replicaNum) {
+				zones = append(zones, zone)
+				counts = append(counts, replicaNum)
+				break
+			}
+		}
+		if len(zones) == 0 {
+			err = NoZoneForCreatePartition
+		}
+	}
+	if err != nil {
+		log.LogError(fmt.Sprintf("action[allocZones],policy:%v,replicaNum:%v,err:%v", policy, replicaNum, err))
+		return nil, nil, err
+	}
+	return
+}
+
+//allocSpreadZones spreads the replicas over enough zones that none of them holds more than maxPerZone
+func (t *Topology) allocSpreadZones(replicaNum, maxPerZone int, excludeZones []string,
+	canWrite func(zone *Zone, replicaNum int) bool) (zones []*Zone, counts []int, err error) {
+	zoneNum := (replicaNum + maxPerZone - 1) / maxPerZone
+	if zones, counts, err = spreadZones(t.candidateZones(excludeZones), replicaNum, zoneNum, canWrite); err != nil {
+		log.LogError(fmt.Sprintf("action[allocSpreadZones],replicaNum:%v,maxPerZone:%v,err:%v", replicaNum, maxPerZone, err))
+	}
+	return
+}
+
+//candidateZones returns the zones not excluded, starting from the next zone in turn
+func (t *Topology) candidateZones(excludeZones []string) (candidates []*Zone) {
+	candidates = make([]*Zone, 0)
+	t.zoneLock.Lock()
+	defer t.zoneLock.Unlock()
+	zoneCount := len(t.zones)
+	for i := 0; i < zoneCount; i++ {
+		if t.zoneIndex >= zoneCount {
+			t.zoneIndex = 0
+		}
+		zone := t.zoneMap[t.zones[t.zoneIndex]]
+		t.zoneIndex++
+		if contains(excludeZones, zone.name) {
+			continue
+		}
+		candidates = append(candidates, zone)
+	}
+	return
+}
+
+//spreadZones deals the replicas in turn to zoneNum writable zones of the candidates
+func spreadZones(candidates []*Zone, replicaNum, zoneNum int,
+	canWrite func(zone *Zone, replicaNum int) bool) (zones []*Zone, counts []int, err error) {
+	zones = make([]*Zone, 0, zoneNum)
+	counts = make([]int, 0, zoneNum)
+	for _, zone := range candidates {
+		if len(zones) >= zoneNum {
+			break
+		}
+		if canWrite(zone, 1) {
+			zones = append(zones, zone)
+			counts = append(counts, 0)
+		}
+	}
+	if len(zones) < zoneNum {
+		return nil, nil, errors.Annotatef(NoZoneForCreatePartition, "%v replicas need %v writable zones,found %v",
+			replicaNum, zoneNum, len(zones))
+	}
+	for i := 0; i < replicaNum; i++ {
+		counts[i%len(zones)]++
+	}
+	for i, zone := range zones {
+		if !canWrite(zone, counts[i]) {
+			return nil, nil, NoZoneForCreatePartition
+		}
+	}
+	return
+}
+
+//crossZoneNum returns the number of zones none of which holds a majority of the replicas
+func crossZoneNum(replicaNum int) int {
+	maxPerZone := (replicaNum - 1) / 2
+	if maxPerZone == 0 {
+		maxPerZone = 1
+	}
+	return (replicaNum + maxPerZone - 1) / maxPerZone
+}
+
+func (zone *Zone) isSingleRack() bool {
+	zone.rackLock.RLock()
+	defer zone.rackLock.RUnlock()
+	return len(zone.rackMap) == 1
 }
 
-func (t *Topology) getRack(name string) (rack *Rack, err error) {
-	t.rackLock.RLock()
-	defer t.rackLock.RUnlock()
-	rack, ok := t.rackMap[name]
+func (zone *Zone) getRack(name string) (rack *Rack, err error) {
+	zone.rackLock.RLock()
+	defer zone.rackLock.RUnlock()
+	rack, ok := zone.rackMap[name]
 	if !ok {
 		return nil, errors.Annotatef(RackNotFound, "%v not found", name)
 	}
 	return
 }
 
-func (t *Topology) putRack(rack *Rack) {
-	t.rackLock.Lock()
-	defer t.rackLock.Unlock()
-	t.rackMap[rack.name] = rack
-	if ok := t.isExist(rack.name); !ok {
-		t.racks = append(t.racks, rack.name)
+func (zone *Zone) putRack(rack *Rack) {
+	zone.rackLock.Lock()
+	defer zone.rackLock.Unlock()
+	zone.rackMap[rack.name] = rack
+	if ok := zone.isExist(rack.name); !ok {
+		zone.racks = append(zone.racks, rack.name)
 	}
 }
 
-func (t *Topology) isExist(rackName string) (ok bool) {
-	for _, name := range t.racks {
+func (zone *Zone) isExist(rackName string) (ok bool) {
+	for _, name := range zone.racks {
 		if name == rackName {
 			ok = true
 			return
@@ -69,59 +264,58 @@ func (t *Topology) isExist(rackName string) (ok bool) {
 	return
 }
 
-func (t *Topology) removeRack(name string) {
-	t.rackLock.Lock()
-	defer t.rackLock.Unlock()
-	delete(t.rackMap, name)
+func (zone *Zone) removeRack(name string) {
+	zone.rackLock.Lock()
+	defer zone.rackLock.Unlock()
+	delete(zone.rackMap, name)
 }
 
-func (t *Topology) putDataNode(dataNode *DataNode) {
-	rack, err := t.getRack(dataNode.RackName)
+func (zone *Zone) putDataNode(dataNode *DataNode) {
+	rack, err := zone.getRack(dataNode.RackName)
 	if err != nil {
 		rack = NewRack(dataNode.RackName)
-		t.putRack(rack)
+		zone.putRack(rack)
 	}
 	rack.PutDataNode(dataNode)
 }
 
-func (t *Topology) getAllRacks() (racks []*Rack) {
-	t.rackLock.RLock()
-	defer t.rackLock.RUnlock()
+func (zone *Zone) getAllRacks() (racks []*Rack) {
+	zone.rackLock.RLock()
+	defer zone.rackLock.RUnlock()
 	racks = make([]*Rack, 0)
-	for _, rack := range t.rackMap {
+	for _, rack := range zone.rackMap {
 		racks = append(racks, rack)
 	}
 	return
 }
 
-func (t *Topology) getRackNameByIndex(index int) (rName string) {
-	t.rackLock.RLock()
-	defer t.rackLock.RUnlock()
-	rName = t.racks[index]
+func (zone *Zone) getRackNameByIndex(index int) (rName string) {
+	zone.rackLock.RLock()
+	defer zone.rackLock.RUnlock()
+	rName = zone.racks[index]
 	return
 }
 
-func (t *Topology) allocRacks(replicaNum int, excludeRack []string) (racks []*Rack, err error) {
-	racks = make([]*Rack, 0)
+func (zone *Zone) allocRacks(replicaNum int, excludeRack []string) (racks []*Rack, err error) {
 	if excludeRack == nil {
 		excludeRack = make([]string, 0)
 	}
-	racks = t.getAllRacks()
-	if t.isSingleRack() {
-		return racks, nil
+	if zone.isSingleRack() {
+		return zone.getAllRacks(), nil
 	}
-
-	for i := 0; i < len(racks); i++ {
-		if t.rackIndex >= len(racks) {
-			t.rackIndex = 0
+	racks = make([]*Rack, 0)
+	rackCount := len(zone.racks)
+	for i := 0; i < rackCount; i++ {
+		if zone.rackIndex >= rackCount {
+			zone.rackIndex = 0
 		}
-		rName := t.getRackNameByIndex(t.rackIndex)
+		rName := zone.getRackNameByIndex(zone.rackIndex)
+		zone.rackIndex++
 		if contains(excludeRack, rName) {
 			continue
 		}
-		t.rackIndex++
 		var rack *Rack
-		if rack, err = t.getRack(t.racks[t.rackIndex]); err != nil {
+		if rack, err = zone.getRack(rName); err != nil {
 			continue
 		}
 		if rack.canWrite(1) {
@@ -132,7 +326,7 @@ func (t *Topology) allocRacks(replicaNum int, excludeRack []string) (racks []*Ra
 		}
 	}
 	if len(racks) == 0 {
-		log.LogError(fmt.Sprintf("action[allocRacks],err:%v", NoRackForCreateDataPartition))
+		log.LogError(fmt.Sprintf("action[allocRacks],zone:%v,err:%v", zone.name, NoRackForCreateDataPartition))
 		return nil, NoRackForCreateDataPartition
 	}
 	if len(racks) > int(replicaNum) {
@@ -142,6 +336,143 @@ func (t *Topology) allocRacks(replicaNum int, excludeRack []string) (racks []*Ra
 	return
 }
 
+func (zone *Zone) canWriteForDataNode(replicaNum int) (can bool) {
+	var leastAlive int
+	for _, rack := range zone.getAllRacks() {
+		leastAlive = leastAlive + rack.writableDataNodeCount()
+		if leastAlive >= replicaNum {
+			return true
+		}
+	}
+	return
+}
+
+/*getAvailDataNodeHosts choose replicaNum data nodes of this zone, spread across
+its racks: a single rack takes all replicas, two racks are split into a majority
+and a minority, otherwise each rack takes one replica.*/
+func (zone *Zone) getAvailDataNodeHosts(excludeHosts []string, replicaNum int) (hosts []string, err error) {
+	var (
+		addrs []string
+		racks []*Rack
+	)
+	hosts = make([]string, 0)
+	if replicaNum == 0 {
+		return
+	}
+	exclude := make([]string, 0, len(excludeHosts)+replicaNum)
+	exclude = append(exclude, excludeHosts...)
+	if racks, err = zone.allocRacks(replicaNum, nil); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if len(racks) == 2 {
+		masterRack := racks[0]
+		slaveRack := racks[1]
+		masterReplicaNum := replicaNum/2 + 1
+		slaveReplicaNum := replicaNum - masterReplicaNum
+		if addrs, err = masterRack.getAvailDataNodeHosts(exclude, masterReplicaNum); err != nil {
+			return nil, errors.Trace(err)
+		}
+		hosts = append(hosts, addrs...)
+		exclude = append(exclude, addrs...)
+		if addrs, err = slaveRack.getAvailDataNodeHosts(exclude, slaveReplicaNum); err != nil {
+			return nil, errors.Trace(err)
+		}
+		hosts = append(hosts, addrs...)
+	} else if len(racks) == replicaNum {
+		for index := 0; index < replicaNum; index++ {
+			rack := racks[index]
+			if addrs, err = rack.getAvailDataNodeHosts(exclude, 1); err != nil {
+				return nil, errors.Trace(err)
+			}
+			hosts = append(hosts, addrs...)
+			exclude = append(exclude, addrs...)
+		}
+	} else if len(racks) == 1 {
+		if hosts, err = racks[0].getAvailDataNodeHosts(exclude, replicaNum); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if len(hosts) != replicaNum {
+		return nil, NoAnyDataNodeForCreateDataPartition
+	}
+	return
+}
+
+/*getSpreadDataNodeHosts chooses replicaNum data nodes of this zone with at most maxPerRack of them
+on a rack, the replicas are dealt in turn to the writable racks*/
+func (zone *Zone) getSpreadDataNodeHosts(excludeHosts []string, replicaNum, maxPerRack int) (hosts []string, err error) {
+	var (
+		addrs []string
+		racks []*Rack
+	)
+	if racks, err = zone.allocRacks(replicaNum, nil); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if rackNum := (replicaNum + maxPerRack - 1) / maxPerRack; len(racks) < rackNum {
+		return nil, errors.Annotatef(NoRackForCreateDataPartition, "zone[%v] %v replicas need %v writable racks,found %v",
+			zone.name, replicaNum, rackNum, len(racks))
+	}
+	counts := make([]int, len(racks))
+	for i := 0; i < replicaNum; i++ {
+		counts[i%len(racks)]++
+	}
+	hosts = make([]string, 0, replicaNum)
+	exclude := make([]string, 0, len(excludeHosts)+replicaNum)
+	exclude = append(exclude, excludeHosts...)
+	for index, rack := range racks {
+		if addrs, err = rack.getAvailDataNodeHosts(exclude, counts[index]); err != nil {
+			return nil, errors.Trace(err)
+		}
+		hosts = append(hosts, addrs...)
+		exclude = append(exclude, addrs...)
+	}
+	return
+}
+
+func (zone *Zone) putMetaNode(metaNode *MetaNode) {
+	zone.metaNodes.Store(metaNode.Addr, metaNode)
+}
+
+func (zone *Zone) RemoveMetaNode(addr string) {
+	zone.metaNodes.Delete(addr)
+}
+
+func (zone *Zone) canWriteForMetaNode(replicaNum int) (can bool) {
+	var leastAlive int
+	zone.metaNodes.Range(func(addr, value interface{}) bool {
+		metaNode := value.(*MetaNode)
+		if metaNode.IsWriteAble() == true {
+			leastAlive++
+		}
+		if leastAlive >= replicaNum {
+			can = true
+			return false
+		}
+		return true
+	})
+	return
+}
+
+func (zone *Zone) GetMetaNodeMaxTotal() (maxTotal uint64) {
+	zone.metaNodes.Range(func(key, value interface{}) bool {
+		metaNode := value.(*MetaNode)
+		if metaNode.Total > maxTotal {
+			maxTotal = metaNode.Total
+		}
+		return true
+	})
+	return
+}
+
+func (zone *Zone) MetaNodeCount() (len int) {
+	zone.metaNodes.Range(func(key, value interface{}) bool {
+		len++
+		return true
+	})
+	return
+}
+
 func (rack *Rack) canWrite(replicaNum uint8) (can bool) {
 	rack.RLock()
 	defer rack.RUnlock()
@@ -160,6 +491,17 @@ func (rack *Rack) canWrite(replicaNum uint8) (can bool) {
 	return
 }
 
+func (rack *Rack) writableDataNodeCount() (count int) {
+	rack.dataNodes.Range(func(addr, value interface{}) bool {
+		dataNode := value.(*DataNode)
+		if dataNode.isActive == true && dataNode.IsWriteAble() == true {
+			count++
+		}
+		return true
+	})
+	return
+}
+
 func (rack *Rack) PutDataNode(dataNode *DataNode) {
 	rack.dataNodes.Store(dataNode.Addr, dataNode)
 }
@@ -242,6 +584,7 @@ func (rack *Rack) GetAvailCarryDataNodeTab(maxTotal uint64, excludeHosts []strin
 		} else {
 			nt.Weight = float64(dataNode.RemainWeightsForCreateVol) / float64(maxTotal)
 		}
+		nt.Weight = nt.Weight * dataNode.healthWeight()
 		nt.Ptr = dataNode
 		nodeTabs = append(nodeTabs, nt)
 
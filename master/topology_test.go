@@ -0,0 +1,141 @@
+package master
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func newTestTopology(zoneNames ...string) (t *Topology) {
+	t = NewTopology()
+	for _, name := range zoneNames {
+		t.getOrCreateZone(name)
+	}
+	return
+}
+
+// canWriteReplicas returns a canWrite of allocZones by the number of replicas each zone can take
+func canWriteReplicas(capacity map[string]int) func(zone *Zone, replicaNum int) bool {
+	return func(zone *Zone, replicaNum int) bool {
+		return capacity[zone.name] >= replicaNum
+	}
+}
+
+func TestAllocZonesCrossZone(t *testing.T) {
+	all := canWriteReplicas(map[string]int{"z1": 5, "z2": 5, "z3": 5})
+	//no zone at all is an error instead of a panic
+	if _, _, err := newTestTopology().allocZones(PlacementPolicyCrossZone, 1, nil, all); err == nil {
+		t.Fatal("zones allocated from an empty topology")
+	}
+	//two zones can not keep a majority of 3 replicas after losing one of them
+	if _, _, err := newTestTopology("z1", "z2").allocZones(PlacementPolicyCrossZone, 3, nil, all); err == nil {
+		t.Fatal("3 replicas placed in 2 zones")
+	}
+	zones, counts, err := newTestTopology("z1", "z2", "z3").allocZones(PlacementPolicyCrossZone, 3, nil, all)
+	if err != nil || len(zones) != 3 || fmt.Sprint(counts) != "[1 1 1]" {
+		t.Fatalf("3 replicas in 3 zones: %v %v", counts, err)
+	}
+	zones, counts, err = newTestTopology("z1", "z2", "z3").allocZones(PlacementPolicyCrossZone, 5, nil, all)
+	if err != nil || len(zones) != 3 || fmt.Sprint(counts) != "[2 2 1]" {
+		t.Fatalf("5 replicas in 3 zones: %v %v", counts, err)
+	}
+	//a zone which can not take its share fails the allocation
+	some := canWriteReplicas(map[string]int{"z1": 1, "z2": 5, "z3": 5})
+	if _, _, err = newTestTopology("z1", "z2", "z3").allocZones(PlacementPolicyCrossZone, 5, nil, some); err == nil {
+		t.Fatal("zone overloaded")
+	}
+}
+
+func TestAllocZonesZoneAndRack(t *testing.T) {
+	topo := newTestTopology("z1", "z2", "z3")
+	canWrite := canWriteReplicas(map[string]int{"z1": 3, "z2": 0, "z3": 3})
+	if _, _, err := topo.allocZones(PlacementPolicyZone, 3, nil, canWrite); err == nil {
+		t.Fatal("3 replicas placed in 2 writable zones")
+	}
+	zones, counts, err := topo.allocZones(PlacementPolicyZone, 2, nil, canWrite)
+	if err != nil || len(zones) != 2 || zones[0].name == "z2" || zones[1].name == "z2" {
+		t.Fatalf("2 replicas in 2 zones: %v %v", counts, err)
+	}
+	for i := 0; i < 3; i++ {
+		zones, counts, err = topo.allocZones(PlacementPolicyRack, 3, []string{"z1"}, canWrite)
+		if err != nil || len(zones) != 1 || zones[0].name != "z3" || counts[0] != 3 {
+			t.Fatalf("rack policy placed in %v %v %v", zones, counts, err)
+		}
+	}
+}
+
+func TestChooseTargetMetaHostsRackPolicy(t *testing.T) {
+	c := &Cluster{t: NewTopology()}
+	for i, zoneName := range []string{"z1", "z2", "z3"} {
+		metaNode := &MetaNode{Addr: fmt.Sprintf("127.0.0.%v:9021", i+1), ID: uint64(i + 1), ZoneName: zoneName,
+			IsActive: true, Total: 100, MaxMemAvailWeight: DefaultMetaNodeReservedMem + 1, Health: newNodeHealth()}
+		c.metaNodes.Store(metaNode.Addr, metaNode)
+		c.t.putMetaNode(metaNode)
+	}
+	//the default policy chooses from all the meta nodes as before the zones
+	hosts, peers, err := c.ChooseTargetMetaHosts(3, PlacementPolicyRack)
+	if err != nil || len(hosts) != 3 || len(peers) != 3 {
+		t.Fatalf("meta hosts %v %v", hosts, err)
+	}
+	if !c.hasEnoughWritableMetaHosts(3, PlacementPolicyRack) || c.hasEnoughWritableMetaHosts(4, PlacementPolicyRack) {
+		t.Fatal("writable meta hosts counted wrong")
+	}
+}
+
+func newTestDataNode(addr, zoneName, rackName string) *DataNode {
+	return &DataNode{Addr: addr, ZoneName: zoneName, RackName: rackName, isActive: true,
+		Total: 1 << 50, MaxDiskAvailWeight: 1 << 40, RemainWeightsForCreateVol: 1 << 40, Health: newNodeHealth()}
+}
+
+func TestChooseTargetECHosts(t *testing.T) {
+	c := &Cluster{t: NewTopology()}
+	domains := make(map[string]string)
+	put := func(zoneName, rackName string, count int) {
+		for i := 0; i < count; i++ {
+			dataNode := newTestDataNode(fmt.Sprintf("%v-%v-%v:6000", zoneName, rackName, i), zoneName, rackName)
+			c.t.putDataNode(dataNode)
+			domains[dataNode.Addr] = zoneName + "/" + rackName
+		}
+	}
+	checkSpread := func(hosts []string, zoneOnly bool, maxPerDomain int) {
+		counts := make(map[string]int)
+		for _, host := range hosts {
+			domain := domains[host]
+			if zoneOnly {
+				domain = domain[:strings.Index(domain, "/")]
+			}
+			if counts[domain]++; counts[domain] > maxPerDomain {
+				t.Fatalf("%v holds more than %v shards: %v", domain, maxPerDomain, hosts)
+			}
+		}
+	}
+	for _, zoneName := range []string{"z1", "z2", "z3"} {
+		put(zoneName, "r1", 2)
+	}
+	//4+2 shards in 3 zones, none of them holds more than the 2 parity shards
+	hosts, err := c.ChooseTargetECHosts(4, 2, PlacementPolicyCrossZone)
+	if err != nil || len(hosts) != 6 {
+		t.Fatalf("cross zone ec hosts %v %v", hosts, err)
+	}
+	checkSpread(hosts, true, 2)
+	if _, err = c.ChooseTargetECHosts(4, 1, PlacementPolicyCrossZone); err == nil {
+		t.Fatal("4+1 shards placed in 3 zones")
+	}
+	if _, err = c.ChooseTargetECHosts(4, 2, PlacementPolicyZone); err == nil {
+		t.Fatal("6 shards placed in 3 zones under the zone policy")
+	}
+
+	//the rack policy spreads the shards over the racks of one zone
+	put("z4", "r1", 2)
+	put("z4", "r2", 2)
+	put("z4", "r3", 2)
+	for i := 0; i < 3; i++ {
+		if hosts, err = c.ChooseTargetECHosts(4, 2, PlacementPolicyRack); err != nil || len(hosts) != 6 {
+			t.Fatalf("rack ec hosts %v %v", hosts, err)
+		}
+		checkSpread(hosts, false, 2)
+	}
+	if _, err = c.ChooseTargetECHosts(5, 1, PlacementPolicyRack); err == nil {
+		t.Fatal("5+1 shards placed in 3 racks")
+	}
+}
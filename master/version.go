@@ -0,0 +1,91 @@
+package master
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/tiglabs/baudstorage/proto"
+)
+
+func (dataNode *DataNode) setVersion(version uint32, features uint64) {
+	dataNode.Lock()
+	defer dataNode.Unlock()
+	dataNode.Version = version
+	dataNode.Features = features
+}
+
+func (metaNode *MetaNode) setVersion(version uint32, features uint64) {
+	metaNode.Lock()
+	defer metaNode.Unlock()
+	metaNode.Version = version
+	metaNode.Features = features
+}
+
+/*getClusterVersion returns the lowest protocol version of the active data nodes and meta nodes,
+and the features all of them support. An old node reports version 0, so the new behaviors stay off
+until every node of a rolling upgrade has been restarted. The inactive nodes are left out, a dead
+node does not hold the features back, nothing is on before any node has reported.*/
+func (c *Cluster) getClusterVersion() (minVersion uint32, features uint64) {
+	minVersion = proto.Version
+	features = proto.LocalFeatures
+	active := 0
+	c.dataNodes.Range(func(addr, node interface{}) bool {
+		dataNode := node.(*DataNode)
+		dataNode.RLock()
+		if dataNode.isActive {
+			if dataNode.Version < minVersion {
+				minVersion = dataNode.Version
+			}
+			features &= dataNode.Features
+			active++
+		}
+		dataNode.RUnlock()
+		return true
+	})
+	c.metaNodes.Range(func(addr, node interface{}) bool {
+		metaNode := node.(*MetaNode)
+		metaNode.RLock()
+		if metaNode.IsActive {
+			if metaNode.Version < minVersion {
+				minVersion = metaNode.Version
+			}
+			features &= metaNode.Features
+			active++
+		}
+		metaNode.RUnlock()
+		return true
+	})
+	if active == 0 {
+		return 0, 0
+	}
+	return
+}
+
+//checkFeature refuses a behavior which some nodes of the cluster do not support yet
+func (c *Cluster) checkFeature(feature uint64) (err error) {
+	if _, features := c.getClusterVersion(); features&feature == feature {
+		return
+	}
+	return fmt.Errorf("feature[%v] is not supported by all nodes of cluster[%v], upgrade them first",
+		proto.FeatureName(feature), c.Name)
+}
+
+//parseVersionPara reads the version and the features a node registers with, both are 0 for old nodes
+func parseVersionPara(r *http.Request) (version uint32, features uint64, err error) {
+	var value uint64
+	if s := r.FormValue(ParaVersion); s != "" {
+		if value, err = strconv.ParseUint(s, 10, 32); err != nil {
+			err = UnMatchPara
+			return
+		}
+		version = uint32(value)
+	}
+	if s := r.FormValue(ParaFeatures); s != "" {
+		if features, err = strconv.ParseUint(s, 10, 64); err != nil {
+			err = UnMatchPara
+			return
+		}
+	}
+	return
+}
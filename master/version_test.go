@@ -0,0 +1,31 @@
+package master
+
+import (
+	"testing"
+
+	"github.com/tiglabs/baudstorage/proto"
+)
+
+func TestGetClusterVersion(t *testing.T) {
+	c, _ := newTestCluster()
+	if version, features := c.getClusterVersion(); version != 0 || features != 0 {
+		t.Fatalf("cluster without nodes has version %v features %v", version, features)
+	}
+	upgraded := newTestDataNode("192.168.0.1:6000", "z1", "r1")
+	upgraded.setVersion(proto.Version, proto.LocalFeatures)
+	c.dataNodes.Store(upgraded.Addr, upgraded)
+	old := newTestDataNode("192.168.0.2:6000", "z1", "r1")
+	c.dataNodes.Store(old.Addr, old)
+	metaNode := &MetaNode{Addr: "192.168.0.3:9021", IsActive: true}
+	metaNode.setVersion(proto.Version, proto.LocalFeatures)
+	c.metaNodes.Store(metaNode.Addr, metaNode)
+
+	if version, features := c.getClusterVersion(); version != 0 || features != 0 {
+		t.Fatalf("active old node is left out: version %v features %v", version, features)
+	}
+	// an old node which is lost does not hold the upgrade back
+	old.isActive = false
+	if version, features := c.getClusterVersion(); version != proto.Version || features != proto.LocalFeatures {
+		t.Fatalf("inactive node is counted: version %v features %v", version, features)
+	}
+}
@@ -8,19 +8,28 @@ import (
 )
 
 type Vol struct {
-	Name           string
-	VolType        string
-	dpReplicaNum   uint8
-	mpReplicaNum   uint8
-	threshold      float32
-	MetaPartitions map[uint64]*MetaPartition
-	mpsLock        sync.RWMutex
-	dataPartitions *DataPartitionMap
+	Name            string
+	VolType         string
+	placementPolicy string
+	dpReplicaNum    uint8
+	mpReplicaNum    uint8
+	threshold       float32
+	qos             proto.VolQos
+	lifecycle       proto.VolLifecycle
+	compression     string
+	keys            []*proto.VolKey
+	MetaPartitions  map[uint64]*MetaPartition
+	mpsLock         sync.RWMutex
+	dataPartitions  *DataPartitionMap
 	sync.RWMutex
 }
 
-func NewVol(name, volType string, replicaNum uint8) (vol *Vol) {
+func NewVol(name, volType, placementPolicy string, replicaNum uint8) (vol *Vol) {
 	vol = &Vol{Name: name, VolType: volType, MetaPartitions: make(map[uint64]*MetaPartition, 0)}
+	if placementPolicy == "" {
+		placementPolicy = PlacementPolicyRack
+	}
+	vol.placementPolicy = placementPolicy
 	vol.dataPartitions = NewDataPartitionMap(name)
 	vol.dpReplicaNum = replicaNum
 	vol.threshold = DefaultMetaPartitionThreshold
@@ -86,18 +95,23 @@ func (vol *Vol) checkDataPartitions(c *Cluster) (readWriteDataPartitions int) {
 		dp.checkReplicaStatus(c.cfg.DataPartitionTimeOutSec)
 		dp.checkStatus(true, c.cfg.DataPartitionTimeOutSec)
 		dp.checkMiss(c.Name, c.cfg.DataPartitionMissSec, c.cfg.DataPartitionWarnInterval)
+		dp.checkECShards(c.Name, vol.placementPolicy, c.cfg.DataPartitionTimeOutSec)
 		dp.checkReplicaNum(c, vol.Name)
-		if dp.Status == proto.ReadWrite {
+		//the clients write an ec partition by stripes only, it is not counted as writable
+		if dp.Status == proto.ReadWrite && !dp.isEC() {
 			readWriteDataPartitions++
 		}
 		diskErrorAddrs := dp.checkDiskError(c.Name)
 		if diskErrorAddrs != nil {
 			for _, addr := range diskErrorAddrs {
+				if c.isDataNodeInMaintenance(addr) {
+					continue
+				}
 				c.dataPartitionOffline(addr, vol.Name, dp, CheckDataPartitionDiskErrorErr)
 			}
 		}
-		tasks := dp.checkReplicationTask()
-		c.putDataNodeTasks(tasks)
+		tasks := dp.checkReplicationTask(vol.getKeys())
+		c.putDataNodeTasks(c.skipDataNodeTasksInMaintenance(tasks))
 	}
 	return
 }
@@ -135,7 +149,7 @@ func (vol *Vol) checkMetaPartitions(c *Cluster) {
 		mp.checkReplicaMiss(c.Name, DefaultMetaPartitionTimeOutSec, DefaultMetaPartitionWarnInterval)
 		tasks = append(tasks, mp.GenerateReplicaTask(c.Name, vol.Name)...)
 	}
-	c.putMetaNodeTasks(tasks)
+	c.putMetaNodeTasks(c.skipMetaNodeTasksInMaintenance(tasks))
 }
 
 func (vol *Vol) cloneMetaPartitionMap() (mps map[uint64]*MetaPartition) {
@@ -0,0 +1,141 @@
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/compress"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+//VolCompressionView is the codec of a vol and the blocks its data partitions compressed since the data nodes started
+type VolCompressionView struct {
+	Codec      string
+	Blocks     uint64
+	RawSize    uint64
+	StoredSize uint64
+	Ratio      float64
+}
+
+func (vol *Vol) getCompression() string {
+	vol.RLock()
+	defer vol.RUnlock()
+	return vol.compression
+}
+
+func (vol *Vol) setCompression(codec string) {
+	vol.Lock()
+	defer vol.Unlock()
+	vol.compression = codec
+}
+
+/*setVolCompression persists the codec of the vol, it is pushed to every data node with the next heartbeat,
+the data nodes compress the blocks of the vol completed from then on, the blocks stored before are kept.*/
+func (c *Cluster) setVolCompression(volName string, codec compress.Codec) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(volName); err != nil {
+		return
+	}
+	if codec.Valid() {
+		if err = c.checkFeature(proto.FeatureCompression); err != nil {
+			return
+		}
+	}
+	name := ""
+	if codec.Valid() {
+		name = codec.String()
+	}
+	old := vol.getCompression()
+	vol.setCompression(name)
+	if err = c.syncUpdateVol(vol); err != nil {
+		vol.setCompression(old)
+		return
+	}
+	log.LogInfof("action[setVolCompression] vol[%v] codec[%v] -> [%v]", volName, old, name)
+	return
+}
+
+//getVolCompressions returns the codecs of the vols which compress their data
+func (c *Cluster) getVolCompressions() (compressions map[string]string) {
+	compressions = make(map[string]string)
+	for name, vol := range c.copyVols() {
+		if codec := vol.getCompression(); codec != "" {
+			compressions[name] = codec
+		}
+	}
+	return
+}
+
+func (vol *Vol) getCompressionView() (view *VolCompressionView) {
+	view = &VolCompressionView{Codec: vol.getCompression()}
+	if view.Codec == "" {
+		view.Codec = compress.CodecNone.String()
+	}
+	vol.dataPartitions.RLock()
+	defer vol.dataPartitions.RUnlock()
+	for _, dp := range vol.dataPartitions.dataPartitionMap {
+		dp.RLock()
+		for _, replica := range dp.Replicas {
+			view.Blocks += replica.CompressedBlocks
+			view.RawSize += replica.CompressedRawSize
+			view.StoredSize += replica.CompressedSize
+		}
+		dp.RUnlock()
+	}
+	if view.StoredSize != 0 {
+		view.Ratio = float64(view.RawSize) / float64(view.StoredSize)
+	}
+	return
+}
+
+func (m *Master) setVolCompression(w http.ResponseWriter, r *http.Request) {
+	var (
+		volName string
+		codec   compress.Codec
+		err     error
+	)
+	r.ParseForm()
+	if volName, err = checkVolPara(r); err != nil {
+		goto errDeal
+	}
+	if codec, err = compress.ParseCodec(r.FormValue(ParaCodec)); err != nil {
+		goto errDeal
+	}
+	if err = m.cluster.setVolCompression(volName, codec); err != nil {
+		goto errDeal
+	}
+	io.WriteString(w, fmt.Sprintf("set compression of vol[%v] to %v SUCCESS", volName, codec))
+	return
+errDeal:
+	logMsg := getReturnMessage(AdminSetVolCompression, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+func (m *Master) getVolCompression(w http.ResponseWriter, r *http.Request) {
+	var (
+		volName string
+		vol     *Vol
+		body    []byte
+		err     error
+	)
+	r.ParseForm()
+	if volName, err = checkVolPara(r); err != nil {
+		goto errDeal
+	}
+	if vol, err = m.cluster.getVol(volName); err != nil {
+		goto errDeal
+	}
+	if body, err = json.Marshal(vol.getCompressionView()); err != nil {
+		goto errDeal
+	}
+	io.WriteString(w, string(body))
+	return
+errDeal:
+	logMsg := getReturnMessage(AdminGetVolCompression, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
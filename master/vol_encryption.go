@@ -0,0 +1,137 @@
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/encrypt"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+//VolEncryptionView shows the versions of the data keys of a vol,the keys are never shown
+type VolEncryptionView struct {
+	Encrypted bool
+	Current   uint32
+	Versions  []uint32
+}
+
+func (vol *Vol) getKeys() []*proto.VolKey {
+	vol.RLock()
+	defer vol.RUnlock()
+	return vol.keys
+}
+
+func (vol *Vol) setKeys(keys []*proto.VolKey) {
+	vol.Lock()
+	defer vol.Unlock()
+	vol.keys = keys
+}
+
+/*rotateVolKey generates a new data key of the vol wrapped by the master key of the cluster,the data
+nodes encrypt the files created from the next heartbeat on by it,the files created before keep their key,
+so a key is never removed. The first key starts to encrypt the vol.*/
+func (c *Cluster) rotateVolKey(volName string) (version uint32, err error) {
+	var (
+		vol     *Vol
+		key     []byte
+		wrapped []byte
+	)
+	if c.masterKey == nil {
+		err = NoMasterKey
+		return
+	}
+	if vol, err = c.getVol(volName); err != nil {
+		return
+	}
+	if err = c.checkFeature(proto.FeatureEncryption); err != nil {
+		return
+	}
+	if key, err = encrypt.NewDataKey(); err != nil {
+		return
+	}
+	if wrapped, err = encrypt.WrapKey(c.masterKey, key); err != nil {
+		return
+	}
+	old := vol.getKeys()
+	version = uint32(len(old) + 1)
+	keys := make([]*proto.VolKey, 0, len(old)+1)
+	keys = append(keys, old...)
+	keys = append(keys, &proto.VolKey{Version: version, Key: wrapped})
+	vol.setKeys(keys)
+	if err = c.syncUpdateVol(vol); err != nil {
+		vol.setKeys(old)
+		return
+	}
+	log.LogInfof("action[rotateVolKey] vol[%v] key version[%v]", volName, version)
+	return
+}
+
+//getVolKeys returns the wrapped keys of the encrypted vols
+func (c *Cluster) getVolKeys() (volKeys map[string][]*proto.VolKey) {
+	volKeys = make(map[string][]*proto.VolKey)
+	for name, vol := range c.copyVols() {
+		if keys := vol.getKeys(); len(keys) != 0 {
+			volKeys[name] = keys
+		}
+	}
+	return
+}
+
+func (m *Master) rotateVolKey(w http.ResponseWriter, r *http.Request) {
+	var (
+		volName string
+		version uint32
+		err     error
+	)
+	r.ParseForm()
+	if volName, err = checkVolPara(r); err != nil {
+		goto errDeal
+	}
+	if version, err = m.cluster.rotateVolKey(volName); err != nil {
+		goto errDeal
+	}
+	io.WriteString(w, fmt.Sprintf("rotate key of vol[%v] to version[%v] SUCCESS", volName, version))
+	return
+errDeal:
+	logMsg := getReturnMessage(AdminRotateVolKey, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+func (m *Master) getVolEncryption(w http.ResponseWriter, r *http.Request) {
+	var (
+		volName string
+		vol     *Vol
+		body    []byte
+		err     error
+	)
+	r.ParseForm()
+	if volName, err = checkVolPara(r); err != nil {
+		goto errDeal
+	}
+	if vol, err = m.cluster.getVol(volName); err != nil {
+		goto errDeal
+	}
+	if body, err = json.Marshal(vol.getEncryptionView()); err != nil {
+		goto errDeal
+	}
+	io.WriteString(w, string(body))
+	return
+errDeal:
+	logMsg := getReturnMessage(AdminGetVolEncryption, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+func (vol *Vol) getEncryptionView() (view *VolEncryptionView) {
+	view = &VolEncryptionView{Versions: make([]uint32, 0)}
+	for _, key := range vol.getKeys() {
+		view.Versions = append(view.Versions, key.Version)
+		view.Current = key.Version
+	}
+	view.Encrypted = view.Current != 0
+	return
+}
@@ -0,0 +1,122 @@
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+func (vol *Vol) getLifecycle() proto.VolLifecycle {
+	vol.RLock()
+	defer vol.RUnlock()
+	return vol.lifecycle
+}
+
+func (vol *Vol) setLifecycle(lifecycle proto.VolLifecycle) {
+	vol.Lock()
+	defer vol.Unlock()
+	vol.lifecycle = lifecycle
+}
+
+/*setVolLifecycle persists the lifecycle policy of the vol, it is pushed to every meta node with the next
+heartbeat, the leader of each meta partition moves the cold files of its inodes into the ec data partitions
+of the vol, which are created by the administrator.*/
+func (c *Cluster) setVolLifecycle(volName string, lifecycle proto.VolLifecycle) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(volName); err != nil {
+		return
+	}
+	if lifecycle != (proto.VolLifecycle{}) {
+		if err = c.checkFeature(proto.FeatureEC | proto.FeatureLifecycle); err != nil {
+			return
+		}
+	}
+	old := vol.getLifecycle()
+	vol.setLifecycle(lifecycle)
+	if err = c.syncUpdateVol(vol); err != nil {
+		vol.setLifecycle(old)
+		return
+	}
+	log.LogInfof("action[setVolLifecycle] vol[%v] lifecycle[%+v] -> [%+v]", volName, old, lifecycle)
+	return
+}
+
+//getVolLifecycles returns the policies of the vols which have any
+func (c *Cluster) getVolLifecycles() (lifecycles map[string]*proto.VolLifecycle) {
+	lifecycles = make(map[string]*proto.VolLifecycle)
+	for name, vol := range c.copyVols() {
+		lifecycle := vol.getLifecycle()
+		if lifecycle == (proto.VolLifecycle{}) {
+			continue
+		}
+		lifecycles[name] = &lifecycle
+	}
+	return
+}
+
+func (m *Master) setVolLifecycle(w http.ResponseWriter, r *http.Request) {
+	var (
+		volName   string
+		lifecycle proto.VolLifecycle
+		body      []byte
+		err       error
+	)
+	r.ParseForm()
+	if volName, err = checkVolPara(r); err != nil {
+		goto errDeal
+	}
+	if lifecycle, err = parseLifecyclePara(r); err != nil {
+		goto errDeal
+	}
+	if err = m.cluster.setVolLifecycle(volName, lifecycle); err != nil {
+		goto errDeal
+	}
+	body, _ = json.Marshal(lifecycle)
+	io.WriteString(w, fmt.Sprintf("set lifecycle of vol[%v] to %v SUCCESS", volName, string(body)))
+	return
+errDeal:
+	logMsg := getReturnMessage(AdminSetVolLifecycle, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+func (m *Master) getVolLifecycle(w http.ResponseWriter, r *http.Request) {
+	var (
+		volName string
+		vol     *Vol
+		body    []byte
+		err     error
+	)
+	r.ParseForm()
+	if volName, err = checkVolPara(r); err != nil {
+		goto errDeal
+	}
+	if vol, err = m.cluster.getVol(volName); err != nil {
+		goto errDeal
+	}
+	if body, err = json.Marshal(vol.getLifecycle()); err != nil {
+		goto errDeal
+	}
+	io.WriteString(w, string(body))
+	return
+errDeal:
+	logMsg := getReturnMessage(AdminGetVolLifecycle, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+//parseLifecyclePara reads the policy of the request, zero days removes the policy
+func parseLifecyclePara(r *http.Request) (lifecycle proto.VolLifecycle, err error) {
+	var days uint64
+	if days, err = strconv.ParseUint(r.FormValue(ParaColdAfterDays), 10, 32); err != nil {
+		err = UnMatchPara
+		return
+	}
+	lifecycle.ColdAfterDays = uint32(days)
+	return
+}
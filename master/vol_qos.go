@@ -0,0 +1,139 @@
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+func (vol *Vol) getQos() proto.VolQos {
+	vol.RLock()
+	defer vol.RUnlock()
+	return vol.qos
+}
+
+func (vol *Vol) setQos(qos proto.VolQos) {
+	vol.Lock()
+	defer vol.Unlock()
+	vol.qos = qos
+}
+
+/*setVolQos persists the limits of the vol, they are pushed to every data node and meta node
+with the next heartbeat, each node enforces them on its own share of the traffic.*/
+func (c *Cluster) setVolQos(volName string, qos proto.VolQos) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(volName); err != nil {
+		return
+	}
+	if qos != (proto.VolQos{}) {
+		if err = c.checkFeature(proto.FeatureVolQos); err != nil {
+			return
+		}
+	}
+	old := vol.getQos()
+	vol.setQos(qos)
+	if err = c.syncUpdateVol(vol); err != nil {
+		vol.setQos(old)
+		return
+	}
+	log.LogInfof("action[setVolQos] vol[%v] qos[%+v] -> [%+v]", volName, old, qos)
+	return
+}
+
+//getVolQosLimits returns the limits of the vols which have any
+func (c *Cluster) getVolQosLimits() (limits map[string]*proto.VolQos) {
+	limits = make(map[string]*proto.VolQos)
+	for name, vol := range c.copyVols() {
+		qos := vol.getQos()
+		if qos == (proto.VolQos{}) {
+			continue
+		}
+		limits[name] = &qos
+	}
+	return
+}
+
+func (m *Master) setVolQos(w http.ResponseWriter, r *http.Request) {
+	var (
+		volName string
+		vol     *Vol
+		qos     proto.VolQos
+		body    []byte
+		err     error
+	)
+	r.ParseForm()
+	if volName, err = checkVolPara(r); err != nil {
+		goto errDeal
+	}
+	if vol, err = m.cluster.getVol(volName); err != nil {
+		goto errDeal
+	}
+	qos = vol.getQos()
+	if err = parseQosPara(r, &qos); err != nil {
+		goto errDeal
+	}
+	if err = m.cluster.setVolQos(volName, qos); err != nil {
+		goto errDeal
+	}
+	body, _ = json.Marshal(qos)
+	io.WriteString(w, fmt.Sprintf("set qos of vol[%v] to %v SUCCESS", volName, string(body)))
+	return
+errDeal:
+	logMsg := getReturnMessage(AdminSetVolQos, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+func (m *Master) getVolQos(w http.ResponseWriter, r *http.Request) {
+	var (
+		volName string
+		vol     *Vol
+		body    []byte
+		err     error
+	)
+	r.ParseForm()
+	if volName, err = checkVolPara(r); err != nil {
+		goto errDeal
+	}
+	if vol, err = m.cluster.getVol(volName); err != nil {
+		goto errDeal
+	}
+	if body, err = json.Marshal(vol.getQos()); err != nil {
+		goto errDeal
+	}
+	io.WriteString(w, string(body))
+	return
+errDeal:
+	logMsg := getReturnMessage(AdminGetVolQos, r.RemoteAddr, err.Error(), http.StatusBadRequest)
+	HandleError(logMsg, err, http.StatusBadRequest, w)
+	return
+}
+
+//parseQosPara overwrites the limits given in the request, zero removes a limit
+func parseQosPara(r *http.Request, qos *proto.VolQos) (err error) {
+	paras := []struct {
+		name  string
+		value *uint64
+	}{
+		{ParaReadIOPS, &qos.ReadIOPS},
+		{ParaWriteIOPS, &qos.WriteIOPS},
+		{ParaReadBandwidth, &qos.ReadBandwidth},
+		{ParaWriteBandwidth, &qos.WriteBandwidth},
+		{ParaMetaOPS, &qos.MetaOPS},
+	}
+	for _, para := range paras {
+		value := r.FormValue(para.name)
+		if value == "" {
+			continue
+		}
+		if *para.value, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return UnMatchPara
+		}
+	}
+	return
+}
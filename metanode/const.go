@@ -66,6 +66,8 @@ const (
 	opStoreTick
 	startStoreTick
 	stopStoreTick
+	opExtentsSwap
+	opFallocate
 )
 
 var (
@@ -81,8 +83,9 @@ var (
 
 // default config
 const (
-	defaultMetaDir = "metaDir"
-	defaultRaftDir = "raftDir"
+	defaultMetaDir  = "metaDir"
+	defaultRaftDir  = "raftDir"
+	defaultZoneName = "default"
 )
 
 const (
@@ -98,8 +101,13 @@ const (
 	cfgMasterAddrs       = "masterAddrs"
 	cfgRaftHeartbeatPort = "raftHeartbeatPort"
 	cfgRaftReplicatePort = "raftReplicatePort"
+	cfgZoneName          = "zone"
+	cfgRackName          = "rack"
 )
 
 const (
 	storeTimeTicker = time.Minute * 5
+	// interval and inodes of a round of the cold file migration of a partition
+	lifecycleTimeTicker  = time.Minute * 10
+	lifecycleBatchInodes = 128
 )
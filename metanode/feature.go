@@ -0,0 +1,28 @@
+package metanode
+
+import (
+	"sync/atomic"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+// clusterFeatures are the features every node of the cluster supports, pushed by the master
+// in the heartbeats. They are none until the first heartbeat, so a restarted node writes
+// nothing the old replicas can not read.
+var clusterFeatures uint64
+
+func setClusterFeatures(features uint64) {
+	if old := atomic.SwapUint64(&clusterFeatures, features); old != features {
+		log.LogInfof("action[setClusterFeatures] cluster features (%v) -> (%v)", old, features)
+	}
+}
+
+func clusterSupports(feature uint64) bool {
+	return atomic.LoadUint64(&clusterFeatures)&feature == feature
+}
+
+// fileOffsetsEnabled tells whether the extent keys are stored with their file offsets.
+func fileOffsetsEnabled() bool {
+	return clusterSupports(proto.FeatureFileOffset)
+}
@@ -165,7 +165,7 @@ func (i *Inode) MarshalValue() (val []byte) {
 	}
 	if i.Extents.Size() != 0 {
 		// Marshal ExtentsKey
-		extData, err := i.Extents.MarshalBinary()
+		extData, err := i.marshalExtents()
 		if err != nil {
 			panic(err)
 		}
@@ -178,6 +178,16 @@ func (i *Inode) MarshalValue() (val []byte) {
 	return
 }
 
+// marshalExtents writes the extent keys in the format of version 1 until every meta node
+// reads the file offsets, or if they are placed as the keys of version 1. The keys which
+// do not fit it exist only once the file offsets are enabled.
+func (i *Inode) marshalExtents() ([]byte, error) {
+	if (i.Extents.Legacy() || !fileOffsetsEnabled()) && i.Extents.Sequential() {
+		return i.Extents.MarshalBinaryV1()
+	}
+	return i.Extents.MarshalBinary()
+}
+
 // UnmarshalValue unmarshal value from bytes.
 func (i *Inode) UnmarshalValue(val []byte) (err error) {
 	buff := bytes.NewBuffer(val)
@@ -216,6 +226,8 @@ func (i *Inode) UnmarshalValue(val []byte) (err error) {
 
 func (i *Inode) AppendExtents(ext proto.ExtentKey) {
 	i.Extents.Put(ext)
-	i.Size = i.Extents.Size()
+	if size := i.Extents.Size(); size > i.Size {
+		i.Size = size
+	}
 	i.ModifyTime = time.Now().Unix()
 }
@@ -31,6 +31,8 @@ func Test_Dentry(t *testing.T) {
 }
 
 func Test_Inode(t *testing.T) {
+	setClusterFeatures(proto.LocalFeatures)
+	defer setClusterFeatures(0)
 	ino := NewInode(1, 0)
 	ino.Extents.Put(proto.ExtentKey{
 		PartitionId: 1000,
@@ -38,6 +40,7 @@ func Test_Inode(t *testing.T) {
 		Size:        10234,
 	})
 	ino.Extents.Put(proto.ExtentKey{
+		FileOffset:  10234,
 		PartitionId: 1020,
 		ExtentId:    28,
 		Size:        150,
@@ -58,6 +61,45 @@ func Test_Inode(t *testing.T) {
 	}
 }
 
+func Test_InodeLegacyExtents(t *testing.T) {
+	ino := NewInode(1, 0)
+	ino.Extents.Put(proto.ExtentKey{PartitionId: 1000, ExtentId: 1222, Size: 10234})
+	ino.Extents.Put(proto.ExtentKey{FileOffset: 10234, PartitionId: 1020, ExtentId: 28, Size: 150})
+	// the old replicas read the keys until every node supports the file offsets
+	data, err := ino.Marshal()
+	if err != nil {
+		t.Fatalf("inode marshal fail: %v", err)
+	}
+	inoTmp := NewInode(0, 0)
+	if err = inoTmp.Unmarshal(data); err != nil {
+		t.Fatalf("inode unmarshal fail: %v", err)
+	}
+	if !inoTmp.Extents.Legacy() || !reflect.DeepEqual(inoTmp.Extents.Extents, ino.Extents.Extents) {
+		t.Fatalf("legacy keys: %v", inoTmp.Extents)
+	}
+	// a key of an old client grows its extent or is appended to the end of the file
+	ek := inoTmp.Extents.PlaceLegacy(proto.ExtentKey{PartitionId: 1020, ExtentId: 28, Size: 200})
+	if ek.FileOffset != 10234 {
+		t.Fatalf("grown key placed at %v", ek.FileOffset)
+	}
+	ek = inoTmp.Extents.PlaceLegacy(proto.ExtentKey{PartitionId: 7, ExtentId: 7, Size: 10})
+	if ek.FileOffset != 10384 {
+		t.Fatalf("appended key placed at %v", ek.FileOffset)
+	}
+	// the keys which are not the extents one after another are never written without the offsets
+	ino.Extents.Put(proto.ExtentKey{FileOffset: 100, PartitionId: 2, ExtentId: 3, ExtentOffset: 8, Size: 10})
+	if data, err = ino.Marshal(); err != nil {
+		t.Fatalf("inode marshal fail: %v", err)
+	}
+	inoTmp = NewInode(0, 0)
+	if err = inoTmp.Unmarshal(data); err != nil {
+		t.Fatalf("inode unmarshal fail: %v", err)
+	}
+	if inoTmp.Extents.Legacy() || !reflect.DeepEqual(inoTmp.Extents.Extents, ino.Extents.Extents) {
+		t.Fatalf("overwritten keys: %v", inoTmp.Extents)
+	}
+}
+
 func TestDentryBtree(t *testing.T) {
 	dTree := btree.New(32)
 	dentry := &Dentry{
@@ -78,3 +120,51 @@ func TestDentryBtree(t *testing.T) {
 	newDen = item.(*Dentry)
 	t.Logf("%v", newDen)
 }
+
+func Test_InodeOverwriteExtents(t *testing.T) {
+	ino := NewInode(1, 0)
+	ino.Extents.Put(proto.ExtentKey{PartitionId: 1, ExtentId: 1, Size: 100})
+	ino.Extents.Put(proto.ExtentKey{FileOffset: 100, PartitionId: 1, ExtentId: 1, ExtentOffset: 100, Size: 100})
+	if ino.Extents.GetExtentLen() != 1 || ino.Extents.Extents[0].Size != 200 {
+		t.Fatalf("contiguous keys are not merged: %v", ino.Extents)
+	}
+	ino.Extents.Put(proto.ExtentKey{FileOffset: 50, PartitionId: 2, ExtentId: 7, Size: 20})
+	expected := []proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 1, ExtentId: 1, ExtentOffset: 0, Size: 50},
+		{FileOffset: 50, PartitionId: 2, ExtentId: 7, ExtentOffset: 0, Size: 20},
+		{FileOffset: 70, PartitionId: 1, ExtentId: 1, ExtentOffset: 70, Size: 130},
+	}
+	if !reflect.DeepEqual(ino.Extents.Extents, expected) {
+		t.Fatalf("overlapped key is not split: %v", ino.Extents)
+	}
+	ino.Extents.Put(proto.ExtentKey{FileOffset: 40, PartitionId: 3, ExtentId: 9, Size: 200})
+	expected = []proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 1, ExtentId: 1, ExtentOffset: 0, Size: 40},
+		{FileOffset: 40, PartitionId: 3, ExtentId: 9, ExtentOffset: 0, Size: 200},
+	}
+	if !reflect.DeepEqual(ino.Extents.Extents, expected) || ino.Extents.Size() != 240 {
+		t.Fatalf("covered keys are not removed: %v", ino.Extents)
+	}
+}
+
+func Test_InodePunchExtents(t *testing.T) {
+	ino := NewInode(1, 0)
+	ino.Extents.Put(proto.ExtentKey{PartitionId: 1, ExtentId: 1, Size: 100})
+	ino.Extents.Put(proto.ExtentKey{FileOffset: 300, PartitionId: 2, ExtentId: 2, Size: 100})
+	pieces := ino.Extents.Slice(50, 300)
+	expected := []proto.ExtentKey{
+		{FileOffset: 50, PartitionId: 1, ExtentId: 1, ExtentOffset: 50, Size: 50},
+		{FileOffset: 300, PartitionId: 2, ExtentId: 2, ExtentOffset: 0, Size: 50},
+	}
+	if !reflect.DeepEqual(pieces, expected) {
+		t.Fatalf("slice of the keys: %v", pieces)
+	}
+	ino.Extents.Punch(50, 300)
+	expected = []proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 1, ExtentId: 1, ExtentOffset: 0, Size: 50},
+		{FileOffset: 350, PartitionId: 2, ExtentId: 2, ExtentOffset: 50, Size: 50},
+	}
+	if !reflect.DeepEqual(ino.Extents.Extents, expected) || ino.Extents.Size() != 400 {
+		t.Fatalf("punched keys: %v", ino.Extents)
+	}
+}
@@ -11,12 +11,14 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/tiglabs/baudstorage/proto"
 	"github.com/tiglabs/baudstorage/raftstore"
 	"github.com/tiglabs/baudstorage/util/log"
 	"github.com/tiglabs/baudstorage/util/pool"
+	"github.com/tiglabs/baudstorage/util/qos"
 	"github.com/tiglabs/baudstorage/util/ump"
 )
 
@@ -36,22 +38,40 @@ type MetaManagerConfig struct {
 	NodeID    uint64
 	RootDir   string
 	RaftStore raftstore.RaftStore
+	ZoneName  string
+	RackName  string
 }
 
 type metaManager struct {
 	nodeId     uint64
 	rootDir    string
 	raftStore  raftstore.RaftStore
+	zoneName   string
+	rackName   string
 	connPool   *pool.ConnPool
 	state      uint32
 	mu         sync.RWMutex
 	partitions map[uint64]MetaPartition // Key: metaRangeId, Val: metaPartition
+	volQos     *qos.Limiter
+	lifecycles *volLifecycles
 }
 
 func (m *metaManager) HandleMetaOperation(conn net.Conn, p *Packet) (err error) {
 	umpKey := UMPKey + "_" + p.GetOpMsg()
 	tpObject := ump.BeforeTP(umpKey)
-	defer ump.AfterTP(tpObject, err)
+	start := time.Now()
+	defer func() {
+		ump.AfterTP(tpObject, err)
+		// only the failures of the node itself count against its health
+		var nodeErr error
+		if p.IsNodeErr() {
+			nodeErr = err
+			if nodeErr == nil {
+				nodeErr = errors.New(p.GetResultMesg())
+			}
+		}
+		opMetrics.ObserveClass(p.GetOpMsg(), opClass(p.Opcode), start, nodeErr)
+	}()
 
 	switch p.Opcode {
 	case proto.OpMetaCreateInode:
@@ -78,6 +98,8 @@ func (m *metaManager) HandleMetaOperation(conn net.Conn, p *Packet) (err error)
 		err = m.opMetaExtentsList(conn, p)
 	case proto.OpMetaExtentsDel:
 		err = m.opMetaExtentsDel(conn, p)
+	case proto.OpMetaFallocate:
+		err = m.opMetaFallocate(conn, p)
 	case proto.OpMetaLookup:
 		err = m.opMetaLookup(conn, p)
 	case proto.OpDeleteMetaPartition:
@@ -100,6 +122,18 @@ func (m *metaManager) HandleMetaOperation(conn net.Conn, p *Packet) (err error)
 	return
 }
 
+// serveVolQos charges a metadata op of the clients to the limits of the volume of the
+// partition serving it, a refused op is answered with OpAgain.
+func (m *metaManager) serveVolQos(conn net.Conn, mp MetaPartition, p *Packet) (ok bool) {
+	err := m.volQos.AllowMeta(mp.GetBaseConfig().VolName)
+	if err == nil {
+		return true
+	}
+	p.PackErrorWithBody(proto.OpAgain, []byte(err.Error()))
+	m.respondToClient(conn, p)
+	return false
+}
+
 func (m *metaManager) Start() (err error) {
 	if atomic.CompareAndSwapUint32(&m.state, StateStandby, StateStart) {
 		defer func() {
@@ -125,7 +159,10 @@ func (m *metaManager) Stop() {
 
 func (m *metaManager) onStart() (err error) {
 	m.connPool = pool.NewConnPool()
-	err = m.loadPartitions()
+	if err = m.loadPartitions(); err != nil {
+		return
+	}
+	m.startLifecycle()
 	return
 }
 
@@ -293,6 +330,10 @@ func NewMetaManager(conf MetaManagerConfig) MetaManager {
 		nodeId:     conf.NodeID,
 		rootDir:    conf.RootDir,
 		raftStore:  conf.RaftStore,
+		zoneName:   conf.ZoneName,
+		rackName:   conf.RackName,
 		partitions: make(map[uint64]MetaPartition),
+		volQos:     qos.NewLimiter(),
+		lifecycles: newVolLifecycles(),
 	}
 }
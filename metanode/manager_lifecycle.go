@@ -0,0 +1,99 @@
+package metanode
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/sdk/data/stream"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+// volLifecycles keeps the lifecycle policies pushed by the master in heartbeats,
+// and the extent clients which move the cold files of the volumes.
+type volLifecycles struct {
+	sync.RWMutex
+	policies map[string]*proto.VolLifecycle
+	clients  map[string]*stream.ExtentClient
+}
+
+func newVolLifecycles() *volLifecycles {
+	return &volLifecycles{
+		policies: make(map[string]*proto.VolLifecycle),
+		clients:  make(map[string]*stream.ExtentClient),
+	}
+}
+
+func (l *volLifecycles) Update(policies map[string]*proto.VolLifecycle) {
+	l.Lock()
+	defer l.Unlock()
+	l.policies = make(map[string]*proto.VolLifecycle, len(policies))
+	for volName, policy := range policies {
+		if policy != nil && policy.ColdAfterDays > 0 {
+			l.policies[volName] = policy
+		}
+	}
+}
+
+func (l *volLifecycles) getPolicy(volName string) (policy *proto.VolLifecycle, ok bool) {
+	l.RLock()
+	defer l.RUnlock()
+	policy, ok = l.policies[volName]
+	return
+}
+
+// getClient returns the extent client of the volume, it is kept once created
+// because the data partition view it holds is refreshed in the background.
+func (l *volLifecycles) getClient(volName string) (client *stream.ExtentClient, err error) {
+	l.Lock()
+	defer l.Unlock()
+	if client = l.clients[volName]; client != nil {
+		return
+	}
+	if client, err = stream.NewExtentClient(volName, strings.Join(masterAddrs, ","), nil, nil, nil); err != nil {
+		return
+	}
+	l.clients[volName] = client
+	return
+}
+
+func (m *metaManager) startLifecycle() {
+	go func() {
+		ticker := time.NewTicker(lifecycleTimeTicker)
+		defer ticker.Stop()
+		for range ticker.C {
+			if atomic.LoadUint32(&m.state) != StateRunning {
+				return
+			}
+			m.migrateColdFiles()
+		}
+	}()
+}
+
+// migrateColdFiles runs a round of the migration on the partitions this node leads.
+func (m *metaManager) migrateColdFiles() {
+	partitions := make([]MetaPartition, 0)
+	m.Range(func(id uint64, partition MetaPartition) bool {
+		partitions = append(partitions, partition)
+		return true
+	})
+	for _, partition := range partitions {
+		volName := partition.GetBaseConfig().VolName
+		policy, ok := m.lifecycles.getPolicy(volName)
+		if !ok {
+			continue
+		}
+		if _, isLeader := partition.IsLeader(); !isLeader {
+			continue
+		}
+		client, err := m.lifecycles.getClient(volName)
+		if err != nil {
+			log.LogErrorf("[migrateColdFiles] vol[%v]: %s", volName, err.Error())
+			continue
+		}
+		coldBefore := time.Now().Add(-time.Duration(policy.ColdAfterDays) * 24 * time.Hour).Unix()
+		partition.MigrateColdInodes(coldBefore, client)
+	}
+}
@@ -44,6 +44,14 @@ func (m *metaManager) opMasterHeartbeat(conn net.Conn, p *Packet) (err error) {
 	if curMasterAddr != req.MasterAddr {
 		curMasterAddr = req.MasterAddr
 	}
+	m.volQos.Update(req.VolQos)
+	setClusterFeatures(req.Features)
+	m.lifecycles.Update(req.Lifecycles)
+	resp.ZoneName = m.zoneName
+	resp.RackName = m.rackName
+	resp.Version = proto.Version
+	resp.Features = proto.LocalFeatures
+	resp.Stats = heartbeatStats()
 	// collect used info
 	// machine mem total and used
 	resp.Total, _, err = util.GetMemInfo()
@@ -386,6 +394,35 @@ func (m *metaManager) opMetaExtentsList(conn net.Conn, p *Packet) (err error) {
 	return
 }
 
+func (m *metaManager) opMetaFallocate(conn net.Conn, p *Packet) (err error) {
+	req := &proto.FallocateRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PackErrorWithBody(proto.OpErr, nil)
+		m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PackErrorWithBody(proto.OpNotExistErr, nil)
+		m.respondToClient(conn, p)
+		err = errors.Errorf("%s, response to client: %s", err.Error(),
+			p.GetResultMesg())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.Fallocate(req, p)
+	m.respondToClient(conn, p)
+	if err != nil {
+		log.LogErrorf("[opMetaFallocate] Fallocate: %s, "+
+			"response to client: %s", err.Error(), p.GetResultMesg())
+	}
+	log.LogDebugf("[opMetaFallocate] req: %v, response status=%v", req,
+		p.GetResultMesg())
+	return
+}
+
 func (m *metaManager) opMetaExtentsDel(conn net.Conn, p *Packet) (err error) {
 	// TODO: not implement yet
 	panic("not implement yet")
@@ -611,6 +648,9 @@ func (m *metaManager) opMetaBatchInodeGet(conn net.Conn, p *Packet) (err error)
 		p.PackErrorWithBody(proto.OpNotExistErr, nil)
 		return
 	}
+	if !m.serveVolQos(conn, mp, p) {
+		return
+	}
 	err = mp.InodeGetBatch(req, p)
 	m.respondToClient(conn, p)
 	log.LogDebugf("[opMetaBatchInodeGet] req[%v], response[%v].", req, p.GetResultMesg())
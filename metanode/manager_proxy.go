@@ -20,7 +20,8 @@ func (m *metaManager) serveProxy(conn net.Conn, mp MetaPartition,
 		err        error
 	)
 	if leaderAddr, ok = mp.IsLeader(); ok {
-		return
+		// the ops are charged where they are served, not again on the proxy
+		return m.serveVolQos(conn, mp, p)
 	}
 	if leaderAddr == "" {
 		err = ErrNonLeader
@@ -36,6 +36,8 @@ type MetaNode struct {
 	raftStore         raftstore.RaftStore
 	raftHeartbeatPort string
 	raftReplicatePort string
+	zoneName          string
+	rackName          string
 	httpStopC         chan uint8
 	state             uint32
 	wg                sync.WaitGroup
@@ -78,6 +80,9 @@ func (m *MetaNode) onStart(cfg *config.Config) (err error) {
 	if err = m.parseConfig(cfg); err != nil {
 		return
 	}
+	if err = ump.ConfigSink(cfg); err != nil {
+		return
+	}
 	if err = m.register(); err != nil {
 		return
 	}
@@ -123,12 +128,16 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 	m.raftDir = cfg.GetString(cfgRaftDir)
 	m.raftHeartbeatPort = cfg.GetString(cfgRaftHeartbeatPort)
 	m.raftReplicatePort = cfg.GetString(cfgRaftReplicatePort)
+	m.zoneName = cfg.GetString(cfgZoneName)
+	m.rackName = cfg.GetString(cfgRackName)
 
 	log.LogDebugf("action[parseConfig] load listen[%v].", m.listen)
 	log.LogDebugf("action[parseConfig] load metaDir[%v].", m.metaDir)
 	log.LogDebugf("action[parseConfig] load raftDir[%v].", m.raftDir)
 	log.LogDebugf("action[parseConfig] load raftHeartbeatPort[%v].", m.raftHeartbeatPort)
 	log.LogDebugf("action[parseConfig] load raftReplicatePort[%v].", m.raftReplicatePort)
+	log.LogDebugf("action[parseConfig] load zoneName[%v].", m.zoneName)
+	log.LogDebugf("action[parseConfig] load rackName[%v].", m.rackName)
 
 	addrs := cfg.GetArray(cfgMasterAddrs)
 	for _, addr := range addrs {
@@ -149,6 +158,9 @@ func (m *MetaNode) validConfig() (err error) {
 	if m.raftDir == "" {
 		m.raftDir = defaultRaftDir
 	}
+	if m.zoneName == "" {
+		m.zoneName = defaultZoneName
+	}
 	if len(masterAddrs) == 0 {
 		err = errors.New("master address list is empty")
 		return
@@ -167,6 +179,8 @@ func (m *MetaNode) startMetaManager() (err error) {
 		NodeID:    m.nodeId,
 		RootDir:   m.metaDir,
 		RaftStore: m.raftStore,
+		ZoneName:  m.zoneName,
+		RackName:  m.rackName,
 	}
 	m.metaManager = NewMetaManager(conf)
 	err = m.metaManager.Start()
@@ -198,7 +212,8 @@ func (m *MetaNode) register() (err error) {
 }
 
 func (m *MetaNode) postNodeID() (err error) {
-	reqPath := fmt.Sprintf("%s?addr=%s:%s", metaNodeURL, m.localAddr, m.listen)
+	reqPath := fmt.Sprintf("%s?addr=%s:%s&version=%d&features=%d", metaNodeURL, m.localAddr, m.listen,
+		proto.Version, proto.LocalFeatures)
 	msg, err := postToMaster(reqPath, nil)
 	if err != nil {
 		err = errors.Errorf("[postNodeID] %s", err.Error())
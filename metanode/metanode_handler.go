@@ -7,6 +7,7 @@ import (
 
 	"github.com/tiglabs/baudstorage/proto"
 	"github.com/tiglabs/baudstorage/util/btree"
+	"github.com/tiglabs/baudstorage/util/metrics"
 )
 
 func (m *MetaNode) registerHandler() (err error) {
@@ -15,6 +16,8 @@ func (m *MetaNode) registerHandler() (err error) {
 	http.HandleFunc("/getInodeInfo", m.inodeInfoHandle)
 	http.HandleFunc("/getInodeRange", m.rangeHandle)
 	http.HandleFunc("/getExtents", m.getExtents)
+	m.registerMetrics()
+	metrics.RegisterHandler()
 	return
 }
 func (m *MetaNode) allPartitionsHandle(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,147 @@
+package metanode
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/metrics"
+)
+
+const (
+	metricsSubsystem = "metanode"
+	opClassRead      = "read"
+	opClassWrite     = "write"
+)
+
+var opMetrics = metrics.NewOpMetrics(metricsSubsystem)
+
+// opClass is the class the latency of a client op is summed in for the heartbeat, the writes
+// go through raft and are slower than the reads.
+func opClass(opcode uint8) string {
+	switch opcode {
+	case proto.OpMetaLookup, proto.OpMetaReadDir, proto.OpMetaInodeGet, proto.OpMetaBatchInodeGet,
+		proto.OpMetaExtentsList:
+		return opClassRead
+	case proto.OpMetaCreateInode, proto.OpMetaDeleteInode, proto.OpMetaCreateDentry, proto.OpMetaDeleteDentry,
+		proto.OpMetaOpen, proto.OpMetaExtentsAdd, proto.OpMetaExtentsDel, proto.OpMetaFallocate:
+		return opClassWrite
+	}
+	return ""
+}
+
+// heartbeatStats is sent with every heartbeat response by class of the ops, the windows restart after each call.
+func heartbeatStats() map[string]proto.NodeStats {
+	stats := make(map[string]proto.NodeStats)
+	for class, s := range opMetrics.TakeStats() {
+		stats[class] = proto.NodeStats{
+			OpCount:      s.Count,
+			ErrCount:     s.Errors,
+			AvgLatencyUs: uint64(s.AvgLatency / time.Microsecond),
+			MaxLatencyUs: uint64(s.MaxLatency / time.Microsecond),
+		}
+	}
+	return stats
+}
+
+type raftGauge struct {
+	name   string
+	help   string
+	report func(mp *metaPartition, report func(v float64, labelValues ...string))
+}
+
+// rangePartitions calls f with a snapshot of the partitions, the lock of the manager is not held by f.
+func (m *MetaNode) rangePartitions(f func(mp *metaPartition)) {
+	mm, ok := m.metaManager.(*metaManager)
+	if !ok || mm == nil {
+		return
+	}
+	mm.mu.RLock()
+	partitions := make([]*metaPartition, 0, len(mm.partitions))
+	for _, partition := range mm.partitions {
+		if mp, ok := partition.(*metaPartition); ok {
+			partitions = append(partitions, mp)
+		}
+	}
+	mm.mu.RUnlock()
+	for _, mp := range partitions {
+		f(mp)
+	}
+}
+
+func (m *MetaNode) registerMetrics() {
+	partitionLabels := []string{"partition"}
+	raftGauges := []raftGauge{
+		{"raft_term", "Raft term of the meta partition.",
+			func(mp *metaPartition, report func(v float64, labelValues ...string)) {
+				if status := mp.raftPartition.Status(); status != nil {
+					report(float64(status.Term), strconv.FormatUint(mp.config.PartitionId, 10))
+				}
+			}},
+		{"raft_commit_index", "Raft commit index of the meta partition.",
+			func(mp *metaPartition, report func(v float64, labelValues ...string)) {
+				if status := mp.raftPartition.Status(); status != nil {
+					report(float64(status.Commit), strconv.FormatUint(mp.config.PartitionId, 10))
+				}
+			}},
+		{"raft_applied_index", "Raft applied index of the meta partition.",
+			func(mp *metaPartition, report func(v float64, labelValues ...string)) {
+				if status := mp.raftPartition.Status(); status != nil {
+					report(float64(status.Applied), strconv.FormatUint(mp.config.PartitionId, 10))
+				}
+			}},
+		{"raft_is_leader", "1 if this node is the raft leader of the meta partition.",
+			func(mp *metaPartition, report func(v float64, labelValues ...string)) {
+				var leader float64
+				if _, ok := mp.IsLeader(); ok {
+					leader = 1
+				}
+				report(leader, strconv.FormatUint(mp.config.PartitionId, 10))
+			}},
+	}
+	for _, g := range raftGauges {
+		gauge := g
+		metrics.NewGaugeFunc(metricsSubsystem, gauge.name, gauge.help, partitionLabels,
+			func(report func(v float64, labelValues ...string)) {
+				m.rangePartitions(func(mp *metaPartition) {
+					if mp.raftPartition != nil {
+						gauge.report(mp, report)
+					}
+				})
+			})
+	}
+	metrics.NewGaugeFunc(metricsSubsystem, "partitions", "Count of the meta partitions by role.", []string{"leader"},
+		func(report func(v float64, labelValues ...string)) {
+			var leaders, followers int
+			m.rangePartitions(func(mp *metaPartition) {
+				if mp.raftPartition == nil {
+					return
+				}
+				if _, ok := mp.IsLeader(); ok {
+					leaders++
+				} else {
+					followers++
+				}
+			})
+			report(float64(leaders), "true")
+			report(float64(followers), "false")
+		})
+	metrics.NewGaugeFunc(metricsSubsystem, "partition_inodes", "Count of the inodes of the meta partition.", partitionLabels,
+		func(report func(v float64, labelValues ...string)) {
+			m.rangePartitions(func(mp *metaPartition) {
+				mp.inodeMu.RLock()
+				count := mp.inodeTree.Len()
+				mp.inodeMu.RUnlock()
+				report(float64(count), strconv.FormatUint(mp.config.PartitionId, 10))
+			})
+		})
+	metrics.NewGaugeFunc(metricsSubsystem, "partition_dentries", "Count of the dentries of the meta partition.", partitionLabels,
+		func(report func(v float64, labelValues ...string)) {
+			m.rangePartitions(func(mp *metaPartition) {
+				mp.dentryMu.RLock()
+				count := mp.dentryTree.Len()
+				mp.dentryMu.RUnlock()
+				report(float64(count), strconv.FormatUint(mp.config.PartitionId, 10))
+			})
+		})
+}
@@ -119,6 +119,8 @@ type OpDentry interface {
 type OpExtent interface {
 	ExtentAppend(req *proto.AppendExtentKeyRequest, p *Packet) (err error)
 	ExtentsList(req *proto.GetExtentsRequest, p *Packet) (err error)
+	Fallocate(req *proto.FallocateRequest, p *Packet) (err error)
+	MigrateColdInodes(coldBefore int64, migrator ExtentMigrator)
 }
 
 type OpMeta interface {
@@ -163,6 +165,7 @@ type metaPartition struct {
 	stopC         chan bool
 	storeChan     chan *storeMsg
 	state         uint32
+	migrateCursor uint64 // Inode ID the next round of the cold file migration starts from.
 }
 
 func (mp *metaPartition) Start() (err error) {
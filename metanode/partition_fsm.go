@@ -71,6 +71,18 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			return
 		}
 		resp = mp.appendExtents(ino)
+	case opExtentsSwap:
+		req := &extentsSwap{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.swapExtents(req)
+	case opFallocate:
+		req := &inodeFallocate{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fallocate(req)
 	case opStoreTick:
 		msg := &storeMsg{
 			command:    opStoreTick,
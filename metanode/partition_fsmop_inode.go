@@ -73,6 +73,8 @@ func (mp *metaPartition) deleteInode(ino *Inode) (resp *ResponseInode) {
 func (mp *metaPartition) appendExtents(ino *Inode) (status uint8) {
 	exts := ino.Extents
 	status = proto.OpOk
+	mp.inodeMu.Lock()
+	defer mp.inodeMu.Unlock()
 	item := mp.inodeTree.Get(ino)
 	if item == nil {
 		status = proto.OpNotExistErr
@@ -80,7 +82,11 @@ func (mp *metaPartition) appendExtents(ino *Inode) (status uint8) {
 	}
 	modifyTime := ino.ModifyTime
 	ino = item.(*Inode)
+	legacy := exts.Legacy()
 	exts.Range(func(i int, ext proto.ExtentKey) bool {
+		if legacy {
+			ext = ino.Extents.PlaceLegacy(ext)
+		}
 		ino.AppendExtents(ext)
 		return true
 	})
@@ -88,3 +94,54 @@ func (mp *metaPartition) appendExtents(ino *Inode) (status uint8) {
 	ino.Generation++
 	return
 }
+
+// swapExtents replaces the extents of the inode if they are not changed since
+// the swap was requested, which the generation of the inode tells.
+func (mp *metaPartition) swapExtents(req *extentsSwap) (status uint8) {
+	status = proto.OpOk
+	mp.inodeMu.Lock()
+	defer mp.inodeMu.Unlock()
+	item := mp.inodeTree.Get(NewInode(req.Inode, 0))
+	if item == nil {
+		status = proto.OpNotExistErr
+		return
+	}
+	ino := item.(*Inode)
+	if ino.Generation != req.Generation {
+		status = proto.OpArgMismatchErr
+		return
+	}
+	extents := proto.NewStreamKey(ino.Inode)
+	extents.Extents = req.Extents
+	ino.Extents = extents
+	ino.Generation++
+	return
+}
+
+// inodeFallocate is the raft command of a fallocate request.
+type inodeFallocate struct {
+	proto.FallocateRequest
+	ModifyTime int64 `json:"mt"`
+}
+
+// fallocate punches a hole in the inode, or preallocates the range of it which extends
+// the size of the inode unless it is kept. The preallocated range is a hole until written.
+func (mp *metaPartition) fallocate(req *inodeFallocate) (status uint8) {
+	status = proto.OpOk
+	mp.inodeMu.Lock()
+	defer mp.inodeMu.Unlock()
+	item := mp.inodeTree.Get(NewInode(req.Inode, 0))
+	if item == nil {
+		status = proto.OpNotExistErr
+		return
+	}
+	ino := item.(*Inode)
+	if req.Mode&proto.FallocPunchHole != 0 {
+		ino.Extents.Punch(req.Offset, req.Length)
+	} else if req.Mode&proto.FallocKeepSize == 0 && req.Offset+req.Length > ino.Size {
+		ino.Size = req.Offset + req.Length
+	}
+	ino.ModifyTime = req.ModifyTime
+	ino.Generation++
+	return
+}
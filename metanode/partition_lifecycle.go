@@ -0,0 +1,137 @@
+package metanode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/util/btree"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+// ExtentMigrator copies extents to the cold storage of a volume and reclaims the replaced ones.
+type ExtentMigrator interface {
+	MigrateToEC(inode uint64, keys []proto.ExtentKey) (newKeys []proto.ExtentKey, err error)
+	Delete(keys []proto.ExtentKey) (err error)
+}
+
+// extentsSwap is the raft command which replaces the extents of a migrated inode.
+type extentsSwap struct {
+	Inode      uint64            `json:"ino"`
+	Generation uint64            `json:"gen"`
+	Extents    []proto.ExtentKey `json:"eks"`
+}
+
+// MigrateColdInodes moves the files which are not modified since coldBefore into
+// the cold storage. Every round checks lifecycleBatchInodes inodes from where the
+// last round stopped, it is run by the leader only.
+func (mp *metaPartition) MigrateColdInodes(coldBefore int64, migrator ExtentMigrator) {
+	if _, ok := mp.IsLeader(); !ok {
+		return
+	}
+	for _, ino := range mp.nextColdInodes(coldBefore) {
+		if err := mp.migrateInode(ino, migrator); err != nil {
+			log.LogWarnf("[MigrateColdInodes] partition[%v] inode[%v]: %s",
+				mp.config.PartitionId, ino.Inode, err.Error())
+		}
+	}
+}
+
+// nextColdInodes returns the cold regular files of the next batch and moves the cursor behind it.
+func (mp *metaPartition) nextColdInodes(coldBefore int64) (inodes []*Inode) {
+	checked := 0
+	cursor := mp.migrateCursor
+	mp.inodeMu.RLock()
+	mp.inodeTree.AscendGreaterOrEqual(NewInode(cursor, 0), func(i btree.Item) bool {
+		ino := i.(*Inode)
+		cursor = ino.Inode + 1
+		if ino.Type == proto.ModeRegular && ino.ModifyTime < coldBefore && ino.Extents.GetExtentLen() > 0 {
+			inodes = append(inodes, ino)
+		}
+		checked++
+		return checked < lifecycleBatchInodes
+	})
+	mp.inodeMu.RUnlock()
+	if checked < lifecycleBatchInodes {
+		// the end of the partition is reached, the next round starts over
+		cursor = 0
+	}
+	mp.migrateCursor = cursor
+	return
+}
+
+// migrateInode copies the replicated extents of the inode and swaps them for the copies.
+// The extents which lose the swap are deleted, the copies if the inode is changed meanwhile.
+func (mp *metaPartition) migrateInode(ino *Inode, migrator ExtentMigrator) (err error) {
+	// the extents are read with their generation under the inode lock, the swap of an
+	// inode changed afterwards is refused
+	mp.inodeMu.RLock()
+	generation := ino.Generation
+	keys := make([]proto.ExtentKey, 0, ino.Extents.GetExtentLen())
+	ino.Extents.Range(func(i int, ek proto.ExtentKey) bool {
+		keys = append(keys, ek)
+		return true
+	})
+	mp.inodeMu.RUnlock()
+	newKeys, err := migrator.MigrateToEC(ino.Inode, keys)
+	if err != nil {
+		return
+	}
+	var replaced, copies []proto.ExtentKey
+	for i := range keys {
+		if !keys[i].Equal(newKeys[i]) {
+			replaced = append(replaced, keys[i])
+			copies = append(copies, newKeys[i])
+		}
+	}
+	if len(copies) == 0 {
+		return
+	}
+	if mp.inodeGeneration(ino) != generation {
+		migrator.Delete(copies)
+		return fmt.Errorf("inode changed during the migration")
+	}
+	val, err := json.Marshal(&extentsSwap{Inode: ino.Inode, Generation: generation, Extents: newKeys})
+	if err != nil {
+		migrator.Delete(copies)
+		return
+	}
+	resp, err := mp.Put(opExtentsSwap, val)
+	if err != nil {
+		// the extents of the inode tell which of them lost the swap
+		if mp.extentsSwapped(ino, generation, newKeys) {
+			migrator.Delete(replaced)
+		} else {
+			migrator.Delete(copies)
+		}
+		return
+	}
+	if status := resp.(uint8); status != proto.OpOk {
+		migrator.Delete(copies)
+		return fmt.Errorf("swap extents status[%v]", status)
+	}
+	log.LogInfof("[migrateInode] partition[%v] inode[%v] migrated extents %v to %v.",
+		mp.config.PartitionId, ino.Inode, replaced, copies)
+	return migrator.Delete(replaced)
+}
+
+func (mp *metaPartition) inodeGeneration(ino *Inode) uint64 {
+	mp.inodeMu.RLock()
+	defer mp.inodeMu.RUnlock()
+	return ino.Generation
+}
+
+// extentsSwapped tells if the inode holds the swapped extents, the swap moves it to the next generation.
+func (mp *metaPartition) extentsSwapped(ino *Inode, generation uint64, newKeys []proto.ExtentKey) bool {
+	mp.inodeMu.RLock()
+	defer mp.inodeMu.RUnlock()
+	if ino.Generation <= generation || ino.Extents.GetExtentLen() < len(newKeys) {
+		return false
+	}
+	for i := range newKeys {
+		if !ino.Extents.Extents[i].FullEqual(newKeys[i]) {
+			return false
+		}
+	}
+	return true
+}
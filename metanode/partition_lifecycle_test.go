@@ -0,0 +1,145 @@
+package metanode
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/raftstore"
+)
+
+func newLifecycleTestPartition(inodes int, modifyTime int64) *metaPartition {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1}).(*metaPartition)
+	for id := 1; id <= inodes; id++ {
+		ino := NewInode(uint64(id), proto.ModeRegular)
+		ino.ModifyTime = modifyTime
+		ino.Extents.Put(proto.ExtentKey{PartitionId: 1, ExtentId: uint64(id), Size: 100})
+		mp.createInode(ino)
+	}
+	return mp
+}
+
+func Test_NextColdInodes(t *testing.T) {
+	mp := newLifecycleTestPartition(lifecycleBatchInodes+10, 100)
+	dir := NewInode(uint64(lifecycleBatchInodes+11), proto.ModeDir)
+	mp.createInode(dir)
+
+	if inodes := mp.nextColdInodes(100); len(inodes) != 0 {
+		t.Fatalf("inodes modified at coldBefore are not cold: %v", len(inodes))
+	}
+	mp.migrateCursor = 0
+	inodes := mp.nextColdInodes(200)
+	if len(inodes) != lifecycleBatchInodes || mp.migrateCursor != lifecycleBatchInodes+1 {
+		t.Fatalf("first round got %v inodes, cursor[%v]", len(inodes), mp.migrateCursor)
+	}
+	inodes = mp.nextColdInodes(200)
+	if len(inodes) != 10 || inodes[0].Inode != lifecycleBatchInodes+1 {
+		t.Fatalf("second round got %v inodes", len(inodes))
+	}
+	if mp.migrateCursor != 0 {
+		t.Fatalf("cursor[%v] is not reset at the end of the partition", mp.migrateCursor)
+	}
+}
+
+func Test_SwapExtents(t *testing.T) {
+	mp := newLifecycleTestPartition(1, 100)
+	ino := mp.getInode(NewInode(1, 0)).Msg
+	newKeys := []proto.ExtentKey{{PartitionId: 2, ExtentId: 7, Size: 100}}
+
+	req := &extentsSwap{Inode: 1, Generation: ino.Generation + 1, Extents: newKeys}
+	if status := mp.swapExtents(req); status != proto.OpArgMismatchErr {
+		t.Fatalf("swap of a changed inode returns %v", status)
+	}
+	req.Generation = ino.Generation
+	if status := mp.swapExtents(req); status != proto.OpOk {
+		t.Fatalf("swap returns %v", status)
+	}
+	ino = mp.getInode(NewInode(1, 0)).Msg
+	if ino.Extents.GetExtentLen() != 1 || !ino.Extents.Extents[0].FullEqual(newKeys[0]) {
+		t.Fatalf("extents are not swapped: %v", ino.Extents)
+	}
+	if status := mp.swapExtents(&extentsSwap{Inode: 2}); status != proto.OpNotExistErr {
+		t.Fatalf("swap of a missing inode returns %v", status)
+	}
+}
+
+// testRaftPartition applies the submitted commands to the partition at once.
+type testRaftPartition struct {
+	raftstore.Partition
+	mp        *metaPartition
+	submitErr error
+}
+
+func (p *testRaftPartition) Submit(cmd []byte) (resp interface{}, err error) {
+	if p.submitErr != nil {
+		return nil, p.submitErr
+	}
+	return p.mp.Apply(cmd, 0)
+}
+
+type testMigrator struct {
+	onMigrate func()
+	deleted   []proto.ExtentKey
+}
+
+func (m *testMigrator) MigrateToEC(inode uint64, keys []proto.ExtentKey) (newKeys []proto.ExtentKey, err error) {
+	if m.onMigrate != nil {
+		m.onMigrate()
+	}
+	for _, ek := range keys {
+		ek.PartitionId = 2
+		newKeys = append(newKeys, ek)
+	}
+	return
+}
+
+func (m *testMigrator) Delete(keys []proto.ExtentKey) (err error) {
+	m.deleted = append(m.deleted, keys...)
+	return
+}
+
+func Test_MigrateInode(t *testing.T) {
+	mp := newLifecycleTestPartition(3, 100)
+	raft := &testRaftPartition{mp: mp}
+	mp.raftPartition = raft
+	getInode := func(id uint64) *Inode {
+		return mp.getInode(NewInode(id, 0)).Msg
+	}
+
+	migrator := &testMigrator{}
+	if err := mp.migrateInode(getInode(1), migrator); err != nil {
+		t.Fatal(err)
+	}
+	if len(migrator.deleted) != 1 || migrator.deleted[0].PartitionId != 1 {
+		t.Fatalf("replaced extents are not deleted: %v", migrator.deleted)
+	}
+	if ek := getInode(1).Extents.Extents[0]; ek.PartitionId != 2 {
+		t.Fatalf("extents are not swapped: %v", ek)
+	}
+
+	// an append during the migration keeps the extents, the copies are deleted
+	migrator = &testMigrator{onMigrate: func() {
+		ino := NewInode(2, 0)
+		ino.Extents.Put(proto.ExtentKey{PartitionId: 1, ExtentId: 20, Size: 100})
+		mp.appendExtents(ino)
+	}}
+	if err := mp.migrateInode(getInode(2), migrator); err == nil {
+		t.Fatal("migration of a changed inode succeeds")
+	}
+	if len(migrator.deleted) != 1 || migrator.deleted[0].PartitionId != 2 {
+		t.Fatalf("copies are not deleted: %v", migrator.deleted)
+	}
+	if ek := getInode(2).Extents.Extents[0]; ek.PartitionId != 1 {
+		t.Fatalf("extents of a changed inode are swapped: %v", ek)
+	}
+
+	// a swap which is not submitted deletes the copies
+	raft.submitErr = errors.New("no leader")
+	migrator = &testMigrator{}
+	if err := mp.migrateInode(getInode(3), migrator); err == nil {
+		t.Fatal("migration without a submit succeeds")
+	}
+	if len(migrator.deleted) != 1 || migrator.deleted[0].PartitionId != 2 {
+		t.Fatalf("copies are not deleted: %v", migrator.deleted)
+	}
+}
@@ -2,13 +2,27 @@ package metanode
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/tiglabs/baudstorage/proto"
 )
 
+// ExtentAppend puts the key to the inode at its file offset. The key of a client which does
+// not send the file offsets, or the one put before every meta node reads them, is placed at
+// apply as the releases before the file offsets did, so it is only appended.
 func (mp *metaPartition) ExtentAppend(req *proto.AppendExtentKeyRequest, p *Packet) (err error) {
 	ino := NewInode(req.Inode, 0)
-	ino.Extents.Put(req.Extent)
+	ek := req.Extent
+	if req.Features&proto.FeatureFileOffset == 0 || !fileOffsetsEnabled() {
+		if req.Features&proto.FeatureFileOffset != 0 && !mp.isLegacyPlacement(req.Inode, ek) {
+			p.PackErrorWithBody(proto.OpArgMismatchErr, []byte("file offsets are not enabled on the cluster"))
+			return
+		}
+		ek.FileOffset = 0
+		ek.ExtentOffset = 0
+		ino.Extents.SetLegacy()
+	}
+	ino.Extents.Put(ek)
 	val, err := ino.Marshal()
 	if err != nil {
 		p.PackErrorWithBody(proto.OpErr, nil)
@@ -23,6 +37,19 @@ func (mp *metaPartition) ExtentAppend(req *proto.AppendExtentKeyRequest, p *Pack
 	return
 }
 
+// isLegacyPlacement reports whether the key of a client using the file offsets is placed
+// at the same offset without them, which it is if it appends to the file.
+func (mp *metaPartition) isLegacyPlacement(inode uint64, ek proto.ExtentKey) bool {
+	retMsg := mp.getInode(NewInode(inode, 0))
+	if retMsg.Status != proto.OpOk {
+		return true
+	}
+	if ek.ExtentOffset != 0 {
+		return false
+	}
+	return retMsg.Msg.Extents.PlaceLegacy(ek).FileOffset == ek.FileOffset
+}
+
 func (mp *metaPartition) ExtentsList(req *proto.GetExtentsRequest,
 	p *Packet) (err error) {
 	ino := NewInode(req.Inode, 0)
@@ -46,3 +73,18 @@ func (mp *metaPartition) ExtentsList(req *proto.GetExtentsRequest,
 	p.PackErrorWithBody(status, reply)
 	return
 }
+
+func (mp *metaPartition) Fallocate(req *proto.FallocateRequest, p *Packet) (err error) {
+	val, err := json.Marshal(&inodeFallocate{FallocateRequest: *req, ModifyTime: time.Now().Unix()})
+	if err != nil {
+		p.PackErrorWithBody(proto.OpErr, nil)
+		return
+	}
+	resp, err := mp.Put(opFallocate, val)
+	if err != nil {
+		p.PackErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.PackErrorWithBody(resp.(uint8), nil)
+	return
+}
@@ -54,7 +54,7 @@ func (m *MockServer) volGroupView() (views []*data.DataPartition) {
 		v := &data.DataPartition{
 			PartitionID: uint32(i),
 			ReplicaNum:  3,
-			Status:      uint8((rand.Int()%2 + 1)),
+			Status:      int8((rand.Int()%2 + 1)),
 			Hosts:       hosts}
 		views = append(views, v)
 	}
@@ -19,6 +19,9 @@ type CreateDataPartitionRequest struct {
 	PartitionId   uint64
 	PartitionSize int
 	VolumeId      string
+	ECDataNum     uint8
+	ECParityNum   uint8
+	Keys          []*VolKey
 }
 
 type CreateDataPartitionResponse struct {
@@ -79,9 +82,110 @@ type LoadMetaPartitionMetricResponse struct {
 	Result   string
 }
 
+type DecommissionDiskRequest struct {
+	DiskPath     string
+	MigrateLocal bool
+}
+
+type DecommissionDiskResponse struct {
+	DiskPath string
+	Status   uint8
+	Result   string
+	// Failures are the partitions a local migration failed to move, by partition id
+	Failures map[uint64]string
+}
+
+type ScrubDataPartitionRequest struct {
+	PartitionType   string
+	PartitionId     uint64
+	ReadBytesPerSec int64
+}
+
+type ScrubFile struct {
+	Name      string
+	StoreMode uint8
+	Size      uint64
+	Count     int
+	Crc       uint32
+	Bad       []uint64
+}
+
+type ScrubDataPartitionResponse struct {
+	PartitionType string
+	PartitionId   uint64
+	Files         []*ScrubFile
+	Status        uint8
+	Result        string
+}
+
+type RepairFileRequest struct {
+	PartitionType string
+	PartitionId   uint64
+	Name          string
+	StoreMode     uint8
+	Source        string
+	Bad           []uint64
+}
+
+// CorruptionReport carries the files of a partition found bad by the background scrub of a data node.
+type CorruptionReport struct {
+	PartitionId uint64
+	Addr        string
+	Files       []*ScrubFile
+}
+
+type RepairFileResponse struct {
+	PartitionId uint64
+	Name        string
+	Status      uint8
+	Result      string
+}
+
+type SetPartitionStatusRequest struct {
+	PartitionType string
+	PartitionId   uint64
+	ManualStatus  int8
+}
+
+type SetPartitionStatusResponse struct {
+	PartitionId uint64
+	Status      uint8
+	Result      string
+}
+
 type HeartBeatRequest struct {
 	CurrTime   int64
 	MasterAddr string
+	VolQos     map[string]*VolQos
+	Lifecycles map[string]*VolLifecycle
+	// Compressions are the codec names of the vols storing their data blocks compressed
+	Compressions map[string]string
+	// VolKeys are the data keys of the encrypted vols
+	VolKeys map[string][]*VolKey
+	// Compact tells the data nodes whether to compact the tiny chunks
+	Compact bool
+	// Features are the ones every node of the cluster supports
+	Features uint64
+}
+
+// VolKey is a version of the data key of a vol wrapped by the master key of the cluster.
+type VolKey struct {
+	Version uint32
+	Key     []byte
+}
+
+type VolQos struct {
+	ReadIOPS       uint64
+	WriteIOPS      uint64
+	ReadBandwidth  uint64
+	WriteBandwidth uint64
+	MetaOPS        uint64
+}
+
+// VolLifecycle moves the files of a volume which are not modified for
+// ColdAfterDays days from replicated data partitions to ec data partitions.
+type VolLifecycle struct {
+	ColdAfterDays uint32
 }
 
 type PartitionReport struct {
@@ -89,6 +193,11 @@ type PartitionReport struct {
 	PartitionStatus int
 	Total           uint64
 	Used            uint64
+	DiskPath        string
+	// blocks compressed since the data node started and their raw and stored size
+	CompressedBlocks  uint64
+	CompressedRawSize uint64
+	CompressedSize    uint64
 }
 
 type DataNodeHeartBeatResponse struct {
@@ -99,12 +208,24 @@ type DataNodeHeartBeatResponse struct {
 	RemainWeightsForCreatePartition uint64 //all-usedvolsWieghts
 	CreatedPartitionCnt             uint32
 	MaxWeightsForCreatePartition    uint64
+	ZoneName                        string
 	RackName                        string
 	PartitionInfo                   []*PartitionReport
+	Version                         uint32
+	Features                        uint64
+	Stats                           map[string]NodeStats
 	Status                          uint8
 	Result                          string
 }
 
+// NodeStats summarizes the ops of a class, like read or write, since the last heartbeat.
+type NodeStats struct {
+	OpCount      uint64
+	ErrCount     uint64
+	AvgLatencyUs uint64
+	MaxLatencyUs uint64
+}
+
 type MetaPartitionReport struct {
 	PartitionID uint64
 	Start       uint64
@@ -115,10 +236,14 @@ type MetaPartitionReport struct {
 }
 
 type MetaNodeHeartbeatResponse struct {
+	ZoneName          string
 	RackName          string
 	Total             uint64
 	Used              uint64
 	MetaPartitionInfo []*MetaPartitionReport
+	Version           uint32
+	Features          uint64
+	Stats             map[string]NodeStats
 	Status            uint8
 	Result            string
 }
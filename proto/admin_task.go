@@ -10,6 +10,7 @@ const (
 	TaskStart        = 0
 	TaskSuccess      = 1
 	TaskRunning      = 3
+	TaskDeadLetter   = 4
 	ResponseInterval = 5
 	ResponseTimeOut  = 100
 	MaxSendCount     = 5
@@ -24,21 +25,30 @@ type AdminTask struct {
 	SendTime     int64
 	CreateTime   int64
 	SendCount    uint8
+	UpdateTime   int64
+	LastError    string
 	Request      interface{}
 	Response     interface{}
 }
 
 func (t *AdminTask) ToString() (msg string) {
-	msg = fmt.Sprintf("Id[%v] Status[%d] LastSendTime[%v]  SendCount[%v] Request[%v] Response[%v]",
-		t.ID, t.Status, t.SendTime, t.SendCount, t.Request, t.Response)
+	msg = fmt.Sprintf("Id[%v] Status[%d] LastSendTime[%v]  SendCount[%v] LastError[%v] Request[%v] Response[%v]",
+		t.ID, t.Status, t.SendTime, t.SendCount, t.LastError, t.Request, t.Response)
 
 	return
 }
 
 //1.has never send, t.SendTime=0,
-//2.has send but response time out
+//2.has send but response time out,the interval doubles with every send except for heartbeat
 func (t *AdminTask) CheckTaskNeedSend() (needRetry bool) {
-	if (int)(t.SendCount) < MaxSendCount && time.Now().Unix()-t.SendTime > (int64)(ResponseInterval) {
+	if t.Status == TaskDeadLetter {
+		return
+	}
+	interval := (int64)(ResponseInterval)
+	if !t.IsHeartbeatTask() && t.SendCount > 0 {
+		interval = interval << (t.SendCount - 1)
+	}
+	if (int)(t.SendCount) < MaxSendCount && time.Now().Unix()-t.SendTime > interval {
 		needRetry = true
 	}
 	return
@@ -58,6 +68,16 @@ func (t *AdminTask) CheckTaskTimeOut() (notResponse bool) {
 	return
 }
 
+//the task which has been sent MaxSendCount times and still has no response after ResponseTimeOut,
+//is given up and kept as a dead letter
+func (t *AdminTask) CheckTaskDeadLetter() (isDead bool) {
+	if t.Status != TaskDeadLetter && (int)(t.SendCount) >= MaxSendCount &&
+		time.Now().Unix()-t.SendTime > (int64)(ResponseTimeOut) {
+		isDead = true
+	}
+	return
+}
+
 func (t *AdminTask) SetStatus(status int8) {
 	t.Status = status
 }
@@ -105,5 +125,6 @@ func NewAdminTask(opCode uint8, opAddr string, request interface{}) (t *AdminTas
 	t.OperatorAddr = opAddr
 	t.ID = fmt.Sprintf("addr[%v]_op[%v]", t.OperatorAddr, t.OpCode)
 	t.CreateTime = time.Now().Unix()
+	t.UpdateTime = t.CreateTime
 	return
 }
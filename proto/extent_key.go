@@ -11,15 +11,20 @@ import (
 
 var InvalidKey = errors.New("invalid key error")
 
+// ExtentKey maps the range of a file starting at FileOffset to the range of an extent
+// starting at ExtentOffset, an overwrite of the file splits the keys it covers.
 type ExtentKey struct {
-	PartitionId uint32
-	ExtentId    uint64
-	Size        uint32
-	Crc         uint32
+	FileOffset   uint64
+	PartitionId  uint32
+	ExtentId     uint64
+	ExtentOffset uint64
+	Size         uint32
+	Crc          uint32
 }
 
 func (ek *ExtentKey) String() string {
-	return fmt.Sprintf("Partition(%v) ExtentID(%v) Size(%v) CRC(%v)", ek.PartitionId, ek.ExtentId, ek.Size, ek.Crc)
+	return fmt.Sprintf("FileOffset(%v) Partition(%v) ExtentID(%v) ExtentOffset(%v) Size(%v) CRC(%v)",
+		ek.FileOffset, ek.PartitionId, ek.ExtentId, ek.ExtentOffset, ek.Size, ek.Crc)
 }
 
 func (ek *ExtentKey) Equal(k ExtentKey) bool {
@@ -30,11 +35,33 @@ func (ek *ExtentKey) FullEqual(k ExtentKey) bool {
 	return ek.PartitionId == k.PartitionId && ek.ExtentId == k.ExtentId && ek.Size == k.Size
 }
 
+// Contiguous reports whether the key continues k in both the file and the extent.
+func (ek *ExtentKey) Contiguous(k ExtentKey) bool {
+	return ek.Equal(k) && k.FileOffset+uint64(k.Size) == ek.FileOffset &&
+		k.ExtentOffset+uint64(k.Size) == ek.ExtentOffset
+}
+
 func (k *ExtentKey) Marshal() (m string) {
-	return fmt.Sprintf("%v_%v_%v_%v", k.PartitionId, k.ExtentId, k.Size, k.Crc)
+	return fmt.Sprintf("%v_%v_%v_%v_%v_%v", k.PartitionId, k.ExtentId, k.Size, k.Crc, k.FileOffset, k.ExtentOffset)
 }
 
 func (k *ExtentKey) MarshalBinary() ([]byte, error) {
+	data, err := k.marshalBinaryV1()
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(data)
+	if err := binary.Write(buf, binary.BigEndian, k.FileOffset); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(buf, binary.BigEndian, k.ExtentOffset); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalBinaryV1 writes the key without its offsets, as it was before the file offsets were added.
+func (k *ExtentKey) marshalBinaryV1() ([]byte, error) {
 	buf := bytes.NewBuffer(make([]byte, 0))
 	if err := binary.Write(buf, binary.BigEndian, k.PartitionId); err != nil {
 		return nil, err
@@ -52,6 +79,20 @@ func (k *ExtentKey) MarshalBinary() ([]byte, error) {
 }
 
 func (k *ExtentKey) UnmarshalBinary(buf *bytes.Buffer) (err error) {
+	if err = k.unmarshalBinaryV1(buf); err != nil {
+		return
+	}
+	if err = binary.Read(buf, binary.BigEndian, &k.FileOffset); err != nil {
+		return
+	}
+	if err = binary.Read(buf, binary.BigEndian, &k.ExtentOffset); err != nil {
+		return
+	}
+	return
+}
+
+// unmarshalBinaryV1 reads a key written before the file offsets were added.
+func (k *ExtentKey) unmarshalBinaryV1(buf *bytes.Buffer) (err error) {
 	if err = binary.Read(buf, binary.BigEndian, &k.PartitionId); err != nil {
 		return
 	}
@@ -78,6 +119,9 @@ func (k *ExtentKey) UnMarshal(m string) (err error) {
 	)
 	err = InvalidKey
 	keyArr := strings.Split(m, "_")
+	if len(keyArr) != 4 && len(keyArr) != 6 {
+		return
+	}
 	size, err = strconv.ParseUint(keyArr[2], 10, 64)
 	if err != nil {
 		return
@@ -91,6 +135,10 @@ func (k *ExtentKey) UnMarshal(m string) (err error) {
 	k.PartitionId = uint32(vId)
 	k.Size = uint32(size)
 	k.Crc = uint32(crc)
+	if len(keyArr) == 6 {
+		k.FileOffset, _ = strconv.ParseUint(keyArr[4], 10, 64)
+		k.ExtentOffset, _ = strconv.ParseUint(keyArr[5], 10, 64)
+	}
 
 	return nil
 }
@@ -0,0 +1,50 @@
+package proto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExtentKey_MarshalBinary(t *testing.T) {
+	k := ExtentKey{FileOffset: 4096, PartitionId: 7, ExtentId: 9, ExtentOffset: 512, Size: 100, Crc: 33}
+	data, err := k.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got ExtentKey
+	if err = got.UnmarshalBinary(bytes.NewBuffer(data)); err != nil || got != k {
+		t.Fatalf("got %v err %v, want %v", got, err, k)
+	}
+
+	// the format of version 1 keeps no offsets
+	if data, err = k.marshalBinaryV1(); err != nil {
+		t.Fatal(err)
+	}
+	got = ExtentKey{}
+	want := k
+	want.FileOffset, want.ExtentOffset = 0, 0
+	if err = got.unmarshalBinaryV1(bytes.NewBuffer(data)); err != nil || got != want {
+		t.Fatalf("version 1 got %v err %v, want %v", got, err, want)
+	}
+}
+
+func TestExtentKey_UnMarshal(t *testing.T) {
+	k := ExtentKey{FileOffset: 4096, PartitionId: 7, ExtentId: 9, ExtentOffset: 512, Size: 100, Crc: 33}
+	cases := []struct {
+		m    string
+		want ExtentKey
+	}{
+		{k.Marshal(), k},
+		{"7_9_100_33", ExtentKey{PartitionId: 7, ExtentId: 9, Size: 100, Crc: 33}},
+	}
+	for _, c := range cases {
+		var got ExtentKey
+		if err := got.UnMarshal(c.m); err != nil || got != c.want {
+			t.Errorf("%v: got %v err %v, want %v", c.m, got, err, c.want)
+		}
+	}
+	var got ExtentKey
+	if err := got.UnMarshal("7_9_100"); err != InvalidKey {
+		t.Errorf("short key err %v, want %v", err, InvalidKey)
+	}
+}
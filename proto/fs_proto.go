@@ -127,6 +127,23 @@ type AppendExtentKeyRequest struct {
 	PartitionID uint64    `json:"pid"`
 	Inode       uint64    `json:"ino"`
 	Extent      ExtentKey `json:"ek"`
+	// Features are the ones the client uses, an old client sends none
+	Features uint64 `json:"features"`
+}
+
+// The modes of a fallocate request, as the ones of the fallocate system call.
+const (
+	FallocKeepSize  uint32 = 0x01
+	FallocPunchHole uint32 = 0x02
+)
+
+type FallocateRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+	Mode        uint32 `json:"mode"`
+	Offset      uint64 `json:"off"`
+	Length      uint64 `json:"len"`
 }
 
 type GetExtentsRequest struct {
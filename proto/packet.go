@@ -27,6 +27,7 @@ const (
 	AddrSplit       = "/"
 	ExtentPartition = "extent"
 	TinyPartition   = "tiny"
+	ECPartition     = "ec"
 )
 
 //operations
@@ -46,6 +47,12 @@ const (
 	OpFlowInfo        uint8 = 0x0B
 	OpSyncDelNeedle   uint8 = 0x0C
 	OpNotifyCompact   uint8 = 0x0D
+	OpECWrite         uint8 = 0x0E
+	OpECRead          uint8 = 0x0F
+	OpECWriteShard    uint8 = 0x10
+	OpECReadShard     uint8 = 0x11
+	OpRandomWrite     uint8 = 0x12
+	OpPunchHole       uint8 = 0x13
 
 	// Operations: Client -> MetaNode.
 	OpMetaCreateInode   uint8 = 0x20
@@ -60,6 +67,7 @@ const (
 	OpMetaExtentsAdd    uint8 = 0x29
 	OpMetaExtentsDel    uint8 = 0x2A
 	OpMetaExtentsList   uint8 = 0x2B
+	OpMetaFallocate     uint8 = 0x2C
 
 	// Operations: Master -> MetaNode
 	OpCreateMetaPartition  uint8 = 0x40
@@ -76,6 +84,10 @@ const (
 	OpDataNodeHeartbeat   uint8 = 0x63
 	OpReplicateFile       uint8 = 0x64
 	OpDeleteFile          uint8 = 0x65
+	OpDecommissionDisk    uint8 = 0x66
+	OpScrubDataPartition  uint8 = 0x67
+	OpRepairFile          uint8 = 0x68
+	OpSetPartitionStatus  uint8 = 0x69
 
 	// Commons
 	OpIntraGroupNetErr uint8 = 0xF3
@@ -169,6 +181,18 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "ExtentRepairRead"
 	case OpFlowInfo:
 		m = "FlowInfo"
+	case OpECWrite:
+		m = "ECWrite"
+	case OpECRead:
+		m = "ECRead"
+	case OpECWriteShard:
+		m = "ECWriteShard"
+	case OpECReadShard:
+		m = "ECReadShard"
+	case OpRandomWrite:
+		m = "RandomWrite"
+	case OpPunchHole:
+		m = "PunchHole"
 	case OpIntraGroupNetErr:
 		m = "IntraGroupNetErr"
 	case OpMetaCreateInode:
@@ -195,6 +219,8 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpMetaExtentsDel"
 	case OpMetaExtentsList:
 		m = "OpMetaExtentsList"
+	case OpMetaFallocate:
+		m = "OpMetaFallocate"
 	case OpCreateMetaPartition:
 		m = "OpCreateMetaPartition"
 	case OpMetaNodeHeartbeat:
@@ -219,6 +245,14 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpReplicateFile"
 	case OpDeleteFile:
 		m = "OpDeleteFile"
+	case OpDecommissionDisk:
+		m = "OpDecommissionDisk"
+	case OpScrubDataPartition:
+		m = "OpScrubDataPartition"
+	case OpRepairFile:
+		m = "OpRepairFile"
+	case OpSetPartitionStatus:
+		m = "OpSetPartitionStatus"
 	case OpPing:
 		m = "OpPing"
 	}
@@ -399,12 +433,18 @@ func (p *Packet) ReadFromConn(c net.Conn, deadlineTime time.Duration) (err error
 		return
 	}
 	size := p.Size
-	if (p.Opcode == OpRead || p.Opcode == OpStreamRead) && p.ResultCode == OpInitResultCode {
+	if p.IsReadRequest() && p.ResultCode == OpInitResultCode {
 		size = 0
 	}
 	return ReadFull(c, &p.Data, int(size))
 }
 
+// IsReadRequest reports the ops whose request carries the size to read but no data.
+func (p *Packet) IsReadRequest() bool {
+	return p.Opcode == OpRead || p.Opcode == OpStreamRead || p.Opcode == OpECRead || p.Opcode == OpECReadShard ||
+		p.Opcode == OpERepairRead
+}
+
 func (p *Packet) PackOkReply() {
 	p.ResultCode = OpOk
 	p.Size = 0
@@ -470,6 +510,16 @@ func (p *Packet) ActionMsg(action, remote string, start int64, err error) (m str
 	return
 }
 
+// IsNodeErr tells the op failed on the node itself, rather than being refused or failed
+// for the request.
+func (p *Packet) IsNodeErr() bool {
+	switch p.ResultCode {
+	case OpErr, OpDiskErr, OpIntraGroupNetErr:
+		return true
+	}
+	return false
+}
+
 func (p *Packet) ShallRetry() bool {
 	return p.ResultCode == OpAgain || p.ResultCode == OpErr
 }
@@ -2,8 +2,10 @@ package proto
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -11,6 +13,7 @@ type StreamKey struct {
 	Inode   uint64
 	Extents []ExtentKey
 	sync.Mutex
+	legacy bool
 }
 
 func (sk *StreamKey) String() string {
@@ -41,43 +44,103 @@ func (sk *StreamKey) UnMarshal(data []byte) {
 	json.Unmarshal(data, sk)
 }
 
+// Put adds the key to the stream. The range of the file it covers is cut from the keys
+// before it, and it is merged with its neighbours it continues in both the file and the extent,
+// so a key put again as it grows replaces the one before.
 func (sk *StreamKey) Put(k ExtentKey) {
 	sk.Lock()
 	defer sk.Unlock()
-	if len(sk.Extents) == 0 {
-		sk.Extents = append(sk.Extents, k)
+	if k.Size == 0 {
 		return
 	}
-	lastIndex := len(sk.Extents) - 1
-	lastKey := sk.Extents[lastIndex]
-	if lastKey.PartitionId == k.PartitionId && lastKey.ExtentId == k.ExtentId {
-		if k.Size > lastKey.Size {
-			sk.Extents[lastIndex].Size = k.Size
-			return
-		}
+	extents := sk.cut(k.FileOffset, k.FileOffset+uint64(k.Size))
+	i := sort.Search(len(extents), func(i int) bool {
+		return extents[i].FileOffset >= k.FileOffset
+	})
+	if i > 0 && k.Contiguous(extents[i-1]) {
+		i--
+		k.FileOffset = extents[i].FileOffset
+		k.ExtentOffset = extents[i].ExtentOffset
+		k.Size += extents[i].Size
+		extents = append(extents[:i], extents[i+1:]...)
+	}
+	if i < len(extents) && extents[i].Contiguous(k) {
+		k.Size += extents[i].Size
+		extents = append(extents[:i], extents[i+1:]...)
+	}
+	extents = append(extents, ExtentKey{})
+	copy(extents[i+1:], extents[i:])
+	extents[i] = k
+	sk.Extents = extents
+}
+
+// Punch removes the range of the file from the keys, so the range reads as a hole.
+func (sk *StreamKey) Punch(offset, size uint64) {
+	sk.Lock()
+	defer sk.Unlock()
+	if size == 0 {
 		return
 	}
-	extentsLen := len(sk.Extents)
-	for i := 0; i < extentsLen; i++ {
-		ek := sk.Extents[i]
-		if ek.PartitionId == k.PartitionId && ek.ExtentId == k.ExtentId {
-			if k.Size > ek.Size {
-				sk.Extents[i].Size = k.Size
-				return
-			}
-			return
+	sk.Extents = sk.cut(offset, offset+size)
+}
+
+// Slice returns the pieces of the keys within the range of the file.
+func (sk *StreamKey) Slice(offset, size uint64) (pieces []ExtentKey) {
+	sk.Lock()
+	defer sk.Unlock()
+	end := offset + size
+	for _, ek := range sk.Extents {
+		ekEnd := ek.FileOffset + uint64(ek.Size)
+		if ekEnd <= offset || ek.FileOffset >= end {
+			continue
+		}
+		if ek.FileOffset < offset {
+			ek.ExtentOffset += offset - ek.FileOffset
+			ek.FileOffset = offset
+		}
+		if ekEnd > end {
+			ekEnd = end
 		}
+		ek.Size = uint32(ekEnd - ek.FileOffset)
+		pieces = append(pieces, ek)
 	}
-	sk.Extents = append(sk.Extents, k)
+	return
+}
 
+// cut returns the keys with the range [offset, end) of the file removed, the keys
+// overlapping the range are trimmed or split.
+func (sk *StreamKey) cut(offset, end uint64) (extents []ExtentKey) {
+	extents = make([]ExtentKey, 0, len(sk.Extents)+2)
+	for _, ek := range sk.Extents {
+		ekEnd := ek.FileOffset + uint64(ek.Size)
+		if ekEnd <= offset || ek.FileOffset >= end {
+			extents = append(extents, ek)
+			continue
+		}
+		if ek.FileOffset < offset {
+			head := ek
+			head.Size = uint32(offset - ek.FileOffset)
+			extents = append(extents, head)
+		}
+		if ekEnd > end {
+			tail := ek
+			tail.FileOffset = end
+			tail.ExtentOffset += end - ek.FileOffset
+			tail.Size = uint32(ekEnd - end)
+			extents = append(extents, tail)
+		}
+	}
 	return
 }
 
+// Size returns the end of the last key, the file is not smaller than it.
 func (sk *StreamKey) Size() (bytes uint64) {
 	sk.Lock()
 	defer sk.Unlock()
-	for _, ok := range sk.Extents {
-		bytes += uint64(ok.Size)
+	for _, ek := range sk.Extents {
+		if end := ek.FileOffset + uint64(ek.Size); end > bytes {
+			bytes = end
+		}
 	}
 	return
 }
@@ -100,10 +163,17 @@ func (sk *StreamKey) Range(f func(i int, v ExtentKey) bool) {
 	}
 }
 
+// The keys are led by extentKeysMagic since the file offsets were added, the keys
+// without it are the ranges of the file one after another.
+const extentKeysMagic uint32 = 0xFFFFFFFF
+
 func (sk *StreamKey) MarshalBinary() (data []byte, err error) {
 	sk.Lock()
 	defer sk.Unlock()
 	buf := bytes.NewBuffer(make([]byte, 0))
+	if err = binary.Write(buf, binary.BigEndian, extentKeysMagic); err != nil {
+		return
+	}
 	for _, extent := range sk.Extents {
 		var binData []byte
 		binData, err = extent.MarshalBinary()
@@ -116,19 +186,101 @@ func (sk *StreamKey) MarshalBinary() (data []byte, err error) {
 	return
 }
 
+// MarshalBinaryV1 writes the keys in the format before the file offsets, which the nodes of
+// version 1 read. Only the keys which are the whole extents one after another fit in it.
+func (sk *StreamKey) MarshalBinaryV1() (data []byte, err error) {
+	sk.Lock()
+	defer sk.Unlock()
+	if !sk.sequential() {
+		return nil, fmt.Errorf("extent keys of inode[%v] do not fit the format of version 1", sk.Inode)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0))
+	for _, extent := range sk.Extents {
+		var binData []byte
+		if binData, err = extent.marshalBinaryV1(); err != nil {
+			return
+		}
+		buf.Write(binData)
+	}
+	data = buf.Bytes()
+	return
+}
+
+// Sequential reports whether the keys fit the format of version 1.
+func (sk *StreamKey) Sequential() bool {
+	sk.Lock()
+	defer sk.Unlock()
+	return sk.sequential()
+}
+
+func (sk *StreamKey) sequential() bool {
+	var fileOffset uint64
+	for _, ek := range sk.Extents {
+		if ek.FileOffset != fileOffset || ek.ExtentOffset != 0 {
+			return false
+		}
+		fileOffset += uint64(ek.Size)
+	}
+	return true
+}
+
 func (sk *StreamKey) UnmarshalBinary(data []byte) (err error) {
 	sk.Lock()
 	defer sk.Unlock()
 	buf := bytes.NewBuffer(data)
-	for {
-		if buf.Len() == 0 {
-			break
+	if len(data) >= 4 && binary.BigEndian.Uint32(data) == extentKeysMagic {
+		buf.Next(4)
+		for buf.Len() != 0 {
+			var ext ExtentKey
+			if err = ext.UnmarshalBinary(buf); err != nil {
+				return
+			}
+			sk.Extents = append(sk.Extents, ext)
 		}
+		return
+	}
+	sk.legacy = true
+	var fileOffset uint64
+	for buf.Len() != 0 {
 		var ext ExtentKey
-		if err = ext.UnmarshalBinary(buf); err != nil {
+		if err = ext.unmarshalBinaryV1(buf); err != nil {
 			return
 		}
+		ext.FileOffset = fileOffset
+		fileOffset += uint64(ext.Size)
 		sk.Extents = append(sk.Extents, ext)
 	}
 	return
 }
+
+// Legacy reports whether the keys were read in the format before the file offsets.
+func (sk *StreamKey) Legacy() bool {
+	sk.Lock()
+	defer sk.Unlock()
+	return sk.legacy
+}
+
+// SetLegacy makes the keys placed as the ones without file offsets, see PlaceLegacy.
+func (sk *StreamKey) SetLegacy() {
+	sk.Lock()
+	defer sk.Unlock()
+	sk.legacy = true
+}
+
+// PlaceLegacy gives a key without a file offset the one it had before the file offsets,
+// which is the offset of the key of its extent it grows or the end of the file.
+func (sk *StreamKey) PlaceLegacy(k ExtentKey) ExtentKey {
+	sk.Lock()
+	defer sk.Unlock()
+	k.FileOffset = 0
+	for _, ek := range sk.Extents {
+		if ek.Equal(k) && ek.ExtentOffset == k.ExtentOffset {
+			k.FileOffset = ek.FileOffset
+			return k
+		}
+		if end := ek.FileOffset + uint64(ek.Size); end > k.FileOffset {
+			k.FileOffset = end
+		}
+	}
+	return k
+}
@@ -0,0 +1,138 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testKey(fileOffset uint64, extentId uint64, extentOffset uint64, size uint32) ExtentKey {
+	return ExtentKey{FileOffset: fileOffset, PartitionId: 1, ExtentId: extentId, ExtentOffset: extentOffset, Size: size}
+}
+
+func testStreamKey(extents ...ExtentKey) *StreamKey {
+	sk := NewStreamKey(1)
+	sk.Extents = append([]ExtentKey{}, extents...)
+	return sk
+}
+
+func TestStreamKey_Put(t *testing.T) {
+	first, second := testKey(0, 1, 0, 100), testKey(100, 2, 0, 100)
+	cases := []struct {
+		name   string
+		before []ExtentKey
+		put    ExtentKey
+		after  []ExtentKey
+	}{
+		{"head", []ExtentKey{first}, testKey(0, 3, 0, 10),
+			[]ExtentKey{testKey(0, 3, 0, 10), testKey(10, 1, 10, 90)}},
+		{"middle", []ExtentKey{first}, testKey(40, 3, 0, 20),
+			[]ExtentKey{testKey(0, 1, 0, 40), testKey(40, 3, 0, 20), testKey(60, 1, 60, 40)}},
+		{"tail", []ExtentKey{first}, testKey(90, 3, 0, 10),
+			[]ExtentKey{testKey(0, 1, 0, 90), testKey(90, 3, 0, 10)}},
+		{"across keys", []ExtentKey{first, second}, testKey(50, 3, 0, 100),
+			[]ExtentKey{testKey(0, 1, 0, 50), testKey(50, 3, 0, 100), testKey(150, 2, 50, 50)}},
+		{"whole key", []ExtentKey{first, second}, testKey(100, 3, 0, 100),
+			[]ExtentKey{first, testKey(100, 3, 0, 100)}},
+		{"beyond the end", []ExtentKey{first}, testKey(150, 3, 0, 10),
+			[]ExtentKey{first, testKey(150, 3, 0, 10)}},
+		{"grown key", []ExtentKey{first}, testKey(0, 1, 0, 150),
+			[]ExtentKey{testKey(0, 1, 0, 150)}},
+		{"appended to its extent", []ExtentKey{first}, testKey(100, 1, 100, 50),
+			[]ExtentKey{testKey(0, 1, 0, 150)}},
+		{"merged with both neighbours",
+			[]ExtentKey{testKey(0, 1, 0, 40), testKey(40, 3, 0, 20), testKey(60, 1, 60, 40)},
+			testKey(40, 1, 40, 20), []ExtentKey{first}},
+		{"empty", []ExtentKey{first}, testKey(50, 3, 0, 0), []ExtentKey{first}},
+	}
+	for _, c := range cases {
+		sk := testStreamKey(c.before...)
+		sk.Put(c.put)
+		if !reflect.DeepEqual(sk.Extents, c.after) {
+			t.Errorf("%v: put %v got %v, want %v", c.name, c.put, sk.Extents, c.after)
+		}
+	}
+}
+
+func TestStreamKey_Punch(t *testing.T) {
+	first, second := testKey(0, 1, 0, 100), testKey(100, 2, 0, 100)
+	cases := []struct {
+		name         string
+		offset, size uint64
+		after        []ExtentKey
+	}{
+		{"key at the boundary", 100, 100, []ExtentKey{first}},
+		{"across the boundary", 50, 100, []ExtentKey{testKey(0, 1, 0, 50), testKey(150, 2, 50, 50)}},
+		{"inside a key", 40, 20, []ExtentKey{testKey(0, 1, 0, 40), testKey(60, 1, 60, 40), second}},
+		{"head of a key", 100, 10, []ExtentKey{first, testKey(110, 2, 10, 90)}},
+		{"beyond the end", 300, 10, []ExtentKey{first, second}},
+		{"empty", 50, 0, []ExtentKey{first, second}},
+	}
+	for _, c := range cases {
+		sk := testStreamKey(first, second)
+		sk.Punch(c.offset, c.size)
+		if !reflect.DeepEqual(sk.Extents, c.after) {
+			t.Errorf("%v: punch [%v, %v) got %v, want %v", c.name, c.offset, c.offset+c.size, sk.Extents, c.after)
+		}
+	}
+}
+
+func TestStreamKey_Slice(t *testing.T) {
+	first, second := testKey(0, 1, 0, 100), testKey(100, 2, 0, 100)
+	cases := []struct {
+		name         string
+		offset, size uint64
+		pieces       []ExtentKey
+	}{
+		{"first extent", 0, 100, []ExtentKey{first}},
+		{"second extent", 100, 100, []ExtentKey{second}},
+		{"across the extents", 50, 100, []ExtentKey{testKey(50, 1, 50, 50), testKey(100, 2, 0, 50)}},
+		{"end of an extent", 90, 10, []ExtentKey{testKey(90, 1, 90, 10)}},
+		{"start of an extent", 100, 10, []ExtentKey{testKey(100, 2, 0, 10)}},
+		{"beyond the end", 200, 10, nil},
+	}
+	sk := testStreamKey(first, second)
+	for _, c := range cases {
+		if pieces := sk.Slice(c.offset, c.size); !reflect.DeepEqual(pieces, c.pieces) {
+			t.Errorf("%v: slice [%v, %v) got %v, want %v", c.name, c.offset, c.offset+c.size, pieces, c.pieces)
+		}
+	}
+}
+
+func TestStreamKey_MarshalBinary(t *testing.T) {
+	sequential := []ExtentKey{testKey(0, 1, 0, 100), testKey(100, 2, 0, 50)}
+	sequential[0].Crc, sequential[1].Crc = 11, 22
+	overwritten := []ExtentKey{testKey(0, 1, 0, 40), testKey(40, 3, 0, 20), testKey(60, 1, 60, 40)}
+	cases := []struct {
+		name    string
+		extents []ExtentKey
+		v1      bool
+	}{
+		{"sequential keys", sequential, false},
+		{"overwritten keys", overwritten, false},
+		{"legacy keys", sequential, true},
+	}
+	for _, c := range cases {
+		var (
+			data []byte
+			err  error
+		)
+		if c.v1 {
+			data, err = testStreamKey(c.extents...).MarshalBinaryV1()
+		} else {
+			data, err = testStreamKey(c.extents...).MarshalBinary()
+		}
+		if err != nil {
+			t.Fatalf("%v: marshal: %v", c.name, err)
+		}
+		sk := NewStreamKey(1)
+		if err = sk.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%v: unmarshal: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(sk.Extents, c.extents) || sk.Legacy() != c.v1 {
+			t.Errorf("%v: got %v legacy %v, want %v legacy %v", c.name, sk.Extents, sk.Legacy(), c.extents, c.v1)
+		}
+	}
+	if _, err := testStreamKey(overwritten...).MarshalBinaryV1(); err == nil {
+		t.Errorf("overwritten keys are written in the format of version 1")
+	}
+}
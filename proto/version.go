@@ -0,0 +1,44 @@
+package proto
+
+// Version is the protocol version of this release, nodes report it when they
+// register and in every heartbeat. Nodes which do not report it are version 0.
+const Version uint32 = 2
+
+// Features are the behaviors a node of this release supports. The master enables
+// a behavior only when every node of the cluster reports its feature.
+const (
+	FeatureScrub uint64 = 1 << iota
+	FeatureManualStatus
+	FeatureVolQos
+	FeatureEC
+	FeatureLifecycle
+	FeatureCompression
+	FeatureEncryption
+	// FeatureFileOffset stores the extent keys with their file offsets, version 2
+	FeatureFileOffset
+	// FeatureFallocate preallocates and punches holes in the files
+	FeatureFallocate
+)
+
+const LocalFeatures = FeatureScrub | FeatureManualStatus | FeatureVolQos | FeatureEC | FeatureLifecycle | FeatureCompression |
+	FeatureEncryption | FeatureFileOffset | FeatureFallocate
+
+var featureNames = map[uint64]string{
+	FeatureScrub:        "scrub",
+	FeatureManualStatus: "manualStatus",
+	FeatureVolQos:       "volQos",
+	FeatureEC:           "ec",
+	FeatureLifecycle:    "lifecycle",
+	FeatureCompression:  "compression",
+	FeatureEncryption:   "encryption",
+	FeatureFileOffset:   "fileOffset",
+	FeatureFallocate:    "fallocate",
+}
+
+// FeatureName returns the name of a single feature bit.
+func FeatureName(feature uint64) string {
+	if name, ok := featureNames[feature]; ok {
+		return name
+	}
+	return "unknown"
+}
@@ -45,16 +45,16 @@ func (pc *PartitionCache) Put(dp *DataPartition) {
 	pc.Lock()
 	defer pc.Unlock()
 
-	if dp.Status != proto.ReadWrite && dp.Status != proto.ReadOnly {
-		log.LogErrorf("PartitionCache Put: Invalid dp(%v)", dp)
-		return
-	}
-
 	if old, ok := pc.parts[dp.PartitionID]; ok {
 		pc.remove(old)
 		delete(pc.parts, dp.PartitionID)
 	}
 
+	if dp.Status != proto.ReadWrite && dp.Status != proto.ReadOnly {
+		log.LogErrorf("PartitionCache Put: Invalid dp(%v)", dp)
+		return
+	}
+
 	element := pc.add(dp)
 	pc.parts[dp.PartitionID] = element
 }
@@ -8,11 +8,21 @@ import (
 	"github.com/tiglabs/baudstorage/proto"
 	"github.com/tiglabs/baudstorage/sdk/data"
 	"github.com/tiglabs/baudstorage/util/log"
+	"github.com/tiglabs/baudstorage/util/metrics"
 	"runtime"
+	"sync/atomic"
+	"time"
 )
 
+const (
+	MetricsSubsystem = "client"
+)
+
+var opMetrics = metrics.NewOpMetrics(MetricsSubsystem)
+
 type AppendExtentKeyFunc func(inode uint64, key proto.ExtentKey) error
 type GetExtentsFunc func(inode uint64) ([]proto.ExtentKey, error)
+type FallocateFunc func(inode uint64, mode uint32, offset, length uint64) error
 
 type ExtentClient struct {
 	w               *data.Wrapper
@@ -22,9 +32,11 @@ type ExtentClient struct {
 	referLock       sync.Mutex
 	appendExtentKey AppendExtentKeyFunc
 	getExtents      GetExtentsFunc
+	fallocate       FallocateFunc
 }
 
-func NewExtentClient(volname, master string, appendExtentKey AppendExtentKeyFunc, getExtents GetExtentsFunc) (client *ExtentClient, err error) {
+func NewExtentClient(volname, master string, appendExtentKey AppendExtentKeyFunc, getExtents GetExtentsFunc,
+	fallocate FallocateFunc) (client *ExtentClient, err error) {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	client = new(ExtentClient)
 	client.w, err = data.NewDataPartitionWrapper(volname, master)
@@ -35,11 +47,23 @@ func NewExtentClient(volname, master string, appendExtentKey AppendExtentKeyFunc
 	client.referCnt = make(map[uint64]uint64)
 	client.appendExtentKey = appendExtentKey
 	client.getExtents = getExtents
+	client.fallocate = fallocate
 	return
 }
 
+// SetStaleRead lets a follower master answer the data partition view.
+func (client *ExtentClient) SetStaleRead(enable bool) {
+	client.w.SetStaleRead(enable)
+}
+
+// SetSupportFeature sets how the features of the cluster are checked, the retries
+// of the requests refused with OpAgain are on with proto.FeatureVolQos only.
+func (client *ExtentClient) SetSupportFeature(supportFeature func(feature uint64) bool) {
+	client.w.SetSupportFeature(supportFeature)
+}
+
 func (client *ExtentClient) InitWriteStream(inode uint64) *StreamWriter {
-	writer := NewStreamWriter(client.w, inode, client.appendExtentKey)
+	writer := NewStreamWriter(client.w, inode, client.appendExtentKey, client.getExtents, client.fallocate)
 	client.writers[inode] = writer
 	return writer
 }
@@ -70,6 +94,9 @@ func (client *ExtentClient) getStreamWriterForClose(inode uint64) (stream *Strea
 }
 
 func (client *ExtentClient) Write(inode uint64, offset int, data []byte) (write int, err error) {
+	defer func(start time.Time) {
+		opMetrics.Observe("Write", start, err)
+	}(time.Now())
 	prefix := fmt.Sprintf("inodewrite %v_%v_%v", inode, offset, len(data))
 	stream := client.getStreamWriter(inode)
 	if stream == nil {
@@ -92,6 +119,9 @@ func (client *ExtentClient) OpenForRead(inode uint64) (stream *StreamReader, err
 }
 
 func (client *ExtentClient) Flush(inode uint64) (err error) {
+	defer func(start time.Time) {
+		opMetrics.Observe("Flush", start, err)
+	}(time.Now())
 	stream := client.getStreamWriter(inode)
 	if stream == nil {
 		return fmt.Errorf("cannot init write stream")
@@ -102,6 +132,21 @@ func (client *ExtentClient) Flush(inode uint64) (err error) {
 	return request.err
 }
 
+// Fallocate preallocates the range of the file, or punches a hole in it with proto.FallocPunchHole.
+func (client *ExtentClient) Fallocate(inode uint64, mode uint32, offset, size int) (err error) {
+	defer func(start time.Time) {
+		opMetrics.Observe("Fallocate", start, err)
+	}(time.Now())
+	stream := client.getStreamWriter(inode)
+	if stream == nil {
+		return fmt.Errorf("cannot init write stream")
+	}
+	request := &WriteRequest{isFallocRequest: true, fallocMode: mode, kernelOffset: offset, size: size}
+	stream.requestCh <- request
+	request = <-stream.replyCh
+	return request.err
+}
+
 func (client *ExtentClient) Close(inode uint64) (err error) {
 	client.referLock.Lock()
 	inodeReferCnt := client.referCnt[inode]
@@ -138,6 +183,9 @@ func (client *ExtentClient) Read(stream *StreamReader, inode uint64, data []byte
 	if size == 0 {
 		return
 	}
+	defer func(start time.Time) {
+		opMetrics.Observe("Read", start, err)
+	}(time.Now())
 	client.writerLock.RLock()
 	wstream := client.writers[inode]
 	client.writerLock.RUnlock()
@@ -148,6 +196,12 @@ func (client *ExtentClient) Read(stream *StreamReader, inode uint64, data []byte
 		if err = request.err; err != nil {
 			return 0, err
 		}
+		if redirects := atomic.LoadUint64(&wstream.redirects); redirects != stream.redirects {
+			if err = stream.reload(); err != nil {
+				return 0, err
+			}
+			stream.redirects = redirects
+		}
 	}
 	read, err = stream.read(data, offset, size)
 
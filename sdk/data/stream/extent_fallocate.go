@@ -0,0 +1,71 @@
+package stream
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/sdk/data"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+// fallocateRange preallocates the range of the file or punches a hole in it. The current
+// extent is left first, so the writes after do not grow its key over the range again.
+// The keys of a hole are removed from the metanode before their extents are punched,
+// so the range is read as zeros from then on.
+func (stream *StreamWriter) fallocateRange(mode uint32, offset, size int) (err error) {
+	if err = stream.closeCurrExtentWriter(); err != nil {
+		return
+	}
+	var pieces []proto.ExtentKey
+	if mode&proto.FallocPunchHole != 0 {
+		sk := proto.NewStreamKey(stream.Inode)
+		if sk.Extents, err = stream.getExtents(stream.Inode); err != nil {
+			return
+		}
+		pieces = sk.Slice(uint64(offset), uint64(size))
+	}
+	if err = stream.fallocate(stream.Inode, mode, uint64(offset), uint64(size)); err != nil {
+		return
+	}
+	stream.fileSize = -1
+	atomic.AddUint64(&stream.redirects, 1)
+	for _, ek := range pieces {
+		if err := stream.punchExtent(ek); err != nil {
+			log.LogWarnf("stream[%v] punch key[%v] failed[%v], the space is left to the extent",
+				stream.toString(), ek.String(), err)
+		}
+	}
+	return
+}
+
+// punchExtent deallocates the range of the extent the key keeps on its replicas.
+func (stream *StreamWriter) punchExtent(ek proto.ExtentKey) (err error) {
+	var dp *data.DataPartition
+	if dp, err = stream.w.GetDataPartition(ek.PartitionId); err != nil {
+		return
+	}
+	if dp.PartitionType == proto.ECPartition {
+		return fmt.Errorf("extent of ec partition[%v] is not punched", dp.PartitionID)
+	}
+	connect, err := stream.w.GetConnect(dp.Hosts[0])
+	if err != nil {
+		return
+	}
+	defer func() {
+		stream.w.PutConnect(connect, err != nil)
+	}()
+	p := NewPunchHolePacket(dp, ek.ExtentId, int(ek.ExtentOffset), int(ek.Size))
+	if err = p.writeTo(connect); err != nil {
+		return
+	}
+	reply := NewReply(p.ReqID, p.PartitionID, p.FileID)
+	if err = reply.ReadFromConn(connect, proto.ReadDeadlineTime); err != nil {
+		return
+	}
+	if reply.ResultCode != proto.OpOk {
+		return fmt.Errorf("punch hole request[%v] reply[%v] %v", p.GetUniqueLogId(),
+			reply.GetUniqueLogId(), reply.GetResultMesg())
+	}
+	return
+}
@@ -0,0 +1,108 @@
+package stream
+
+import (
+	"fmt"
+
+	"github.com/juju/errors"
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/sdk/data"
+	"github.com/tiglabs/baudstorage/util"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+// ECStripeUnitSize is the size of a shard in a stripe, it must be the stripe unit of the data nodes.
+const ECStripeUnitSize = util.ReadBlockSize
+
+// MigrateToEC copies the extents of an inode which are kept by replicated data partitions into
+// ec data partitions. The keys are returned in the order they are given, the copies replacing
+// the replicated extents. No copy is left when any extent fails.
+func (client *ExtentClient) MigrateToEC(inode uint64, keys []proto.ExtentKey) (newKeys []proto.ExtentKey, err error) {
+	copies := make([]proto.ExtentKey, 0)
+	defer func() {
+		if err != nil {
+			client.Delete(copies)
+			newKeys = nil
+		}
+	}()
+	for _, key := range keys {
+		var dp *data.DataPartition
+		if dp, err = client.w.GetDataPartition(key.PartitionId); err != nil {
+			return
+		}
+		if dp.PartitionType == proto.ECPartition || key.Size == 0 {
+			newKeys = append(newKeys, key)
+			continue
+		}
+		var newKey proto.ExtentKey
+		if newKey, err = client.copyToEC(inode, key); err != nil {
+			return
+		}
+		copies = append(copies, newKey)
+		newKeys = append(newKeys, newKey)
+	}
+	return
+}
+
+// copyToEC reads the extent stripe by stripe and writes the stripes to a new extent of an ec partition.
+func (client *ExtentClient) copyToEC(inode uint64, key proto.ExtentKey) (newKey proto.ExtentKey, err error) {
+	reader, err := NewExtentReader(inode, int(key.FileOffset), key, client.w)
+	if err != nil {
+		return
+	}
+	dp, err := client.w.GetECWriteDataPartition(nil)
+	if err != nil {
+		return
+	}
+	if dp.ECDataNum == 0 {
+		return newKey, fmt.Errorf("ec partition[%v] has no data shards in its view", dp.PartitionID)
+	}
+	extentId, err := createExtent(dp, inode)
+	if err != nil {
+		return
+	}
+	stripeSize := int(dp.ECDataNum) * ECStripeUnitSize
+	stripe := make([]byte, stripeSize)
+	for offset := 0; offset < int(key.Size); offset += stripeSize {
+		size := util.Min(stripeSize, int(key.Size)-offset)
+		if err = reader.read(stripe, offset, size, offset, size); err != nil {
+			break
+		}
+		if err = client.writeStripe(dp, extentId, offset, stripe[:size]); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		client.delete(dp, extentId)
+		return newKey, errors.Annotatef(err, "copy extent[%v] to ec partition[%v] extent[%v]",
+			key.String(), dp.PartitionID, extentId)
+	}
+	newKey = proto.ExtentKey{FileOffset: key.FileOffset, PartitionId: dp.PartitionID, ExtentId: extentId, Size: key.Size}
+	log.LogInfof("action[copyToEC] inode[%v] extent[%v] copied to [%v].", inode, key.String(), newKey.String())
+	return
+}
+
+func (client *ExtentClient) writeStripe(dp *data.DataPartition, extentId uint64, offset int, stripe []byte) (err error) {
+	connect, err := client.w.GetConnect(dp.Hosts[0])
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err == nil {
+			client.w.PutConnect(connect, NoCloseConnect)
+		} else {
+			client.w.PutConnect(connect, ForceCloseConnect)
+		}
+	}()
+	p := NewECWritePacket(dp, extentId, offset, stripe)
+	if err = p.WriteToConn(connect); err != nil {
+		return
+	}
+	if err = p.ReadFromConn(connect, proto.ReadDeadlineTime); err != nil {
+		return
+	}
+	if p.ResultCode != proto.OpOk {
+		err = fmt.Errorf("ec write request[%v] reply[%v] %v", p.GetUniqueLogId(), p.GetResultMesg(),
+			string(p.Data[:p.Size]))
+	}
+	return
+}
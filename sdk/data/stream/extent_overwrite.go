@@ -0,0 +1,121 @@
+package stream
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/sdk/data"
+	"github.com/tiglabs/baudstorage/util"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+// loadFileSize reads the size of the file from its keys before the first write.
+func (stream *StreamWriter) loadFileSize() (err error) {
+	if stream.fileSize >= 0 {
+		return
+	}
+	sk := proto.NewStreamKey(stream.Inode)
+	if sk.Extents, err = stream.getExtents(stream.Inode); err != nil {
+		return
+	}
+	stream.fileSize = int(sk.Size())
+	return
+}
+
+// overwrite writes the data over the range of the file written before. The ranges kept by
+// the extents of replicated partitions are overwritten in place, the others and the ones failed
+// to be overwritten are written to a new extent, whose key takes the range over on the metanode.
+func (stream *StreamWriter) overwrite(data []byte, offset, size int) (err error) {
+	if err = stream.flushCurrExtentWriter(); err != nil {
+		return
+	}
+	var extents []proto.ExtentKey
+	if extents, err = stream.getExtents(stream.Inode); err != nil {
+		return
+	}
+	end := offset + size
+	redirect := func(start, end int) (err error) {
+		if start >= end {
+			return
+		}
+		if _, err = stream.writeExtent(data[start-offset:end-offset], start, end-start); err != nil {
+			return
+		}
+		atomic.AddUint64(&stream.redirects, 1)
+		return
+	}
+	cursor := offset
+	for _, ek := range extents {
+		start := util.Max(cursor, int(ek.FileOffset))
+		keyEnd := util.Min(end, int(ek.FileOffset)+int(ek.Size))
+		if start >= keyEnd {
+			continue
+		}
+		if err = redirect(cursor, start); err != nil {
+			return
+		}
+		var written proto.ExtentKey
+		if written, err = stream.overwriteInPlace(ek, data[start-offset:keyEnd-offset], start); err != nil {
+			log.LogWarnf("stream[%v] overwrite key[%v] in place failed[%v], write it to a new extent",
+				stream.toString(), ek.String(), err)
+			if err = redirect(start, keyEnd); err != nil {
+				return
+			}
+		} else if err = stream.appendExtentKey(stream.Inode, written); err != nil {
+			// the key is put again to tell the metanode the file is modified
+			log.LogWarnf("stream[%v] update overwritten key[%v] to metanode failed[%v]",
+				stream.toString(), written.String(), err)
+			return
+		}
+		cursor = keyEnd
+	}
+	if err = redirect(cursor, end); err != nil {
+		return
+	}
+	// the writes of the redirected ranges are not continued by the next write
+	return stream.closeCurrExtentWriter()
+}
+
+// overwriteInPlace overwrites the range of the key at the offset of the file by the buf,
+// it returns the key of the overwritten range.
+func (stream *StreamWriter) overwriteInPlace(ek proto.ExtentKey, buf []byte, offset int) (written proto.ExtentKey, err error) {
+	var dp *data.DataPartition
+	if dp, err = stream.w.GetDataPartition(ek.PartitionId); err != nil {
+		return
+	}
+	if dp.PartitionType == proto.ECPartition {
+		err = fmt.Errorf("extent of ec partition[%v] is not overwritten", dp.PartitionID)
+		return
+	}
+	connect, err := stream.w.GetConnect(dp.Hosts[0])
+	if err != nil {
+		return
+	}
+	defer func() {
+		stream.w.PutConnect(connect, err != nil)
+	}()
+	extentOffset := int(ek.ExtentOffset) + offset - int(ek.FileOffset)
+	for total := 0; total < len(buf); {
+		size := util.Min(util.BlockSize, len(buf)-total)
+		p := NewRandomWritePacket(dp, ek.ExtentId, extentOffset+total, offset+total, buf[total:total+size])
+		if err = p.writeTo(connect); err != nil {
+			return
+		}
+		reply := NewReply(p.ReqID, p.PartitionID, p.FileID)
+		if err = reply.ReadFromConn(connect, proto.ReadDeadlineTime); err != nil {
+			return
+		}
+		if reply.ResultCode != proto.OpOk || !p.IsEqualWriteReply(reply) {
+			err = fmt.Errorf("random write request[%v] reply[%v] %v", p.GetUniqueLogId(),
+				reply.GetUniqueLogId(), reply.GetResultMesg())
+			return
+		}
+		total += size
+	}
+	written = ek
+	written.FileOffset = uint64(offset)
+	written.ExtentOffset = uint64(extentOffset)
+	written.Size = uint32(len(buf))
+	return
+}
@@ -22,6 +22,7 @@ const (
 
 var (
 	ReadConnectPool = pool.NewConnPoolWithPara(60, 100, time.Second*20, nil)
+	AgainErr        = errors.New("refused with OpAgain")
 )
 
 type ExtentReader struct {
@@ -53,17 +54,19 @@ func NewExtentReader(inode uint64, inInodeOffset int, key proto.ExtentKey,
 	return reader, nil
 }
 
+// read reads the data at the offset of the range of the key.
 func (reader *ExtentReader) read(data []byte, offset, size, kerneloffset, kernelsize int) (err error) {
 	if size <= 0 {
 		return
 	}
+	offset += int(reader.key.ExtentOffset)
 	err = reader.readDataFromDataPartition(offset, size, data, kerneloffset, kernelsize)
 
 	return
 }
 
 func (reader *ExtentReader) readDataFromDataPartition(offset, size int, data []byte, kerneloffset, kernelsize int) (err error) {
-	if _, err = reader.streamReadDataFromHost(offset, size, data, kerneloffset, kernelsize); err != nil {
+	if _, err = reader.readFromHost(offset, size, data, kerneloffset, kernelsize); err != nil {
 		log.LogWarnf(err.Error())
 		goto forLoop
 	}
@@ -72,7 +75,7 @@ func (reader *ExtentReader) readDataFromDataPartition(offset, size int, data []b
 forLoop:
 	mesg := ""
 	for i := 0; i < 3; i++ {
-		_, err = reader.streamReadDataFromHost(offset, size, data, kerneloffset, kernelsize)
+		_, err = reader.readFromHost(offset, size, data, kerneloffset, kernelsize)
 		if err == nil {
 			return
 		} else {
@@ -86,6 +89,62 @@ forLoop:
 	return
 }
 
+func (reader *ExtentReader) readFromHost(offset, size int, data []byte, kerneloffset, kernelsize int) (actualReadSize int, err error) {
+	if reader.dp.PartitionType == proto.ECPartition {
+		return reader.ecReadDataFromHost(offset, size, data)
+	}
+	return reader.streamReadDataFromHost(offset, size, data, kerneloffset, kernelsize)
+}
+
+// ecReadDataFromHost reads an extent of an ec partition from any of its hosts, the host
+// gathers the data shards and reconstructs the ones it can not read from the parity.
+func (reader *ExtentReader) ecReadDataFromHost(offset, expectReadSize int, data []byte) (actualReadSize int, err error) {
+	var connect *net.TCPConn
+	index := atomic.LoadUint32(&reader.readerIndex)
+	if index >= uint32(len(reader.dp.Hosts)) {
+		index = 0
+		atomic.StoreUint32(&reader.readerIndex, 0)
+	}
+	host := reader.dp.Hosts[index]
+	connect, err = ReadConnectPool.Get(host)
+	if err != nil {
+		atomic.AddUint32(&reader.readerIndex, 1)
+		return 0, errors.Annotatef(err, reader.toString()+
+			"ecReadDataFromHost dp[%v] cannot get  connect from host[%v] ", reader.key.PartitionId, host)
+	}
+	defer func() {
+		if err != nil {
+			atomic.AddUint32(&reader.readerIndex, 1)
+			ReadConnectPool.Put(connect, ForceCloseConnect)
+		} else {
+			ReadConnectPool.Put(connect, NoCloseConnect)
+		}
+	}()
+
+	for actualReadSize < expectReadSize {
+		//a whole block is replied at once if it is sent from the extent file
+		canRead := util.Min(util.BlockSize, expectReadSize-actualReadSize)
+		request := NewECReadPacket(&reader.key, offset+actualReadSize, canRead)
+		if err = request.WriteToConn(connect); err != nil {
+			err = errors.Annotatef(err, reader.toString()+"ecReadDataFromHost host[%v] error request[%v]",
+				host, request.GetUniqueLogId())
+			return 0, err
+		}
+		reply := NewReply(request.ReqID, reader.dp.PartitionID, request.FileID)
+		if err = reply.ReadFromConn(connect, proto.ReadDeadlineTime); err != nil {
+			err = errors.Annotatef(err, reader.toString()+"ecReadDataFromHost host[%v] error request[%v]",
+				host, request.GetUniqueLogId())
+			return 0, err
+		}
+		if err = reader.checkReply(request, reply, offset, expectReadSize); err != nil {
+			return 0, err
+		}
+		actualReadSize += copy(data[actualReadSize:], reply.Data[:reply.Size])
+	}
+
+	return actualReadSize, nil
+}
+
 func (reader *ExtentReader) streamReadDataFromHost(offset, expectReadSize int, data []byte, kerneloffset, kernelsize int) (actualReadSize int, err error) {
 	request := NewStreamReadPacket(&reader.key, offset, expectReadSize)
 	var connect *net.TCPConn
@@ -123,7 +182,8 @@ func (reader *ExtentReader) streamReadDataFromHost(offset, expectReadSize int, d
 			break
 		}
 		reply := NewReply(request.ReqID, reader.dp.PartitionID, request.FileID)
-		canRead := util.Min(util.ReadBlockSize, expectReadSize-actualReadSize)
+		//a whole block is replied at once if it is sent from the extent file
+		canRead := util.Min(util.BlockSize, expectReadSize-actualReadSize)
 		reply.Data = data[actualReadSize : canRead+actualReadSize]
 		err = reply.ReadFromConnStream(connect, proto.ReadDeadlineTime)
 		if err != nil {
@@ -132,6 +192,17 @@ func (reader *ExtentReader) streamReadDataFromHost(offset, expectReadSize int, d
 			return 0, err
 		}
 		err = reader.checkStreamReply(request, reply, kerneloffset, kernelsize)
+		if err == AgainErr && actualReadSize == 0 && request.againCnt < MaxAgainRetry {
+			//the host refuses the whole read before it streams,it is asked again
+			time.Sleep(againBackoff(request.againCnt))
+			request.againCnt++
+			if err = request.WriteToConn(connect); err != nil {
+				err = errors.Annotatef(err, reader.toString()+"streamReadDataFromHost host[%v] error request[%v]",
+					host, request.GetUniqueLogId())
+				return 0, err
+			}
+			continue
+		}
 		if err != nil {
 			return 0, err
 		}
@@ -144,7 +215,11 @@ func (reader *ExtentReader) streamReadDataFromHost(offset, expectReadSize int, d
 	return actualReadSize, nil
 }
 
+//checkStreamReply returns AgainErr if the read is refused by the qos of the volume
 func (reader *ExtentReader) checkStreamReply(request *Packet, reply *Packet, kerneloffset, kernelsize int) (err error) {
+	if reply.ResultCode == proto.OpAgain && reader.w.SupportFeature(proto.FeatureVolQos) {
+		return AgainErr
+	}
 	if reply.ResultCode != proto.OpOk {
 		return errors.Annotatef(fmt.Errorf("reply status code[%v] is not ok,request [%v] "+
 			"but reply [%v] ", reply.ResultCode, request.GetUniqueLogId(), reply.GetUniqueLogId()),
@@ -232,7 +307,8 @@ func (reader *ExtentReader) checkReply(request *Packet, reply *Packet, kerneloff
 }
 
 func (reader *ExtentReader) updateKey(key proto.ExtentKey) (update bool) {
-	if !(key.PartitionId == reader.key.PartitionId && key.ExtentId == reader.key.ExtentId) {
+	if !(key.PartitionId == reader.key.PartitionId && key.ExtentId == reader.key.ExtentId &&
+		key.FileOffset == reader.key.FileOffset && key.ExtentOffset == reader.key.ExtentOffset) {
 		return
 	}
 	if key.Size <= reader.key.Size {
@@ -0,0 +1,56 @@
+package stream
+
+import (
+	"bytes"
+	"hash/crc32"
+	"testing"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/sdk/data"
+)
+
+func TestExtentReaderRetryAgain(t *testing.T) {
+	content := []byte("data behind the volume qos")
+	addr, offsets := newAgainServer(t, 1, func(p *proto.Packet) {
+		p.Data = content
+		p.Size = uint32(len(content))
+		p.Crc = crc32.ChecksumIEEE(content)
+		p.PackOkReadReply()
+	})
+	dp := &data.DataPartition{PartitionID: 1, ReplicaNum: 1, Hosts: []string{addr}}
+	reader := &ExtentReader{dp: dp, w: newFeatureWrapper(true),
+		key: proto.ExtentKey{PartitionId: 1, ExtentId: 1, Size: uint32(len(content))}}
+
+	buf := make([]byte, len(content))
+	n, err := reader.streamReadDataFromHost(0, len(buf), buf, 0, len(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(content) || !bytes.Equal(buf, content) {
+		t.Fatalf("read %q, want %q", buf[:n], content)
+	}
+	if got := offsets(); len(got) != 2 || reader.readerIndex != 0 {
+		t.Fatalf("the refused read is not asked to the same host again: offsets %v index %v", got, reader.readerIndex)
+	}
+}
+
+func TestExtentReaderAgainWithoutFeature(t *testing.T) {
+	content := []byte("data behind the volume qos")
+	addr, offsets := newAgainServer(t, 1, func(p *proto.Packet) {
+		p.Data = content
+		p.Size = uint32(len(content))
+		p.Crc = crc32.ChecksumIEEE(content)
+		p.PackOkReadReply()
+	})
+	dp := &data.DataPartition{PartitionID: 1, ReplicaNum: 1, Hosts: []string{addr}}
+	reader := &ExtentReader{dp: dp, w: newFeatureWrapper(false),
+		key: proto.ExtentKey{PartitionId: 1, ExtentId: 1, Size: uint32(len(content))}}
+
+	buf := make([]byte, len(content))
+	if _, err := reader.streamReadDataFromHost(0, len(buf), buf, 0, len(buf)); err == nil {
+		t.Fatal("the refused read is taken as done")
+	}
+	if got := offsets(); len(got) != 1 || reader.readerIndex != 1 {
+		t.Fatalf("the refused read is asked again on an old cluster: offsets %v index %v", got, reader.readerIndex)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"container/list"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"sync/atomic"
 
@@ -39,6 +40,7 @@ type ExtentWriter struct {
 	currentPacket *Packet
 	byteAck       uint64 //DataNode Has Ack Bytes
 	offset        int
+	fileOffset    int //the offset in the file of the first byte of the extent,-1 before the first write
 	connect       *net.TCPConn
 	handleCh      chan bool //a Chan for signal recive goroutine recive packet from connect
 	recoverCnt    int       //if failed,then recover contine,this is recover count
@@ -47,6 +49,7 @@ type ExtentWriter struct {
 	flushLock     sync.Mutex
 	forbidUpdate  int64
 	requestLock   sync.RWMutex
+	sendLock      sync.Mutex //keeps the order of the packets on the connect the order of the queue
 	isflushIng    int32
 	flushSignleCh chan bool
 }
@@ -59,6 +62,7 @@ func NewExtentWriter(inode uint64, dp *data.DataPartition, w *data.Wrapper, exte
 	writer.requestQueue = list.New()
 	writer.handleCh = make(chan bool, DefaultWriteBufferSize/(64*util.KB))
 	writer.extentId = extentId
+	writer.fileOffset = -1
 	writer.dp = dp
 	writer.inode = inode
 	writer.w = w
@@ -117,9 +121,12 @@ func (writer *ExtentWriter) write(data []byte, kernelOffset, size int) (total in
 		writer.Unlock()
 		return 0, FullExtentErr
 	}
+	if writer.fileOffset < 0 {
+		writer.fileOffset = kernelOffset
+	}
 	for total < size {
 		if writer.currentPacket == nil {
-			writer.currentPacket = NewWritePacket(writer.dp, writer.extentId, writer.offset, kernelOffset)
+			writer.currentPacket = NewWritePacket(writer.dp, writer.extentId, writer.offset, kernelOffset+total)
 		}
 		canWrite = writer.currentPacket.fill(data[total:size], size-total) //fill this packet
 		if writer.IsFullCurrentPacket() || canWrite == 0 {
@@ -152,6 +159,7 @@ func (writer *ExtentWriter) sendCurrPacket() (err error) {
 		writer.Unlock()
 		return
 	}
+	writer.sendLock.Lock()
 	writer.pushRequestToQueue(writer.currentPacket)
 	packet := writer.currentPacket
 	writer.currentPacket = nil
@@ -159,6 +167,7 @@ func (writer *ExtentWriter) sendCurrPacket() (err error) {
 	writer.offset += packet.getPacketLength()
 	writer.Unlock()
 	err = packet.writeTo(writer.connect) //if send packet,then signal recive goroutine for recive from connect
+	writer.sendLock.Unlock()
 	prefix := fmt.Sprintf("send inode %v_%v", writer.inode, packet.kernelOffset)
 	log.LogDebugf(prefix+" to extent[%v] pkg[%v] orgextentOffset[%v]"+
 		" packetGetPacketLength[%v] after jia[%v] crc[%v]",
@@ -199,6 +208,20 @@ func (writer *ExtentWriter) isFullExtent() bool {
 	return writer.offset+util.BlockSize*10 >= util.ExtentSize
 }
 
+//check a write at the offset of the file continues the data of the writer
+func (writer *ExtentWriter) isContiguous(kernelOffset int) bool {
+	writer.Lock()
+	defer writer.Unlock()
+	if writer.fileOffset < 0 {
+		return true
+	}
+	next := writer.fileOffset + writer.offset
+	if writer.currentPacket != nil {
+		next += writer.currentPacket.getPacketLength()
+	}
+	return next == kernelOffset
+}
+
 //check allPacket has Ack
 func (writer *ExtentWriter) isAllFlushed() bool {
 	writer.Lock()
@@ -271,31 +294,38 @@ func (writer *ExtentWriter) close() (err error) {
 	return
 }
 
-func (writer *ExtentWriter) processReply(e *list.Element, request, reply *Packet) (err error) {
+//resent tells the request is refused with OpAgain and sent to the same replicas again
+func (writer *ExtentWriter) processReply(e *list.Element, request, reply *Packet) (resent bool, err error) {
+	if reply.ResultCode == proto.OpAgain && writer.w.SupportFeature(proto.FeatureVolQos) {
+		if err = writer.resendRequest(e, request); err != nil {
+			return false, errors.Annotatef(err, "writer[%v]", writer.toString())
+		}
+		return true, nil
+	}
 	if reply.ResultCode != proto.OpOk {
-		return errors.Annotatef(fmt.Errorf("reply status code[%v] is not ok,request [%v] "+
+		return false, errors.Annotatef(fmt.Errorf("reply status code[%v] is not ok,request [%v] "+
 			"but reply [%v] ", reply.ResultCode, request.GetUniqueLogId(), reply.GetUniqueLogId()),
 			fmt.Sprintf("writer[%v]", writer.toString()))
 	}
 	if !request.IsEqualWriteReply(reply) {
-		return errors.Annotatef(fmt.Errorf("request not equare reply , request [%v] "+
+		return false, errors.Annotatef(fmt.Errorf("request not equare reply , request [%v] "+
 			"and reply [%v] ", request.GetUniqueLogId(), reply.GetUniqueLogId()),
 			fmt.Sprintf("writer[%v]", writer.toString()))
 	}
 	if reply.Crc != request.Crc {
-		return errors.Annotatef(fmt.Errorf("crc not match on  request [%v] "+
+		return false, errors.Annotatef(fmt.Errorf("crc not match on  request [%v] "+
 			"and reply [%v] expectCrc[%v] but reciveCrc[%v] ", request.GetUniqueLogId(), reply.GetUniqueLogId(), request.Crc, reply.Crc),
 			fmt.Sprintf("writer[%v]", writer.toString()))
 	}
 
 	if atomic.LoadInt64(&writer.forbidUpdate) == ForBidUpdateExtentKey {
-		return fmt.Errorf("forbid update extent key [%v]", writer.toString())
+		return false, fmt.Errorf("forbid update extent key [%v]", writer.toString())
 	}
 	if atomic.LoadInt64(&writer.forbidUpdate) == ForBidUpdateMetaNode {
-		return fmt.Errorf("forbid update extent key [%v] to metanode", writer.toString())
+		return false, fmt.Errorf("forbid update extent key [%v] to metanode", writer.toString())
 	}
 	writer.removeRquest(e)
-	writer.addByteAck(uint64(request.Size))
+	writer.updateByteAck()
 	if atomic.LoadInt32(&writer.isflushIng) == ExtentFlushIng && !(writer.getQueueListLen() > 0 || writer.currentPacket != nil) {
 		atomic.StoreInt32(&writer.isflushIng, ExtentHasFlushed)
 		select {
@@ -308,7 +338,27 @@ func (writer *ExtentWriter) processReply(e *list.Element, request, reply *Packet
 	log.LogDebugf("recive inode[%v] kerneloffset[%v] to extent[%v] pkg[%v] recive[%v]",
 		writer.inode, request.kernelOffset, writer.toString(), request.GetUniqueLogId(), reply.GetUniqueLogId())
 
-	return nil
+	return false, nil
+}
+
+/*resendRequest sends a request refused with OpAgain to the same replicas again after a jittered backoff.
+the head replica refuses it before it writes or forwards it,the replies of the requests sent after it
+come first,so it goes to the back of the queue*/
+func (writer *ExtentWriter) resendRequest(e *list.Element, request *Packet) (err error) {
+	if request.againCnt >= MaxAgainRetry {
+		return fmt.Errorf("request [%v] is refused with OpAgain %v times", request.GetUniqueLogId(), request.againCnt)
+	}
+	time.Sleep(againBackoff(request.againCnt))
+	request.againCnt++
+	connect := writer.getConnect()
+	writer.sendLock.Lock()
+	defer writer.sendLock.Unlock()
+	writer.requestLock.Lock()
+	writer.requestQueue.Remove(e)
+	writer.requestQueue.PushBack(request)
+	writer.requestLock.Unlock()
+	log.LogWarnf("resend request [%v] to extent[%v] after OpAgain", request.GetUniqueLogId(), writer.toString())
+	return request.writeTo(connect)
 }
 
 func (writer *ExtentWriter) toKey() (k proto.ExtentKey) {
@@ -316,6 +366,9 @@ func (writer *ExtentWriter) toKey() (k proto.ExtentKey) {
 	writer.Lock()
 	defer writer.Unlock()
 	k.PartitionId = writer.dp.PartitionID
+	if writer.fileOffset > 0 {
+		k.FileOffset = uint64(writer.fileOffset)
+	}
 	k.Size = uint32(writer.getByteAck())
 	k.ExtentId = writer.extentId
 	if atomic.LoadInt64(&writer.forbidUpdate) == ForBidUpdateMetaNode {
@@ -326,38 +379,63 @@ func (writer *ExtentWriter) toKey() (k proto.ExtentKey) {
 }
 
 func (writer *ExtentWriter) receive() {
+	//the replies of the resent requests are read without a signal of handleCh
+	resent := 0
 	for {
-		select {
-		case code := <-writer.handleCh:
-			if code == NotReceive {
-				writer.getConnect().Close()
-				return
-			}
-			e := writer.getFrontRequest()
-			if e == nil {
-				continue
-			}
-			request := e.Value.(*Packet)
-			reply := NewReply(request.ReqID, request.PartitionID, request.FileID)
-			reply.Opcode = request.Opcode
-			reply.Offset = request.Offset
-			reply.Size = request.Size
-			err := reply.ReadFromConn(writer.getConnect(), proto.ReadDeadlineTime)
-			if err != nil {
-				writer.getConnect().Close()
-				continue
-			}
-			if err = writer.processReply(e, request, reply); err != nil {
-				writer.getConnect().Close()
-				log.LogWarn(err.Error())
-				continue
+		code := ContinueReceive
+		if resent > 0 {
+			select {
+			case code = <-writer.handleCh:
+			default:
+				resent--
 			}
+		} else {
+			code = <-writer.handleCh
+		}
+		if code == NotReceive {
+			writer.getConnect().Close()
+			return
+		}
+		e := writer.getFrontRequest()
+		if e == nil {
+			continue
+		}
+		request := e.Value.(*Packet)
+		reply := NewReply(request.ReqID, request.PartitionID, request.FileID)
+		reply.Opcode = request.Opcode
+		reply.Offset = request.Offset
+		reply.Size = request.Size
+		err := reply.ReadFromConn(writer.getConnect(), proto.ReadDeadlineTime)
+		if err != nil {
+			writer.getConnect().Close()
+			continue
+		}
+		isResent, err := writer.processReply(e, request, reply)
+		if err != nil {
+			writer.getConnect().Close()
+			log.LogWarn(err.Error())
+			continue
+		}
+		if isResent {
+			resent++
 		}
 	}
 }
 
-func (writer *ExtentWriter) addByteAck(size uint64) {
-	atomic.AddUint64(&writer.byteAck, size)
+//updateByteAck acks the bytes before the first request not replied yet,a resent request
+//is replied after the requests sent behind it
+func (writer *ExtentWriter) updateByteAck() {
+	writer.Lock()
+	defer writer.Unlock()
+	ack := uint64(writer.offset)
+	writer.requestLock.RLock()
+	for e := writer.requestQueue.Front(); e != nil; e = e.Next() {
+		if offset := uint64(e.Value.(*Packet).Offset); offset < ack {
+			ack = offset
+		}
+	}
+	writer.requestLock.RUnlock()
+	atomic.StoreUint64(&writer.byteAck, ack)
 }
 
 func (writer *ExtentWriter) forbirdUpdateToMetanode() {
@@ -418,6 +496,8 @@ func (writer *ExtentWriter) getNeedRetrySendPackets() (requests []*Packet) {
 	for e := writer.requestQueue.Front(); e != nil; e = e.Next() {
 		requests = append(requests, e.Value.(*Packet))
 	}
+	//a resent request is behind the requests sent after it
+	sort.Slice(requests, func(i, j int) bool { return requests[i].kernelOffset < requests[j].kernelOffset })
 	if writer.currentPacket == nil {
 		return
 	}
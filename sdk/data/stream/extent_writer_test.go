@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/tiglabs/baudstorage/proto"
+	"github.com/tiglabs/baudstorage/sdk/data"
+	"github.com/tiglabs/baudstorage/util"
+)
+
+// newAgainServer starts a fake head replica which refuses the first refused packets with
+// OpAgain and answers the rest with reply, the offsets of the packets it gets are recorded.
+func newAgainServer(t *testing.T, refused int, reply func(p *proto.Packet)) (addr string, offsets func() []int64) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		lock sync.Mutex
+		got  []int64
+	)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			p := proto.NewPacket()
+			if err := p.ReadFromConn(conn, proto.NoReadDeadlineTime); err != nil {
+				return
+			}
+			lock.Lock()
+			got = append(got, p.Offset)
+			lock.Unlock()
+			if refused > 0 {
+				refused--
+				p.PackErrorWithBody(proto.OpAgain, []byte("volume qos"))
+			} else {
+				reply(p)
+			}
+			if err := p.WriteToConn(conn); err != nil {
+				return
+			}
+		}
+	}()
+	return ln.Addr().String(), func() []int64 {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]int64(nil), got...)
+	}
+}
+
+// newFeatureWrapper returns a wrapper of a cluster which supports the volume qos or not.
+func newFeatureWrapper(volQos bool) *data.Wrapper {
+	w := new(data.Wrapper)
+	w.SetSupportFeature(func(feature uint64) bool {
+		return feature != proto.FeatureVolQos || volQos
+	})
+	return w
+}
+
+func TestExtentWriterResendAgain(t *testing.T) {
+	addr, offsets := newAgainServer(t, 1, func(p *proto.Packet) { p.PackOkReply() })
+	dp := &data.DataPartition{PartitionID: 1, ReplicaNum: 1, Hosts: []string{addr}}
+	writer, err := NewExtentWriter(1, dp, newFeatureWrapper(true), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.close()
+
+	size := 3 * util.BlockSize
+	if _, err = writer.write(make([]byte, size), 0, size); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if k := writer.toKey(); int(k.Size) != size {
+		t.Fatalf("acked size %v, want %v", k.Size, size)
+	}
+	got := offsets()
+	if len(got) != 4 || got[0] != 0 || got[3] != 0 {
+		t.Fatalf("the refused packet is not sent to the same replica again: offsets %v", got)
+	}
+}
+
+func TestExtentWriterAgainWithoutFeature(t *testing.T) {
+	addr, offsets := newAgainServer(t, 1, func(p *proto.Packet) { p.PackOkReply() })
+	dp := &data.DataPartition{PartitionID: 1, ReplicaNum: 1, Hosts: []string{addr}}
+	writer, err := NewExtentWriter(1, dp, newFeatureWrapper(false), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.close()
+
+	size := 3 * util.BlockSize
+	if _, err = writer.write(make([]byte, size), 0, size); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.flush(); err == nil {
+		t.Fatal("the refused packet is taken as written")
+	}
+	if k := writer.toKey(); k.Size != 0 {
+		t.Fatalf("acked size %v, want 0", k.Size)
+	}
+	for i, offset := range offsets() {
+		if i > 0 && offset == 0 {
+			t.Fatalf("the refused packet is sent again to an old cluster: offsets %v", offsets())
+		}
+	}
+}
@@ -2,21 +2,40 @@ package stream
 
 import (
 	"encoding/binary"
+	"fmt"
 	"github.com/tiglabs/baudstorage/proto"
 	"github.com/tiglabs/baudstorage/sdk/data"
 	"github.com/tiglabs/baudstorage/util"
 	"hash/crc32"
 	"io"
+	"math/rand"
 	"net"
 	"time"
 )
 
+const (
+	MaxAgainRetry     = 5
+	AgainBackoffBase  = 50 * time.Millisecond
+	AgainBackoffLimit = time.Second
+)
+
 type Packet struct {
 	proto.Packet
 	fillBytes    uint32
 	kernelOffset int
 	orgSize      uint32
 	orgData      []byte
+	againCnt     int //times the packet is refused with OpAgain
+}
+
+// againBackoff is the wait before a packet refused with OpAgain by the qos of its volume is
+// sent again, it doubles with the retries and is jittered so the refused clients spread out.
+func againBackoff(retry int) time.Duration {
+	d := AgainBackoffBase << uint(retry)
+	if d > AgainBackoffLimit {
+		d = AgainBackoffLimit
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
 }
 
 func NewWritePacket(dp *data.DataPartition, extentId uint64, offset int, kernelOffset int) (p *Packet) {
@@ -37,6 +56,28 @@ func NewWritePacket(dp *data.DataPartition, extentId uint64, offset int, kernelO
 	return
 }
 
+// NewRandomWritePacket overwrites the data of an extent in place, it goes through the replicas
+// like a write.
+func NewRandomWritePacket(dp *data.DataPartition, extentId uint64, offset int, kernelOffset int, data []byte) (p *Packet) {
+	p = NewWritePacket(dp, extentId, offset, kernelOffset)
+	p.Opcode = proto.OpRandomWrite
+	p.Data = data
+	p.Size = uint32(len(data))
+
+	return
+}
+
+// NewPunchHolePacket deallocates a range of an extent on the replicas, the size of the range is the data.
+func NewPunchHolePacket(dp *data.DataPartition, extentId uint64, offset, size int) (p *Packet) {
+	p = NewWritePacket(dp, extentId, offset, 0)
+	p.Opcode = proto.OpPunchHole
+	p.Data = make([]byte, 8)
+	binary.BigEndian.PutUint64(p.Data, uint64(size))
+	p.Size = uint32(len(p.Data))
+
+	return
+}
+
 func NewReadPacket(key *proto.ExtentKey, offset, size int) (p *Packet) {
 	p = new(Packet)
 	p.FileID = key.ExtentId
@@ -52,6 +93,32 @@ func NewReadPacket(key *proto.ExtentKey, offset, size int) (p *Packet) {
 	return
 }
 
+func NewECReadPacket(key *proto.ExtentKey, offset, size int) (p *Packet) {
+	p = NewReadPacket(key, offset, size)
+	p.Opcode = proto.OpECRead
+
+	return
+}
+
+// NewECWritePacket writes a stripe of an extent of an ec partition, the receiving host encodes it
+// and writes the shards to all the hosts, so the packet is not forwarded.
+func NewECWritePacket(dp *data.DataPartition, extentId uint64, offset int, stripe []byte) (p *Packet) {
+	p = new(Packet)
+	p.PartitionID = dp.PartitionID
+	p.Magic = proto.ProtoMagic
+	p.StoreMode = proto.ExtentStoreMode
+	p.FileID = extentId
+	p.Offset = int64(offset)
+	p.Data = stripe
+	p.Size = uint32(len(stripe))
+	p.Crc = crc32.ChecksumIEEE(stripe)
+	p.ReqID = proto.GetReqID()
+	p.Opcode = proto.OpECWrite
+	p.Nodes = 0
+
+	return
+}
+
 func NewStreamReadPacket(key *proto.ExtentKey, offset, size int) (p *Packet) {
 	p = new(Packet)
 	p.FileID = key.ExtentId
@@ -194,6 +261,13 @@ func (p *Packet) ReadFromConnStream(c net.Conn, deadlineTime time.Duration) (err
 		return
 	}
 	size := p.Size
+	if int(size) > len(p.Data) {
+		if p.ResultCode == proto.OpOk {
+			return fmt.Errorf("reply size[%v] exceeds read buffer[%v]", size, len(p.Data))
+		}
+		//the error message of a failed read
+		p.Data = make([]byte, size)
+	}
 	_, err = io.ReadFull(c, p.Data[:size])
 	return
 }
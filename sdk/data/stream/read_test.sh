@@ -1 +0,0 @@
-go test -v -run TestStreamReader_GetReader
@@ -29,6 +29,7 @@ type StreamReader struct {
 	getExtents GetExtentsFunc
 	extents    *proto.StreamKey
 	fileSize   uint64
+	redirects  uint64 //the redirects of the writer of the inode the keys are read after
 }
 
 func NewStreamReader(inode uint64, w *data.Wrapper, getExtents GetExtentsFunc) (stream *StreamReader, err error) {
@@ -41,15 +42,13 @@ func NewStreamReader(inode uint64, w *data.Wrapper, getExtents GetExtentsFunc) (
 	if err != nil {
 		return
 	}
-	var offset int
 	var reader *ExtentReader
 	for _, key := range stream.extents.Extents {
-		if reader, err = NewExtentReader(inode, offset, key, stream.w); err != nil {
+		if reader, err = NewExtentReader(inode, int(key.FileOffset), key, stream.w); err != nil {
 			return nil, errors.Annotatef(err, "NewStreamReader inode[%v] "+
 				"key[%v] dp not found error", inode, key)
 		}
 		stream.readers = append(stream.readers, reader)
-		offset += int(key.Size)
 	}
 	stream.fileSize = stream.extents.Size()
 	return
@@ -87,35 +86,38 @@ func (stream *StreamReader) initCheck(offset, size int) (canread int, err error)
 	return size, nil
 }
 
+// reload reads the keys of the file again, after they are split by an overwrite or a hole.
+func (stream *StreamReader) reload() (err error) {
+	newStreamKey := proto.NewStreamKey(stream.inode)
+	if newStreamKey.Extents, err = stream.getExtents(stream.inode); err != nil {
+		return
+	}
+	return stream.updateLocalReader(newStreamKey)
+}
+
+// updateLocalReader keeps the readers of the keys which are unchanged or grown, the keys
+// split by an overwrite get new readers.
 func (stream *StreamReader) updateLocalReader(newStreamKey *proto.StreamKey) (err error) {
-	var (
-		newOffSet int
-		r         *ExtentReader
-	)
-	readers := make([]*ExtentReader, 0)
-	oldReaders := stream.readers
-	oldReaderCnt := len(stream.readers)
-	for index, key := range newStreamKey.Extents {
-		if index < oldReaderCnt-1 {
-			newOffSet += int(key.Size)
-			continue
-		} else if index == oldReaderCnt-1 {
-			oldReaders[index].updateKey(key)
-			newOffSet += int(key.Size)
-			continue
-		} else if index > oldReaderCnt-1 {
-			if r, err = NewExtentReader(stream.inode, newOffSet, key, stream.w); err != nil {
-				return errors.Annotatef(err, "NewStreamReader inode[%v] key[%v] "+
-					"dp not found error", stream.inode, key)
-			}
+	var r *ExtentReader
+	oldReaders := make(map[uint64]*ExtentReader, len(stream.readers))
+	for _, r = range stream.readers {
+		oldReaders[r.key.FileOffset] = r
+	}
+	readers := make([]*ExtentReader, 0, len(newStreamKey.Extents))
+	for _, key := range newStreamKey.Extents {
+		if r = oldReaders[key.FileOffset]; r != nil && (r.key == key || r.updateKey(key)) {
 			readers = append(readers, r)
-			newOffSet += int(key.Size)
 			continue
 		}
+		if r, err = NewExtentReader(stream.inode, int(key.FileOffset), key, stream.w); err != nil {
+			return errors.Annotatef(err, "NewStreamReader inode[%v] key[%v] "+
+				"dp not found error", stream.inode, key)
+		}
+		readers = append(readers, r)
 	}
 	stream.fileSize = newStreamKey.Size()
 	stream.extents = newStreamKey
-	stream.readers = append(stream.readers, readers...)
+	stream.readers = readers
 
 	return nil
 }
@@ -129,6 +131,15 @@ func (stream *StreamReader) read(data []byte, offset int, size int) (canRead int
 	readers, readerOffset, readerSize := stream.GetReader(offset, size)
 	for index := 0; index < len(readers); index++ {
 		r := readers[index]
+		if r == nil {
+			//a hole of the file reads as zeros
+			hole := data[canRead : canRead+readerSize[index]]
+			for i := range hole {
+				hole[i] = 0
+			}
+			canRead += readerSize[index]
+			continue
+		}
 		err = r.read(data[canRead:canRead+readerSize[index]], readerOffset[index], readerSize[index], offset, size)
 		if err != nil {
 			err = errors.Annotatef(err, "UserRequest{inode[%v] FileSize[%v] "+
@@ -149,6 +160,9 @@ func (stream *StreamReader) read(data []byte, offset int, size int) (canRead int
 
 func (stream *StreamReader) predictExtent(offset, size int) (startIndex int) {
 	startIndex = offset >> 28
+	if startIndex >= len(stream.readers) {
+		return 0
+	}
 	r := stream.readers[startIndex]
 	if int(atomic.LoadUint64(&r.startInodeOffset)) <= offset && int(atomic.LoadUint64(&r.endInodeOffset)) >= offset+size {
 		return startIndex
@@ -172,6 +186,8 @@ func (stream *StreamReader) predictExtent(offset, size int) (startIndex int) {
 	return 0
 }
 
+// GetReader returns the readers of the range of the file, a nil reader stands for a hole
+// between the keys.
 func (stream *StreamReader) GetReader(offset, size int) (readers []*ExtentReader, readersOffsets []int, readersSize []int) {
 	readers = make([]*ExtentReader, 0)
 	readersOffsets = make([]int, 0)
@@ -181,9 +197,20 @@ func (stream *StreamReader) GetReader(offset, size int) (readers []*ExtentReader
 		if size <= 0 {
 			break
 		}
-		if int(atomic.LoadUint64(&r.startInodeOffset)) > offset || int(atomic.LoadUint64(&r.endInodeOffset)) <= offset {
+		if int(atomic.LoadUint64(&r.endInodeOffset)) <= offset {
 			continue
 		}
+		if start := int(atomic.LoadUint64(&r.startInodeOffset)); start > offset {
+			holeSize := util.Min(start-offset, size)
+			readersSize = append(readersSize, holeSize)
+			readersOffsets = append(readersOffsets, 0)
+			readers = append(readers, nil)
+			offset += holeSize
+			size -= holeSize
+			if size <= 0 {
+				break
+			}
+		}
 		var (
 			currReaderSize   int
 			currReaderOffset int
@@ -29,6 +29,9 @@ type WriteRequest struct {
 	err            error
 	kernelOffset   int
 	isFlushRequest bool
+	//a fallocate request of the range from kernelOffset by size
+	isFallocRequest bool
+	fallocMode      uint32
 }
 
 type StreamWriter struct {
@@ -41,6 +44,10 @@ type StreamWriter struct {
 	Inode              uint64        //inode
 	excludePartition   []uint32
 	appendExtentKey    AppendExtentKeyFunc
+	getExtents         GetExtentsFunc
+	fallocate          FallocateFunc
+	fileSize           int    //size of the file the writes have seen,-1 before it is read
+	redirects          uint64 //count of the changes of the keys other than the appends, the readers reload the keys
 	requestCh          chan *WriteRequest
 	replyCh            chan *WriteRequest
 	exitCh             chan bool
@@ -50,10 +57,14 @@ type StreamWriter struct {
 	HasWriteSize       uint64
 }
 
-func NewStreamWriter(w *data.Wrapper, inode uint64, appendExtentKey AppendExtentKeyFunc) (stream *StreamWriter) {
+func NewStreamWriter(w *data.Wrapper, inode uint64, appendExtentKey AppendExtentKeyFunc,
+	getExtents GetExtentsFunc, fallocate FallocateFunc) (stream *StreamWriter) {
 	stream = new(StreamWriter)
 	stream.w = w
 	stream.appendExtentKey = appendExtentKey
+	stream.getExtents = getExtents
+	stream.fallocate = fallocate
+	stream.fileSize = -1
 	stream.Inode = inode
 	stream.requestCh = make(chan *WriteRequest, 1000)
 	stream.replyCh = make(chan *WriteRequest, 1000)
@@ -104,10 +115,13 @@ func (stream *StreamWriter) toStringWithWriter(writer *ExtentWriter) (m string)
 }
 
 //stream init,alloc a extent ,select dp and extent
-func (stream *StreamWriter) init() (err error) {
+func (stream *StreamWriter) init(offset int) (err error) {
 	if stream.getWriter() != nil && stream.getWriter().isFullExtent() {
 		err = stream.flushCurrExtentWriter()
 	}
+	if err == nil && stream.getWriter() != nil && !stream.getWriter().isContiguous(offset) {
+		err = stream.closeCurrExtentWriter()
+	}
 	if err != nil {
 		return errors.Annotatef(err, "WriteInit")
 	}
@@ -135,6 +149,8 @@ func (stream *StreamWriter) server() {
 		case request := <-stream.requestCh:
 			if request.isFlushRequest {
 				request.err = stream.flushCurrExtentWriter()
+			} else if request.isFallocRequest {
+				request.err = stream.fallocateRange(request.fallocMode, request.kernelOffset, request.size)
 			} else {
 				request.canWrite, request.err = stream.write(request.data, request.kernelOffset, request.size)
 			}
@@ -154,9 +170,6 @@ func (stream *StreamWriter) server() {
 }
 
 func (stream *StreamWriter) write(data []byte, offset, size int) (total int, err error) {
-	var (
-		write int
-	)
 	defer func() {
 		atomic.AddUint64(&stream.HasWriteSize, uint64(total))
 		if err == nil {
@@ -169,15 +182,38 @@ func (stream *StreamWriter) write(data []byte, offset, size int) (total int, err
 		log.LogError(errors.ErrorStack(err))
 	}()
 
+	if err = stream.loadFileSize(); err != nil {
+		return
+	}
+	if offset < stream.fileSize {
+		total = util.Min(size, stream.fileSize-offset)
+		if err = stream.overwrite(data[:total], offset, total); err != nil {
+			total = 0
+			return
+		}
+	}
+	var write int
+	write, err = stream.writeExtent(data[total:size], offset+total, size-total)
+	total += write
+	stream.fileSize = util.Max(stream.fileSize, offset+total)
+	return
+}
+
+// writeExtent writes the data to the extent of the current writer, a write which does not
+// continue the data of the writer starts a new extent.
+func (stream *StreamWriter) writeExtent(data []byte, offset, size int) (total int, err error) {
+	var (
+		write int
+	)
 	var initRetry int = 0
 	for total < size {
-		if err = stream.init(); err != nil {
+		if err = stream.init(offset + total); err != nil {
 			if initRetry++; initRetry > MaxStreamInitRetry {
 				return
 			}
 			continue
 		}
-		write, err = stream.getWriter().write(data[total:size], offset, size-total)
+		write, err = stream.getWriter().write(data[total:size], offset+total, size-total)
 		if err == FullExtentErr {
 			continue
 		}
@@ -254,6 +290,21 @@ func (stream *StreamWriter) flushCurrExtentWriter() (err error) {
 	return err
 }
 
+// closeCurrExtentWriter flushes the writer and leaves the rest of its extent.
+func (stream *StreamWriter) closeCurrExtentWriter() (err error) {
+	if err = stream.flushCurrExtentWriter(); err != nil {
+		return
+	}
+	writer := stream.getWriter()
+	if writer == nil {
+		return
+	}
+	writer.close()
+	stream.w.PutConnect(writer.getConnect(), NoCloseConnect)
+	stream.setWriterToNull()
+	return
+}
+
 func (stream *StreamWriter) updateToMetaNodeSize() (sumSize int) {
 	for _, v := range stream.hasUpdateKey {
 		sumSize += v
@@ -348,7 +399,7 @@ func (stream *StreamWriter) allocateNewExtentWriter() (writer *ExtentWriter, err
 				"failed on getWriteDataPartion,error[%v] execludeDataPartion[%v]", stream.toString(), err.Error(), stream.excludePartition))
 			continue
 		}
-		if extentId, err = stream.createExtent(dp); err != nil {
+		if extentId, err = createExtent(dp, stream.Inode); err != nil {
 			log.LogWarn(fmt.Sprintf("stream [%v]ActionAllocNewExtentWriter "+
 				"create Extent,error[%v] execludeDataPartion[%v]", stream.toString(), err.Error(), stream.excludePartition))
 			continue
@@ -371,7 +422,7 @@ func (stream *StreamWriter) allocateNewExtentWriter() (writer *ExtentWriter, err
 	return writer, nil
 }
 
-func (stream *StreamWriter) createExtent(dp *data.DataPartition) (extentId uint64, err error) {
+func createExtent(dp *data.DataPartition, inode uint64) (extentId uint64, err error) {
 	var (
 		connect *net.TCPConn
 	)
@@ -384,7 +435,7 @@ func (stream *StreamWriter) createExtent(dp *data.DataPartition) (extentId uint6
 	connect.SetKeepAlive(true)
 	connect.SetNoDelay(true)
 	defer connect.Close()
-	p := NewCreateExtentPacket(dp, stream.Inode)
+	p := NewCreateExtentPacket(dp, inode)
 	if err = p.WriteToConn(connect); err != nil {
 		err = errors.Annotatef(err, "send CreateExtent[%v] to datapartionHosts[%v]", p.GetUniqueLogId(), dp.Hosts[0])
 		return
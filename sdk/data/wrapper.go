@@ -19,6 +19,7 @@ import (
 const (
 	DataPartitionViewUrl        = "/client/dataPartitions"
 	ActionGetDataPartitionView  = "ActionGetDataPartitionView"
+	StaleReadParam              = "stale"
 	MinWritableDataPartitionNum = 10
 )
 
@@ -28,9 +29,10 @@ var (
 
 type DataPartition struct {
 	PartitionID   uint32
-	Status        uint8
+	Status        int8
 	ReplicaNum    uint8
 	PartitionType string
+	ECDataNum     uint8
 	Hosts         []string
 }
 
@@ -54,6 +56,10 @@ type Wrapper struct {
 	conns       *pool.ConnPool
 	partitions  map[uint32]*DataPartition
 	rwPartition []*DataPartition
+	ecPartition []*DataPartition
+	staleRead   bool
+	// supportFeature tells if every node of the cluster supports a feature
+	supportFeature func(feature uint64) bool
 }
 
 func NewDataPartitionWrapper(volName, masterHosts string) (w *Wrapper, err error) {
@@ -84,9 +90,42 @@ func (w *Wrapper) update() {
 	}
 }
 
+// SetStaleRead lets a follower master answer the data partition view, which may
+// lag a little behind the leader but is still available while the leader is lost.
+func (w *Wrapper) SetStaleRead(enable bool) {
+	w.Lock()
+	defer w.Unlock()
+	w.staleRead = enable
+}
+
+// SetSupportFeature sets how the features of the cluster are checked, every feature
+// is taken as supported until it is set.
+func (w *Wrapper) SetSupportFeature(supportFeature func(feature uint64) bool) {
+	w.Lock()
+	defer w.Unlock()
+	w.supportFeature = supportFeature
+}
+
+// SupportFeature tells if every node of the cluster supports the feature, a reader
+// or writer without a wrapper takes every feature as supported.
+func (w *Wrapper) SupportFeature(feature uint64) bool {
+	if w == nil {
+		return true
+	}
+	w.RLock()
+	supportFeature := w.supportFeature
+	w.RUnlock()
+	return supportFeature == nil || supportFeature(feature)
+}
+
 func (w *Wrapper) updateDataPartition() error {
 	paras := make(map[string]string, 0)
 	paras["name"] = w.volName
+	w.RLock()
+	if w.staleRead {
+		paras[StaleReadParam] = "true"
+	}
+	w.RUnlock()
 	msg, err := MasterHelper.Request(http.MethodGet, DataPartitionViewUrl, paras, nil)
 	if err != nil {
 		return err
@@ -98,11 +137,19 @@ func (w *Wrapper) updateDataPartition() error {
 	}
 
 	rwPartitionGroups := make([]*DataPartition, 0)
+	ecPartitionGroups := make([]*DataPartition, 0)
 	for _, dp := range view.DataPartitions {
-		if dp.Status == proto.ReadWrite {
+		if dp.Status != proto.ReadWrite {
+			continue
+		}
+		// an ec partition is written by stripes, not by the extent writer
+		if dp.PartitionType == proto.ECPartition {
+			ecPartitionGroups = append(ecPartitionGroups, dp)
+		} else {
 			rwPartitionGroups = append(rwPartitionGroups, dp)
 		}
 	}
+	w.ecPartition = ecPartitionGroups
 	if len(rwPartitionGroups) < MinWritableDataPartitionNum {
 		err = fmt.Errorf("action[Wrapper.updateDataPartition] RW partitions[%v] Minimum[%v]", len(rwPartitionGroups), MinWritableDataPartitionNum)
 		log.LogErrorf(err.Error())
@@ -141,7 +188,15 @@ func isExcluded(partitionId uint32, excludes []uint32) bool {
 }
 
 func (w *Wrapper) GetWriteDataPartition(exclude []uint32) (*DataPartition, error) {
-	rwPartitionGroups := w.rwPartition
+	return chooseWriteDataPartition(w.rwPartition, exclude)
+}
+
+// GetECWriteDataPartition returns a writable ec partition, cold extents are moved into it by stripes.
+func (w *Wrapper) GetECWriteDataPartition(exclude []uint32) (*DataPartition, error) {
+	return chooseWriteDataPartition(w.ecPartition, exclude)
+}
+
+func chooseWriteDataPartition(rwPartitionGroups []*DataPartition, exclude []uint32) (*DataPartition, error) {
 	if len(rwPartitionGroups) == 0 {
 		return nil, fmt.Errorf("no writable data partition")
 	}
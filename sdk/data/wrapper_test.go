@@ -0,0 +1,42 @@
+package data
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tiglabs/baudstorage/proto"
+)
+
+func TestUpdateDataPartitionStaleRead(t *testing.T) {
+	var stale []string
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stale = append(stale, r.URL.Query().Get(StaleReadParam))
+		view := &DataPartitionView{}
+		for id := uint32(1); id <= MinWritableDataPartitionNum; id++ {
+			view.DataPartitions = append(view.DataPartitions, &DataPartition{PartitionID: id, Status: proto.ReadWrite,
+				ReplicaNum: 1, Hosts: []string{"127.0.0.1:6000"}})
+		}
+		data, _ := json.Marshal(view)
+		w.Write(data)
+	}))
+	defer master.Close()
+	MasterHelper.AddNode(strings.TrimPrefix(master.URL, "http://"))
+
+	w := &Wrapper{volName: "stale", partitions: make(map[uint32]*DataPartition)}
+	if err := w.updateDataPartition(); err != nil {
+		t.Fatal(err)
+	}
+	w.SetStaleRead(true)
+	if err := w.updateDataPartition(); err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 2 || stale[0] != "" || stale[1] != "true" {
+		t.Fatalf("stale read params %q", stale)
+	}
+	if _, err := w.GetDataPartition(1); err != nil {
+		t.Fatal(err)
+	}
+}
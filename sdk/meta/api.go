@@ -254,6 +254,29 @@ func (mw *MetaWrapper) AppendExtentKey(inode uint64, ek proto.ExtentKey) error {
 	return nil
 }
 
+// Fallocate preallocates the range of the inode, or punches a hole in it with proto.FallocPunchHole.
+func (mw *MetaWrapper) Fallocate(inode uint64, mode uint32, offset, length uint64) error {
+	log.LogDebugf("Fallocate: inode(%v) mode(%v) offset(%v) length(%v)", inode, mode, offset, length)
+	if !mw.SupportFeature(proto.FeatureFallocate) {
+		return syscall.EOPNOTSUPP
+	}
+
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		return syscall.ENOENT
+	}
+
+	status, err := mw.fallocate(mp, inode, mode, offset, length)
+	if err != nil || status != statusOK {
+		log.LogErrorf("Fallocate: inode(%v) err(%v) status(%v)", inode, err, status)
+		if status == statusNoent {
+			return syscall.ENOENT
+		}
+		return syscall.EPERM
+	}
+	return nil
+}
+
 func (mw *MetaWrapper) GetExtents(inode uint64) ([]proto.ExtentKey, error) {
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
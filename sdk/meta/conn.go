@@ -10,8 +10,15 @@ import (
 
 	"github.com/tiglabs/baudstorage/proto"
 	"github.com/tiglabs/baudstorage/util/log"
+	"github.com/tiglabs/baudstorage/util/metrics"
 )
 
+const (
+	MetricsSubsystem = "client"
+)
+
+var opMetrics = metrics.NewOpMetrics(MetricsSubsystem)
+
 const (
 	SendRetryLimit    = 100
 	SendRetryInterval = 100 * time.Millisecond
@@ -57,9 +64,11 @@ func (mw *MetaWrapper) sendToMetaPartition(mp *MetaPartition, req *proto.Packet)
 		addr  string
 		mc    *MetaConn
 		start time.Time
+		begin time.Time
 		op    string
 	)
 
+	begin = time.Now()
 	op = req.GetOpMsg()
 	addr = mp.LeaderAddr
 	if addr == "" {
@@ -100,6 +109,7 @@ retry:
 	}
 
 out:
+	opMetrics.Observe(op, begin, err)
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("sendToMetaPartition faild: mp(%v) op(%v)", mp, req.GetOpMsg()))
 	}
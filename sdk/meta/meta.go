@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tiglabs/baudstorage/util/btree"
@@ -18,6 +19,7 @@ const (
 	MetaPartitionViewURL = "/client/vol"
 	GetVolStatURL        = "/client/volStat"
 	GetClusterInfoURL    = "/admin/getIp"
+	StaleReadParam       = "stale"
 
 	RefreshMetaPartitionsInterval = time.Minute * 5
 )
@@ -51,6 +53,13 @@ type MetaWrapper struct {
 
 	totalSize uint64
 	usedSize  uint64
+
+	// Lowest protocol version and common features of the cluster nodes
+	minVersion uint32
+	features   uint64
+
+	// Whether a follower master may answer the volume view
+	staleRead int32
 }
 
 func NewMetaWrapper(volname, masterHosts string) (*MetaWrapper, error) {
@@ -77,6 +86,21 @@ func (mw *MetaWrapper) Cluster() string {
 	return mw.cluster
 }
 
+// SupportFeature tells if every node of the cluster supports the feature.
+func (mw *MetaWrapper) SupportFeature(feature uint64) bool {
+	return atomic.LoadUint64(&mw.features)&feature == feature
+}
+
+// SetStaleRead lets a follower master answer the volume view, which may lag
+// a little behind the leader but is still available while the leader is lost.
+func (mw *MetaWrapper) SetStaleRead(enable bool) {
+	var staleRead int32
+	if enable {
+		staleRead = 1
+	}
+	atomic.StoreInt32(&mw.staleRead, staleRead)
+}
+
 func (mw *MetaWrapper) umpKey(act string) string {
 	return fmt.Sprintf("%s_sdk_meta_%s", mw.cluster, act)
 }
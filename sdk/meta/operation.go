@@ -405,6 +405,9 @@ func (mw *MetaWrapper) appendExtentKey(mp *MetaPartition, inode uint64, extent p
 		Inode:       inode,
 		Extent:      extent,
 	}
+	if mw.SupportFeature(proto.FeatureFileOffset) {
+		req.Features |= proto.FeatureFileOffset
+	}
 
 	packet := proto.NewPacket()
 	packet.Opcode = proto.OpMetaExtentsAdd
@@ -431,6 +434,41 @@ func (mw *MetaWrapper) appendExtentKey(mp *MetaPartition, inode uint64, extent p
 	return status, nil
 }
 
+func (mw *MetaWrapper) fallocate(mp *MetaPartition, inode uint64, mode uint32, offset, length uint64) (status int, err error) {
+	req := &proto.FallocateRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Mode:        mode,
+		Offset:      offset,
+		Length:      length,
+	}
+
+	packet := proto.NewPacket()
+	packet.Opcode = proto.OpMetaFallocate
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("fallocate: err(%v)", err)
+		return
+	}
+
+	umpKey := mw.umpKey(packet.GetOpMsg())
+	tpObject := ump.BeforeTP(umpKey)
+	defer ump.AfterTP(tpObject, err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("fallocate: mp(%v) req(%v) err(%v)", mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("fallocate: mp(%v) req(%v) result(%v)", mp, *req, packet.GetResultMesg())
+	}
+	return status, nil
+}
+
 func (mw *MetaWrapper) getExtents(mp *MetaPartition, inode uint64) (status int, extents []proto.ExtentKey, err error) {
 	req := &proto.GetExtentsRequest{
 		VolName:     mw.volname,
@@ -8,6 +8,7 @@ import (
 
 	"github.com/juju/errors"
 
+	"github.com/tiglabs/baudstorage/proto"
 	"github.com/tiglabs/baudstorage/util/log"
 )
 
@@ -22,6 +23,8 @@ var (
 type VolumeView struct {
 	VolName        string
 	MetaPartitions []*MetaPartition
+	MinVersion     uint32
+	Features       uint64
 }
 
 type ClusterInfo struct {
@@ -39,6 +42,9 @@ type VolStatInfo struct {
 func (mw *MetaWrapper) PullVolumeView() (*VolumeView, error) {
 	params := make(map[string]string)
 	params["name"] = mw.volname
+	if atomic.LoadInt32(&mw.staleRead) == 1 {
+		params[StaleReadParam] = "true"
+	}
 	body, err := mw.master.Request(http.MethodPost, MetaPartitionViewURL, params, nil)
 	if err != nil {
 		log.LogWarnf("PullVolumeView request: err(%v)", err)
@@ -100,9 +106,21 @@ func (mw *MetaWrapper) UpdateMetaPartitions() error {
 		mw.replaceOrInsertPartition(mp)
 		log.LogInfof("UpdateMetaPartition: mp(%v)", mp)
 	}
+	mw.updateClusterVersion(nv.MinVersion, nv.Features)
 	return nil
 }
 
+func (mw *MetaWrapper) updateClusterVersion(minVersion uint32, features uint64) {
+	if minVersion > proto.Version {
+		log.LogWarnf("updateClusterVersion: cluster min version(%v) is newer than client version(%v)",
+			minVersion, proto.Version)
+	}
+	if old := atomic.SwapUint64(&mw.features, features); old != features {
+		log.LogInfof("updateClusterVersion: min version(%v) features(%v) -> (%v)", minVersion, old, features)
+	}
+	atomic.StoreUint32(&mw.minVersion, minVersion)
+}
+
 func (mw *MetaWrapper) refresh() {
 	t := time.NewTicker(RefreshMetaPartitionsInterval)
 	for {
@@ -4,24 +4,37 @@ import (
 	"encoding/binary"
 	"hash/crc32"
 	"os"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
 
 	"github.com/tiglabs/baudstorage/util"
+	"github.com/tiglabs/baudstorage/util/btree"
 )
 
+// CompactThrottle is called with the size of the next object copied by a compaction,
+// it blocks to rate-limit the compaction.
+type CompactThrottle func(size int)
+
 type Chunk struct {
+	id          int
 	file        *os.File
 	tree        *ObjectTree
 	lastOid     uint64
 	syncLastOid uint64
 	commitLock  sync.RWMutex
 	compactLock util.TryMutex
+	keys        *storeKeys
+	segments    []keySegment
+	generation  uint32
+	segmentLock sync.RWMutex
 }
 
 func NewChunk(dataDir string, chunkId int) (c *Chunk, err error) {
 	c = new(Chunk)
+	c.id = chunkId
+	c.keys = &storeKeys{}
 	name := dataDir + "/" + strconv.Itoa(chunkId)
 	maxOid, err := c.loadTree(name)
 	if err != nil {
@@ -33,14 +46,116 @@ func NewChunk(dataDir string, chunkId int) (c *Chunk, err error) {
 }
 
 func (c *Chunk) applyDelObjects(objects []uint64) (err error) {
-	for _, needle := range objects {
-		c.tree.delete(needle)
-	}
+	c.deleteObjects(objects)
 
 	c.storeSyncLastOid(c.loadLastOid())
 	return
 }
 
+// deleteObjects deletes the objects from the index and punches the whole file system blocks
+// of their data. The tombstones are synced before the punch, so a crash never brings back
+// an object whose data is gone.
+func (c *Chunk) deleteObjects(objects []uint64) (err error) {
+	c.commitLock.RLock()
+	defer c.commitLock.RUnlock()
+	holes := make([]*Object, 0)
+	for _, oid := range objects {
+		offset, size, e := c.tree.delete(oid)
+		if e != nil {
+			err = e
+			continue
+		}
+		if start, end := holeRange(int64(offset), int64(offset)+int64(size)); start < end {
+			holes = append(holes, &Object{Oid: oid, Offset: offset, Size: size})
+		}
+	}
+	if len(holes) == 0 {
+		return
+	}
+	if e := c.tree.idxFile.Sync(); e != nil {
+		return e
+	}
+	for _, o := range holes {
+		c.punchHole(int64(o.Offset), int64(o.Offset)+int64(o.Size))
+	}
+	return
+}
+
+// punchGarbage punches the space between the live objects of the chunk, which is left by
+// the deletions before a restart, the overwritten objects and the failed writes. The live
+// objects keep their offsets, so the index is not rewritten.
+func (c *Chunk) punchGarbage() (punched uint64, err error) {
+	// prevent the writes, whose data is in the file before it is in the index, while the
+	// garbage is gathered
+	if !c.compactLock.TryLock() {
+		return 0, ErrorAgain
+	}
+	// the commit of a compaction, which replaces the file, waits for the punch
+	c.commitLock.RLock()
+	defer c.commitLock.RUnlock()
+	holes, err := c.garbageRanges()
+	c.compactLock.Unlock()
+	if err != nil {
+		return
+	}
+	// the writes append behind the garbage, they go on while it is punched
+	for _, hole := range holes {
+		punched += c.punchHole(hole[0], hole[1])
+	}
+	return
+}
+
+// garbageRanges returns the ranges of the chunk file which no live object is in.
+func (c *Chunk) garbageRanges() (holes [][2]int64, err error) {
+	var fi os.FileInfo
+	if fi, err = c.file.Stat(); err != nil {
+		return
+	}
+	live := make([]*Object, 0)
+	c.tree.idxLock.Lock()
+	c.tree.getTree().Ascend(func(i btree.Item) bool {
+		o := i.(*Object)
+		live = append(live, &Object{Oid: o.Oid, Offset: o.Offset, Size: o.Size})
+		return true
+	})
+	c.tree.idxLock.Unlock()
+	if err = c.tree.idxFile.Sync(); err != nil {
+		return
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].Offset < live[j].Offset })
+
+	var pos int64
+	for _, o := range live {
+		if pos < int64(o.Offset) {
+			holes = append(holes, [2]int64{pos, int64(o.Offset)})
+		}
+		if end := int64(o.Offset) + int64(o.Size); end > pos {
+			pos = end
+		}
+	}
+	if pos < fi.Size() {
+		holes = append(holes, [2]int64{pos, fi.Size()})
+	}
+	return
+}
+
+// punchHole deallocates the whole file system blocks of [start, end) of the chunk file, and
+// returns the size of them. The space is only reclaimed where the file system supports holes.
+func (c *Chunk) punchHole(start, end int64) (punched uint64) {
+	if start, end = holeRange(start, end); start >= end {
+		return
+	}
+	if err := c.tryPunchHole(start, end-start); err != nil {
+		return
+	}
+	return uint64(end - start)
+}
+
+// holeRange shrinks [start, end) to the whole file system blocks in it.
+func holeRange(start, end int64) (int64, int64) {
+	return (start + PunchHoleAlign - 1) / PunchHoleAlign * PunchHoleAlign, end / PunchHoleAlign * PunchHoleAlign
+}
+
 func (c *Chunk) loadTree(name string) (maxOid uint64, err error) {
 	if c.file, err = os.OpenFile(name, ChunkOpenOpt, 0666); err != nil {
 		return
@@ -58,6 +173,11 @@ func (c *Chunk) loadTree(name string) (maxOid uint64, err error) {
 	} else {
 		idxFile.Close()
 		c.file.Close()
+		return
+	}
+	if err = c.loadKeySegments(name); err != nil {
+		idxFile.Close()
+		c.file.Close()
 	}
 
 	return
@@ -118,16 +238,18 @@ func (c *Chunk) storeSyncLastOid(val uint64) {
 	return
 }
 
-func (c *Chunk) doCompact() (err error) {
+func (c *Chunk) doCompact(throttle CompactThrottle) (err error) {
 	var (
 		newIdxFile *os.File
 		newDatFile *os.File
+		newKeyFile *os.File
 		tree       *ObjectTree
 	)
 
 	name := c.file.Name()
 	newIdxName := name + ".cpx"
 	newDatName := name + ".cpd"
+	newKeyName := name + ".cpk"
 	if newIdxFile, err = os.OpenFile(newIdxName, ChunkOpenOpt|os.O_TRUNC, 0644); err != nil {
 		return err
 	}
@@ -138,18 +260,34 @@ func (c *Chunk) doCompact() (err error) {
 	}
 	defer newDatFile.Close()
 
+	// the valid data is encrypted by the current key version from the start of the new chunk,
+	// and by a new generation as the new chunk reuses the offsets of the old one
+	if newKeyFile, err = os.OpenFile(newKeyName, ExtKeyFileOpt|os.O_TRUNC, 0644); err != nil {
+		return err
+	}
+	defer newKeyFile.Close()
+	generation := c.loadGeneration() + 1
+	if err = appendKeyRecord(newKeyFile, chunkGenerationRecord, generation); err != nil {
+		return err
+	}
+	version := c.keys.current()
+	if version != 0 {
+		if err = appendKeyRecord(newKeyFile, 0, version); err != nil {
+			return err
+		}
+	}
+
 	tree = NewObjectTree(newIdxFile)
 
-	if err = c.copyValidData(tree, newDatFile); err != nil {
+	if err = c.copyValidData(tree, newDatFile, version, generation, throttle); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (c *Chunk) copyValidData(dstNm *ObjectTree, dstDatFile *os.File) (err error) {
+func (c *Chunk) copyValidData(dstNm *ObjectTree, dstDatFile *os.File, version, generation uint32, throttle CompactThrottle) (err error) {
 	srcNm := c.tree
-	srcDatFile := c.file
 	srcIdxFile := srcNm.idxFile
 	deletedSet := make(map[uint64]struct{})
 	_, err = WalkIndexFile(srcIdxFile, func(oid uint64, offset, size, crc uint32) error {
@@ -180,12 +318,18 @@ func (c *Chunk) copyValidData(dstNm *ObjectTree, dstDatFile *os.File) (err error
 		}
 
 		realsize := o.Size
+		if throttle != nil {
+			throttle(int(realsize))
+		}
 		if newOffset, e = dstDatFile.Seek(0, 2); e != nil {
 			return e
 		}
 
 		dataInFile := make([]byte, realsize)
-		if _, e = srcDatFile.ReadAt(dataInFile, int64(o.Offset)); e != nil {
+		if _, e = c.readAt(dataInFile, int64(o.Offset)); e != nil {
+			return e
+		}
+		if dataInFile, e = c.encrypt(dataInFile, newOffset, version, generation); e != nil {
 			return e
 		}
 
@@ -222,6 +366,10 @@ func (c *Chunk) doCommit() (err error) {
 	if err != nil {
 		return
 	}
+	err = os.Rename(name+".cpk", name+ChunkKeySuffix)
+	if err != nil {
+		return
+	}
 
 	maxOid, err := c.loadTree(name)
 	if err == nil && maxOid > c.loadLastOid() {
@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"os"
+)
+
+func (c *Chunk) tryPunchHole(off int64, len int64) (err error) {
+	// Do nothing
+	return
+}
+
+func allocatedSize(fi os.FileInfo) int64 {
+	return fi.Size()
+}
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"os"
+	"syscall"
+)
+
+func (c *Chunk) tryPunchHole(off int64, len int64) (err error) {
+	err = syscall.Fallocate(int(c.file.Fd()), FALLOC_FL_PUNCH_HOLE|FALLOC_FL_KEEP_SIZE, off, len)
+	return
+}
+
+// allocatedSize returns the disk space held by the chunk file, which is less than
+// its size once the garbage is punched.
+func allocatedSize(fi os.FileInfo) int64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return st.Blocks * 512
+	}
+	return fi.Size()
+}
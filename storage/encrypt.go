@@ -0,0 +1,379 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/tiglabs/baudstorage/util"
+	"github.com/tiglabs/baudstorage/util/encrypt"
+)
+
+/*
+The data of the extents and chunks of an encrypted vol is encrypted by the data key of the vol
+at its position in the file, see package encrypt. Headers, indexes and crcs stay plain.
+
+An extent is encrypted by the key version current when it is created, the versions are appended
+to EXTENT_KEY. A chunk is appended forever, so a key rotation starts a new segment of the chunk
+encrypted by the new version, the segments are appended to <chunk>.key.
+
+EXTENT_KEY record:          <chunk>.key record:
+
+	+----------+---------+      +--------+---------+
+	| extentId | version |      | offset | version |
+	+----------+---------+      +--------+---------+
+	|    8     |    4    |      |   8    |    4    |
+	+----------+---------+      +--------+---------+
+
+The data written again at a position is encrypted by a new generation. A compaction rewrites a
+chunk from its start, it bumps the generation of the chunk kept by the record of <chunk>.key whose
+offset is chunkGenerationRecord. A block of an extent rewritten by an overwrite, a compression or
+an expansion is encrypted by a new generation of the block, the generations of the blocks of an
+extent are kept by <extent>.gen as BlockCount big endian uint32.
+*/
+const (
+	ExtKeyFileName  = "EXTENT_KEY"
+	ExtKeyFileOpt   = os.O_CREATE | os.O_RDWR | os.O_APPEND
+	ChunkKeySuffix  = ".key"
+	ExtentGenSuffix = ".gen"
+	keyRecordSize   = 12
+
+	chunkGenerationRecord = math.MaxUint64
+)
+
+// storeKeys are the keys of a store shared with its files, they are replaced when the vol rotates its key.
+type storeKeys struct {
+	sync.RWMutex
+	keys *encrypt.Keys
+}
+
+func (k *storeKeys) set(keys *encrypt.Keys) {
+	k.Lock()
+	defer k.Unlock()
+	k.keys = keys
+}
+
+func (k *storeKeys) current() uint32 {
+	k.RLock()
+	defer k.RUnlock()
+	return k.keys.Current()
+}
+
+func (k *storeKeys) cipher(version uint32) (*encrypt.Cipher, error) {
+	k.RLock()
+	defer k.RUnlock()
+	return k.keys.Cipher(version)
+}
+
+func appendKeyRecord(f *os.File, id uint64, version uint32) (err error) {
+	buf := make([]byte, keyRecordSize)
+	binary.BigEndian.PutUint64(buf[:8], id)
+	binary.BigEndian.PutUint32(buf[8:], version)
+	if _, err = f.Write(buf); err != nil {
+		return
+	}
+	return f.Sync()
+}
+
+func walkKeyRecords(name string, f func(id uint64, version uint32)) (err error) {
+	var data []byte
+	if data, err = ioutil.ReadFile(name); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	reader := bytes.NewReader(data[:len(data)/keyRecordSize*keyRecordSize])
+	for {
+		var (
+			id      uint64
+			version uint32
+		)
+		if err = binary.Read(reader, binary.BigEndian, &id); err == io.EOF {
+			return nil
+		}
+		binary.Read(reader, binary.BigEndian, &version)
+		f(id, version)
+	}
+}
+
+// SetKeys sets the keys of the vol, nil keys store the extents created from now on plain.
+func (s *ExtentStore) SetKeys(keys *encrypt.Keys) {
+	s.keys.set(keys)
+}
+
+func (s *ExtentStore) loadExtentKeys() (err error) {
+	s.extentKeys = make(map[uint64]uint32)
+	name := path.Join(s.dataDir, ExtKeyFileName)
+	if err = walkKeyRecords(name, func(id uint64, version uint32) { s.extentKeys[id] = version }); err != nil {
+		return
+	}
+	s.keyFp, err = os.OpenFile(name, ExtKeyFileOpt, 0666)
+	return
+}
+
+func (s *ExtentStore) getExtentKey(extentId uint64) uint32 {
+	s.extentKeyLock.RLock()
+	defer s.extentKeyLock.RUnlock()
+	return s.extentKeys[extentId]
+}
+
+// setExtentKey records the key version of a new extent.
+func (s *ExtentStore) setExtentKey(extentId uint64, version uint32) (err error) {
+	s.extentKeyLock.Lock()
+	defer s.extentKeyLock.Unlock()
+	if s.extentKeys[extentId] == version {
+		return
+	}
+	if err = appendKeyRecord(s.keyFp, extentId, version); err != nil {
+		return
+	}
+	s.extentKeys[extentId] = version
+	return
+}
+
+func (e *fsExtent) cipher() (*encrypt.Cipher, error) {
+	if e.keyVersion == 0 {
+		return nil, nil
+	}
+	return e.keys.cipher(e.keyVersion)
+}
+
+// readAt reads the data of the extent file at a file offset and decrypts it.
+func (e *fsExtent) readAt(data []byte, offset int64) (n int, err error) {
+	var c *encrypt.Cipher
+	if c, err = e.cipher(); err != nil {
+		return
+	}
+	n, err = e.file.ReadAt(data, offset)
+	if c != nil {
+		e.xorKeyStreamAt(c, data[:n], data[:n], offset)
+	}
+	return
+}
+
+// writeAt encrypts the data and writes it to the extent file at a file offset.
+func (e *fsExtent) writeAt(data []byte, offset int64) (n int, err error) {
+	var c *encrypt.Cipher
+	if c, err = e.cipher(); err != nil {
+		return
+	}
+	if c != nil {
+		buf := make([]byte, len(data))
+		e.xorKeyStreamAt(c, buf, data, offset)
+		data = buf
+	}
+	return e.file.WriteAt(data, offset)
+}
+
+// xorKeyStreamAt encrypts or decrypts the data at a file offset by the generations of its blocks.
+func (e *fsExtent) xorKeyStreamAt(c *encrypt.Cipher, dst, src []byte, offset int64) {
+	for done := 0; done < len(src); {
+		blockNo := int((offset + int64(done) - util.BlockHeaderSize) / util.BlockSize)
+		size := util.Min(len(src)-done, int(blockSlot(blockNo+1)-offset-int64(done)))
+		c.XORKeyStreamAt(dst[done:done+size], src[done:done+size], e.extentId, e.blockGeneration(blockNo),
+			offset+int64(done))
+		done += size
+	}
+}
+
+func (e *fsExtent) blockGeneration(blockNo int) uint32 {
+	if e.blockGens == nil {
+		return 0
+	}
+	return e.blockGens[blockNo]
+}
+
+func (e *fsExtent) loadBlockGenerations() (err error) {
+	var data []byte
+	if data, err = ioutil.ReadFile(e.filePath + ExtentGenSuffix); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	e.blockGens = make([]uint32, util.BlockCount)
+	for no := 0; no < util.BlockCount && (no+1)*4 <= len(data); no++ {
+		e.blockGens[no] = binary.BigEndian.Uint32(data[no*4:])
+	}
+	return
+}
+
+// setBlockGeneration puts a block back to a generation it had, the block journal of the
+// extent restores the block encrypted by it.
+func (e *fsExtent) setBlockGeneration(blockNo int, gen uint32) (err error) {
+	if e.blockGeneration(blockNo) == gen {
+		return
+	}
+	if e.blockGens == nil {
+		e.blockGens = make([]uint32, util.BlockCount)
+	}
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, gen)
+	var f *os.File
+	if f, err = os.OpenFile(e.filePath+ExtentGenSuffix, os.O_CREATE|os.O_RDWR, 0666); err != nil {
+		return
+	}
+	defer f.Close()
+	if _, err = f.WriteAt(buf, int64(blockNo)*4); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	e.blockGens[blockNo] = gen
+	return
+}
+
+// renewBlockGenerations moves the blocks of an encrypted extent to new generations before
+// they are written again, it is called under the write lock of the extent. The data they hold
+// is not readable until it is written again, a crash in between leaves blocks the scrub repairs.
+func (e *fsExtent) renewBlockGenerations(startBlockNo, endBlockNo int) (err error) {
+	if e.keyVersion == 0 {
+		return
+	}
+	if e.blockGens == nil {
+		e.blockGens = make([]uint32, util.BlockCount)
+	}
+	buf := make([]byte, (endBlockNo-startBlockNo+1)*4)
+	for no := startBlockNo; no <= endBlockNo; no++ {
+		binary.BigEndian.PutUint32(buf[(no-startBlockNo)*4:], e.blockGens[no]+1)
+	}
+	var f *os.File
+	if f, err = os.OpenFile(e.filePath+ExtentGenSuffix, os.O_CREATE|os.O_RDWR, 0666); err != nil {
+		return
+	}
+	defer f.Close()
+	if _, err = f.WriteAt(buf, int64(startBlockNo)*4); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	for no := startBlockNo; no <= endBlockNo; no++ {
+		e.blockGens[no]++
+	}
+	return
+}
+
+// rewriteBlocks overwrites the data of an encrypted extent at an offset of the data. The blocks
+// it touches are encrypted again as a whole by their new generations.
+func (e *fsExtent) rewriteBlocks(data []byte, offset int64) (n int, err error) {
+	startBlockNo := int(offset / util.BlockSize)
+	endBlockNo := int((offset + int64(len(data)) - 1) / util.BlockSize)
+	// the data held by the blocks after the range is kept
+	start := startBlockNo * util.BlockSize
+	end := util.Max(int(offset)+len(data), util.Min(int(e.dataSize), (endBlockNo+1)*util.BlockSize))
+	buf := make([]byte, end-start)
+	if _, err = e.readAt(buf[:util.Min(int(e.dataSize), end)-start], blockSlot(startBlockNo)); err != nil && err != io.EOF {
+		return
+	}
+	copy(buf[int(offset)-start:], data)
+	if err = e.renewBlockGenerations(startBlockNo, endBlockNo); err != nil {
+		return
+	}
+	if _, err = e.writeAt(buf, blockSlot(startBlockNo)); err != nil {
+		return
+	}
+	return len(data), nil
+}
+
+// SetKeys sets the keys of the vol, nil keys store the data appended from now on plain.
+func (s *TinyStore) SetKeys(keys *encrypt.Keys) {
+	s.keys.set(keys)
+}
+
+type keySegment struct {
+	offset  int64
+	version uint32
+}
+
+func (c *Chunk) loadKeySegments(name string) (err error) {
+	var generation uint32
+	segments := make([]keySegment, 0)
+	err = walkKeyRecords(name+ChunkKeySuffix, func(offset uint64, version uint32) {
+		if offset == chunkGenerationRecord {
+			generation = version
+			return
+		}
+		segments = append(segments, keySegment{offset: int64(offset), version: version})
+	})
+	if err != nil {
+		return
+	}
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].offset < segments[j].offset })
+	c.segmentLock.Lock()
+	c.segments = segments
+	c.generation = generation
+	c.segmentLock.Unlock()
+	return
+}
+
+// loadGeneration returns the generation of the chunk file, which its data is encrypted by.
+func (c *Chunk) loadGeneration() uint32 {
+	c.segmentLock.RLock()
+	defer c.segmentLock.RUnlock()
+	return c.generation
+}
+
+// keyVersionAt returns the key version of the segment holding a file offset.
+func (c *Chunk) keyVersionAt(offset int64) (version uint32) {
+	c.segmentLock.RLock()
+	defer c.segmentLock.RUnlock()
+	for _, s := range c.segments {
+		if s.offset > offset {
+			break
+		}
+		version = s.version
+	}
+	return
+}
+
+// startKeySegment makes the data appended from offset encrypted by the version.
+func (c *Chunk) startKeySegment(offset int64, version uint32) (err error) {
+	if c.keyVersionAt(offset) == version {
+		return
+	}
+	c.segmentLock.Lock()
+	defer c.segmentLock.Unlock()
+	var f *os.File
+	if f, err = os.OpenFile(c.file.Name()+ChunkKeySuffix, ExtKeyFileOpt, 0666); err != nil {
+		return
+	}
+	defer f.Close()
+	if err = appendKeyRecord(f, uint64(offset), version); err != nil {
+		return
+	}
+	c.segments = append(c.segments, keySegment{offset: offset, version: version})
+	return
+}
+
+// readAt reads the data of an object at a file offset and decrypts it.
+func (c *Chunk) readAt(data []byte, offset int64) (n int, err error) {
+	var ci *encrypt.Cipher
+	if ci, err = c.keys.cipher(c.keyVersionAt(offset)); err != nil {
+		return
+	}
+	n, err = c.file.ReadAt(data, offset)
+	if ci != nil {
+		ci.XORKeyStreamAt(data[:n], data[:n], uint64(c.id), c.loadGeneration(), offset)
+	}
+	return
+}
+
+// encrypt encrypts the data to write at a file offset of a generation by the given key version.
+func (c *Chunk) encrypt(data []byte, offset int64, version, generation uint32) (out []byte, err error) {
+	var ci *encrypt.Cipher
+	if ci, err = c.keys.cipher(version); err != nil || ci == nil {
+		return data, err
+	}
+	out = make([]byte, len(data))
+	ci.XORKeyStreamAt(out, data, uint64(c.id), generation, offset)
+	return
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/tiglabs/baudstorage/util"
+	"github.com/tiglabs/baudstorage/util/encrypt"
+)
+
+// readEncryptedExtent reads a range across the first two blocks of an extent written by writeTestExtent.
+func readEncryptedExtent(t *testing.T, store *ExtentStore, extentId uint64, data []byte) error {
+	buf := make([]byte, 1500)
+	crc, err := store.Read(extentId, util.BlockSize-500, int64(len(buf)), buf)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(buf, data[util.BlockSize-500:util.BlockSize+1000]) || crc != crc32.ChecksumIEEE(buf) {
+		t.Fatalf("extent %v read mismatch", extentId)
+	}
+	return nil
+}
+
+func TestExtentStore_Encrypt(t *testing.T) {
+	store, dir := newTestExtentStore(t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+	key, _ := encrypt.NewDataKey()
+	keys, err := encrypt.NewKeys(map[uint32][]byte{1: key}, "extent/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetKeys(keys)
+	extentId, data := writeTestExtent(t, store, util.BlockSize+1000)
+
+	if err = readEncryptedExtent(t, store, extentId, data); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := ioutil.ReadFile(testExtentPath(store, extentId))
+	if bytes.Contains(stored, data[:64]) {
+		t.Fatal("extent data is stored plain")
+	}
+}
+
+func TestExtentStore_EncryptRestart(t *testing.T) {
+	store, dir := newTestExtentStore(t)
+	defer os.RemoveAll(dir)
+	key, _ := encrypt.NewDataKey()
+	keys, _ := encrypt.NewKeys(map[uint32][]byte{1: key}, "extent/1")
+	store.SetKeys(keys)
+	extentId, data := writeTestExtent(t, store, util.BlockSize+1000)
+	store.Close()
+
+	// the key version of the extent survives a restart, the data is not readable without the key
+	store, err := NewExtentStore(dir, util.GB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err = readEncryptedExtent(t, store, extentId, data); err == nil {
+		t.Fatal("encrypted extent read without key")
+	}
+	store.SetKeys(keys)
+	if err = readEncryptedExtent(t, store, extentId, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtentStore_EncryptKeyRotation(t *testing.T) {
+	store, dir := newTestExtentStore(t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+	key1, _ := encrypt.NewDataKey()
+	key2, _ := encrypt.NewDataKey()
+	keys, _ := encrypt.NewKeys(map[uint32][]byte{1: key1}, "extent/1")
+	store.SetKeys(keys)
+	extentId, data := writeTestExtent(t, store, util.BlockSize+1000)
+
+	// a rotated key encrypts the new extents only
+	keys, _ = encrypt.NewKeys(map[uint32][]byte{1: key1, 2: key2}, "extent/1")
+	store.SetKeys(keys)
+	newExtentId, newData := writeTestExtent(t, store, util.BlockSize+1000)
+	if store.getExtentKey(extentId) != 1 || store.getExtentKey(newExtentId) != 2 {
+		t.Fatalf("key versions %v %v", store.getExtentKey(extentId), store.getExtentKey(newExtentId))
+	}
+	if err := readEncryptedExtent(t, store, extentId, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := readEncryptedExtent(t, store, newExtentId, newData); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func xorBytes(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+func TestEncrypt_RewriteExtentKeyStream(t *testing.T) {
+	store, dir := newTestExtentStore(t)
+	defer os.RemoveAll(dir)
+	key, _ := encrypt.NewDataKey()
+	keys, _ := encrypt.NewKeys(map[uint32][]byte{1: key}, "extent/1")
+	store.SetKeys(keys)
+	extentId, first := writeTestExtent(t, store, util.BlockSize)
+
+	// an extent block overwritten in place
+	name := testExtentPath(store, extentId)
+	stored, _ := ioutil.ReadFile(name)
+	firstStream := xorBytes(stored[util.BlockHeaderSize+100:util.BlockHeaderSize+1100], first[100:1100])
+	second := make([]byte, 1000)
+	rand.Read(second)
+	if err := store.Overwrite(extentId, 100, 1000, second, crc32.ChecksumIEEE(second)); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ = ioutil.ReadFile(name)
+	if bytes.Equal(xorBytes(stored[util.BlockHeaderSize+100:util.BlockHeaderSize+1100], second), firstStream) {
+		t.Fatal("overwritten block reuses the key stream")
+	}
+	copy(first[100:], second)
+	store.Close()
+
+	// the generations of the blocks survive a restart
+	store, err := NewExtentStore(dir, util.GB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.SetKeys(keys)
+	buf := make([]byte, util.BlockSize)
+	if _, err = store.Read(extentId, 0, util.BlockSize, buf); err != nil || !bytes.Equal(buf, first) {
+		t.Fatalf("overwritten block read %v", err)
+	}
+}
+
+func TestEncrypt_RewriteChunkKeyStream(t *testing.T) {
+	tiny, dir := newTestTinyStore(t)
+	defer os.RemoveAll(dir)
+	defer tiny.CloseAll()
+	key, _ := encrypt.NewDataKey()
+	keys, _ := encrypt.NewKeys(map[uint32][]byte{1: key}, "extent/1")
+	tiny.SetKeys(keys)
+	const chunkId = 1
+	objects := writeTestObjects(t, tiny, chunkId, 2, 1000)
+
+	// a chunk offset written again after a compaction
+	name := path.Join(dir, strconv.Itoa(chunkId))
+	stored, _ := ioutil.ReadFile(name)
+	firstStream := xorBytes(stored[:1000], objects[1])
+	if err := tiny.MarkDelete(chunkId, 1, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err, _ := tiny.DoCompactWork(chunkId, nil); err != nil {
+		t.Fatal(err)
+	}
+	// the second object is moved to the offset of the first one
+	stored, _ = ioutil.ReadFile(name)
+	if len(stored) != 1000 || bytes.Equal(xorBytes(stored, objects[2]), firstStream) {
+		t.Fatal("compacted chunk reuses the key stream")
+	}
+	buf := make([]byte, 1000)
+	if _, err := tiny.Read(chunkId, 2, 1000, buf); err != nil || !bytes.Equal(buf, objects[2]) {
+		t.Fatalf("compacted object read %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/juju/errors"
 	"github.com/tiglabs/baudstorage/util"
+	"github.com/tiglabs/baudstorage/util/compress"
 	"hash/crc32"
 	"io"
 	"math"
@@ -95,6 +96,23 @@ type Extent interface {
 	// HeaderChecksum returns crc checksum value of extent header data
 	// include inode data and block crc.
 	HeaderChecksum() (crc uint32)
+
+	// Scrub re-reads all data of the extent and checks it against the block crc.
+	Scrub(throttle ScrubThrottle) (result *ScrubResult, err error)
+
+	// CompressBlock stores a full block of the extent compressed, it returns the stored size
+	// or 0 if the block is kept raw.
+	CompressBlock(blockNo int, codec compress.Codec) (storedSize int, err error)
+
+	// PunchHole deallocates a range of the extent data, the extent keeps its size.
+	PunchHole(offset, size int64) (err error)
+
+	// BlockCrc returns the crc of the block stored in the header.
+	BlockCrc(blockNo int) (crc uint32)
+
+	// SendBlock sends a whole block from the extent file to the connection after the header
+	// written with the crc of the block.
+	SendBlock(blockNo int, conn syscall.Conn, writeHeader func(crc uint32) error) (err error)
 }
 
 // FSExtent is an implementation of Extent for local regular extent file data management.
@@ -110,6 +128,14 @@ type fsExtent struct {
 	dataSize   int64
 	closeC     chan bool
 	closed     bool
+	// blockStates tells whether every block is stored raw or compressed
+	blockStates []uint32
+	lastBlock   decompressedBlock
+	// keyVersion is the version of the key the data is encrypted by, 0 for plain data
+	keys       *storeKeys
+	keyVersion uint32
+	// blockGens are the generations the blocks are encrypted by, nil until a block is rewritten
+	blockGens []uint32
 }
 
 // NewExtentInCore create and returns a new extent instance.
@@ -118,6 +144,8 @@ func NewExtentInCore(name string, extentId uint64) Extent {
 	e.extentId = extentId
 	e.filePath = name
 	e.header = make([]byte, util.BlockHeaderSize)
+	e.blockStates = make([]uint32, util.BlockCount)
+	e.keys = &storeKeys{}
 	e.closeC = make(chan bool)
 	return e
 }
@@ -173,6 +201,12 @@ func (e *fsExtent) InitToFS(ino uint64, overwrite bool) (err error) {
 	if _, err = e.file.WriteAt(inodeData, 0); err != nil {
 		return
 	}
+	// the data written again from the start is encrypted by new generations
+	if overwrite {
+		if err = e.renewBlockGenerations(0, util.BlockCount-1); err != nil {
+			return
+		}
+	}
 	emptyCrc := crc32.ChecksumIEEE(make([]byte, util.BlockSize))
 	for blockNo := 0; blockNo < util.BlockCount; blockNo++ {
 		if err = e.updateBlockCrc(blockNo, emptyCrc); err != nil {
@@ -222,7 +256,10 @@ func (e *fsExtent) RestoreFromFS() (err error) {
 	}
 	e.dataSize = info.Size() - util.BlockHeaderSize
 	e.modifyTime = info.ModTime()
-	return
+	if err = e.loadBlockGenerations(); err != nil {
+		return
+	}
+	return e.recoverBlockJournal()
 }
 
 // MarkDelete mark this extent as deleted.
@@ -267,17 +304,47 @@ func (e *fsExtent) Write(data []byte, offset, size int64, crc uint32) (err error
 	var (
 		writeSize int
 	)
+	endBlockNo := int((offset + size - 1) / util.BlockSize)
+	// the overwrites of the written blocks read their rest back for the crc, they are serialized
+	// with each other and the scrub so that the crc matches the data
 	e.lock.RLock()
-	defer e.lock.RUnlock()
+	overwrite := offset < e.dataSize
+	e.lock.RUnlock()
+	if overwrite {
+		e.lock.Lock()
+		defer e.lock.Unlock()
+	} else {
+		e.lock.RLock()
+		defer e.lock.RUnlock()
+	}
+	// a compressed block partially overwritten is stored raw again first, under the same
+	// lock so that it is not compressed again before the write
+	if overwrite && (offset%util.BlockSize != 0 || size != util.BlockSize) {
+		for no := int(offset / util.BlockSize); no <= endBlockNo; no++ {
+			if err = e.expandBlock(no); err != nil {
+				return
+			}
+		}
+	}
 
-	if writeSize, err = e.file.WriteAt(data[:size], int64(offset+util.BlockHeaderSize)); err != nil {
+	if overwrite && e.keyVersion != 0 {
+		writeSize, err = e.rewriteBlocks(data[:size], offset)
+	} else {
+		writeSize, err = e.writeAt(data[:size], int64(offset+util.BlockHeaderSize))
+	}
+	if err != nil {
 		return
 	}
 	blockNo := offset / util.BlockSize
 	offsetInBlock := offset % util.BlockSize
+	for no := int(blockNo); no <= endBlockNo; no++ {
+		e.setBlockRaw(no)
+	}
+	// the crc of the data is the one of its block unless it overwrites a part of the block
+	wholeBlock := size == util.BlockSize || offset+size >= e.dataSize
 	e.dataSize = int64(math.Max(float64(e.dataSize), float64(offset+size)))
 	e.modifyTime = time.Now()
-	if offsetInBlock == 0 {
+	if offsetInBlock == 0 && wholeBlock {
 		return e.updateBlockCrc(int(blockNo), crc)
 	}
 	blockBuffer := make([]byte, util.BlockSize)
@@ -286,7 +353,7 @@ func (e *fsExtent) Write(data []byte, offset, size int64, crc uint32) (err error
 		if remainCheckByteCnt <= 0 {
 			break
 		}
-		readN, readErr := e.file.ReadAt(blockBuffer, int64(blockNo*util.BlockSize+util.BlockHeaderSize))
+		readN, readErr := e.readAt(blockBuffer, int64(blockNo*util.BlockSize+util.BlockHeaderSize))
 		if readErr != nil && readErr != io.EOF {
 			err = readErr
 			return
@@ -317,7 +384,7 @@ func (e *fsExtent) Read(data []byte, offset, size int64) (crc uint32, err error)
 	var (
 		readN int
 	)
-	if readN, err = e.file.ReadAt(data[:size], offset+util.BlockHeaderSize); err != nil {
+	if readN, err = e.readData(data[:size], offset); err != nil {
 		return
 	}
 	if offset%util.BlockSize == 0 && readN == util.BlockSize {
@@ -329,6 +396,37 @@ func (e *fsExtent) Read(data []byte, offset, size int64) (crc uint32, err error)
 	return
 }
 
+// readData reads the raw data of the extent, the compressed blocks are decompressed.
+func (e *fsExtent) readData(data []byte, offset int64) (readN int, err error) {
+	for readN < len(data) {
+		blockNo := int((offset + int64(readN)) / util.BlockSize)
+		offsetInBlock := int((offset + int64(readN)) % util.BlockSize)
+		size := util.BlockSize - offsetInBlock
+		if size > len(data)-readN {
+			size = len(data) - readN
+		}
+		if !e.isCompressed(blockNo) {
+			var n int
+			n, err = e.readAt(data[readN:readN+size], offset+int64(readN)+util.BlockHeaderSize)
+			readN += n
+			if err != nil {
+				return
+			}
+			continue
+		}
+		var block []byte
+		if block, err = e.decompressedData(blockNo); err != nil {
+			return
+		}
+		if offsetInBlock+size > len(block) {
+			readN += copy(data[readN:], block[offsetInBlock:])
+			return readN, io.EOF
+		}
+		readN += copy(data[readN:readN+size], block[offsetInBlock:])
+	}
+	return
+}
+
 func (e *fsExtent) updateBlockCrc(blockNo int, crc uint32) (err error) {
 	startIdx := util.BlockHeaderCrcIndex + blockNo*util.PerBlockCrcSize
 	endIdx := startIdx + util.PerBlockCrcSize
@@ -340,6 +438,12 @@ func (e *fsExtent) updateBlockCrc(blockNo int, crc uint32) (err error) {
 	return
 }
 
+func (e *fsExtent) BlockCrc(blockNo int) (crc uint32) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.getBlockCrc(blockNo)
+}
+
 func (e *fsExtent) getBlockCrc(blockNo int) (crc uint32) {
 	startIdx := util.BlockHeaderCrcIndex + blockNo*util.PerBlockCrcSize
 	endIdx := startIdx + util.PerBlockCrcSize
@@ -0,0 +1,341 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tiglabs/baudstorage/util"
+	"github.com/tiglabs/baudstorage/util/compress"
+)
+
+/*
+A block of an extent keeps its slot of util.BlockSize bytes in the extent file, so the
+offset of any block is known without an index. A full block may be stored compressed:
+its slot then starts with a header and the compressed data, and the rest of the slot is
+a hole punched in the file. The block crc in the extent header is the crc of the raw data.
+
+Compressed block header:
+
+	+-------+-------+---------+----------------+
+	| magic | codec | rawSize | compressedSize |
+	+-------+-------+---------+----------------+
+	|   4   |   1   |    4    |       4        |
+	+-------+-------+---------+----------------+
+*/
+const (
+	CompressHeaderSize = 13
+	// ExtentBlockJournalSuffix names the journal of the block of an extent being rewritten
+	ExtentBlockJournalSuffix = ".bjn"
+	blockJournalHeaderSize   = 12
+	compressMagic            = 0x42535a43 // "BSZC"
+	// a block is kept raw unless compression saves this much of its slot
+	minCompressSaving = util.BlockSize / 8
+)
+
+// States of a block in fsExtent.blockStates, a block is detected on first access.
+const (
+	blockUnknown uint32 = iota
+	blockRaw
+	blockCompressed
+)
+
+var errNotCompressed = errors.New("block is not compressed")
+
+// CompressStat counts the blocks compressed by a store since it is loaded.
+type CompressStat struct {
+	Blocks      uint64
+	RawBytes    uint64
+	StoredBytes uint64
+}
+
+// decompressedBlock caches the last decompressed block of an extent, the stream reads
+// of the clients read a block in several packets.
+type decompressedBlock struct {
+	sync.Mutex
+	blockNo int
+	data    []byte
+}
+
+func blockSlot(blockNo int) int64 {
+	return util.BlockHeaderSize + int64(blockNo)*util.BlockSize
+}
+
+func (e *fsExtent) isCompressed(blockNo int) bool {
+	switch atomic.LoadUint32(&e.blockStates[blockNo]) {
+	case blockRaw:
+		return false
+	case blockCompressed:
+		return true
+	}
+	if _, err := e.cipher(); err != nil {
+		// detected once the key is loaded
+		return false
+	}
+	state := blockRaw
+	if _, err := e.readCompressedBlock(blockNo); err == nil {
+		state = blockCompressed
+	}
+	atomic.StoreUint32(&e.blockStates[blockNo], state)
+	return state == blockCompressed
+}
+
+// readCompressedBlock returns the decompressed data of a block. Raw data which happens to
+// start with the magic is told apart by the block crc, which is computed from the raw data.
+func (e *fsExtent) readCompressedBlock(blockNo int) (data []byte, err error) {
+	header := make([]byte, CompressHeaderSize)
+	if _, err = e.readAt(header, blockSlot(blockNo)); err != nil {
+		return
+	}
+	codec := compress.Codec(header[4])
+	rawSize := int(binary.BigEndian.Uint32(header[5:9]))
+	size := int(binary.BigEndian.Uint32(header[9:13]))
+	if binary.BigEndian.Uint32(header[0:4]) != compressMagic || !codec.Valid() ||
+		rawSize > util.BlockSize || size > util.BlockSize-CompressHeaderSize {
+		return nil, errNotCompressed
+	}
+	payload := make([]byte, size)
+	if _, err = e.readAt(payload, blockSlot(blockNo)+CompressHeaderSize); err != nil {
+		return
+	}
+	data = make([]byte, rawSize)
+	if err = codec.Decompress(data, payload); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(data) != e.getBlockCrc(blockNo) {
+		return nil, ErrorCompressedBlockCrc
+	}
+	return
+}
+
+// decompressedData returns the raw data of a compressed block.
+func (e *fsExtent) decompressedData(blockNo int) (data []byte, err error) {
+	cache := &e.lastBlock
+	cache.Lock()
+	defer cache.Unlock()
+	if cache.data != nil && cache.blockNo == blockNo {
+		return cache.data, nil
+	}
+	if data, err = e.readCompressedBlock(blockNo); err != nil {
+		return
+	}
+	cache.blockNo, cache.data = blockNo, data
+	return
+}
+
+func (e *fsExtent) setBlockRaw(blockNo int) {
+	atomic.StoreUint32(&e.blockStates[blockNo], blockRaw)
+	e.lastBlock.Lock()
+	if e.lastBlock.blockNo == blockNo {
+		e.lastBlock.data = nil
+	}
+	e.lastBlock.Unlock()
+}
+
+// expandBlock stores a compressed block raw again, before it is partially overwritten.
+// The caller holds the write lock of the extent.
+func (e *fsExtent) expandBlock(blockNo int) (err error) {
+	if !e.isCompressed(blockNo) {
+		return
+	}
+	var data []byte
+	if data, err = e.decompressedData(blockNo); err != nil {
+		return
+	}
+	if err = e.rewriteBlock(blockNo, data); err != nil {
+		return
+	}
+	e.setBlockRaw(blockNo)
+	return
+}
+
+// CompressBlock stores a full block compressed by the codec and punches a hole in the rest of
+// its slot. A block which is not full, does not match its crc or does not compress well is kept.
+func (e *fsExtent) CompressBlock(blockNo int, codec compress.Codec) (storedSize int, err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.isCompressed(blockNo) {
+		return
+	}
+	data := make([]byte, util.BlockSize)
+	var readN int
+	if readN, err = e.readAt(data, blockSlot(blockNo)); err != nil && err != io.EOF {
+		return
+	}
+	err = nil
+	if readN != util.BlockSize || crc32.ChecksumIEEE(data) != e.getBlockCrc(blockNo) {
+		return
+	}
+	var payload []byte
+	if payload, err = codec.Compress(data); err != nil {
+		return
+	}
+	if CompressHeaderSize+len(payload) > util.BlockSize-minCompressSaving {
+		return
+	}
+	buf := make([]byte, CompressHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], compressMagic)
+	buf[4] = byte(codec)
+	binary.BigEndian.PutUint32(buf[5:9], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[9:13], uint32(len(payload)))
+	copy(buf[CompressHeaderSize:], payload)
+	if err = e.rewriteBlock(blockNo, buf); err != nil {
+		return
+	}
+	atomic.StoreUint32(&e.blockStates[blockNo], blockCompressed)
+	storedSize = len(buf)
+	holeStart := blockSlot(blockNo) + int64(storedSize)
+	// the space is only reclaimed where the file system supports holes
+	e.tryPunchHole(int(e.file.Fd()), holeStart, blockSlot(blockNo+1)-holeStart)
+	return
+}
+
+/*
+rewriteBlock stores a block in its other form, raw or compressed, at the start of its slot.
+The slot as stored and the generation of the block are kept in the block journal of the
+extent first, the journal is only dropped once the new form is synced. A crash in between
+leaves the journal, which puts the block back in its old form when the extent is loaded.
+The caller holds the write lock of the extent.
+
+Block journal:
+
+	+---------+------------+-----+----------------+
+	| blockNo | generation | crc |      slot      |
+	+---------+------------+-----+----------------+
+	|    4    |     4      |  4  | util.BlockSize |
+	+---------+------------+-----+----------------+
+*/
+func (e *fsExtent) rewriteBlock(blockNo int, data []byte) (err error) {
+	slot := make([]byte, util.BlockSize)
+	var readN int
+	if readN, err = e.file.ReadAt(slot, blockSlot(blockNo)); err != nil && err != io.EOF {
+		return
+	}
+	if err = e.writeBlockJournal(blockNo, slot[:readN]); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			// the block is put back in its old form at once
+			if rollbackErr := e.recoverBlockJournal(); rollbackErr != nil {
+				err = fmt.Errorf("%v, rollback: %v", err, rollbackErr)
+			}
+		}
+	}()
+	if err = e.renewBlockGenerations(blockNo, blockNo); err != nil {
+		return
+	}
+	if _, err = e.writeAt(data, blockSlot(blockNo)); err != nil {
+		return
+	}
+	if err = e.file.Sync(); err != nil {
+		return
+	}
+	return os.Remove(e.filePath + ExtentBlockJournalSuffix)
+}
+
+func (e *fsExtent) writeBlockJournal(blockNo int, slot []byte) (err error) {
+	buf := make([]byte, blockJournalHeaderSize+len(slot))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(blockNo))
+	binary.BigEndian.PutUint32(buf[4:8], e.blockGeneration(blockNo))
+	binary.BigEndian.PutUint32(buf[8:12], crc32.ChecksumIEEE(slot))
+	copy(buf[blockJournalHeaderSize:], slot)
+	var f *os.File
+	if f, err = os.OpenFile(e.filePath+ExtentBlockJournalSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666); err != nil {
+		return
+	}
+	if _, err = f.Write(buf); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return
+	}
+	return syncDir(path.Dir(e.filePath))
+}
+
+// recoverBlockJournal puts the block of the journal back as it was stored before it was
+// rewritten, a journal which is not complete was written before the block was touched.
+func (e *fsExtent) recoverBlockJournal() (err error) {
+	var buf []byte
+	journalPath := e.filePath + ExtentBlockJournalSuffix
+	if buf, err = ioutil.ReadFile(journalPath); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	if len(buf) >= blockJournalHeaderSize && len(buf) <= blockJournalHeaderSize+util.BlockSize &&
+		binary.BigEndian.Uint32(buf[8:12]) == crc32.ChecksumIEEE(buf[blockJournalHeaderSize:]) {
+		blockNo := int(binary.BigEndian.Uint32(buf[0:4]))
+		if blockNo >= util.BlockCount {
+			return fmt.Errorf("block journal of %v holds block %v", e.filePath, blockNo)
+		}
+		if err = e.setBlockGeneration(blockNo, binary.BigEndian.Uint32(buf[4:8])); err != nil {
+			return
+		}
+		if _, err = e.file.WriteAt(buf[blockJournalHeaderSize:], blockSlot(blockNo)); err != nil {
+			return
+		}
+		if err = e.file.Sync(); err != nil {
+			return
+		}
+		atomic.StoreUint32(&e.blockStates[blockNo], blockUnknown)
+		e.lastBlock.Lock()
+		if e.lastBlock.blockNo == blockNo {
+			e.lastBlock.data = nil
+		}
+		e.lastBlock.Unlock()
+	}
+	return os.Remove(journalPath)
+}
+
+func syncDir(dir string) (err error) {
+	var f *os.File
+	if f, err = os.Open(dir); err != nil {
+		return
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// SetCompression sets the codec of the blocks completed from now on, CodecNone stops compression.
+func (s *ExtentStore) SetCompression(codec compress.Codec) {
+	atomic.StoreUint32(&s.codec, uint32(codec))
+}
+
+func (s *ExtentStore) GetCompression() compress.Codec {
+	return compress.Codec(atomic.LoadUint32(&s.codec))
+}
+
+func (s *ExtentStore) GetCompressStat() CompressStat {
+	return CompressStat{
+		Blocks:      atomic.LoadUint64(&s.compressStat.Blocks),
+		RawBytes:    atomic.LoadUint64(&s.compressStat.RawBytes),
+		StoredBytes: atomic.LoadUint64(&s.compressStat.StoredBytes),
+	}
+}
+
+// compressBlock compresses the block a write completes.
+func (s *ExtentStore) compressBlock(extent Extent, offset, size int64) {
+	codec := s.GetCompression()
+	if !codec.Valid() || (offset+size)%util.BlockSize != 0 {
+		return
+	}
+	storedSize, err := extent.CompressBlock(int((offset+size)/util.BlockSize)-1, codec)
+	if err != nil || storedSize == 0 {
+		return
+	}
+	atomic.AddUint64(&s.compressStat.Blocks, 1)
+	atomic.AddUint64(&s.compressStat.RawBytes, util.BlockSize)
+	atomic.AddUint64(&s.compressStat.StoredBytes, uint64(storedSize))
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"bytes"
+	"hash/crc32"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/tiglabs/baudstorage/util"
+	"github.com/tiglabs/baudstorage/util/compress"
+)
+
+func compressibleData(size int) []byte {
+	data := make([]byte, size)
+	words := []string{"extent ", "block ", "volume ", "partition "}
+	for n := 0; n < size; {
+		n += copy(data[n:], words[rand.Intn(len(words))])
+	}
+	return data
+}
+
+func TestFsExtent_CompressBlock(t *testing.T) {
+	name := "/tmp/extent_compress_1"
+	defer os.Remove(name)
+	extent := NewExtentInCore(name, 1)
+	if err := extent.InitToFS(1, true); err != nil {
+		t.Fatal(err)
+	}
+	defer extent.Close()
+
+	size := 2*util.BlockSize + 100
+	content := compressibleData(size)
+	for offset := 0; offset < size; offset += util.BlockSize {
+		end := offset + util.BlockSize
+		if end > size {
+			end = size
+		}
+		data := content[offset:end]
+		if err := extent.Write(data, int64(offset), int64(len(data)), crc32.ChecksumIEEE(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for blockNo := 0; blockNo < 3; blockNo++ {
+		storedSize, err := extent.CompressBlock(blockNo, compress.CodecLZ4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the last block is not full and stays raw
+		if (blockNo < 2) != (storedSize > 0) {
+			t.Fatalf("block %v stored size %v", blockNo, storedSize)
+		}
+	}
+
+	check := func() {
+		for _, r := range [][2]int{{0, util.BlockSize}, {util.BlockSize, util.BlockSize}, {100, util.BlockSize}, {util.BlockSize - 10, 110}} {
+			data := make([]byte, r[1])
+			crc, err := extent.Read(data, int64(r[0]), int64(r[1]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, content[r[0]:r[0]+r[1]]) || crc != crc32.ChecksumIEEE(data) {
+				t.Fatalf("read offset %v size %v mismatch", r[0], r[1])
+			}
+		}
+		result, err := extent.Scrub(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Bad) != 0 {
+			t.Fatalf("unexpected bad blocks %v", result.Bad)
+		}
+	}
+	check()
+
+	// a restored extent detects the compressed blocks again
+	extent.Close()
+	extent = NewExtentInCore(name, 1)
+	if err := extent.RestoreFromFS(); err != nil {
+		t.Fatal(err)
+	}
+	check()
+
+	// overwrite across the first two compressed blocks
+	offset := util.BlockSize - 50
+	data := compressibleData(100)
+	copy(content[offset:], data)
+	if err := extent.Write(data, int64(offset), int64(len(data)), crc32.ChecksumIEEE(data)); err != nil {
+		t.Fatal(err)
+	}
+	check()
+}
+
+func TestFsExtent_RecoverBlockJournal(t *testing.T) {
+	name := "/tmp/extent_compress_2"
+	defer os.Remove(name)
+	defer os.Remove(name + ExtentBlockJournalSuffix)
+	extent := NewExtentInCore(name, 2)
+	if err := extent.InitToFS(2, true); err != nil {
+		t.Fatal(err)
+	}
+	defer extent.Close()
+	content := compressibleData(util.BlockSize)
+	if err := extent.Write(content, 0, int64(len(content)), crc32.ChecksumIEEE(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash while the block is rewritten leaves the journal and a slot in neither form
+	fe := extent.(*fsExtent)
+	slot := make([]byte, util.BlockSize)
+	if _, err := fe.file.ReadAt(slot, blockSlot(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fe.writeBlockJournal(0, slot); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fe.file.WriteAt(compressibleData(100), blockSlot(0)); err != nil {
+		t.Fatal(err)
+	}
+	extent.Close()
+
+	extent = NewExtentInCore(name, 2)
+	if err := extent.RestoreFromFS(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name + ExtentBlockJournalSuffix); !os.IsNotExist(err) {
+		t.Fatalf("journal kept after the recovery: %v", err)
+	}
+	data := make([]byte, util.BlockSize)
+	if _, err := extent.Read(data, 0, int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatal("block not recovered from the journal")
+	}
+	result, err := extent.Scrub(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Bad) != 0 {
+		t.Fatalf("unexpected bad blocks %v", result.Bad)
+	}
+}
@@ -1,5 +1,12 @@
 package storage
 
+import (
+	"os"
+	"syscall"
+)
+
+const sendFileSupported = false
+
 func (e *fsExtent) tryKeepSize(fd int, off int64, len int64) (err error) {
 	// Do nothing
 	return
@@ -9,3 +16,7 @@ func (e *fsExtent) tryPunchHole(fd int, off int64, len int64) (err error) {
 	// Do nothing
 	return
 }
+
+func sendFile(conn syscall.Conn, f *os.File, offset int64, size int) (err error) {
+	return ErrorNoZeroCopy
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/tiglabs/baudstorage/util"
+)
+
+// PunchHole deallocates the range of the extent data where the file system supports holes.
+// The range is not read by the clients afterwards, the crcs of the blocks it touches are
+// computed from what is stored in them, so the scrub and the repair take them as intact.
+func (e *fsExtent) PunchHole(offset, size int64) (err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	dataSize := e.dataSize
+	if offset < 0 || size <= 0 || offset >= dataSize {
+		return NewParamMismatchErr(fmt.Sprintf("offset=%v size=%v", offset, size))
+	}
+	end := offset + size
+	if end > dataSize {
+		end = dataSize
+	}
+	startBlockNo := int(offset / util.BlockSize)
+	endBlockNo := int((end - 1) / util.BlockSize)
+	// a compressed block partially punched is stored raw first, a whole one is dropped
+	for no := startBlockNo; no <= endBlockNo; no++ {
+		if offset <= int64(no)*util.BlockSize && end >= int64(util.Min((no+1)*util.BlockSize, int(dataSize))) {
+			continue
+		}
+		if err = e.expandBlock(no); err != nil {
+			return
+		}
+	}
+	for no := startBlockNo; no <= endBlockNo; no++ {
+		e.setBlockRaw(no)
+	}
+	// the space is only reclaimed where the file system supports holes
+	e.tryPunchHole(int(e.file.Fd()), offset+util.BlockHeaderSize, end-offset)
+	blockBuffer := make([]byte, util.BlockSize)
+	for no := startBlockNo; no <= endBlockNo; no++ {
+		blockSize := util.Min(util.BlockSize, int(dataSize)-no*util.BlockSize)
+		var readN int
+		if readN, err = e.readAt(blockBuffer[:blockSize], blockSlot(no)); err != nil && err != io.EOF {
+			return
+		}
+		if err = e.updateBlockCrc(no, crc32.ChecksumIEEE(blockBuffer[:readN])); err != nil {
+			return
+		}
+	}
+	e.modifyTime = time.Now()
+	return
+}
+
+// PunchHole deallocates the range of the extent, which is not read by the clients any more.
+func (s *ExtentStore) PunchHole(extentId uint64, offset, size int64) (err error) {
+	s.extentInfoMux.RLock()
+	extentInfo, has := s.extentInfoMap[extentId]
+	s.extentInfoMux.RUnlock()
+	if !has {
+		return fmt.Errorf("extent %v not exist", extentId)
+	}
+	extent, err := s.getExtent(extentId)
+	if err != nil {
+		return
+	}
+	if extent.IsMarkDelete() {
+		return ErrorHasDelete
+	}
+	if err = extent.PunchHole(offset, size); err != nil {
+		return
+	}
+	s.releaseBlocks(extentId, offset, size, extent.Size())
+	extentInfo.FromExtent(extent)
+	return
+}
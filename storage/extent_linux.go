@@ -1,12 +1,16 @@
 package storage
 
 import (
+	"io"
+	"os"
 	"syscall"
 )
 
 const (
 	FALLOC_FL_KEEP_SIZE  = 1
 	FALLOC_FL_PUNCH_HOLE = 2
+
+	sendFileSupported = true
 )
 
 func (e *fsExtent) tryKeepSize(fd int, off int64, len int64) (err error) {
@@ -15,6 +19,43 @@ func (e *fsExtent) tryKeepSize(fd int, off int64, len int64) (err error) {
 }
 
 func (e *fsExtent) tryPunchHole(fd int, off int64, len int64) (err error) {
-	err = syscall.Fallocate(fd, FALLOC_FL_PUNCH_HOLE, off, len)
+	err = syscall.Fallocate(fd, FALLOC_FL_PUNCH_HOLE|FALLOC_FL_KEEP_SIZE, off, len)
+	return
+}
+
+// sendFile sends size bytes of the file at offset to the connection by sendfile, the data
+// goes from the page cache to the socket without being copied to the user space.
+func sendFile(conn syscall.Conn, f *os.File, offset int64, size int) (err error) {
+	var rawConn syscall.RawConn
+	if rawConn, err = conn.SyscallConn(); err != nil {
+		return
+	}
+	src := int(f.Fd())
+	var sendErr error
+	err = rawConn.Write(func(fd uintptr) bool {
+		for size > 0 {
+			var n int
+			n, sendErr = syscall.Sendfile(int(fd), src, &offset, size)
+			if n > 0 {
+				size -= n
+			}
+			switch {
+			case sendErr == syscall.EAGAIN:
+				// wait for the socket to be writable
+				return false
+			case sendErr == syscall.EINTR:
+				continue
+			case sendErr != nil:
+				return true
+			case n == 0:
+				sendErr = io.ErrUnexpectedEOF
+				return true
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = sendErr
+	}
 	return
 }
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"syscall"
+
+	"github.com/tiglabs/baudstorage/util"
+)
+
+// SendBlock sends a whole block of the extent to the connection straight from the extent file,
+// after the header written by writeHeader with the crc stored for the block, so the data is
+// neither copied to the user space nor checksummed again. ErrorNoZeroCopy is returned before
+// anything is sent if the block is stored compressed or encrypted, or is not a whole one.
+func (e *fsExtent) SendBlock(blockNo int, conn syscall.Conn, writeHeader func(crc uint32) error) (err error) {
+	if !sendFileSupported {
+		return ErrorNoZeroCopy
+	}
+	// the block is not compressed or punched while it is sent
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	if e.keyVersion != 0 || int64(blockNo+1)*util.BlockSize > e.dataSize || e.isCompressed(blockNo) {
+		return ErrorNoZeroCopy
+	}
+	if err = writeHeader(e.getBlockCrc(blockNo)); err != nil {
+		return
+	}
+	return sendFile(conn, e.file, blockSlot(blockNo), util.BlockSize)
+}
+
+// SendBlock sends the whole block of the extent at offset to the connection without reading it,
+// see Extent.SendBlock. The caller reads the block by Read if ErrorNoZeroCopy is returned.
+func (s *ExtentStore) SendBlock(extentId uint64, offset int64, conn syscall.Conn, writeHeader func(crc uint32) error) (err error) {
+	var extent Extent
+	if extent, err = s.getExtent(extentId); err != nil {
+		return
+	}
+	if err = s.checkOffsetAndSize(offset, util.BlockSize); err != nil {
+		return
+	}
+	if offset%util.BlockSize != 0 {
+		return ErrorNoZeroCopy
+	}
+	if extent.IsMarkDelete() {
+		return ErrorHasDelete
+	}
+	if s.isQuarantined(extentId, offset, util.BlockSize) {
+		return ErrorQuarantined
+	}
+	return extent.SendBlock(int(offset/util.BlockSize), conn, writeHeader)
+}
@@ -62,6 +62,12 @@ func (tree *ObjectTree) FileBytes() uint64 {
 	return atomic.LoadUint64(&tree.fileBytes)
 }
 
+func (tree *ObjectTree) stat() treeStat {
+	tree.idxLock.Lock()
+	defer tree.idxLock.Unlock()
+	return tree.treeStat
+}
+
 func NewObjectTree(f *os.File) *ObjectTree {
 	tree := &ObjectTree{
 		tree: btree.New(32),
@@ -177,19 +183,22 @@ func (tree *ObjectTree) get(oid uint64) (n *Object, exist bool) {
 	return nil, false
 }
 
-func (tree *ObjectTree) delete(oid uint64) error {
+func (tree *ObjectTree) delete(oid uint64) (oldOff, oldSize uint32, err error) {
 	tree.idxLock.Lock()
 	found := tree.tree.Delete(&Object{Oid: oid})
 	if found == nil {
 		tree.idxLock.Unlock()
-		return nil
+		return
 	}
 	o := found.(*Object)
+	oldOff = o.Offset
+	oldSize = o.Size
 	tree.decreaseSize(o.Size)
 	o.Size = TombstoneFileSize
 	tree.idxLock.Unlock()
 
-	return tree.appendToIdxFile(o)
+	err = tree.appendToIdxFile(o)
+	return
 }
 
 func (tree *ObjectTree) checkConsistency(oid uint64, offset, size uint32) bool {
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/tiglabs/baudstorage/util"
+)
+
+// quarantine holds the blocks of the extents, or the objects of the chunks, whose data is
+// found not matching the stored crc by a scrub. The reads of them fail until they are
+// rewritten, so the clients and the repairing replicas turn to a healthy replica.
+// It is kept in memory only, the next scrub finds the same data again after a restart.
+type quarantine struct {
+	files map[uint64]map[uint64]bool
+	sync.RWMutex
+}
+
+func (q *quarantine) add(fileId uint64, ids []uint64) {
+	if len(ids) == 0 {
+		return
+	}
+	q.Lock()
+	defer q.Unlock()
+	if q.files == nil {
+		q.files = make(map[uint64]map[uint64]bool)
+	}
+	if q.files[fileId] == nil {
+		q.files[fileId] = make(map[uint64]bool)
+	}
+	for _, id := range ids {
+		q.files[fileId][id] = true
+	}
+}
+
+// hasAny returns true if any id of [first, last] of the file is quarantined.
+func (q *quarantine) hasAny(fileId, first, last uint64) bool {
+	q.RLock()
+	defer q.RUnlock()
+	ids, ok := q.files[fileId]
+	if !ok {
+		return false
+	}
+	for id := first; id <= last; id++ {
+		if ids[id] {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *quarantine) hasFile(fileId uint64) bool {
+	q.RLock()
+	defer q.RUnlock()
+	_, ok := q.files[fileId]
+	return ok
+}
+
+func (q *quarantine) remove(fileId, id uint64) {
+	q.Lock()
+	defer q.Unlock()
+	ids, ok := q.files[fileId]
+	if !ok {
+		return
+	}
+	delete(ids, id)
+	if len(ids) == 0 {
+		delete(q.files, fileId)
+	}
+}
+
+func (q *quarantine) removeFile(fileId uint64) {
+	q.Lock()
+	delete(q.files, fileId)
+	q.Unlock()
+}
+
+func (q *quarantine) list(fileId uint64) (ids []uint64) {
+	q.RLock()
+	defer q.RUnlock()
+	ids = make([]uint64, 0, len(q.files[fileId]))
+	for id := range q.files[fileId] {
+		ids = append(ids, id)
+	}
+	return
+}
+
+// QuarantineBlocks fails the reads of the blocks of the extent until they are rewritten.
+func (s *ExtentStore) QuarantineBlocks(extentId uint64, blocks []uint64) {
+	s.bad.add(extentId, blocks)
+}
+
+// QuarantinedBlocks returns the blocks of the extent whose reads fail.
+func (s *ExtentStore) QuarantinedBlocks(extentId uint64) []uint64 {
+	return s.bad.list(extentId)
+}
+
+// QuarantinedBlockCrc returns the crc stored for the block if it is quarantined,
+// the copy of the block fetched from a healthy replica matches it.
+func (s *ExtentStore) QuarantinedBlockCrc(extentId, blockNo uint64) (crc uint32, ok bool) {
+	if !s.bad.hasAny(extentId, blockNo, blockNo) {
+		return
+	}
+	extent, err := s.getExtent(extentId)
+	if err != nil {
+		return
+	}
+	return extent.BlockCrc(int(blockNo)), true
+}
+
+func (s *ExtentStore) isQuarantined(extentId uint64, offset, size int64) bool {
+	return s.bad.hasAny(extentId, uint64(offset/util.BlockSize), uint64((offset+size-1)/util.BlockSize))
+}
+
+// releaseBlocks lifts the quarantine of the blocks rewritten as a whole, the last block
+// of the extent is a whole one up to the size of the extent.
+func (s *ExtentStore) releaseBlocks(extentId uint64, offset, size, extentSize int64) {
+	if !s.bad.hasFile(extentId) {
+		return
+	}
+	end := offset + size
+	for blockNo := offset / util.BlockSize; blockNo*util.BlockSize < end; blockNo++ {
+		blockStart := blockNo * util.BlockSize
+		blockEnd := blockStart + util.BlockSize
+		if blockEnd > extentSize {
+			blockEnd = extentSize
+		}
+		if offset <= blockStart && end >= blockEnd {
+			s.bad.remove(extentId, uint64(blockNo))
+		}
+	}
+}
+
+// QuarantineObjects fails the reads of the objects of the chunk until they are repaired.
+func (s *TinyStore) QuarantineObjects(fileId uint32, objects []uint64) {
+	s.bad.add(uint64(fileId), objects)
+}
+
+// QuarantinedObjects returns the objects of the chunk whose reads fail.
+func (s *TinyStore) QuarantinedObjects(fileId uint32) []uint64 {
+	return s.bad.list(uint64(fileId))
+}
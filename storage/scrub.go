@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/tiglabs/baudstorage/util"
+	"github.com/tiglabs/baudstorage/util/btree"
+)
+
+// ScrubResult is the result of re-reading a whole extent or chunk from disk.
+// Crc is computed from the crc of every block or object re-calculated from the data,
+// so it is comparable between replicas holding the same content.
+type ScrubResult struct {
+	Size  uint64
+	Count int
+	Crc   uint32
+	// Bad are the block numbers of an extent, or the object ids of a chunk,
+	// whose data does not match the crc stored when it was written.
+	Bad []uint64
+}
+
+// ScrubThrottle is called with the size of the next read, it blocks to rate-limit the scrub.
+type ScrubThrottle func(size int)
+
+// Scrub re-reads every block of the extent and checks it against the block crc in the header.
+func (e *fsExtent) Scrub(throttle ScrubThrottle) (result *ScrubResult, err error) {
+	var (
+		readN int
+		crcs  []byte
+	)
+	result = &ScrubResult{Bad: make([]uint64, 0)}
+	size := e.Size()
+	data := make([]byte, util.BlockSize)
+	buf := make([]byte, util.PerBlockCrcSize)
+	for offset := int64(0); offset < size; offset += util.BlockSize {
+		blockNo := int(offset / util.BlockSize)
+		readSize := size - offset
+		if readSize > util.BlockSize {
+			readSize = util.BlockSize
+		}
+		if throttle != nil {
+			throttle(int(readSize))
+		}
+		e.lock.RLock()
+		if e.isCompressed(blockNo) {
+			// a block which fails to decompress is reported bad
+			readN = 0
+			if block, readErr := e.readCompressedBlock(blockNo); readErr == nil {
+				readN = copy(data[:readSize], block)
+			}
+		} else {
+			readN, err = e.readAt(data[:readSize], offset+util.BlockHeaderSize)
+		}
+		storedCrc := e.getBlockCrc(blockNo)
+		e.lock.RUnlock()
+		if err != nil && err != io.EOF {
+			return
+		}
+		err = nil
+		crc := crc32.ChecksumIEEE(data[:readN])
+		if crc != storedCrc || int64(readN) != readSize {
+			result.Bad = append(result.Bad, uint64(blockNo))
+		}
+		binary.BigEndian.PutUint32(buf, crc)
+		crcs = append(crcs, buf...)
+		result.Count++
+	}
+	result.Size = uint64(size)
+	result.Crc = crc32.ChecksumIEEE(crcs)
+	return
+}
+
+// ScrubExtent re-reads the extent from disk, see fsExtent.Scrub.
+func (s *ExtentStore) ScrubExtent(extentId uint64, throttle ScrubThrottle) (result *ScrubResult, err error) {
+	var extent Extent
+	if extent, err = s.getExtent(extentId); err != nil {
+		return
+	}
+	if extent.IsMarkDelete() {
+		err = ErrorHasDelete
+		return
+	}
+	return extent.Scrub(throttle)
+}
+
+// ScrubChunk re-reads every object of the chunk which is synced to all replicas,
+// and checks its data against the crc in the index.
+func (s *TinyStore) ScrubChunk(fileId uint32, throttle ScrubThrottle) (result *ScrubResult, err error) {
+	c, ok := s.chunks[int(fileId)]
+	if !ok {
+		return nil, ErrorFileNotFound
+	}
+	syncLastOid := c.loadSyncLastOid()
+	if syncLastOid == 0 {
+		syncLastOid = c.loadLastOid()
+	}
+	oids := make([]uint64, 0)
+	c.commitLock.RLock()
+	c.tree.idxLock.Lock()
+	c.tree.getTree().Ascend(func(i btree.Item) bool {
+		o := i.(*Object)
+		if o.Oid > syncLastOid {
+			return false
+		}
+		if o.Size != TombstoneFileSize {
+			oids = append(oids, o.Oid)
+		}
+		return true
+	})
+	c.tree.idxLock.Unlock()
+	c.commitLock.RUnlock()
+
+	result = &ScrubResult{Bad: make([]uint64, 0)}
+	buf := make([]byte, 4)
+	crcs := make([]byte, 0, 4*len(oids))
+	data := make([]byte, 0)
+	for _, oid := range oids {
+		var (
+			crc uint32
+			bad bool
+		)
+		if crc, bad, data, err = c.scrubObject(oid, data, throttle); err != nil {
+			return
+		}
+		if bad {
+			result.Bad = append(result.Bad, oid)
+		}
+		binary.BigEndian.PutUint32(buf, crc)
+		crcs = append(crcs, buf...)
+		result.Count++
+	}
+	result.Size = syncLastOid
+	result.Crc = crc32.ChecksumIEEE(crcs)
+	return
+}
+
+func (c *Chunk) scrubObject(oid uint64, data []byte, throttle ScrubThrottle) (crc uint32, bad bool, buf []byte, err error) {
+	buf = data
+	c.commitLock.RLock()
+	defer c.commitLock.RUnlock()
+	o, ok := c.tree.get(oid)
+	if !ok || o.Size == TombstoneFileSize {
+		// deleted while scrubbing, the replicas apply the same deletion
+		return
+	}
+	if cap(buf) < int(o.Size) {
+		buf = make([]byte, o.Size)
+	}
+	if throttle != nil {
+		throttle(int(o.Size))
+	}
+	var readN int
+	if readN, err = c.readAt(buf[:o.Size], int64(o.Offset)); err != nil && err != io.EOF {
+		return
+	}
+	err = nil
+	crc = crc32.ChecksumIEEE(buf[:readN])
+	bad = crc != o.Crc || readN != int(o.Size)
+	if bad {
+		// the data of an object deleted while it is read may be punched
+		if _, ok = c.tree.get(oid); !ok {
+			bad = false
+		}
+	}
+	return
+}
+
+// RepairObject overwrites the data of an object in place with a healthy copy fetched from
+// another replica. The object keeps its offset, so the size and crc must match the index.
+func (s *TinyStore) RepairObject(fileId uint32, objectId uint64, data []byte, crc uint32) (err error) {
+	c, ok := s.chunks[int(fileId)]
+	if !ok {
+		return ErrorFileNotFound
+	}
+	if crc32.ChecksumIEEE(data) != crc {
+		return ErrorParamMismatch
+	}
+	c.commitLock.RLock()
+	defer c.commitLock.RUnlock()
+	o, ok := c.tree.get(objectId)
+	if !ok {
+		return ErrorObjNotFound
+	}
+	if int(o.Size) != len(data) || o.Crc != crc {
+		return ErrorParamMismatch
+	}
+	// the chunk file is opened with O_APPEND, which ignores the offset of a write
+	var f *os.File
+	if f, err = os.OpenFile(c.file.Name(), os.O_WRONLY, 0666); err != nil {
+		return
+	}
+	defer f.Close()
+	if data, err = c.encrypt(data, int64(o.Offset), c.keyVersionAt(int64(o.Offset)), c.loadGeneration()); err != nil {
+		return
+	}
+	if _, err = f.WriteAt(data, int64(o.Offset)); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	s.bad.remove(uint64(fileId), objectId)
+	return
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"bytes"
+	"hash/crc32"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/tiglabs/baudstorage/util"
+)
+
+func TestFsExtent_Scrub(t *testing.T) {
+	name := "/tmp/extent_scrub_1"
+	defer os.Remove(name)
+	extent := NewExtentInCore(name, 1)
+	if err := extent.InitToFS(1, true); err != nil {
+		t.Fatal(err)
+	}
+	defer extent.Close()
+
+	size := 2*util.BlockSize + 100
+	for offset := 0; offset < size; offset += util.BlockSize {
+		length := size - offset
+		if length > util.BlockSize {
+			length = util.BlockSize
+		}
+		data := make([]byte, length)
+		rand.Read(data)
+		if err := extent.Write(data, int64(offset), int64(length), crc32.ChecksumIEEE(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	extent.Flush()
+
+	result, err := extent.Scrub(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Count != 3 || len(result.Bad) != 0 || result.Size != uint64(size) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	healthyCrc := result.Crc
+
+	// flip a byte in the second block behind the back of the extent
+	flipStoredByte(t, name, util.BlockHeaderSize+util.BlockSize+10)
+
+	var throttled int
+	if result, err = extent.Scrub(func(n int) { throttled += n }); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Bad) != 1 || result.Bad[0] != 1 {
+		t.Fatalf("expect bad block 1, got %v", result.Bad)
+	}
+	if result.Crc == healthyCrc {
+		t.Fatalf("crc of corrupted extent must differ")
+	}
+	if throttled != size {
+		t.Fatalf("throttle saw %v bytes, expect %v", throttled, size)
+	}
+}
+
+func TestExtentStore_Quarantine(t *testing.T) {
+	store, dir := newTestExtentStore(t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+	extentId, data := writeTestExtent(t, store, 2*util.BlockSize+1000)
+	store.Sync(extentId)
+
+	// flip a byte in the second block behind the back of the store
+	flipStoredByte(t, testExtentPath(store, extentId), util.BlockHeaderSize+util.BlockSize+10)
+
+	result, err := store.ScrubExtent(extentId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Bad) != 1 || result.Bad[0] != 1 {
+		t.Fatalf("expect bad block 1, got %v", result.Bad)
+	}
+	store.QuarantineBlocks(extentId, result.Bad)
+
+	buf := make([]byte, util.BlockSize)
+	if _, err = store.Read(extentId, util.BlockSize-10, 20, buf); err != ErrorQuarantined {
+		t.Fatalf("read across the bad block: expect %v, got %v", ErrorQuarantined, err)
+	}
+	if _, err = store.Read(extentId, 0, util.BlockSize, buf); err != nil {
+		t.Fatalf("read the healthy block: %v", err)
+	}
+
+	// a write of part of the block keeps it in quarantine
+	part := data[util.BlockSize : util.BlockSize+100]
+	if err = store.Write(extentId, util.BlockSize, int64(len(part)), part, crc32.ChecksumIEEE(part)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Read(extentId, util.BlockSize, 100, buf); err != ErrorQuarantined {
+		t.Fatalf("read after partial rewrite: expect %v, got %v", ErrorQuarantined, err)
+	}
+
+	// the repair rewrites the whole block
+	part = data[util.BlockSize : 2*util.BlockSize]
+	if err = store.Write(extentId, util.BlockSize, int64(len(part)), part, crc32.ChecksumIEEE(part)); err != nil {
+		t.Fatal(err)
+	}
+	if blocks := store.QuarantinedBlocks(extentId); len(blocks) != 0 {
+		t.Fatalf("expect no quarantined block, got %v", blocks)
+	}
+	if _, err = store.Read(extentId, util.BlockSize, util.BlockSize, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, part) {
+		t.Fatalf("repaired block mismatch")
+	}
+	if result, err = store.ScrubExtent(extentId, nil); err != nil || len(result.Bad) != 0 {
+		t.Fatalf("scrub after repair: bad %v err %v", result.Bad, err)
+	}
+}
@@ -64,6 +64,13 @@ type ExtentStore struct {
 	deleteFp      *os.File
 	closeC        chan bool
 	closed        bool
+	codec         uint32
+	compressStat  CompressStat
+	keys          storeKeys
+	keyFp         *os.File
+	extentKeys    map[uint64]uint32
+	extentKeyLock sync.RWMutex
+	bad           quarantine
 }
 
 func NewExtentStore(dataDir string, storeSize int) (s *ExtentStore, err error) {
@@ -87,6 +94,10 @@ func NewExtentStore(dataDir string, storeSize int) (s *ExtentStore, err error) {
 	if s.deleteFp, err = os.OpenFile(deleteIdxFilePath, ExtDeleteFileOpt, 0666); err != nil {
 		return
 	}
+	// Load EXTENT_KEY
+	if err = s.loadExtentKeys(); err != nil {
+		return
+	}
 	s.extentInfoMap = make(map[uint64]*FileInfo, 40)
 	s.cache = NewExtentCache(40)
 	if err = s.initBaseFileId(); err != nil {
@@ -144,7 +155,10 @@ func (s *ExtentStore) Create(extentId uint64, inode uint64, overwrite bool) (err
 		}
 		extent.InitToFS(extentId, true)
 	} else {
-		extent = NewExtentInCore(name, extentId)
+		if err = s.setExtentKey(extentId, s.keys.current()); err != nil {
+			return
+		}
+		extent = s.newExtent(name, extentId)
 		if err = extent.InitToFS(inode, false); err != nil {
 			return
 		}
@@ -191,9 +205,17 @@ func (s *ExtentStore) IsExistExtent(extentId uint64) (exist bool) {
 	return
 }
 
+// newExtent returns an extent encrypted by its key version, if any.
+func (s *ExtentStore) newExtent(name string, extentId uint64) Extent {
+	e := NewExtentInCore(name, extentId).(*fsExtent)
+	e.keys = &s.keys
+	e.keyVersion = s.getExtentKey(extentId)
+	return e
+}
+
 func (s *ExtentStore) loadExtentFromDisk(extentId uint64) (e Extent, err error) {
 	name := path.Join(s.dataDir, strconv.Itoa(int(extentId)))
-	e = NewExtentInCore(name, extentId)
+	e = s.newExtent(name, extentId)
 	if err = e.RestoreFromFS(); err != nil {
 		err = fmt.Errorf("restore from file system: %v", err)
 		return
@@ -269,10 +291,27 @@ func (s *ExtentStore) Write(extentId uint64, offset, size int64, data []byte, cr
 	if err = extent.Write(data, offset, size, crc); err != nil {
 		return
 	}
+	s.compressBlock(extent, offset, size)
+	s.releaseBlocks(extentId, offset, size, extent.Size())
 	extentInfo.FromExtent(extent)
 	return
 }
 
+// Overwrite writes the data over the extent in place, it does not extend the extent.
+func (s *ExtentStore) Overwrite(extentId uint64, offset, size int64, data []byte, crc uint32) (err error) {
+	s.extentInfoMux.RLock()
+	extentInfo, has := s.extentInfoMap[extentId]
+	s.extentInfoMux.RUnlock()
+	if !has {
+		return fmt.Errorf("extent %v not exist", extentId)
+	}
+	if offset+size > int64(extentInfo.Size) {
+		return NewParamMismatchErr(fmt.Sprintf("overwrite offset=%v size=%v beyond extent size=%v",
+			offset, size, extentInfo.Size))
+	}
+	return s.Write(extentId, offset, size, data, crc)
+}
+
 func (s *ExtentStore) checkOffsetAndSize(offset, size int64) error {
 	if offset+size > util.BlockSize*util.BlockCount {
 		return NewParamMismatchErr(fmt.Sprintf("offset=%v size=%v", offset, size))
@@ -299,6 +338,10 @@ func (s *ExtentStore) Read(extentId uint64, offset, size int64, nbuf []byte) (cr
 		err = ErrorHasDelete
 		return
 	}
+	if s.isQuarantined(extentId, offset, size) {
+		err = ErrorQuarantined
+		return
+	}
 	crc, err = extent.Read(nbuf, offset, size)
 	return
 }
@@ -326,6 +369,7 @@ func (s *ExtentStore) MarkDelete(extentId uint64) (err error) {
 	extentInfo.FromExtent(extent)
 
 	s.cache.Del(extent.ID())
+	s.bad.removeFile(extentId)
 
 	s.extentInfoMux.Lock()
 	delete(s.extentInfoMap, extentId)
@@ -412,6 +456,8 @@ func (s *ExtentStore) FlushDelete() (err error) {
 		if opErr = os.Remove(extentFilePath); opErr != nil {
 			continue
 		}
+		os.Remove(extentFilePath + ExtentGenSuffix)
+		os.Remove(extentFilePath + ExtentBlockJournalSuffix)
 	}
 
 	// Store offset of EXTENT_DELETE into EXTENT_META
@@ -451,6 +497,8 @@ func (s *ExtentStore) Close() {
 	s.deleteFp.Sync()
 	s.deleteFp.Close()
 
+	s.keyFp.Close()
+
 	s.closed = true
 }
 
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/tiglabs/baudstorage/util"
+)
+
+func TestExtentStore_Overwrite(t *testing.T) {
+	store, dir := newTestExtentStore(t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+	extentId, data := writeTestExtent(t, store, 2*util.BlockSize+1000)
+
+	// the overwrite crosses the first two blocks
+	part := make([]byte, 2000)
+	rand.Read(part)
+	offset := util.BlockSize - 1000
+	if err := store.Overwrite(extentId, int64(offset), int64(len(part)), part, crc32.ChecksumIEEE(part)); err != nil {
+		t.Fatal(err)
+	}
+	copy(data[offset:], part)
+	for offset := 0; offset < len(data); offset += util.BlockSize {
+		expected := data[offset:util.Min(offset+util.BlockSize, len(data))]
+		buf := make([]byte, len(expected))
+		crc, err := store.Read(extentId, int64(offset), int64(len(buf)), buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, expected) || crc != crc32.ChecksumIEEE(expected) {
+			t.Fatalf("block at %v mismatch after overwrite", offset)
+		}
+	}
+	info, _ := store.GetWatermark(extentId)
+	if info.Size != uint64(len(data)) {
+		t.Fatalf("extent size %v changed by overwrite", info.Size)
+	}
+	if err := store.Overwrite(extentId, int64(len(data)-10), 20, part[:20], crc32.ChecksumIEEE(part[:20])); err == nil {
+		t.Fatal("overwrite beyond the extent size")
+	}
+}
+
+func TestExtentStore_PunchHole(t *testing.T) {
+	store, dir := newTestExtentStore(t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+	extentId, data := writeTestExtent(t, store, 2*util.BlockSize+1000)
+
+	if err := store.PunchHole(extentId, 1000, util.BlockSize); err != nil {
+		t.Fatal(err)
+	}
+	for offset := 0; offset < len(data); offset += util.BlockSize {
+		buf := make([]byte, util.Min(util.BlockSize, len(data)-offset))
+		crc, err := store.Read(extentId, int64(offset), int64(len(buf)), buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if crc != crc32.ChecksumIEEE(buf) {
+			t.Fatalf("block at %v does not match its crc after punch", offset)
+		}
+	}
+	buf := make([]byte, 1000)
+	if _, err := store.Read(extentId, util.BlockSize+1000, int64(len(buf)), buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data[util.BlockSize+1000:util.BlockSize+2000]) {
+		t.Fatal("data out of the hole is changed")
+	}
+	info, _ := store.GetWatermark(extentId)
+	if info.Size != uint64(len(data)) {
+		t.Fatalf("extent size %v changed by punch", info.Size)
+	}
+}
+
+func TestExtentStore_SendBlock(t *testing.T) {
+	if !sendFileSupported {
+		t.Skip("sendfile not supported")
+	}
+	store, dir := newTestExtentStore(t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+	extentId, data := writeTestExtent(t, store, util.BlockSize+1000)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	tcpConn := conn.(*net.TCPConn)
+
+	writeHeader := func(crc uint32) error {
+		header := make([]byte, 4)
+		binary.BigEndian.PutUint32(header, crc)
+		_, err := conn.Write(header)
+		return err
+	}
+	// the unaligned offset and the partial last block are read instead
+	for _, offset := range []int64{100, util.BlockSize} {
+		if err = store.SendBlock(extentId, offset, tcpConn, writeHeader); err != ErrorNoZeroCopy {
+			t.Fatalf("send block at %v err[%v], expect %v", offset, err, ErrorNoZeroCopy)
+		}
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- store.SendBlock(extentId, 0, tcpConn, writeHeader)
+	}()
+	reply := make([]byte, 4+util.BlockSize)
+	if _, err = io.ReadFull(client, reply); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	if binary.BigEndian.Uint32(reply[:4]) != crc32.ChecksumIEEE(data[:util.BlockSize]) {
+		t.Fatalf("sent crc mismatch")
+	}
+	if !bytes.Equal(reply[4:], data[:util.BlockSize]) {
+		t.Fatalf("sent data mismatch")
+	}
+}
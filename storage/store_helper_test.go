@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"hash/crc32"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/tiglabs/baudstorage/util"
+)
+
+// newTestExtentStore creates an extent store in a new temporary directory,
+// the caller closes the store and removes the directory.
+func newTestExtentStore(t *testing.T) (store *ExtentStore, dir string) {
+	dir, err := ioutil.TempDir("", "extent_store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store, err = NewExtentStore(dir, util.GB); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return
+}
+
+// writeTestExtent creates an extent in the store and writes size random bytes to it block by block.
+func writeTestExtent(t *testing.T, store *ExtentStore, size int) (extentId uint64, data []byte) {
+	extentId = store.NextExtentId()
+	if err := store.Create(extentId, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	data = make([]byte, size)
+	rand.Read(data)
+	for offset := 0; offset < size; offset += util.BlockSize {
+		part := data[offset:util.Min(offset+util.BlockSize, size)]
+		if err := store.Write(extentId, int64(offset), int64(len(part)), part, crc32.ChecksumIEEE(part)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return
+}
+
+// newTestTinyStore creates a tiny store in a new temporary directory,
+// the caller closes the store and removes the directory.
+func newTestTinyStore(t *testing.T) (store *TinyStore, dir string) {
+	dir, err := ioutil.TempDir("", "tiny_store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store, err = NewTinyStore(dir, util.GB); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return
+}
+
+// writeTestObjects writes count objects of size random bytes to the chunk, keyed by object id.
+func writeTestObjects(t *testing.T, store *TinyStore, chunkId uint32, count, size int) (objects map[uint64][]byte) {
+	objects = make(map[uint64][]byte)
+	for i := 0; i < count; i++ {
+		oid, err := store.AllocObjectId(chunkId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, size)
+		rand.Read(data)
+		if err = store.Write(chunkId, oid, int64(size), data, crc32.ChecksumIEEE(data)); err != nil {
+			t.Fatal(err)
+		}
+		objects[oid] = data
+	}
+	return
+}
+
+// testExtentPath returns the file of the extent in the store.
+func testExtentPath(store *ExtentStore, extentId uint64) string {
+	return path.Join(store.dataDir, strconv.FormatUint(extentId, 10))
+}
+
+// flipStoredByte corrupts the byte at pos of the file behind the back of its store.
+func flipStoredByte(t *testing.T, name string, pos int64) {
+	f, err := os.OpenFile(name, os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b := make([]byte, 1)
+	if _, err = f.ReadAt(b, pos); err != nil {
+		t.Fatal(err)
+	}
+	b[0] ^= 0xff
+	if _, err = f.WriteAt(b, pos); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"os"
+	"sort"
 	"time"
 
 	"fmt"
@@ -21,6 +22,7 @@ const (
 	NewStoreMode      = true
 	MinWriteAbleChunk = 1
 	ObjectIdLen       = 8
+	PunchHoleAlign    = 4 * util.KB
 )
 
 // TinyStore is a store implement for tiny file storage which container 40 chunk files.
@@ -33,6 +35,8 @@ type TinyStore struct {
 	storeSize      int
 	chunkSize      int
 	fullChunks     *util.Set
+	keys           storeKeys
+	bad            quarantine
 }
 
 func NewTinyStore(dataDir string, storeSize int) (s *TinyStore, err error) {
@@ -77,6 +81,7 @@ func (s *TinyStore) initChunkFile() (err error) {
 		if c, err = NewChunk(s.dataDir, i); err != nil {
 			return fmt.Errorf("initChunkFile Error %s", err.Error())
 		}
+		c.keys = &s.keys
 		s.chunks[i] = c
 	}
 
@@ -144,7 +149,15 @@ func (s *TinyStore) Write(fileId uint32, objectId uint64, size int64, data []byt
 	}
 
 	newOffset := fi.Size()
-	if _, err = c.file.WriteAt(data[:size], newOffset); err != nil {
+	version := s.keys.current()
+	if err = c.startKeySegment(newOffset, version); err != nil {
+		return
+	}
+	if data, err = c.encrypt(data[:size], newOffset, version, c.loadGeneration()); err != nil {
+		return
+	}
+	// the chunk file is opened with O_APPEND, the object lands at newOffset
+	if _, err = c.file.Write(data[:size]); err != nil {
 		return
 	}
 
@@ -168,6 +181,9 @@ func (s *TinyStore) Read(fileId uint32, offset, size int64, nbuf []byte) (crc ui
 	if lastOid < objectId {
 		return 0, ErrorFileNotFound
 	}
+	if s.bad.hasAny(uint64(fileId), objectId, objectId) {
+		return 0, ErrorQuarantined
+	}
 
 	c.commitLock.RLock()
 	defer c.commitLock.RUnlock()
@@ -186,9 +202,13 @@ func (s *TinyStore) Read(fileId uint32, offset, size int64, nbuf []byte) (crc ui
 		return 0, ErrorParamMismatch
 	}
 
-	if _, err = c.file.ReadAt(nbuf[:size], int64(o.Offset)); err != nil {
+	if _, err = c.readAt(nbuf[:size], int64(o.Offset)); err != nil {
 		return
 	}
+	// the data of an object deleted while it is read may be punched
+	if _, ok = c.tree.get(objectId); !ok {
+		return 0, ErrorObjNotFound
+	}
 	crc = o.Crc
 
 	return
@@ -296,8 +316,9 @@ func (s *TinyStore) MarkDelete(fileId uint32, offset, size int64) error {
 	if !ok {
 		return ErrorFileNotFound
 	}
+	s.bad.remove(uint64(fileId), objectId)
 
-	return c.tree.delete(objectId)
+	return c.deleteObjects([]uint64{objectId})
 }
 
 func (s *TinyStore) GetUnAvailChanLen() (chanLen int) {
@@ -390,33 +411,103 @@ func (s *TinyStore) UpdateStoreInfo() {
 	return
 }
 
-// make sure chunkId is valid
-func (s *TinyStore) IsReadyToCompact(chunkId int) bool {
-	c := s.chunks[chunkId]
-	tree := c.tree
+// ChunkStat is the garbage of a chunk, the space of the deleted and the overwritten objects.
+// The garbage is reclaimed by punching holes in the chunk file, the part sharing the file
+// system blocks with the live objects is only released by a compaction.
+type ChunkStat struct {
+	ChunkId        int
+	FileSize       int64
+	DiskBytes      int64
+	LiveObjects    uint32
+	LiveBytes      uint64
+	DeletedObjects uint32
+	DeletedBytes   uint64
+	GarbageBytes   uint64
+	ReclaimedBytes uint64
+	// GarbageRatio is the percent of the garbage not reclaimed by holes in the disk space of the chunk file
+	GarbageRatio uint64
+	Full         bool
+}
+
+// ChunkStat returns the garbage of the chunk, the data left by the failed writes counts too.
+func (s *TinyStore) ChunkStat(chunkId int) (stat *ChunkStat, err error) {
+	c, ok := s.chunks[chunkId]
+	if !ok {
+		return nil, ErrorFileNotFound
+	}
+	var fi os.FileInfo
+	c.commitLock.RLock()
+	fi, err = c.file.Stat()
+	ts := c.tree.stat()
+	c.commitLock.RUnlock()
+	if err != nil {
+		return
+	}
+	stat = &ChunkStat{
+		ChunkId:        chunkId,
+		FileSize:       fi.Size(),
+		DiskBytes:      fi.Size(),
+		LiveObjects:    ts.fileCount - ts.deleteCount,
+		LiveBytes:      ts.fileBytes - ts.deleteBytes,
+		DeletedObjects: ts.deleteCount,
+		DeletedBytes:   ts.deleteBytes,
+		Full:           s.fullChunks.Has(chunkId),
+	}
+	if allocated := allocatedSize(fi); allocated < stat.DiskBytes {
+		stat.DiskBytes = allocated
+	}
+	if uint64(stat.FileSize) > stat.LiveBytes {
+		stat.GarbageBytes = uint64(stat.FileSize) - stat.LiveBytes
+	}
+	stat.ReclaimedBytes = uint64(stat.FileSize - stat.DiskBytes)
+	if stat.GarbageBytes > stat.ReclaimedBytes && stat.DiskBytes > 0 {
+		stat.GarbageRatio = (stat.GarbageBytes - stat.ReclaimedBytes) * 100 / uint64(stat.DiskBytes)
+	}
+	return
+}
 
-	if s.fullChunks.Has(chunkId) {
-		if tree.fileBytes < uint64(s.chunkSize) {
-			return true
-		} else {
-			return false
+// ChunkStats returns the garbage of every chunk of the store.
+func (s *TinyStore) ChunkStats() (stats []*ChunkStat) {
+	stats = make([]*ChunkStat, 0, len(s.chunks))
+	for chunkId := range s.chunks {
+		if stat, err := s.ChunkStat(chunkId); err == nil {
+			stats = append(stats, stat)
 		}
 	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ChunkId < stats[j].ChunkId })
+	return
+}
 
-	if tree.deleteBytes*100/(tree.fileBytes+1) >= uint64(CompactThreshold) {
-		return true
+// IsReadyToCompact tells whether the garbage of the chunk not reclaimed by holes reaches ratio
+// percent, a full chunk is compacted for any garbage to make room for the new objects, whose
+// offsets follow the holes.
+func (s *TinyStore) IsReadyToCompact(chunkId int, ratio uint64) bool {
+	stat, err := s.ChunkStat(chunkId)
+	if err != nil || stat.GarbageBytes == 0 {
+		return false
 	}
+	return stat.Full || stat.GarbageRatio >= ratio
+}
 
-	return false
+// PunchGarbage reclaims the garbage of the chunk by punching holes between the live objects,
+// it returns the size of the holes punched.
+func (s *TinyStore) PunchGarbage(chunkId int) (punched uint64, err error) {
+	c, ok := s.chunks[chunkId]
+	if !ok {
+		return 0, ErrorFileNotFound
+	}
+	return c.punchGarbage()
 }
 
-func (s *TinyStore) DoCompactWork(chunkID int) (err error, released uint64) {
+// DoCompactWork rewrites the live objects of the chunk to a new file, the throttle paces
+// the copy of the objects.
+func (s *TinyStore) DoCompactWork(chunkID int, throttle CompactThrottle) (err error, released uint64) {
 	_, ok := s.chunks[chunkID]
 	if !ok {
 		return ErrorFileNotFound, 0
 	}
 
-	err, released = s.doCompactAndCommit(chunkID)
+	err, released = s.doCompactAndCommit(chunkID, throttle)
 	if err != nil {
 		return err, 0
 	}
@@ -442,7 +533,7 @@ func (s *TinyStore) MoveChunkToUnavailChan() {
 	}
 }
 
-func (s *TinyStore) doCompactAndCommit(chunkID int) (err error, released uint64) {
+func (s *TinyStore) doCompactAndCommit(chunkID int, throttle CompactThrottle) (err error, released uint64) {
 	cc := s.chunks[chunkID]
 	// prevent write and delete operations
 	if !cc.compactLock.TryLockTimed(CompactMaxWait) {
@@ -451,7 +542,7 @@ func (s *TinyStore) doCompactAndCommit(chunkID int) (err error, released uint64)
 	defer cc.compactLock.Unlock()
 
 	sizeBeforeCompact := cc.tree.FileBytes()
-	if err = cc.doCompact(); err != nil {
+	if err = cc.doCompact(throttle); err != nil {
 		return ErrorCompaction, 0
 	}
 
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestTinyStore_CompactByGarbage(t *testing.T) {
+	store, dir := newTestTinyStore(t)
+	defer os.RemoveAll(dir)
+	defer store.CloseAll()
+
+	const (
+		chunkId    = 1
+		objectSize = 1000
+	)
+	objects := writeTestObjects(t, store, chunkId, 10, objectSize)
+	for oid := uint64(1); oid <= 6; oid++ {
+		if err := store.MarkDelete(chunkId, int64(oid), objectSize); err != nil {
+			t.Fatal(err)
+		}
+		delete(objects, oid)
+	}
+
+	stat, err := store.ChunkStat(chunkId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.FileSize != 10*objectSize || stat.LiveObjects != 4 || stat.LiveBytes != 4*objectSize ||
+		stat.DeletedBytes != 6*objectSize || stat.GarbageRatio != 60 {
+		t.Fatalf("unexpected stat before compaction %+v", stat)
+	}
+	if !store.IsReadyToCompact(chunkId, 50) || store.IsReadyToCompact(chunkId, 70) {
+		t.Fatalf("garbage ratio %v compared wrong", stat.GarbageRatio)
+	}
+
+	var throttled int
+	if err, _ = store.DoCompactWork(chunkId, func(n int) { throttled += n }); err != nil {
+		t.Fatal(err)
+	}
+	if throttled != 4*objectSize {
+		t.Fatalf("throttle saw %v bytes, expect %v", throttled, 4*objectSize)
+	}
+	if stat, err = store.ChunkStat(chunkId); err != nil {
+		t.Fatal(err)
+	}
+	if stat.FileSize != 4*objectSize || stat.GarbageBytes != 0 || stat.LiveObjects != 4 {
+		t.Fatalf("unexpected stat after compaction %+v", stat)
+	}
+	buf := make([]byte, objectSize)
+	for oid, data := range objects {
+		if _, err = store.Read(chunkId, int64(oid), objectSize, buf); err != nil {
+			t.Fatalf("read object %v: %v", oid, err)
+		}
+		if !bytes.Equal(buf, data) {
+			t.Fatalf("object %v mismatch after compaction", oid)
+		}
+	}
+}
+
+func TestTinyStore_PunchDeleted(t *testing.T) {
+	store, dir := newTestTinyStore(t)
+	defer os.RemoveAll(dir)
+	defer store.CloseAll()
+
+	const (
+		chunkId    = 1
+		objectSize = 1000
+		count      = 40
+	)
+	objects := writeTestObjects(t, store, chunkId, count, objectSize)
+	store.Sync(chunkId)
+	before, err := store.ChunkStat(chunkId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.ReclaimedBytes != 0 {
+		t.Skipf("chunk file allocated %v of %v bytes before deletion", before.DiskBytes, before.FileSize)
+	}
+
+	// no object covers a whole block, the deletions are punched by PunchGarbage
+	for oid := uint64(1); oid <= count/2; oid++ {
+		if err := store.MarkDelete(chunkId, int64(oid), objectSize); err != nil {
+			t.Fatal(err)
+		}
+		delete(objects, oid)
+	}
+	punched, err := store.PunchGarbage(chunkId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if punched != 4*PunchHoleAlign {
+		t.Fatalf("punched %v bytes, expect %v", punched, 4*PunchHoleAlign)
+	}
+	store.Sync(chunkId)
+	stat, err := store.ChunkStat(chunkId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.FileSize != count*objectSize || stat.GarbageBytes != count/2*objectSize {
+		t.Fatalf("unexpected stat after punching %+v", stat)
+	}
+	if stat.ReclaimedBytes == 0 {
+		t.Skip("file system does not support holes")
+	}
+	if store.IsReadyToCompact(chunkId, 50) {
+		t.Fatalf("punched garbage still counted %+v", stat)
+	}
+
+	buf := make([]byte, objectSize)
+	for oid, data := range objects {
+		if _, err = store.Read(chunkId, int64(oid), objectSize, buf); err != nil {
+			t.Fatalf("read object %v: %v", oid, err)
+		}
+		if !bytes.Equal(buf, data) {
+			t.Fatalf("object %v mismatch after punching", oid)
+		}
+	}
+	if _, err = store.Read(chunkId, 1, objectSize, buf); err != ErrorObjNotFound {
+		t.Fatalf("read deleted object err[%v]", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Package compress implements the codecs the data nodes compress the blocks of extents with.
+// All of them are pure Go, lz4 is fast enough for the write path, flate saves more space.
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type Codec uint8
+
+const (
+	CodecNone Codec = iota
+	CodecLZ4
+	CodecFlate
+)
+
+var (
+	ErrUnknownCodec = errors.New("unknown compression codec")
+	ErrCorrupted    = errors.New("corrupted compressed data")
+)
+
+var codecNames = map[Codec]string{
+	CodecNone:  "none",
+	CodecLZ4:   "lz4",
+	CodecFlate: "flate",
+}
+
+// ParseCodec returns the codec of the name, an empty name is CodecNone.
+func ParseCodec(name string) (Codec, error) {
+	if name == "" {
+		return CodecNone, nil
+	}
+	for codec, codecName := range codecNames {
+		if codecName == name {
+			return codec, nil
+		}
+	}
+	return CodecNone, fmt.Errorf("%v: %v", ErrUnknownCodec, name)
+}
+
+func (c Codec) String() string {
+	if name, ok := codecNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("codec(%d)", uint8(c))
+}
+
+// Valid tells whether the codec compresses data.
+func (c Codec) Valid() bool {
+	return c == CodecLZ4 || c == CodecFlate
+}
+
+// Compress returns the compressed src, it may be larger than src when the data does not compress.
+func (c Codec) Compress(src []byte) (dst []byte, err error) {
+	switch c {
+	case CodecLZ4:
+		return lz4Compress(src), nil
+	case CodecFlate:
+		buf := bytes.NewBuffer(make([]byte, 0, len(src)/2))
+		var w *flate.Writer
+		if w, err = flate.NewWriter(buf, flate.BestSpeed); err != nil {
+			return
+		}
+		if _, err = w.Write(src); err != nil {
+			return
+		}
+		if err = w.Close(); err != nil {
+			return
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, ErrUnknownCodec
+}
+
+// Decompress decompresses src into dst, which must have the exact size of the raw data.
+func (c Codec) Decompress(dst, src []byte) (err error) {
+	var n int
+	switch c {
+	case CodecLZ4:
+		if n, err = lz4Decompress(dst, src); err != nil {
+			return
+		}
+	case CodecFlate:
+		r := flate.NewReader(bytes.NewReader(src))
+		defer r.Close()
+		if n, err = io.ReadFull(r, dst); err != nil {
+			return ErrCorrupted
+		}
+		var extra [1]byte
+		if m, _ := r.Read(extra[:]); m != 0 {
+			return ErrCorrupted
+		}
+	default:
+		return ErrUnknownCodec
+	}
+	if n != len(dst) {
+		return ErrCorrupted
+	}
+	return
+}
@@ -0,0 +1,69 @@
+package compress
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func testData() map[string][]byte {
+	random := make([]byte, 256*1024)
+	rand.Read(random)
+	logs := strings.Repeat("2018-06-01 12:00:00 INFO datanode write extent ok\n", 6000)
+	return map[string][]byte{
+		"empty":  {},
+		"short":  []byte("abc"),
+		"zeros":  make([]byte, 256*1024),
+		"random": random,
+		"logs":   []byte(logs[:256*1024]),
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{CodecLZ4, CodecFlate} {
+		for name, data := range testData() {
+			compressed, err := codec.Compress(data)
+			if err != nil {
+				t.Fatalf("%v %v: %v", codec, name, err)
+			}
+			raw := make([]byte, len(data))
+			if err = codec.Decompress(raw, compressed); err != nil {
+				t.Fatalf("%v %v: %v", codec, name, err)
+			}
+			if !bytes.Equal(raw, data) {
+				t.Fatalf("%v %v: data differs after round trip", codec, name)
+			}
+			if name == "logs" && len(compressed) > len(data)/4 {
+				t.Fatalf("%v: logs compressed to %v of %v bytes", codec, len(compressed), len(data))
+			}
+		}
+	}
+}
+
+func TestDecompressCorrupted(t *testing.T) {
+	data := testData()["logs"]
+	for _, codec := range []Codec{CodecLZ4, CodecFlate} {
+		compressed, _ := codec.Compress(data)
+		if err := codec.Decompress(make([]byte, len(data)-1), compressed); err == nil {
+			t.Fatalf("%v: decompress into a short buffer must fail", codec)
+		}
+		if err := codec.Decompress(make([]byte, len(data)), compressed[:len(compressed)/2]); err == nil {
+			t.Fatalf("%v: decompress of truncated data must fail", codec)
+		}
+	}
+}
+
+func TestParseCodec(t *testing.T) {
+	for _, codec := range []Codec{CodecNone, CodecLZ4, CodecFlate} {
+		if parsed, err := ParseCodec(codec.String()); err != nil || parsed != codec {
+			t.Fatalf("parse %v: %v %v", codec, parsed, err)
+		}
+	}
+	if codec, err := ParseCodec(""); err != nil || codec != CodecNone {
+		t.Fatalf("empty name is %v %v", codec, err)
+	}
+	if _, err := ParseCodec("zip"); err == nil {
+		t.Fatalf("unknown codec is parsed")
+	}
+}
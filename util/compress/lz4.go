@@ -0,0 +1,134 @@
+package compress
+
+import (
+	"encoding/binary"
+)
+
+// The lz4 block format: a block is a list of sequences, each sequence is a token, literals
+// and a match. The high 4 bits of the token are the literal length and the low 4 bits the
+// match length minus minMatch, 15 means more length bytes follow. The match is a 2 bytes
+// little endian offset back into the output. The last sequence has literals only.
+const (
+	minMatch     = 4
+	lastLiterals = 5  // the last 5 bytes are always literals
+	mfLimit      = 12 // the last match starts at least 12 bytes before the end
+	maxOffset    = 65535
+	hashLog      = 16
+)
+
+func lz4Hash(seq uint32) uint32 {
+	return (seq * 2654435761) >> (32 - hashLog)
+}
+
+func lz4Compress(src []byte) (dst []byte) {
+	dst = make([]byte, 0, len(src)+len(src)/255+16)
+	anchor := 0
+	if len(src) > mfLimit {
+		table := make([]int32, 1<<hashLog)
+		limit := len(src) - mfLimit
+		for i := 0; i < limit; {
+			seq := binary.LittleEndian.Uint32(src[i:])
+			h := lz4Hash(seq)
+			ref := int(table[h]) - 1
+			table[h] = int32(i + 1)
+			if ref < 0 || i-ref > maxOffset || binary.LittleEndian.Uint32(src[ref:]) != seq {
+				i++
+				continue
+			}
+			matchLen := minMatch
+			for i+matchLen < len(src)-lastLiterals && src[ref+matchLen] == src[i+matchLen] {
+				matchLen++
+			}
+			dst = appendToken(dst, i-anchor, matchLen-minMatch)
+			dst = append(dst, src[anchor:i]...)
+			dst = append(dst, byte(i-ref), byte((i-ref)>>8))
+			if matchLen-minMatch >= 15 {
+				dst = appendLength(dst, matchLen-minMatch-15)
+			}
+			i += matchLen
+			anchor = i
+		}
+	}
+	dst = appendToken(dst, len(src)-anchor, 0)
+	return append(dst, src[anchor:]...)
+}
+
+// appendToken appends the token and the extra bytes of the literal length.
+func appendToken(dst []byte, litLen, matchLen int) []byte {
+	token := byte(matchLen)
+	if matchLen >= 15 {
+		token = 15
+	}
+	if litLen >= 15 {
+		dst = append(dst, 15<<4|token)
+		return appendLength(dst, litLen-15)
+	}
+	return append(dst, byte(litLen)<<4|token)
+}
+
+func appendLength(dst []byte, length int) []byte {
+	for ; length >= 255; length -= 255 {
+		dst = append(dst, 255)
+	}
+	return append(dst, byte(length))
+}
+
+func readLength(src []byte, si int, length int) (int, int, error) {
+	for {
+		if si >= len(src) {
+			return 0, si, ErrCorrupted
+		}
+		b := src[si]
+		si++
+		length += int(b)
+		if b != 255 {
+			return length, si, nil
+		}
+	}
+}
+
+func lz4Decompress(dst, src []byte) (di int, err error) {
+	si := 0
+	for si < len(src) {
+		token := src[si]
+		si++
+		litLen := int(token >> 4)
+		if litLen == 15 {
+			if litLen, si, err = readLength(src, si, litLen); err != nil {
+				return
+			}
+		}
+		if si+litLen > len(src) || di+litLen > len(dst) {
+			return di, ErrCorrupted
+		}
+		di += copy(dst[di:], src[si:si+litLen])
+		si += litLen
+		if si == len(src) {
+			break
+		}
+		if si+2 > len(src) {
+			return di, ErrCorrupted
+		}
+		offset := int(src[si]) | int(src[si+1])<<8
+		si += 2
+		if offset == 0 || offset > di {
+			return di, ErrCorrupted
+		}
+		matchLen := int(token & 15)
+		if matchLen == 15 {
+			if matchLen, si, err = readLength(src, si, matchLen); err != nil {
+				return
+			}
+		}
+		matchLen += minMatch
+		if di+matchLen > len(dst) {
+			return di, ErrCorrupted
+		}
+		// the match may overlap the bytes it produces, so it is copied byte by byte
+		for k := 0; k < matchLen; k++ {
+			dst[di+k] = dst[di-offset+k]
+		}
+		di += matchLen
+	}
+	return
+}
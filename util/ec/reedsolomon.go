@@ -0,0 +1,302 @@
+// Package ec implements a systematic Reed-Solomon code over GF(2^8) for the
+// erasure-coded data partitions. k data shards are stored as they are, m parity
+// shards are computed from them, and any k of the k+m shards rebuild the others.
+package ec
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxTotalShards is the limit of k+m imposed by the field size.
+	MaxTotalShards = 255
+	// the primitive polynomial x^8+x^4+x^3+x^2+1
+	fieldPolynomial = 0x11d
+)
+
+var (
+	ErrInvalidShardNum = errors.New("invalid number of shards")
+	ErrShardSize       = errors.New("shards have different sizes")
+	ErrTooFewShards    = errors.New("too few shards to reconstruct")
+	ErrSingularMatrix  = errors.New("matrix is singular")
+)
+
+var (
+	expTable [512]byte
+	logTable [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		expTable[i] = byte(x)
+		logTable[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= fieldPolynomial
+		}
+	}
+	for i := 255; i < len(expTable); i++ {
+		expTable[i] = expTable[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func gfInv(a byte) byte {
+	return expTable[255-int(logTable[a])]
+}
+
+func gfExp(a byte, n int) byte {
+	if n == 0 {
+		return 1
+	}
+	if a == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])*n)%255]
+}
+
+// mulAdd adds c*in to out
+func mulAdd(c byte, in, out []byte) {
+	if c == 0 {
+		return
+	}
+	logC := int(logTable[c])
+	for i, v := range in {
+		if v != 0 {
+			out[i] ^= expTable[logC+int(logTable[v])]
+		}
+	}
+}
+
+type matrix [][]byte
+
+func newMatrix(rows, cols int) matrix {
+	m := make(matrix, rows)
+	for i := range m {
+		m[i] = make([]byte, cols)
+	}
+	return m
+}
+
+func (m matrix) multiply(right matrix) matrix {
+	result := newMatrix(len(m), len(right[0]))
+	for r := range m {
+		for c := range right[0] {
+			var v byte
+			for i := range right {
+				v ^= gfMul(m[r][i], right[i][c])
+			}
+			result[r][c] = v
+		}
+	}
+	return result
+}
+
+// invert returns the inverse of a square matrix by Gauss-Jordan elimination.
+func (m matrix) invert() (matrix, error) {
+	n := len(m)
+	work := newMatrix(n, 2*n)
+	for r := 0; r < n; r++ {
+		copy(work[r], m[r])
+		work[r][n+r] = 1
+	}
+	for c := 0; c < n; c++ {
+		if work[c][c] == 0 {
+			for r := c + 1; r < n; r++ {
+				if work[r][c] != 0 {
+					work[c], work[r] = work[r], work[c]
+					break
+				}
+			}
+		}
+		if work[c][c] == 0 {
+			return nil, ErrSingularMatrix
+		}
+		if scale := work[c][c]; scale != 1 {
+			inv := gfInv(scale)
+			for i := range work[c] {
+				work[c][i] = gfMul(work[c][i], inv)
+			}
+		}
+		for r := 0; r < n; r++ {
+			if r != c && work[r][c] != 0 {
+				factor := work[r][c]
+				for i := range work[r] {
+					work[r][i] ^= gfMul(factor, work[c][i])
+				}
+			}
+		}
+	}
+	inverse := newMatrix(n, n)
+	for r := 0; r < n; r++ {
+		copy(inverse[r], work[r][n:])
+	}
+	return inverse, nil
+}
+
+// Encoder encodes and reconstructs the shards of a k+m stripe.
+type Encoder struct {
+	dataShards   int
+	parityShards int
+	// the (k+m)*k encoding matrix, its top k rows are the identity
+	matrix matrix
+}
+
+// NewEncoder builds the encoding matrix from a Vandermonde matrix, which is made
+// systematic so that any k of its rows are still invertible.
+func NewEncoder(dataShards, parityShards int) (e *Encoder, err error) {
+	if dataShards <= 0 || parityShards <= 0 || dataShards+parityShards > MaxTotalShards {
+		return nil, ErrInvalidShardNum
+	}
+	total := dataShards + parityShards
+	vandermonde := newMatrix(total, dataShards)
+	for r := 0; r < total; r++ {
+		for c := 0; c < dataShards; c++ {
+			vandermonde[r][c] = gfExp(byte(r), c)
+		}
+	}
+	top, err := vandermonde[:dataShards].invert()
+	if err != nil {
+		return
+	}
+	e = &Encoder{
+		dataShards:   dataShards,
+		parityShards: parityShards,
+		matrix:       vandermonde.multiply(top),
+	}
+	return
+}
+
+func (e *Encoder) DataShards() int {
+	return e.dataShards
+}
+
+func (e *Encoder) ParityShards() int {
+	return e.parityShards
+}
+
+func (e *Encoder) TotalShards() int {
+	return e.dataShards + e.parityShards
+}
+
+// Encode computes the parity shards from the data shards. All k+m shards must be
+// allocated with the same size.
+func (e *Encoder) Encode(shards [][]byte) (err error) {
+	if len(shards) != e.TotalShards() {
+		return ErrInvalidShardNum
+	}
+	size := len(shards[0])
+	for _, shard := range shards {
+		if len(shard) != size {
+			return ErrShardSize
+		}
+	}
+	for i := 0; i < e.parityShards; i++ {
+		e.computeRow(e.matrix[e.dataShards+i], shards[:e.dataShards], shards[e.dataShards+i])
+	}
+	return
+}
+
+func (e *Encoder) computeRow(row []byte, inputs [][]byte, out []byte) {
+	for i := range out {
+		out[i] = 0
+	}
+	for i, in := range inputs {
+		mulAdd(row[i], in, out)
+	}
+}
+
+// Reconstruct rebuilds the missing shards, a missing shard is nil or empty.
+// At least k shards of the same size must be present.
+func (e *Encoder) Reconstruct(shards [][]byte) (err error) {
+	if len(shards) != e.TotalShards() {
+		return ErrInvalidShardNum
+	}
+	size := 0
+	present := 0
+	for _, shard := range shards {
+		if len(shard) == 0 {
+			continue
+		}
+		if size != 0 && len(shard) != size {
+			return ErrShardSize
+		}
+		size = len(shard)
+		present++
+	}
+	if present < e.dataShards {
+		return fmt.Errorf("%v: present[%v] need[%v]", ErrTooFewShards, present, e.dataShards)
+	}
+	if present == e.TotalShards() {
+		return
+	}
+
+	// decode the data shards from the first k present shards
+	subMatrix := newMatrix(e.dataShards, e.dataShards)
+	subShards := make([][]byte, e.dataShards)
+	for i, r := 0, 0; i < e.TotalShards() && r < e.dataShards; i++ {
+		if len(shards[i]) == 0 {
+			continue
+		}
+		copy(subMatrix[r], e.matrix[i])
+		subShards[r] = shards[i]
+		r++
+	}
+	decode, err := subMatrix.invert()
+	if err != nil {
+		return
+	}
+	for i := 0; i < e.dataShards; i++ {
+		if len(shards[i]) != 0 {
+			continue
+		}
+		shards[i] = make([]byte, size)
+		e.computeRow(decode[i], subShards, shards[i])
+	}
+	for i := e.dataShards; i < e.TotalShards(); i++ {
+		if len(shards[i]) != 0 {
+			continue
+		}
+		shards[i] = make([]byte, size)
+		e.computeRow(e.matrix[i], shards[:e.dataShards], shards[i])
+	}
+	return
+}
+
+// SplitStripe cuts a stripe into k data shards of unitSize bytes, shard i holds
+// bytes [i*unitSize, (i+1)*unitSize) of the stripe. A short stripe gives shards of
+// min(len(stripe), unitSize) bytes, padded with zeros, and m parity shards of the
+// same size are allocated behind them.
+func (e *Encoder) SplitStripe(stripe []byte, unitSize int) (shards [][]byte, err error) {
+	if len(stripe) == 0 || len(stripe) > e.dataShards*unitSize {
+		return nil, fmt.Errorf("illegal stripe size[%v] unitSize[%v] dataShards[%v]",
+			len(stripe), unitSize, e.dataShards)
+	}
+	size := unitSize
+	if len(stripe) < unitSize {
+		size = len(stripe)
+	}
+	shards = make([][]byte, e.TotalShards())
+	for i := range shards {
+		shards[i] = make([]byte, size)
+		if i >= e.dataShards {
+			continue
+		}
+		if start := i * unitSize; start < len(stripe) {
+			end := start + size
+			if end > len(stripe) {
+				end = len(stripe)
+			}
+			copy(shards[i], stripe[start:end])
+		}
+	}
+	return
+}
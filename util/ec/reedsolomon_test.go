@@ -0,0 +1,104 @@
+package ec
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func newTestShards(t *testing.T, e *Encoder, size int) [][]byte {
+	shards := make([][]byte, e.TotalShards())
+	for i := range shards {
+		shards[i] = make([]byte, size)
+		if i < e.DataShards() {
+			rand.Read(shards[i])
+		}
+	}
+	if err := e.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	return shards
+}
+
+func TestReconstruct(t *testing.T) {
+	e, err := NewEncoder(4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origin := newTestShards(t, e, 1000)
+	// lose every pair of shards
+	for i := 0; i < e.TotalShards(); i++ {
+		for j := i + 1; j < e.TotalShards(); j++ {
+			shards := make([][]byte, len(origin))
+			copy(shards, origin)
+			shards[i], shards[j] = nil, nil
+			if err = e.Reconstruct(shards); err != nil {
+				t.Fatalf("lost shards[%v,%v]: %v", i, j, err)
+			}
+			for n := range shards {
+				if !bytes.Equal(shards[n], origin[n]) {
+					t.Fatalf("lost shards[%v,%v]: shard %v differs", i, j, n)
+				}
+			}
+		}
+	}
+
+	shards := make([][]byte, len(origin))
+	copy(shards, origin)
+	shards[0], shards[2], shards[5] = nil, nil, nil
+	if err = e.Reconstruct(shards); err == nil {
+		t.Fatalf("reconstruct must fail with k-1 shards")
+	}
+}
+
+func TestNewEncoder(t *testing.T) {
+	for _, c := range []struct{ k, m int }{{0, 1}, {1, 0}, {200, 56}} {
+		if _, err := NewEncoder(c.k, c.m); err == nil {
+			t.Fatalf("k[%v] m[%v] must be refused", c.k, c.m)
+		}
+	}
+	e, err := NewEncoder(10, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origin := newTestShards(t, e, 64)
+	shards := make([][]byte, len(origin))
+	copy(shards, origin)
+	shards[1], shards[3], shards[7], shards[12] = nil, nil, nil, nil
+	if err = e.Reconstruct(shards); err != nil {
+		t.Fatal(err)
+	}
+	for n := range shards {
+		if !bytes.Equal(shards[n], origin[n]) {
+			t.Fatalf("shard %v differs", n)
+		}
+	}
+}
+
+func TestSplitStripe(t *testing.T) {
+	e, _ := NewEncoder(3, 2)
+	stripe := []byte("abcdefgh")
+	shards, err := e.SplitStripe(stripe, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expects := []string{"abcd", "efgh", "\x00\x00\x00\x00"}
+	for i, expect := range expects {
+		if string(shards[i]) != expect {
+			t.Fatalf("shard %v is %q, expect %q", i, shards[i], expect)
+		}
+	}
+	if len(shards[3]) != 4 || len(shards[4]) != 4 {
+		t.Fatalf("parity shards are not allocated")
+	}
+
+	if shards, err = e.SplitStripe([]byte("ab"), 4); err != nil {
+		t.Fatal(err)
+	}
+	if string(shards[0]) != "ab" || len(shards[2]) != 2 {
+		t.Fatalf("short stripe split wrong: %q", shards)
+	}
+	if _, err = e.SplitStripe(make([]byte, 13), 4); err == nil {
+		t.Fatalf("stripe larger than k units must be refused")
+	}
+}
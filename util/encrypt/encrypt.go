@@ -0,0 +1,168 @@
+// Package encrypt implements the encryption at rest of the data nodes. The data keys of a vol
+// are generated by the master and wrapped by the master key of the cluster, which every master
+// and data node loads from a local key file, so only wrapped keys are persisted or sent.
+// The data is encrypted by AES-CTR at its position in a file, which keeps the size and the random
+// access of the files, the crc of the plain data kept by the stores detects a corruption. The data
+// rewritten at a position is encrypted by a new generation, a key stream never encrypts it twice.
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+const KeySize = 32
+
+// MaxOffset is the size of the key stream of a generation of a file.
+const MaxOffset = int64(1) << 32 * aes.BlockSize
+
+var (
+	ErrKeyNotFound = errors.New("data key not found")
+	ErrBadKey      = errors.New("illegal key")
+)
+
+// LoadMasterKey reads the master key of the cluster, the file holds the key in hex.
+func LoadMasterKey(path string) (key []byte, err error) {
+	var data []byte
+	if data, err = ioutil.ReadFile(path); err != nil {
+		return
+	}
+	if key, err = hex.DecodeString(strings.TrimSpace(string(data))); err != nil {
+		return nil, fmt.Errorf("%v: %v", ErrBadKey, err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("%v: master key of %v bytes, want %v", ErrBadKey, len(key), KeySize)
+	}
+	return
+}
+
+// NewDataKey generates a random data key.
+func NewDataKey() (key []byte, err error) {
+	key = make([]byte, KeySize)
+	_, err = rand.Read(key)
+	return
+}
+
+// WrapKey seals a data key by the master key with AES-GCM.
+func WrapKey(masterKey, key []byte) (wrapped []byte, err error) {
+	var aead cipher.AEAD
+	if aead, err = newGCM(masterKey); err != nil {
+		return
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	return aead.Seal(nonce, nonce, key, nil), nil
+}
+
+// UnwrapKey opens a data key sealed by WrapKey.
+func UnwrapKey(masterKey, wrapped []byte) (key []byte, err error) {
+	var aead cipher.AEAD
+	if aead, err = newGCM(masterKey); err != nil {
+		return
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrBadKey
+	}
+	nonce := wrapped[:aead.NonceSize()]
+	if key, err = aead.Open(nil, nonce, wrapped[aead.NonceSize():], nil); err != nil {
+		return nil, fmt.Errorf("%v: %v", ErrBadKey, err)
+	}
+	return
+}
+
+func newGCM(key []byte) (aead cipher.AEAD, err error) {
+	var block cipher.Block
+	if block, err = aes.NewCipher(key); err != nil {
+		return
+	}
+	return cipher.NewGCM(block)
+}
+
+// Cipher encrypts the files of a context, e.g. the extents of a data partition.
+type Cipher struct {
+	block cipher.Block
+}
+
+// NewCipher derives the key of the context from a data key, the files of different
+// contexts with the same id never share a key stream.
+func NewCipher(key []byte, context string) (c *Cipher, err error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(context))
+	c = new(Cipher)
+	if c.block, err = aes.NewCipher(mac.Sum(nil)); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// XORKeyStreamAt encrypts or decrypts src into dst, src is the data at offset of the file.
+// The data rewritten at an offset must be encrypted by another generation, the key stream
+// of a generation of a file only covers the first MaxOffset bytes.
+func (c *Cipher) XORKeyStreamAt(dst, src []byte, fileId uint64, generation uint32, offset int64) {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[:8], fileId)
+	binary.BigEndian.PutUint32(iv[8:12], generation)
+	binary.BigEndian.PutUint32(iv[12:], uint32(offset/aes.BlockSize))
+	stream := cipher.NewCTR(c.block, iv)
+	if skip := int(offset % aes.BlockSize); skip != 0 {
+		pad := make([]byte, skip)
+		stream.XORKeyStream(pad, pad)
+	}
+	stream.XORKeyStream(dst, src)
+}
+
+// Keys are the ciphers of a context by the version of their data key, the files created
+// from now on are encrypted by the current version.
+type Keys struct {
+	ciphers map[uint32]*Cipher
+	current uint32
+}
+
+// NewKeys derives the ciphers of the context from the data keys of a vol, the last version is current.
+func NewKeys(keys map[uint32][]byte, context string) (k *Keys, err error) {
+	k = &Keys{ciphers: make(map[uint32]*Cipher, len(keys))}
+	for version, key := range keys {
+		if version == 0 {
+			return nil, ErrBadKey
+		}
+		if k.ciphers[version], err = NewCipher(key, context); err != nil {
+			return nil, err
+		}
+		if version > k.current {
+			k.current = version
+		}
+	}
+	return
+}
+
+// Current returns the version new files are encrypted by, zero if the keys are empty.
+func (k *Keys) Current() uint32 {
+	if k == nil {
+		return 0
+	}
+	return k.current
+}
+
+// Cipher returns the cipher of a version, the version zero is not encrypted and returns nil.
+func (k *Keys) Cipher(version uint32) (c *Cipher, err error) {
+	if version == 0 {
+		return nil, nil
+	}
+	if k != nil {
+		if c = k.ciphers[version]; c != nil {
+			return
+		}
+	}
+	return nil, fmt.Errorf("%v: version %v", ErrKeyNotFound, version)
+}
@@ -0,0 +1,102 @@
+package encrypt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestWrapKey(t *testing.T) {
+	masterKey, _ := NewDataKey()
+	key, _ := NewDataKey()
+	wrapped, err := WrapKey(masterKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapped, err := UnwrapKey(masterKey, wrapped)
+	if err != nil || !bytes.Equal(unwrapped, key) {
+		t.Fatalf("unwrap %v %v", unwrapped, err)
+	}
+	otherKey, _ := NewDataKey()
+	if _, err = UnwrapKey(otherKey, wrapped); err == nil {
+		t.Fatal("unwrapped by another master key")
+	}
+}
+
+func TestLoadMasterKey(t *testing.T) {
+	key, _ := NewDataKey()
+	f, err := ioutil.TempFile("", "masterkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(hex.EncodeToString(key) + "\n")
+	f.Close()
+	loaded, err := LoadMasterKey(f.Name())
+	if err != nil || !bytes.Equal(loaded, key) {
+		t.Fatalf("load %v %v", loaded, err)
+	}
+	ioutil.WriteFile(f.Name(), []byte("abcd"), 0600)
+	if _, err = LoadMasterKey(f.Name()); err == nil {
+		t.Fatal("short key loaded")
+	}
+}
+
+func TestXORKeyStreamAt(t *testing.T) {
+	key, _ := NewDataKey()
+	c, err := NewCipher(key, "extent/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 1000)
+	rand.Read(data)
+	encrypted := make([]byte, len(data))
+	c.XORKeyStreamAt(encrypted, data, 7, 0, 100)
+	if bytes.Equal(encrypted, data) {
+		t.Fatal("data is not encrypted")
+	}
+	// any part decrypts by its own offset
+	for _, r := range [][2]int{{0, 1000}, {3, 17}, {16, 32}, {333, 1000}} {
+		part := make([]byte, r[1]-r[0])
+		c.XORKeyStreamAt(part, encrypted[r[0]:r[1]], 7, 0, int64(100+r[0]))
+		if !bytes.Equal(part, data[r[0]:r[1]]) {
+			t.Fatalf("range %v mismatch", r)
+		}
+	}
+	other, _ := NewCipher(key, "extent/2")
+	part := make([]byte, len(data))
+	other.XORKeyStreamAt(part, encrypted, 7, 0, 100)
+	if bytes.Equal(part, data) {
+		t.Fatal("another context decrypts the data")
+	}
+	// the data rewritten at the offset by another generation does not share the key stream
+	c.XORKeyStreamAt(part, data, 7, 1, 100)
+	if bytes.Equal(part, encrypted) {
+		t.Fatal("another generation shares the key stream")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	k1, _ := NewDataKey()
+	k2, _ := NewDataKey()
+	keys, err := NewKeys(map[uint32][]byte{1: k1, 2: k2}, "tiny/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.Current() != 2 {
+		t.Fatalf("current %v", keys.Current())
+	}
+	if c, err := keys.Cipher(0); c != nil || err != nil {
+		t.Fatal("version 0 is encrypted")
+	}
+	if _, err = keys.Cipher(3); err == nil {
+		t.Fatal("unknown version found")
+	}
+	var empty *Keys
+	if empty.Current() != 0 {
+		t.Fatal("empty keys have a version")
+	}
+}
@@ -0,0 +1,199 @@
+// Package metrics is a small registry of counters, gauges and histograms
+// exported in the Prometheus text format on /metrics.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	Namespace   = "baudstorage"
+	MetricsPath = "/metrics"
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+var (
+	// DefLatencyBuckets are the upper bounds in seconds of the latency histograms.
+	DefLatencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	// DefaultRegistry is served by Handler.
+	DefaultRegistry = NewRegistry()
+)
+
+// Metric is a family of samples with the same name.
+type Metric interface {
+	Name() string
+	describe() *desc
+	write(w io.Writer)
+}
+
+type Registry struct {
+	sync.RWMutex
+	metrics map[string]Metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]Metric)}
+}
+
+// Register adds m to the registry, a metric with the same name, type and labels registered before
+// is returned instead, so the roles sharing a process share the metric. A metric with the same name
+// but another type or other labels is an error.
+func (r *Registry) Register(m Metric) (Metric, error) {
+	r.Lock()
+	defer r.Unlock()
+	if old, ok := r.metrics[m.Name()]; ok {
+		if reflect.TypeOf(old) != reflect.TypeOf(m) ||
+			!reflect.DeepEqual(old.describe().labelNames, m.describe().labelNames) {
+			return nil, fmt.Errorf("metric %v registered as %T with labels %v, got %T with labels %v",
+				m.Name(), old, old.describe().labelNames, m, m.describe().labelNames)
+		}
+		return old, nil
+	}
+	r.metrics[m.Name()] = m
+	return m, nil
+}
+
+// mustRegister registers m to the DefaultRegistry, the metrics are registered on start,
+// so a conflict is a bug.
+func mustRegister(m Metric) Metric {
+	registered, err := DefaultRegistry.Register(m)
+	if err != nil {
+		panic(err)
+	}
+	return registered
+}
+
+func (r *Registry) Write(w io.Writer) {
+	r.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metrics := make([]Metric, 0, len(names))
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.RUnlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+	r.Write(&buf)
+	w.Header().Set("Content-Type", ContentType)
+	w.Write(buf.Bytes())
+}
+
+// Handler serves the DefaultRegistry.
+func Handler() http.Handler {
+	return DefaultRegistry
+}
+
+var registerOnce sync.Once
+
+// RegisterHandler registers the DefaultRegistry on /metrics of the default http mux once.
+func RegisterHandler() {
+	registerOnce.Do(func() {
+		http.Handle(MetricsPath, DefaultRegistry)
+	})
+}
+
+func metricName(subsystem, name string) string {
+	return Namespace + "_" + subsystem + "_" + name
+}
+
+type desc struct {
+	name       string
+	help       string
+	labelNames []string
+}
+
+func (d *desc) Name() string {
+	return d.name
+}
+
+func (d *desc) describe() *desc {
+	return d
+}
+
+func (d *desc) writeHeader(w io.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, typ)
+}
+
+func (d *desc) labels(values []string, extra ...string) string {
+	if len(values) != len(d.labelNames) {
+		panic(fmt.Sprintf("metric %v expects %v labels, got %v", d.name, len(d.labelNames), len(values)))
+	}
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(values)+1)
+	for i, name := range d.labelNames {
+		pairs = append(pairs, name+"=\""+escapeLabel(values[i])+"\"")
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"=\""+escapeLabel(extra[i+1])+"\"")
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+	labelReplacer = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\"", "\\\"")
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
+
+// Since returns the seconds elapsed since start, for Observe.
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	c := &CounterVec{desc: desc{name: "test_errors_total", help: "errors", labelNames: []string{"op"}},
+		values: make(map[string]float64), labels: make(map[string][]string)}
+	r.Register(c)
+	c.Inc("read")
+	c.Add(2, "read")
+	c.Inc("wr\"ite")
+
+	h := &HistogramVec{desc: desc{name: "test_seconds", help: "latency", labelNames: []string{"op"}},
+		buckets: []float64{0.1, 1}, histograms: make(map[string]*histogram)}
+	r.Register(h)
+	h.Observe(0.05, "read")
+	h.Observe(0.5, "read")
+	h.Observe(5, "read")
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+	out := buf.String()
+	expected := []string{
+		"# TYPE test_errors_total counter",
+		`test_errors_total{op="read"} 3`,
+		`test_errors_total{op="wr\"ite"} 1`,
+		"# TYPE test_seconds histogram",
+		`test_seconds_bucket{op="read",le="0.1"} 1`,
+		`test_seconds_bucket{op="read",le="1"} 2`,
+		`test_seconds_bucket{op="read",le="+Inf"} 3`,
+		`test_seconds_sum{op="read"} 5.55`,
+		`test_seconds_count{op="read"} 3`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing %q in\n%v", line, out)
+		}
+	}
+}
+
+func TestRegisterSameName(t *testing.T) {
+	a := NewGaugeVec("test", "same", "first", "disk")
+	b := NewGaugeVec("test", "same", "second", "disk")
+	if a != b {
+		t.Fatalf("expect the metric registered first")
+	}
+	r := NewRegistry()
+	newGauge := func(labelNames ...string) *GaugeVec {
+		return &GaugeVec{desc: desc{name: "test_conflict", labelNames: labelNames},
+			values: make(map[string]float64), labels: make(map[string][]string)}
+	}
+	if _, err := r.Register(newGauge("disk")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Register(newGauge("disk", "partition")); err == nil {
+		t.Fatal("metric registered again with other labels")
+	}
+	counter := &CounterVec{desc: desc{name: "test_conflict", labelNames: []string{"disk"}}}
+	if _, err := r.Register(counter); err == nil {
+		t.Fatal("metric registered again as another type")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("conflict of the default registry does not panic")
+		}
+	}()
+	NewCounterVec("test", "same", "third", "disk")
+}
+
+func TestGaugeFunc(t *testing.T) {
+	r := NewRegistry()
+	r.Register(&GaugeFunc{desc: desc{name: "test_disk_bytes", help: "disk", labelNames: []string{"disk"}},
+		collect: func(report func(v float64, labelValues ...string)) {
+			report(1024, "/data1")
+		}})
+	var buf bytes.Buffer
+	r.Write(&buf)
+	if !strings.Contains(buf.String(), `test_disk_bytes{disk="/data1"} 1024`) {
+		t.Fatalf("unexpected output %v", buf.String())
+	}
+}
+
+func TestOpMetricsTakeStats(t *testing.T) {
+	m := NewOpMetrics("test_window")
+	now := time.Now()
+	m.ObserveClass("read", "read", now.Add(-10*time.Millisecond), nil)
+	m.ObserveClass("write", "write", now.Add(-30*time.Millisecond), errors.New("failed"))
+	m.ObserveClass("write", "write", now.Add(-10*time.Millisecond), nil)
+	m.ObserveClass("stream", "", now.Add(-time.Second), nil)
+	stats := m.TakeStats()
+	if len(stats) != 2 || stats["read"].Count != 1 || stats["write"].Count != 2 || stats["write"].Errors != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	write := stats["write"]
+	if write.MaxLatency < 30*time.Millisecond || write.AvgLatency < 20*time.Millisecond ||
+		write.AvgLatency > write.MaxLatency {
+		t.Fatalf("unexpected latency %+v", write)
+	}
+	if stats = m.TakeStats(); len(stats) != 0 {
+		t.Fatalf("window not reset %+v", stats)
+	}
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// OpMetrics records the latency and the errors of the requests handled by a role, labeled by opcode.
+type OpMetrics struct {
+	latency *HistogramVec
+	errors  *CounterVec
+	lock    sync.Mutex
+	windows map[string]*opWindow
+}
+
+// opWindow sums the operations of a class since it was last taken, the node reports it in the heartbeat.
+type opWindow struct {
+	count uint64
+	errs  uint64
+	total time.Duration
+	max   time.Duration
+}
+
+// OpStats is the summary of the operations of a class in a window.
+type OpStats struct {
+	Count      uint64
+	Errors     uint64
+	AvgLatency time.Duration
+	MaxLatency time.Duration
+}
+
+func NewOpMetrics(subsystem string) *OpMetrics {
+	return &OpMetrics{
+		latency: NewHistogramVec(subsystem, "op_duration_seconds", "Latency of the operations by opcode.",
+			DefLatencyBuckets, "op"),
+		errors:  NewCounterVec(subsystem, "op_errors_total", "Count of the failed operations by opcode.", "op"),
+		windows: make(map[string]*opWindow),
+	}
+}
+
+func (m *OpMetrics) Observe(op string, start time.Time, err error) {
+	m.observe(op, time.Since(start), err)
+}
+
+// ObserveClass records an operation like Observe and sums it into the window of its class,
+// the operations of the empty class, like the streams and the repairs, are not summed.
+func (m *OpMetrics) ObserveClass(op, class string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	m.observe(op, elapsed, err)
+	if class == "" {
+		return
+	}
+	m.lock.Lock()
+	w, ok := m.windows[class]
+	if !ok {
+		w = new(opWindow)
+		m.windows[class] = w
+	}
+	w.count++
+	if err != nil {
+		w.errs++
+	}
+	w.total += elapsed
+	if elapsed > w.max {
+		w.max = elapsed
+	}
+	m.lock.Unlock()
+}
+
+func (m *OpMetrics) observe(op string, elapsed time.Duration, err error) {
+	m.latency.Observe(elapsed.Seconds(), op)
+	if err != nil {
+		m.errors.Inc(op)
+	}
+}
+
+// TakeStats returns the summary of the operations of every class observed since the last call
+// and starts new windows.
+func (m *OpMetrics) TakeStats() (stats map[string]OpStats) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	stats = make(map[string]OpStats, len(m.windows))
+	for class, w := range m.windows {
+		s := OpStats{Count: w.count, Errors: w.errs, MaxLatency: w.max}
+		if w.count > 0 {
+			s.AvgLatency = w.total / time.Duration(w.count)
+		}
+		stats[class] = s
+	}
+	m.windows = make(map[string]*opWindow)
+	return
+}
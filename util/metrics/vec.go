@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	desc
+	sync.Mutex
+	values map[string]float64
+	labels map[string][]string
+}
+
+func NewCounterVec(subsystem, name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{
+		desc:   desc{name: metricName(subsystem, name), help: help, labelNames: labelNames},
+		values: make(map[string]float64),
+		labels: make(map[string][]string),
+	}
+	return mustRegister(c).(*CounterVec)
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	key := labelKey(labelValues)
+	c.Lock()
+	if _, ok := c.labels[key]; !ok {
+		c.labels[key] = append([]string(nil), labelValues...)
+	}
+	c.values[key] += v
+	c.Unlock()
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.Lock()
+	defer c.Unlock()
+	c.writeHeader(w, TypeCounter)
+	for _, key := range sortedKeys(c.labels) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.desc.labels(c.labels[key]), formatFloat(c.values[key]))
+	}
+}
+
+// GaugeVec is a gauge partitioned by labels.
+type GaugeVec struct {
+	desc
+	sync.Mutex
+	values map[string]float64
+	labels map[string][]string
+}
+
+func NewGaugeVec(subsystem, name, help string, labelNames ...string) *GaugeVec {
+	g := &GaugeVec{
+		desc:   desc{name: metricName(subsystem, name), help: help, labelNames: labelNames},
+		values: make(map[string]float64),
+		labels: make(map[string][]string),
+	}
+	return mustRegister(g).(*GaugeVec)
+}
+
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	key := labelKey(labelValues)
+	g.Lock()
+	if _, ok := g.labels[key]; !ok {
+		g.labels[key] = append([]string(nil), labelValues...)
+	}
+	g.values[key] = v
+	g.Unlock()
+}
+
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	key := labelKey(labelValues)
+	g.Lock()
+	if _, ok := g.labels[key]; !ok {
+		g.labels[key] = append([]string(nil), labelValues...)
+	}
+	g.values[key] += v
+	g.Unlock()
+}
+
+// Reset drops all the samples, the gauges of removed partitions are not exported any more.
+func (g *GaugeVec) Reset() {
+	g.Lock()
+	g.values = make(map[string]float64)
+	g.labels = make(map[string][]string)
+	g.Unlock()
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.Lock()
+	defer g.Unlock()
+	g.writeHeader(w, TypeGauge)
+	for _, key := range sortedKeys(g.labels) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.desc.labels(g.labels[key]), formatFloat(g.values[key]))
+	}
+}
+
+// GaugeFunc collects the samples by calling collect on every scrape,
+// it suits the values kept by the server already, such as disk usage and raft state.
+type GaugeFunc struct {
+	desc
+	collect func(report func(v float64, labelValues ...string))
+}
+
+func NewGaugeFunc(subsystem, name, help string, labelNames []string,
+	collect func(report func(v float64, labelValues ...string))) *GaugeFunc {
+	g := &GaugeFunc{
+		desc:    desc{name: metricName(subsystem, name), help: help, labelNames: labelNames},
+		collect: collect,
+	}
+	return mustRegister(g).(*GaugeFunc)
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	g.writeHeader(w, TypeGauge)
+	g.collect(func(v float64, labelValues ...string) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.desc.labels(labelValues), formatFloat(v))
+	})
+}
+
+type histogram struct {
+	labels []string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	desc
+	sync.Mutex
+	buckets    []float64
+	histograms map[string]*histogram
+}
+
+func NewHistogramVec(subsystem, name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:       desc{name: metricName(subsystem, name), help: help, labelNames: labelNames},
+		buckets:    buckets,
+		histograms: make(map[string]*histogram),
+	}
+	return mustRegister(h).(*HistogramVec)
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := labelKey(labelValues)
+	h.Lock()
+	defer h.Unlock()
+	hist, ok := h.histograms[key]
+	if !ok {
+		hist = &histogram{labels: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.histograms[key] = hist
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hist.counts[i]++
+		}
+	}
+	hist.sum += v
+	hist.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.Lock()
+	defer h.Unlock()
+	h.writeHeader(w, TypeHistogram)
+	keys := make([]string, 0, len(h.histograms))
+	for key := range h.histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		hist := h.histograms[key]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.desc.labels(hist.labels, "le", formatFloat(upper)), hist.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.desc.labels(hist.labels, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.desc.labels(hist.labels), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.desc.labels(hist.labels), hist.count)
+	}
+}
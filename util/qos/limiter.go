@@ -0,0 +1,185 @@
+// Package qos enforces the per-volume limits pushed by the master in the heartbeats.
+package qos
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tiglabs/baudstorage/proto"
+)
+
+// TokenBucket holds at most one second of tokens. A request larger than the bucket is
+// admitted when the bucket is full and leaves it in debt, so large reads and writes are
+// paced instead of refused forever.
+type TokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// NewTokenBucket returns a full bucket refilled with rate tokens per second.
+func NewTokenBucket(rate uint64) *TokenBucket {
+	return &TokenBucket{
+		rate:     float64(rate),
+		capacity: float64(rate),
+		tokens:   float64(rate),
+		last:     time.Now(),
+	}
+}
+
+// Allow takes n tokens if they are available.
+func (b *TokenBucket) Allow(n uint64) bool {
+	return b.allowAt(n, time.Now())
+}
+
+func (b *TokenBucket) allowAt(n uint64, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+	}
+	need := float64(n)
+	if need > b.capacity {
+		need = b.capacity
+	}
+	if b.tokens < need {
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
+
+func (b *TokenBucket) put(n uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += float64(n)
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// allow on a nil bucket means there is no limit.
+func allow(b *TokenBucket, n uint64) bool {
+	return b == nil || b.Allow(n)
+}
+
+func newBucket(rate uint64) *TokenBucket {
+	if rate == 0 {
+		return nil
+	}
+	return NewTokenBucket(rate)
+}
+
+type volBuckets struct {
+	limit      proto.VolQos
+	readOps    *TokenBucket
+	writeOps   *TokenBucket
+	readBytes  *TokenBucket
+	writeBytes *TokenBucket
+	metaOps    *TokenBucket
+}
+
+func newVolBuckets(limit proto.VolQos) *volBuckets {
+	return &volBuckets{
+		limit:      limit,
+		readOps:    newBucket(limit.ReadIOPS),
+		writeOps:   newBucket(limit.WriteIOPS),
+		readBytes:  newBucket(limit.ReadBandwidth),
+		writeBytes: newBucket(limit.WriteBandwidth),
+		metaOps:    newBucket(limit.MetaOPS),
+	}
+}
+
+// Limiter holds the token buckets of the limited volumes on this node,
+// a volume without limits is never refused.
+type Limiter struct {
+	sync.RWMutex
+	vols map[string]*volBuckets
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{vols: make(map[string]*volBuckets)}
+}
+
+// Update replaces the limits with the ones of the last heartbeat. The buckets of
+// a volume whose limits have not changed are kept with their tokens.
+func (l *Limiter) Update(limits map[string]*proto.VolQos) {
+	vols := make(map[string]*volBuckets, len(limits))
+	l.Lock()
+	defer l.Unlock()
+	for name, limit := range limits {
+		if limit == nil || *limit == (proto.VolQos{}) {
+			continue
+		}
+		if old, ok := l.vols[name]; ok && old.limit == *limit {
+			vols[name] = old
+			continue
+		}
+		vols[name] = newVolBuckets(*limit)
+	}
+	l.vols = vols
+}
+
+// Limits returns a copy of the limits in force.
+func (l *Limiter) Limits() (limits map[string]proto.VolQos) {
+	l.RLock()
+	defer l.RUnlock()
+	limits = make(map[string]proto.VolQos, len(l.vols))
+	for name, vb := range l.vols {
+		limits[name] = vb.limit
+	}
+	return
+}
+
+func (l *Limiter) get(vol string) *volBuckets {
+	l.RLock()
+	defer l.RUnlock()
+	return l.vols[vol]
+}
+
+// AllowRead charges one read op and size bytes to the volume.
+func (l *Limiter) AllowRead(vol string, size int) error {
+	vb := l.get(vol)
+	if vb == nil {
+		return nil
+	}
+	return allowIO(vol, "read", vb.readOps, vb.readBytes, uint64(size))
+}
+
+// AllowWrite charges one write op and size bytes to the volume.
+func (l *Limiter) AllowWrite(vol string, size int) error {
+	vb := l.get(vol)
+	if vb == nil {
+		return nil
+	}
+	return allowIO(vol, "write", vb.writeOps, vb.writeBytes, uint64(size))
+}
+
+// AllowMeta charges one metadata op to the volume.
+func (l *Limiter) AllowMeta(vol string) error {
+	vb := l.get(vol)
+	if vb == nil || allow(vb.metaOps, 1) {
+		return nil
+	}
+	return fmt.Errorf("vol[%v] exceeds meta ops limit[%v/s]", vol, vb.limit.MetaOPS)
+}
+
+func allowIO(vol, kind string, ops, bytes *TokenBucket, size uint64) error {
+	if !allow(ops, 1) {
+		return fmt.Errorf("vol[%v] exceeds %v iops limit", vol, kind)
+	}
+	if !allow(bytes, size) {
+		if ops != nil {
+			ops.put(1)
+		}
+		return fmt.Errorf("vol[%v] exceeds %v bandwidth limit", vol, kind)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package qos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tiglabs/baudstorage/proto"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := NewTokenBucket(10)
+	now := b.last
+	for i := 0; i < 10; i++ {
+		if !b.allowAt(1, now) {
+			t.Fatalf("token %v of a full bucket refused", i)
+		}
+	}
+	if b.allowAt(1, now) {
+		t.Fatalf("empty bucket must refuse")
+	}
+	if !b.allowAt(1, now.Add(100*time.Millisecond)) {
+		t.Fatalf("bucket must refill one token in 100ms")
+	}
+
+	// a request larger than the bucket passes on a full bucket and leaves it in debt
+	now = now.Add(10 * time.Second)
+	if !b.allowAt(25, now) {
+		t.Fatalf("large request refused on a full bucket")
+	}
+	if b.allowAt(1, now.Add(time.Second)) {
+		t.Fatalf("bucket in debt must refuse")
+	}
+	if !b.allowAt(1, now.Add(2*time.Second+100*time.Millisecond)) {
+		t.Fatalf("bucket must recover from the debt")
+	}
+}
+
+func TestLimiterUpdate(t *testing.T) {
+	l := NewLimiter()
+	l.Update(map[string]*proto.VolQos{
+		"vol1": {WriteIOPS: 1},
+		"vol2": {},
+	})
+	if err := l.AllowWrite("vol2", 100); err != nil {
+		t.Fatalf("vol without limits refused: %v", err)
+	}
+	if err := l.AllowRead("vol1", 100); err != nil {
+		t.Fatalf("unlimited read refused: %v", err)
+	}
+	if err := l.AllowWrite("vol1", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.AllowWrite("vol1", 100); err == nil {
+		t.Fatalf("second write must exceed the limit")
+	}
+
+	// the same limits keep the drained bucket
+	l.Update(map[string]*proto.VolQos{"vol1": {WriteIOPS: 1}})
+	if err := l.AllowWrite("vol1", 100); err == nil {
+		t.Fatalf("unchanged limits must keep the tokens")
+	}
+	l.Update(nil)
+	if err := l.AllowWrite("vol1", 100); err != nil {
+		t.Fatalf("removed limits must not refuse: %v", err)
+	}
+	if len(l.Limits()) != 0 {
+		t.Fatalf("unexpected limits %v", l.Limits())
+	}
+}
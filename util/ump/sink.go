@@ -0,0 +1,286 @@
+package ump
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tiglabs/baudstorage/util/config"
+	"github.com/tiglabs/baudstorage/util/log"
+)
+
+const (
+	CfgSink         = "umpSink"
+	CfgFileDir      = "umpFileDir"
+	CfgStatsdAddr   = "umpStatsdAddr"
+	CfgStatsdPrefix = "umpStatsdPrefix"
+)
+
+var ErrSinkNotInitialized = errors.New("ump sink is not initialized")
+
+const (
+	SinkUmp     = "ump"
+	SinkFile    = "file"
+	SinkStatsd  = "statsd"
+	SinkMemory  = "memory"
+	SinkDiscard = "none"
+)
+
+// Sink receives every record produced by BeforeTP/AfterTP, Alive and Alarm.
+// The record is owned by the sink once passed in, and should be returned to
+// its pool when the sink is done with it. Writes must never block the caller.
+type Sink interface {
+	Init(module string) error
+	WriteTP(tp *FunctionTp)
+	WriteAlive(alive *SystemAlive)
+	WriteAlarm(alarm *BusinessAlarm)
+}
+
+type sinkHolder struct {
+	sink Sink
+}
+
+var currentSink atomic.Value
+
+func init() {
+	currentSink.Store(sinkHolder{sink: NewLogSink(LogDir)})
+}
+
+func getSink() Sink {
+	return currentSink.Load().(sinkHolder).sink
+}
+
+// SetSink replaces the sink records are routed to. It should be called
+// before InitUmp so that the new sink gets initialized.
+func SetSink(s Sink) {
+	currentSink.Store(sinkHolder{sink: s})
+}
+
+// ConfigSink selects the sink from the node config file. When the config
+// does not name a sink the default UMP log sink is kept.
+func ConfigSink(cfg *config.Config) (err error) {
+	var s Sink
+	switch name := cfg.GetString(CfgSink); name {
+	case "", SinkUmp:
+		return
+	case SinkFile:
+		dir := cfg.GetString(CfgFileDir)
+		if dir == "" {
+			return fmt.Errorf("ump sink %v requires %v", name, CfgFileDir)
+		}
+		s = NewLogSink(dir)
+	case SinkStatsd:
+		addr := cfg.GetString(CfgStatsdAddr)
+		if addr == "" {
+			return fmt.Errorf("ump sink %v requires %v", name, CfgStatsdAddr)
+		}
+		s = NewStatsdSink(addr, cfg.GetString(CfgStatsdPrefix))
+	case SinkMemory:
+		s = NewMemorySink()
+	case SinkDiscard:
+		s = DiscardSink{}
+	default:
+		return fmt.Errorf("unknown ump sink %v", name)
+	}
+	SetSink(s)
+	return
+}
+
+// LogSink writes records as json lines to rotated files under dir,
+// which is the format collected by the UMP agent.
+type LogSink struct {
+	dir string
+}
+
+func NewLogSink(dir string) *LogSink {
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	return &LogSink{dir: dir}
+}
+
+func (s *LogSink) Init(module string) (err error) {
+	if err = initLogName(s.dir, module); err != nil {
+		return
+	}
+	backGroudWrite()
+	return
+}
+
+func (s *LogSink) WriteTP(tp *FunctionTp) {
+	select {
+	case FunctionTpLogWrite.logCh <- tp:
+	default:
+	}
+}
+
+func (s *LogSink) WriteAlive(alive *SystemAlive) {
+	select {
+	case SystemAliveLogWrite.logCh <- alive:
+	default:
+	}
+}
+
+func (s *LogSink) WriteAlarm(alarm *BusinessAlarm) {
+	select {
+	case BusinessAlarmLogWrite.logCh <- alarm:
+	default:
+	}
+}
+
+// StatsdSink sends records to a statsd daemon over udp. Function tps are
+// sent as timings plus an error counter, alive and alarm records as counters.
+type StatsdSink struct {
+	addr   string
+	prefix string
+	module string
+	conn   net.Conn
+	// warned is set once the records dropped before Init are logged
+	warned int32
+}
+
+func NewStatsdSink(addr, prefix string) *StatsdSink {
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	return &StatsdSink{addr: addr, prefix: prefix}
+}
+
+func (s *StatsdSink) Init(module string) (err error) {
+	s.module = statsdName(module)
+	s.conn, err = net.Dial("udp", s.addr)
+	return
+}
+
+func (s *StatsdSink) WriteTP(tp *FunctionTp) {
+	key := s.metric(tp.Key)
+	s.logError(s.send(fmt.Sprintf("%v:%v|ms", key, tp.ElapsedTime)))
+	if tp.ProcessState != "0" {
+		s.logError(s.send(fmt.Sprintf("%v.error:1|c", key)))
+	}
+	FunctionTpPool.Put(tp)
+}
+
+func (s *StatsdSink) WriteAlive(alive *SystemAlive) {
+	s.logError(s.send(fmt.Sprintf("%v.alive:1|c", s.metric(alive.Key))))
+	SystemAlivePool.Put(alive)
+}
+
+func (s *StatsdSink) WriteAlarm(alarm *BusinessAlarm) {
+	s.logError(s.send(fmt.Sprintf("%v.alarm:1|c", s.metric(alarm.Key))))
+	AlarmPool.Put(alarm)
+}
+
+func (s *StatsdSink) metric(key string) string {
+	if s.module == "" {
+		return s.prefix + statsdName(key)
+	}
+	return s.prefix + s.module + "." + statsdName(key)
+}
+
+func (s *StatsdSink) send(line string) (err error) {
+	if s.conn == nil {
+		return ErrSinkNotInitialized
+	}
+	_, err = s.conn.Write([]byte(line))
+	return
+}
+
+// logError logs the records dropped before Init once, and every failed send.
+func (s *StatsdSink) logError(err error) {
+	if err == nil {
+		return
+	}
+	if err == ErrSinkNotInitialized && !atomic.CompareAndSwapInt32(&s.warned, 0, 1) {
+		return
+	}
+	log.LogErrorf("[StatsdSink] send to %v: %v", s.addr, err)
+}
+
+var statsdReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", " ", "_", "\n", "_")
+
+func statsdName(name string) string {
+	return statsdReplacer.Replace(name)
+}
+
+// MemorySink keeps copies of every record in memory, it is meant for tests.
+type MemorySink struct {
+	sync.RWMutex
+	module string
+	tps    []FunctionTp
+	alives []SystemAlive
+	alarms []BusinessAlarm
+}
+
+func NewMemorySink() *MemorySink {
+	return new(MemorySink)
+}
+
+func (s *MemorySink) Init(module string) error {
+	s.Lock()
+	s.module = module
+	s.Unlock()
+	return nil
+}
+
+func (s *MemorySink) WriteTP(tp *FunctionTp) {
+	s.Lock()
+	s.tps = append(s.tps, *tp)
+	s.Unlock()
+	FunctionTpPool.Put(tp)
+}
+
+func (s *MemorySink) WriteAlive(alive *SystemAlive) {
+	s.Lock()
+	s.alives = append(s.alives, *alive)
+	s.Unlock()
+	SystemAlivePool.Put(alive)
+}
+
+func (s *MemorySink) WriteAlarm(alarm *BusinessAlarm) {
+	s.Lock()
+	s.alarms = append(s.alarms, *alarm)
+	s.Unlock()
+	AlarmPool.Put(alarm)
+}
+
+func (s *MemorySink) Module() string {
+	s.RLock()
+	defer s.RUnlock()
+	return s.module
+}
+
+func (s *MemorySink) TPs() []FunctionTp {
+	s.RLock()
+	defer s.RUnlock()
+	return append([]FunctionTp(nil), s.tps...)
+}
+
+func (s *MemorySink) Alives() []SystemAlive {
+	s.RLock()
+	defer s.RUnlock()
+	return append([]SystemAlive(nil), s.alives...)
+}
+
+func (s *MemorySink) Alarms() []BusinessAlarm {
+	s.RLock()
+	defer s.RUnlock()
+	return append([]BusinessAlarm(nil), s.alarms...)
+}
+
+func (s *MemorySink) Reset() {
+	s.Lock()
+	s.tps, s.alives, s.alarms = nil, nil, nil
+	s.Unlock()
+}
+
+// DiscardSink drops every record.
+type DiscardSink struct{}
+
+func (DiscardSink) Init(module string) error        { return nil }
+func (DiscardSink) WriteTP(tp *FunctionTp)          { FunctionTpPool.Put(tp) }
+func (DiscardSink) WriteAlive(alive *SystemAlive)   { SystemAlivePool.Put(alive) }
+func (DiscardSink) WriteAlarm(alarm *BusinessAlarm) { AlarmPool.Put(alarm) }
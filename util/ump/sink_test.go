@@ -0,0 +1,92 @@
+package ump
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tiglabs/baudstorage/util/config"
+)
+
+func TestMemorySink(t *testing.T) {
+	defer SetSink(getSink())
+	sink := NewMemorySink()
+	SetSink(sink)
+	if err := getSink().Init("datanode"); err != nil {
+		t.Fatal(err)
+	}
+
+	AfterTP(BeforeTP("read"), nil)
+	AfterTP(BeforeTP("write"), errors.New("disk error"))
+	Alive("datanode_alive")
+	Alarm("datanode_warning", "disk full")
+
+	tps := sink.TPs()
+	if len(tps) != 2 || tps[0].Key != "read" || tps[0].ProcessState != "0" || tps[1].ProcessState != "1" {
+		t.Fatalf("unexpected tps %+v", tps)
+	}
+	if alives := sink.Alives(); len(alives) != 1 || alives[0].Key != "datanode_alive" {
+		t.Fatalf("unexpected alives %+v", alives)
+	}
+	if alarms := sink.Alarms(); len(alarms) != 1 || alarms[0].Detail != "disk full" {
+		t.Fatalf("unexpected alarms %+v", alarms)
+	}
+	if sink.Module() != "datanode" {
+		t.Fatalf("module %v", sink.Module())
+	}
+	sink.Reset()
+	if len(sink.TPs()) != 0 {
+		t.Fatal("records kept after reset")
+	}
+}
+
+func TestConfigSink(t *testing.T) {
+	defer SetSink(getSink())
+	if err := ConfigSink(config.LoadConfigString(`{"umpSink": "memory"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := getSink().(*MemorySink); !ok {
+		t.Fatalf("sink %T", getSink())
+	}
+	for _, cfg := range []string{`{"umpSink": "statsd"}`, `{"umpSink": "file"}`, `{"umpSink": "kafka"}`} {
+		if err := ConfigSink(config.LoadConfigString(cfg)); err == nil {
+			t.Fatalf("config %v accepted", cfg)
+		}
+	}
+}
+
+func TestStatsdSink(t *testing.T) {
+	sink := NewStatsdSink("127.0.0.1:0", "baud")
+	if err := sink.send("read:1|ms"); err != ErrSinkNotInitialized {
+		t.Fatalf("send before init: %v", err)
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sink = NewStatsdSink(conn.LocalAddr().String(), "baud")
+	if err = sink.Init("data node"); err != nil {
+		t.Fatal(err)
+	}
+	tp := FunctionTpPool.Get().(*FunctionTp)
+	tp.Key, tp.ElapsedTime, tp.ProcessState = "read:extent", "3", "1"
+	sink.WriteTP(tp)
+
+	buf := make([]byte, 512)
+	lines := make([]string, 0)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for len(lines) < 2 {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(buf[:n]))
+	}
+	if expect := "baud.data_node.read_extent:3|ms,baud.data_node.read_extent.error:1|c"; strings.Join(lines, ",") != expect {
+		t.Fatalf("sent %v, expect %v", lines, expect)
+	}
+}
@@ -44,11 +44,13 @@ var (
 
 func InitUmp(module string) {
 	runtime.GOMAXPROCS(runtime.NumCPU())
-	if err := initLogName(module); err != nil {
+	var err error
+	if HostName, err = GetLocalIpAddr(); err != nil {
+		panic("init UMP Monitor failed " + err.Error())
+	}
+	if err = getSink().Init(module); err != nil {
 		panic("init UMP Monitor failed " + err.Error())
 	}
-
-	backGroudWrite()
 }
 
 func BeforeTP(key string) (o *TpObject) {
@@ -72,10 +74,7 @@ func AfterTP(o *TpObject, err error) {
 	if err != nil {
 		tp.ProcessState = "1"
 	}
-	select {
-	case FunctionTpLogWrite.logCh <- tp:
-	default:
-	}
+	getSink().WriteTP(tp)
 
 	return
 }
@@ -85,10 +84,7 @@ func Alive(key string) {
 	alive.HostName = HostName
 	alive.Key = key
 	alive.Time = time.Now().Format(LogTimeForMat)
-	select {
-	case SystemAliveLogWrite.logCh <- alive:
-	default:
-	}
+	getSink().WriteAlive(alive)
 	return
 }
 
@@ -105,9 +101,6 @@ func Alarm(key, detail string) {
 		alarm.Detail = string(rs[0:510])
 	}
 
-	select {
-	case BusinessAlarmLogWrite.logCh <- alarm:
-	default:
-	}
+	getSink().WriteAlarm(alarm)
 	return
 }
@@ -52,6 +52,7 @@ var (
 
 type LogWrite struct {
 	logCh     chan interface{}
+	logDir    string
 	logName   string
 	logSize   int64
 	seq       int
@@ -60,12 +61,13 @@ type LogWrite struct {
 	sigCh     chan bool
 }
 
-func (lw *LogWrite) initLogFp(sufixx string) (err error) {
+func (lw *LogWrite) initLogFp(dir, sufixx string) (err error) {
 	var fi os.FileInfo
 	lw.seq = 0
 	lw.sigCh = make(chan bool, 1)
+	lw.logDir = dir
 	lw.logSufixx = sufixx
-	lw.logName = fmt.Sprintf("%s%s%s", LogDir, "ump_", lw.logSufixx)
+	lw.logName = fmt.Sprintf("%s%s%s", lw.logDir, "ump_", lw.logSufixx)
 	if lw.logFp, err = os.OpenFile(lw.logName, LogFileOpt, 0666); err != nil {
 		return
 	}
@@ -87,7 +89,7 @@ func (lw *LogWrite) backGroundCheckFile() (err error) {
 		lw.seq = 1
 	}
 
-	name := fmt.Sprintf("%s%s%s.%d", LogDir, "ump_", lw.logSufixx, lw.seq)
+	name := fmt.Sprintf("%s%s%s.%d", lw.logDir, "ump_", lw.logSufixx, lw.seq)
 	if _, err = os.Stat(name); err == nil {
 		os.Remove(name)
 	}
@@ -136,24 +138,20 @@ func (lw *LogWrite) backGroundWrite(umpType string) {
 	}
 }
 
-func initLogName(module string) (err error) {
-	if err = os.MkdirAll(LogDir, 0666); err != nil {
+func initLogName(dir, module string) (err error) {
+	if err = os.MkdirAll(dir, 0666); err != nil {
 		return
 	}
 
-	if HostName, err = GetLocalIpAddr(); err != nil {
+	if err = FunctionTpLogWrite.initLogFp(dir, module+"_"+FunctionTpSufixx); err != nil {
 		return
 	}
 
-	if err = FunctionTpLogWrite.initLogFp(module + "_" + FunctionTpSufixx); err != nil {
+	if err = SystemAliveLogWrite.initLogFp(dir, module+"_"+SystemAliveSufixx); err != nil {
 		return
 	}
 
-	if err = SystemAliveLogWrite.initLogFp(module + "_" + SystemAliveSufixx); err != nil {
-		return
-	}
-
-	if err = BusinessAlarmLogWrite.initLogFp(module + "_" + BusinessAlarmSufixx); err != nil {
+	if err = BusinessAlarmLogWrite.initLogFp(dir, module+"_"+BusinessAlarmSufixx); err != nil {
 		return
 	}
 
@@ -15,7 +15,7 @@ func TestUmp(t *testing.T) {
 		go func() {
 			for {
 				up := BeforeTP("wocao" + strconv.FormatInt(rand.Int63(), 16))
-				AfterTP(up)
+				AfterTP(up, nil)
 				Alive("nimei")
 				Alarm("baojingle", "weishenmene")
 			}